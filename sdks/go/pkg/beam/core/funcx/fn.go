@@ -70,6 +70,9 @@ const (
 	// FnRTracker indicates a function input parameter that implements
 	// sdf.RTracker.
 	FnRTracker FnParamKind = 0x100
+	// FnBundleFinalizer indicates a function input parameter of type
+	// typex.BundleFinalizer.
+	FnBundleFinalizer FnParamKind = 0x200
 )
 
 func (k FnParamKind) String() string {
@@ -92,6 +95,8 @@ func (k FnParamKind) String() string {
 		return "Window"
 	case FnRTracker:
 		return "RTracker"
+	case FnBundleFinalizer:
+		return "BundleFinalizer"
 	default:
 		return fmt.Sprintf("%v", int(k))
 	}
@@ -245,6 +250,17 @@ func (u *Fn) RTracker() (pos int, exists bool) {
 	return -1, false
 }
 
+// BundleFinalizer returns (index, true) iff the function expects a
+// typex.BundleFinalizer.
+func (u *Fn) BundleFinalizer() (pos int, exists bool) {
+	for i, p := range u.Param {
+		if p.Kind == FnBundleFinalizer {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // Error returns (index, true) iff the function returns an error.
 func (u *Fn) Error() (pos int, exists bool) {
 	for i, p := range u.Ret {
@@ -309,6 +325,8 @@ func New(fn reflectx.Func) (*Fn, error) {
 			kind = FnWindow
 		case t == reflectx.Type:
 			kind = FnType
+		case t == typex.BundleFinalizerType:
+			kind = FnBundleFinalizer
 		case t.Implements(reflect.TypeOf((*sdf.RTracker)(nil)).Elem()):
 			kind = FnRTracker
 		case typex.IsContainer(t), typex.IsConcrete(t), typex.IsUniversal(t):
@@ -376,9 +394,11 @@ func SubReturns(list []ReturnParam, indices ...int) []ReturnParam {
 }
 
 // The order of present parameters and return values must be as follows:
-// func(FnContext?, FnWindow?, FnEventTime?, FnType?, FnRTracker?, (FnValue, SideInput*)?, FnEmit*) (RetEventTime?, RetOutput?, RetError?)
-//     where ? indicates 0 or 1, and * indicates any number.
-//     and  a SideInput is one of FnValue or FnIter or FnReIter
+// func(FnContext?, FnWindow?, FnEventTime?, FnType?, FnBundleFinalizer?, FnRTracker?, (FnValue, SideInput*)?, FnEmit*) (RetEventTime?, RetOutput?, RetError?)
+//
+//	where ? indicates 0 or 1, and * indicates any number.
+//	and  a SideInput is one of FnValue or FnIter or FnReIter
+//
 // Note: Fns with inputs must have at least one FnValue as the main input.
 func validateOrder(u *Fn) error {
 	paramState := psStart
@@ -400,12 +420,13 @@ func validateOrder(u *Fn) error {
 }
 
 var (
-	errContextParam             = errors.New("may only have a single context.Context parameter and it must be the first parameter")
-	errWindowParamPrecedence    = errors.New("may only have a single Window parameter and it must precede the EventTime and main input parameter")
-	errEventTimeParamPrecedence = errors.New("may only have a single beam.EventTime parameter and it must precede the main input parameter")
-	errReflectTypePrecedence    = errors.New("may only have a single reflect.Type parameter and it must precede the main input parameter")
-	errRTrackerPrecedence       = errors.New("may only have a single sdf.RTracker parameter and it must precede the main input parameter")
-	errInputPrecedence          = errors.New("inputs parameters must precede emit function parameters")
+	errContextParam              = errors.New("may only have a single context.Context parameter and it must be the first parameter")
+	errWindowParamPrecedence     = errors.New("may only have a single Window parameter and it must precede the EventTime and main input parameter")
+	errEventTimeParamPrecedence  = errors.New("may only have a single beam.EventTime parameter and it must precede the main input parameter")
+	errReflectTypePrecedence     = errors.New("may only have a single reflect.Type parameter and it must precede the main input parameter")
+	errBundleFinalizerPrecedence = errors.New("may only have a single typex.BundleFinalizer parameter and it must precede the sdf.RTracker and main input parameters")
+	errRTrackerPrecedence        = errors.New("may only have a single sdf.RTracker parameter and it must precede the main input parameter")
+	errInputPrecedence           = errors.New("inputs parameters must precede emit function parameters")
 )
 
 type paramState int
@@ -419,6 +440,7 @@ const (
 	psInput
 	psOutput
 	psRTracker
+	psBundleFinalizer
 )
 
 func nextParamState(cur paramState, transition FnParamKind) (paramState, error) {
@@ -433,6 +455,8 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 			return psEventTime, nil
 		case FnType:
 			return psType, nil
+		case FnBundleFinalizer:
+			return psBundleFinalizer, nil
 		case FnRTracker:
 			return psRTracker, nil
 		}
@@ -444,6 +468,8 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 			return psEventTime, nil
 		case FnType:
 			return psType, nil
+		case FnBundleFinalizer:
+			return psBundleFinalizer, nil
 		case FnRTracker:
 			return psRTracker, nil
 		}
@@ -453,6 +479,8 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 			return psEventTime, nil
 		case FnType:
 			return psType, nil
+		case FnBundleFinalizer:
+			return psBundleFinalizer, nil
 		case FnRTracker:
 			return psRTracker, nil
 		}
@@ -460,10 +488,19 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 		switch transition {
 		case FnType:
 			return psType, nil
+		case FnBundleFinalizer:
+			return psBundleFinalizer, nil
 		case FnRTracker:
 			return psRTracker, nil
 		}
 	case psType:
+		switch transition {
+		case FnBundleFinalizer:
+			return psBundleFinalizer, nil
+		case FnRTracker:
+			return psRTracker, nil
+		}
+	case psBundleFinalizer:
 		switch transition {
 		case FnRTracker:
 			return psRTracker, nil
@@ -491,6 +528,8 @@ func nextParamState(cur paramState, transition FnParamKind) (paramState, error)
 		return -1, errEventTimeParamPrecedence
 	case FnType:
 		return -1, errReflectTypePrecedence
+	case FnBundleFinalizer:
+		return -1, errBundleFinalizerPrecedence
 	case FnRTracker:
 		return -1, errRTrackerPrecedence
 	case FnIter, FnReIter, FnValue:
@@ -75,6 +75,11 @@ func TestNew(t *testing.T) {
 			Fn:    func(typex.Window, typex.EventTime, reflect.Type, []byte) {},
 			Param: []FnParamKind{FnWindow, FnEventTime, FnType, FnValue},
 		},
+		{
+			Name:  "good6",
+			Fn:    func(context.Context, typex.EventTime, typex.BundleFinalizer, []byte) {},
+			Param: []FnParamKind{FnContext, FnEventTime, FnBundleFinalizer, FnValue},
+		},
 		{
 			Name:  "good-method",
 			Fn:    foo{1}.Do,
@@ -147,6 +152,18 @@ func TestNew(t *testing.T) {
 			},
 			Err: errReflectTypePrecedence,
 		},
+		{
+			Name: "errBundleFinalizerPrecedence: after value",
+			Fn: func(int, typex.BundleFinalizer) {
+			},
+			Err: errBundleFinalizerPrecedence,
+		},
+		{
+			Name: "errBundleFinalizerPrecedence: multiple bundle finalizers",
+			Fn: func(typex.BundleFinalizer, typex.BundleFinalizer, int) {
+			},
+			Err: errBundleFinalizerPrecedence,
+		},
 		{
 			Name: "errInputPrecedence- Iter before after output",
 			Fn:   func(int, func(int), func(*int) bool, func(*int, *string) bool) {},
@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coder
+
+import (
+	"io"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// Encodings of panes, in the upper half of the first byte, as in the Java SDK.
+const (
+	paneFirst      = 0 // No indices: index 0 and the implied non-speculative index.
+	paneOneIndex   = 1 // One index, and the implied non-speculative index.
+	paneTwoIndices = 2 // Both indices.
+)
+
+// EncodePane encodes a PaneInfo. The first byte holds the first and last
+// flags, the timing and the encoding of the indices, which follow as varints
+// unless implied.
+func EncodePane(v typex.PaneInfo, w io.Writer) error {
+	b := byte(v.Timing&0x3) << 2
+	if v.IsFirst {
+		b |= 0x1
+	}
+	if v.IsLast {
+		b |= 0x2
+	}
+	nonSpeculative := v.Index
+	if v.Timing == typex.PaneEarly {
+		nonSpeculative = -1
+	}
+	switch {
+	case v.NonSpeculativeIndex != nonSpeculative:
+		if err := EncodeByte(b|paneTwoIndices<<4, w); err != nil {
+			return err
+		}
+		if err := EncodeVarInt(v.Index, w); err != nil {
+			return err
+		}
+		return EncodeVarInt(v.NonSpeculativeIndex, w)
+	case v.IsFirst && v.Index == 0:
+		return EncodeByte(b|paneFirst<<4, w)
+	default:
+		if err := EncodeByte(b|paneOneIndex<<4, w); err != nil {
+			return err
+		}
+		return EncodeVarInt(v.Index, w)
+	}
+}
+
+// DecodePane decodes a PaneInfo.
+func DecodePane(r io.Reader) (typex.PaneInfo, error) {
+	b, err := DecodeByte(r)
+	if err != nil {
+		return typex.PaneInfo{}, err
+	}
+	v := typex.PaneInfo{
+		Timing:  typex.PaneTiming(b>>2) & 0x3,
+		IsFirst: b&0x1 != 0,
+		IsLast:  b&0x2 != 0,
+	}
+	switch b >> 4 {
+	case paneFirst:
+	case paneOneIndex:
+		if v.Index, err = DecodeVarInt(r); err != nil {
+			return typex.PaneInfo{}, err
+		}
+	case paneTwoIndices:
+		if v.Index, err = DecodeVarInt(r); err != nil {
+			return typex.PaneInfo{}, err
+		}
+		if v.NonSpeculativeIndex, err = DecodeVarInt(r); err != nil {
+			return typex.PaneInfo{}, err
+		}
+		return v, nil
+	default:
+		return typex.PaneInfo{}, errors.Errorf("unknown pane encoding %v in byte %#x", b>>4, b)
+	}
+	v.NonSpeculativeIndex = v.Index
+	if v.Timing == typex.PaneEarly {
+		v.NonSpeculativeIndex = -1
+	}
+	return v, nil
+}
@@ -0,0 +1,64 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+func TestEncodeDecodePane(t *testing.T) {
+	tests := []struct {
+		pane typex.PaneInfo
+		size int
+	}{
+		{pane: typex.NoFiringPane(), size: 1},
+		{pane: typex.PaneInfo{Timing: typex.PaneEarly, IsFirst: true, Index: 0, NonSpeculativeIndex: -1}, size: 1},
+		{pane: typex.PaneInfo{Timing: typex.PaneEarly, Index: 3, NonSpeculativeIndex: -1}, size: 2},
+		{pane: typex.PaneInfo{Timing: typex.PaneOnTime, IsFirst: true, Index: 0, NonSpeculativeIndex: 0}, size: 1},
+		{pane: typex.PaneInfo{Timing: typex.PaneOnTime, Index: 2, NonSpeculativeIndex: 0}, size: 3},
+		{pane: typex.PaneInfo{Timing: typex.PaneLate, IsLast: true, Index: 300, NonSpeculativeIndex: 300}, size: 3},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := EncodePane(test.pane, &buf); err != nil {
+			t.Fatalf("EncodePane(%+v) failed: %v", test.pane, err)
+		}
+		if buf.Len() != test.size {
+			t.Errorf("EncodePane(%+v) = %v bytes, want %v", test.pane, buf.Len(), test.size)
+		}
+		actual, err := DecodePane(&buf)
+		if err != nil {
+			t.Fatalf("DecodePane(<%+v>) failed: %v", test.pane, err)
+		}
+		if actual != test.pane {
+			t.Errorf("DecodePane(<%+v>) = %+v, want %+v", test.pane, actual, test.pane)
+		}
+	}
+
+	// The pane of elements not produced by a trigger firing has a fixed
+	// encoding.
+	var buf bytes.Buffer
+	if err := EncodePane(typex.NoFiringPane(), &buf); err != nil {
+		t.Fatalf("EncodePane(NoFiringPane()) failed: %v", err)
+	}
+	if got, want := buf.Bytes(), []byte{0xf}; !bytes.Equal(got, want) {
+		t.Errorf("EncodePane(NoFiringPane()) = %#x, want %#x", got, want)
+	}
+}
@@ -160,8 +160,10 @@ const (
 	setupName          = "Setup"
 	startBundleName    = "StartBundle"
 	processElementName = "ProcessElement"
+	processBatchName   = "ProcessBatch"
 	finishBundleName   = "FinishBundle"
 	teardownName       = "Teardown"
+	onTimerName        = "OnTimer"
 
 	createInitialRestrictionName = "CreateInitialRestriction"
 	splitRestrictionName         = "SplitRestriction"
@@ -209,6 +211,7 @@ var lifecycleMethods map[string]struct{}
 func init() {
 	lifecycleMethods = make(map[string]struct{})
 	methods := append(doFnNames, combineFnNames...)
+	methods = append(methods, processBatchName, onTimerName)
 	for _, name := range methods {
 		lifecycleMethods[name] = struct{}{}
 	}
@@ -225,6 +228,50 @@ func IsLifecycleMethod(n string) bool {
 // DoFn represents a DoFn.
 type DoFn Fn
 
+// BatchProcessing is embedded by DoFns to opt in to a ProcessBatch method,
+// which is only used by batching executors. Other DoFns must not have a
+// ProcessBatch method.
+type BatchProcessing struct{}
+
+// TimerProcessing is embedded by DoFns to opt in to an OnTimer method, which
+// is invoked for the timers delivered to the DoFn. Other DoFns must not have
+// an OnTimer method.
+type TimerProcessing struct{}
+
+// optionalDoFnMethods maps the types embedded by DoFns to opt in to optional
+// methods to the names of these methods.
+var optionalDoFnMethods = map[reflect.Type]string{
+	reflect.TypeOf(BatchProcessing{}): processBatchName,
+	reflect.TypeOf(TimerProcessing{}): onTimerName,
+}
+
+// doFnMethodNames returns the valid method names of the given DoFn, including
+// the optional methods it opted in to.
+func doFnMethodNames(fn *Fn) []string {
+	names := doFnNames
+	for t, name := range optionalDoFnMethods {
+		if embeds(fn.Recv, t) {
+			names = append(append([]string(nil), names...), name)
+		}
+	}
+	return names
+}
+
+// embeds returns whether recv is a (ptr to) struct with an embedded field of
+// the given type.
+func embeds(recv interface{}, t reflect.Type) bool {
+	v := reflect.Indirect(reflect.ValueOf(recv))
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Type().Field(i); f.Anonymous && f.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
 // SetupFn returns the "Setup" function, if present.
 func (f *DoFn) SetupFn() *funcx.Fn {
 	return f.methods[setupName]
@@ -240,11 +287,23 @@ func (f *DoFn) ProcessElementFn() *funcx.Fn {
 	return f.methods[processElementName]
 }
 
+// ProcessBatchFn returns the "ProcessBatch" function, if present. It is only
+// present in DoFns embedding BatchProcessing, and used by batching executors.
+func (f *DoFn) ProcessBatchFn() *funcx.Fn {
+	return f.methods[processBatchName]
+}
+
 // FinishBundleFn returns the "FinishBundle" function, if present.
 func (f *DoFn) FinishBundleFn() *funcx.Fn {
 	return f.methods[finishBundleName]
 }
 
+// OnTimerFn returns the "OnTimer" function, if present. It is only present in
+// DoFns embedding TimerProcessing.
+func (f *DoFn) OnTimerFn() *funcx.Fn {
+	return f.methods[onTimerName]
+}
+
 // TeardownFn returns the "Teardown" function, if present.
 func (f *DoFn) TeardownFn() *funcx.Fn {
 	return f.methods[teardownName]
@@ -379,7 +438,7 @@ func AsDoFn(fn *Fn, numMainIn mainInputs) (*DoFn, error) {
 	if fn.Fn != nil {
 		fn.methods[processElementName] = fn.Fn
 	}
-	if err := verifyValidNames("graph.AsDoFn", fn, doFnNames...); err != nil {
+	if err := verifyValidNames("graph.AsDoFn", fn, doFnMethodNames(fn)...); err != nil {
 		return nil, err
 	}
 
@@ -910,6 +969,16 @@ func validateSdfElementT(fn *Fn, name string, method *funcx.Fn, num int) error {
 // CombineFn represents a CombineFn.
 type CombineFn Fn
 
+// Commutative is embedded by CombineFns to declare that their result does not
+// depend on the order in which inputs are added and accumulators merged. Only
+// such CombineFns are subject to optimizations reordering their inputs.
+type Commutative struct{}
+
+// IsCommutative returns whether the CombineFn embeds Commutative.
+func (f *CombineFn) IsCommutative() bool {
+	return embeds(f.Recv, reflect.TypeOf(Commutative{}))
+}
+
 // SetupFn returns the "Setup" function, if present.
 func (f *CombineFn) SetupFn() *funcx.Fn {
 	return f.methods[setupName]
@@ -46,6 +46,8 @@ func TestNewDoFn(t *testing.T) {
 			{dfn: &GoodDoFnCoGbk2{}, opt: CoGBKMainInput(3)},
 			{dfn: &GoodDoFnCoGbk7{}, opt: CoGBKMainInput(8)},
 			{dfn: &GoodDoFnCoGbk1wSide{}, opt: NumMainInputs(MainKv)},
+			{dfn: &GoodDoFnProcessBatch{}, opt: NumMainInputs(MainSingle)},
+			{dfn: &GoodDoFnOnTimer{}, opt: NumMainInputs(MainSingle)},
 		}
 
 		for _, test := range tests {
@@ -88,6 +90,9 @@ func TestNewDoFn(t *testing.T) {
 			{dfn: &BadDoFnReturnValuesInFinishBundle{}},
 			{dfn: &BadDoFnReturnValuesInSetup{}},
 			{dfn: &BadDoFnReturnValuesInTeardown{}},
+			// Validate optional methods.
+			{dfn: &BadDoFnProcessBatchNotOptedIn{}},
+			{dfn: &BadDoFnOnTimerNotOptedIn{}},
 		}
 		for _, test := range tests {
 			t.Run(reflect.TypeOf(test.dfn).String(), func(t *testing.T) {
@@ -299,6 +304,28 @@ func TestNewCombineFn(t *testing.T) {
 	})
 }
 
+func TestCombineFn_IsCommutative(t *testing.T) {
+	tests := []struct {
+		cfn  interface{}
+		want bool
+	}{
+		{cfn: func(int, int) int { return 0 }, want: false},
+		{cfn: &GoodCombineFn{}, want: false},
+		{cfn: &GoodCommutativeCombineFn{}, want: true},
+	}
+	for _, test := range tests {
+		t.Run(reflect.TypeOf(test.cfn).String(), func(t *testing.T) {
+			cfn, err := NewCombineFn(test.cfn)
+			if err != nil {
+				t.Fatalf("NewCombineFn failed: %v", err)
+			}
+			if got := cfn.IsCommutative(); got != test.want {
+				t.Errorf("IsCommutative() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
 // Do not copy. The following types are for testing signatures only.
 // They are not working examples.
 // Keep all test functions Above this point.
@@ -466,9 +493,46 @@ func (fn *GoodDoFnUnexportedExtraMethod) Teardown() {
 func (fn *GoodDoFnUnexportedExtraMethod) unexportedFunction() {
 }
 
+type GoodDoFnProcessBatch struct {
+	BatchProcessing
+}
+
+func (fn *GoodDoFnProcessBatch) ProcessElement(int) int {
+	return 0
+}
+
+func (fn *GoodDoFnProcessBatch) ProcessBatch(context.Context, *struct{}) error {
+	return nil
+}
+
+type GoodDoFnOnTimer struct {
+	TimerProcessing
+}
+
+func (fn *GoodDoFnOnTimer) ProcessElement(int, func(int)) {
+}
+
+func (fn *GoodDoFnOnTimer) OnTimer(string, int, func(int)) {
+}
+
 // Examples of incorrect DoFn signatures.
 // Embedding good DoFns avoids repetitive ProcessElement signatures when desired.
 
+type BadDoFnProcessBatchNotOptedIn struct {
+	*GoodDoFn
+}
+
+func (fn *BadDoFnProcessBatchNotOptedIn) ProcessBatch(context.Context, *struct{}) error {
+	return nil
+}
+
+type BadDoFnOnTimerNotOptedIn struct {
+	*GoodDoFn
+}
+
+func (fn *BadDoFnOnTimerNotOptedIn) OnTimer(string, int) {
+}
+
 type BadDoFnHasRTracker struct {
 	*GoodDoFn
 }
@@ -890,6 +954,14 @@ func (fn *GoodCombineFn) ExtractOutput(MyAccum) int64 {
 	return 0
 }
 
+type GoodCommutativeCombineFn struct {
+	Commutative
+}
+
+func (fn *GoodCommutativeCombineFn) MergeAccumulators(int, int) int {
+	return 0
+}
+
 type GoodWErrorCombineFn struct{}
 
 func (fn *GoodWErrorCombineFn) MergeAccumulators(int, int) (int, error) {
@@ -66,8 +66,9 @@ import (
 type ctxKey string
 
 const (
-	counterSetKey ctxKey = "beam:counterset"
-	storeKey      ctxKey = "beam:bundlestore"
+	counterSetKey   ctxKey = "beam:counterset"
+	storeKey        ctxKey = "beam:bundlestore"
+	ptransformIDKey ctxKey = "beam:ptransformid"
 )
 
 // beamCtx is a caching context for IDs necessary to place metric updates.
@@ -87,21 +88,21 @@ func (ctx *beamCtx) Value(key interface{}) interface{} {
 	switch key {
 	case counterSetKey:
 		if ctx.cs == nil {
-			if cs := ctx.Context.Value(key); cs != nil {
-				ctx.cs = cs.(*ptCounterSet)
-			} else {
-				// It's not created previously
-				ctx.store.mu.Lock()
-				cs := &ptCounterSet{
+			// Contexts of the same PTransform share a counter set, so that
+			// their cells don't collide in the store.
+			ctx.store.mu.Lock()
+			cs, ok := ctx.store.css[ctx.ptransformID]
+			if !ok {
+				cs = &ptCounterSet{
 					pid:           ctx.ptransformID,
 					counters:      make(map[nameHash]*counter),
 					distributions: make(map[nameHash]*distribution),
 					gauges:        make(map[nameHash]*gauge),
 				}
-				ctx.store.css = append(ctx.store.css, cs)
-				ctx.cs = cs
-				ctx.store.mu.Unlock()
+				ctx.store.css[ctx.ptransformID] = cs
 			}
+			ctx.cs = cs
+			ctx.store.mu.Unlock()
 		}
 		return ctx.cs
 	case storeKey:
@@ -111,6 +112,8 @@ func (ctx *beamCtx) Value(key interface{}) interface{} {
 			}
 		}
 		return ctx.store
+	case ptransformIDKey:
+		return ctx.ptransformID
 	}
 	return ctx.Context.Value(key)
 }
@@ -126,7 +129,9 @@ func SetBundleID(ctx context.Context, id string) context.Context {
 	if bctx, ok := ctx.(*beamCtx); ok {
 		return &beamCtx{Context: bctx.Context, bundleID: id, store: newStore(), ptransformID: bctx.ptransformID}
 	}
-	return &beamCtx{Context: ctx, bundleID: id, store: newStore()}
+	// The context may wrap a PTransform context, whose ID is kept.
+	pid, _ := ctx.Value(ptransformIDKey).(string)
+	return &beamCtx{Context: ctx, bundleID: id, store: newStore(), ptransformID: pid}
 }
 
 // SetPTransformID sets the id of the current PTransform.
@@ -137,6 +142,10 @@ func SetPTransformID(ctx context.Context, id string) context.Context {
 	if bctx, ok := ctx.(*beamCtx); ok {
 		return &beamCtx{Context: bctx.Context, bundleID: bctx.bundleID, store: bctx.store, ptransformID: id}
 	}
+	// The context may wrap a bundle context, whose store must be used.
+	if store, ok := ctx.Value(storeKey).(*Store); ok && store != nil {
+		return &beamCtx{Context: ctx, bundleID: bundleIDUnset, store: store, ptransformID: id}
+	}
 	// Avoid breaking if the bundle is unset in testing.
 	return &beamCtx{Context: ctx, bundleID: bundleIDUnset, store: newStore(), ptransformID: id}
 }
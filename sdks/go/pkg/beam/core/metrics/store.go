@@ -126,13 +126,13 @@ type ptCounterSet struct {
 // Store retains per transform countersets, intended for per bundle use.
 type Store struct {
 	mu  sync.RWMutex
-	css []*ptCounterSet
+	css map[string]*ptCounterSet
 
 	store map[Labels]userMetric
 }
 
 func newStore() *Store {
-	return &Store{store: make(map[Labels]userMetric)}
+	return &Store{css: make(map[string]*ptCounterSet), store: make(map[Labels]userMetric)}
 }
 
 // storeMetric stores a metric away on its first use so it may be retrieved later on.
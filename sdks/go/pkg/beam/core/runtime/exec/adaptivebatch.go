@@ -0,0 +1,122 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// batchParDoSize is the current batch size of BatchParDos with an adaptive
+// batch size, reported per transform.
+var batchParDoSize = metrics.NewGauge("exec", "batchParDo.batchSize")
+
+const (
+	// adaptiveDeadband is the relative deviation from the current batch size
+	// that AdaptiveBatchSize ignores, so that noise in the observed latency
+	// doesn't make the size oscillate.
+	adaptiveDeadband = 0.2
+	// defaultAdaptiveIdle is the default idle time after which an
+	// AdaptiveBatchSize returns to its initial size.
+	defaultAdaptiveIdle = time.Minute
+)
+
+// AdaptiveBatchSize controls the batch size of a BatchParDo, targeting a
+// processing time of Target per batch, including the time spent downstream on
+// the output of the batch. After each batch, it estimates the size that would
+// have taken Target from the observed time per element, and moves the size
+// halfway towards it, within Min and Max. Estimates within 20% of the current
+// size are ignored. The damping and this deadband keep the size from
+// oscillating under noisy latencies. If no batch has been processed for
+// IdleAfter, the size returns to the initial one, as earlier observations
+// no longer reflect the load. It is not safe for concurrent use.
+type AdaptiveBatchSize struct {
+	Target    time.Duration
+	Min, Max  int
+	IdleAfter time.Duration
+
+	initial int
+	size    int
+	last    time.Time
+}
+
+// NewAdaptiveBatchSize returns a controller starting at the initial batch
+// size, which adapts the size within min and max to take target per batch.
+func NewAdaptiveBatchSize(initial, min, max int, target time.Duration) *AdaptiveBatchSize {
+	return &AdaptiveBatchSize{Target: target, Min: min, Max: max, IdleAfter: defaultAdaptiveIdle, initial: initial, size: initial}
+}
+
+func (c *AdaptiveBatchSize) validate() error {
+	switch {
+	case c.Min < 1 || c.Max < c.Min:
+		return errors.Errorf("invalid adaptive batch size bounds: [%v, %v], want 0 < min <= max", c.Min, c.Max)
+	case c.initial < c.Min || c.initial > c.Max:
+		return errors.Errorf("invalid initial adaptive batch size: %v, want within [%v, %v]", c.initial, c.Min, c.Max)
+	case c.Target <= 0:
+		return errors.Errorf("invalid adaptive batch target time: %v, want > 0", c.Target)
+	}
+	return nil
+}
+
+// Size returns the current batch size.
+func (c *AdaptiveBatchSize) Size() int {
+	return c.size
+}
+
+// start returns the size of a batch started at the given time, first
+// returning to the initial size if idle.
+func (c *AdaptiveBatchSize) start(now time.Time) int {
+	if c.IdleAfter > 0 && !c.last.IsZero() && now.Sub(c.last) >= c.IdleAfter {
+		c.size = c.initial
+		c.last = time.Time{}
+	}
+	return c.size
+}
+
+// observe adapts the size to a batch of n elements processed in d, ending at
+// the given time.
+func (c *AdaptiveBatchSize) observe(n int, d time.Duration, now time.Time) {
+	c.last = now
+	if n < 1 {
+		return
+	}
+	if d <= 0 {
+		d = time.Nanosecond
+	}
+	want := float64(c.Target) * float64(n) / float64(d)
+	size := float64(c.size)
+	if want > size*(1-adaptiveDeadband) && want < size*(1+adaptiveDeadband) {
+		return
+	}
+	next := int(size + (want-size)/2)
+	if next == c.size {
+		// Always make progress towards the estimate.
+		if want > size {
+			next++
+		} else {
+			next--
+		}
+	}
+	if next < c.Min {
+		next = c.Min
+	}
+	if next > c.Max {
+		next = c.Max
+	}
+	c.size = next
+}
@@ -0,0 +1,173 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// sinkBackpressure is the time, in milliseconds, DataSinks were blocked on
+// a full write buffer.
+var sinkBackpressure = metrics.NewCounter("exec", "dataSink.backpressureMsecs")
+
+// WithSinkBuffer returns a context in which DataSinks write encoded elements
+// to their stream asynchronously, holding at most maxBytes of elements that
+// have not yet been accepted by the stream. Once the buffer is full, the sink
+// blocks until the stream drains enough of it, or the context is done. An
+// element larger than maxBytes is only buffered once the buffer is empty. The
+// time spent blocked is reported in the dataSink.backpressureMsecs counter.
+// Values less than 1 disable the buffer, which is the default, so elements are
+// written synchronously.
+func WithSinkBuffer(ctx context.Context, maxBytes int64) context.Context {
+	return context.WithValue(ctx, sinkBufferKey, maxBytes)
+}
+
+func getSinkBuffer(ctx context.Context) int64 {
+	v, _ := ctx.Value(sinkBufferKey).(int64)
+	return v
+}
+
+// boundedWriter writes byte slices in order from a separate goroutine,
+// bounding the number of bytes waiting to be written. It is safe for a
+// single producer only.
+type boundedWriter struct {
+	write func([]byte) error
+	max   int64
+
+	mu       sync.Mutex
+	pending  [][]byte
+	inflight int64
+	closing  bool
+	err      error
+
+	wake  chan struct{} // Signals the writer that data is pending or closing.
+	freed chan struct{} // Signals the producer that bytes were written.
+	done  chan struct{} // Closed once the writer exits.
+}
+
+func newBoundedWriter(write func([]byte) error, max int64) *boundedWriter {
+	w := &boundedWriter{
+		write: write,
+		max:   max,
+		wake:  make(chan struct{}, 1),
+		freed: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Write buffers b for writing, blocking while the buffer is full. It returns
+// the error of a failed earlier write, or the context error if the context is
+// done while blocked. The caller must not modify b afterwards.
+func (w *boundedWriter) Write(ctx context.Context, b []byte) error {
+	var blocked time.Time
+	for {
+		w.mu.Lock()
+		if w.err != nil {
+			w.mu.Unlock()
+			return w.err
+		}
+		if w.inflight == 0 || w.inflight+int64(len(b)) <= w.max {
+			w.pending = append(w.pending, b)
+			w.inflight += int64(len(b))
+			w.mu.Unlock()
+			notify(w.wake)
+			if !blocked.IsZero() {
+				sinkBackpressure.Inc(ctx, int64(time.Since(blocked)/time.Millisecond))
+			}
+			return nil
+		}
+		w.mu.Unlock()
+
+		if blocked.IsZero() {
+			blocked = time.Now()
+		}
+		select {
+		case <-w.freed:
+		case <-ctx.Done():
+			sinkBackpressure.Inc(ctx, int64(time.Since(blocked)/time.Millisecond))
+			return errors.Wrap(ctx.Err(), "blocked on full sink buffer")
+		}
+	}
+}
+
+// loop writes pending data until closed, or until a write fails.
+func (w *boundedWriter) loop() {
+	defer close(w.done)
+	for {
+		w.mu.Lock()
+		if len(w.pending) == 0 {
+			closing := w.closing
+			w.mu.Unlock()
+			if closing {
+				return
+			}
+			<-w.wake
+			continue
+		}
+		b := w.pending[0]
+		w.pending[0] = nil
+		w.pending = w.pending[1:]
+		w.mu.Unlock()
+
+		err := w.write(b)
+
+		w.mu.Lock()
+		w.inflight -= int64(len(b))
+		if err != nil {
+			w.err = err
+		}
+		w.mu.Unlock()
+		notify(w.freed)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Close waits for all buffered data to be written, and returns the error of
+// the first failed write, if any.
+func (w *boundedWriter) Close() error {
+	w.mu.Lock()
+	w.closing = true
+	w.mu.Unlock()
+	notify(w.wake)
+	<-w.done
+	return w.err
+}
+
+// abort discards any buffered data and stops the writer once the current
+// write, if any, returns.
+func (w *boundedWriter) abort() {
+	w.mu.Lock()
+	w.pending = nil
+	w.closing = true
+	w.mu.Unlock()
+	notify(w.wake)
+}
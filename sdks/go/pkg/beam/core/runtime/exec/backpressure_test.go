@@ -0,0 +1,182 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// slowWriter delays every write by a fixed duration.
+type slowWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) Close() error {
+	return nil
+}
+
+// TestDataSink_sinkBuffer verifies that a buffered DataSink writes the same
+// data as an unbuffered one, and reports the time blocked on a slow stream.
+func TestDataSink_sinkBuffer(t *testing.T) {
+	run := func(ctx context.Context) (*Plan, []byte) {
+		w := &slowWriter{delay: 5 * time.Millisecond}
+		sink := &DataSink{UID: 1, SID: StreamID{PtransformID: "mySink"}, Coder: coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())}
+		root := &FixedRoot{UID: 2, Elements: makeInput(int64(1), int64(2), int64(3), int64(4), int64(5)), Out: sink}
+		p, err := NewPlan("a", []Unit{root, sink})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(ctx, "1", DataContext{Data: &TestDataManager{W: w}}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		return p, w.buf.Bytes()
+	}
+
+	ctx := metrics.SetPTransformID(context.Background(), "sink")
+	_, want := run(ctx)
+	// Each element is encoded in 14 bytes, so only one fits the buffer.
+	p, got := run(WithSinkBuffer(ctx, 20))
+	if !bytes.Equal(got, want) {
+		t.Errorf("buffered sink wrote %x, want %x", got, want)
+	}
+
+	var blocked int64 = -1
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			if l.Transform() == "sink" && l.Name() == "dataSink.backpressureMsecs" {
+				blocked = v
+			}
+		},
+	}.ExtractFrom(p.Store())
+	if blocked <= 0 {
+		t.Errorf("backpressure counter = %v, want > 0", blocked)
+	}
+}
+
+// TestDataSink_sinkBufferFailedBundle verifies that the writer of a failed
+// bundle that wasn't taken down is stopped by the next bundle, and doesn't
+// write to its stream.
+func TestDataSink_sinkBufferFailedBundle(t *testing.T) {
+	ctx := WithSinkBuffer(context.Background(), 20)
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	sink := &DataSink{UID: 1, SID: StreamID{PtransformID: "mySink"}, Coder: c}
+	if err := sink.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	failed := &slowWriter{delay: 50 * time.Millisecond}
+	if err := sink.StartBundle(ctx, "1", DataContext{Data: &TestDataManager{W: failed}}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	for _, v := range makeValues(int64(1), int64(2), int64(3)) {
+		if err := sink.ProcessElement(ctx, &v); err != nil {
+			t.Fatalf("ProcessElement failed: %v", err)
+		}
+	}
+
+	failed.mu.Lock()
+	written := failed.buf.Len()
+	failed.mu.Unlock()
+	w := &slowWriter{}
+	if err := sink.StartBundle(ctx, "2", DataContext{Data: &TestDataManager{W: w}}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	v := makeValues(int64(4))[0]
+	if err := sink.ProcessElement(ctx, &v); err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	if err := sink.FinishBundle(ctx); err != nil {
+		t.Fatalf("FinishBundle failed: %v", err)
+	}
+	if got, want := w.buf.Bytes(), encodeElements(t, c, int64(4)).Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("sink wrote %x, want %x", got, want)
+	}
+
+	// Only the write in progress when the bundle failed may complete.
+	time.Sleep(150 * time.Millisecond)
+	failed.mu.Lock()
+	defer failed.mu.Unlock()
+	if got, max := failed.buf.Len(), written+encodeElements(t, c, int64(1)).Len(); got > max {
+		t.Errorf("failed bundle wrote %v bytes, want at most %v", got, max)
+	}
+}
+
+// TestBoundedWriter verifies that writes block while the buffer is full, that
+// blocked writes respect the context, and that write errors are reported.
+func TestBoundedWriter(t *testing.T) {
+	release := make(chan struct{})
+	var written [][]byte
+	w := newBoundedWriter(func(b []byte) error {
+		<-release
+		written = append(written, b)
+		return nil
+	}, 4)
+
+	ctx := context.Background()
+	if err := w.Write(ctx, []byte("abc")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := w.Write(short, []byte("def")); err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("Write on full buffer = %v, want deadline exceeded", err)
+	}
+
+	close(release)
+	// A single element larger than the buffer is accepted once it's empty.
+	for _, b := range []string{"def", "ghijkl"} {
+		if err := w.Write(ctx, []byte(b)); err != nil {
+			t.Fatalf("Write(%v) failed: %v", b, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got, want := string(bytes.Join(written, nil)), "abcdefghijkl"; got != want {
+		t.Errorf("written %q, want %q", got, want)
+	}
+
+	t.Run("error", func(t *testing.T) {
+		w := newBoundedWriter(func(b []byte) error {
+			return io.ErrClosedPipe
+		}, 4)
+		w.Write(ctx, []byte("a"))
+		if err := w.Close(); err != io.ErrClosedPipe {
+			t.Errorf("Close = %v, want %v", err, io.ErrClosedPipe)
+		}
+		if err := w.Write(ctx, []byte("b")); err != io.ErrClosedPipe {
+			t.Errorf("Write after failure = %v, want %v", err, io.ErrClosedPipe)
+		}
+	})
+}
@@ -0,0 +1,328 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/util/errorx"
+)
+
+// BatchProcessor is implemented by DoFns that can process multiple elements
+// in a single invocation. Such DoFns must embed graph.BatchProcessing.
+type BatchProcessor interface {
+	ProcessBatch(ctx context.Context, batch *Batch) error
+}
+
+// Batch is a set of buffered elements of a single window, passed to
+// ProcessBatch. It only has unexported fields, so it is accepted as a DoFn
+// method parameter.
+type Batch struct {
+	window typex.Window
+	elms   []FullValue
+	out    []FullValue
+}
+
+// Window returns the window of all elements in the batch.
+func (b *Batch) Window() typex.Window {
+	return b.window
+}
+
+// Elements returns the elements of the batch.
+func (b *Batch) Elements() []FullValue {
+	return b.elms
+}
+
+// Emit outputs a value to the main output. Values without windows are placed
+// in the window of the batch. Values with a zero timestamp are emitted at the
+// earliest timestamp of the elements of the batch, which the watermark can't
+// have passed.
+func (b *Batch) Emit(v FullValue) {
+	if len(v.Windows) == 0 {
+		v.Windows = []typex.Window{b.window}
+	}
+	if v.Timestamp == mtime.ZeroTimestamp {
+		v.Timestamp = b.timestamp()
+	}
+	b.out = append(b.out, v)
+}
+
+// timestamp returns the earliest timestamp of the elements of the batch, or
+// the end of its window if it has none.
+func (b *Batch) timestamp() typex.EventTime {
+	if len(b.elms) == 0 {
+		return b.window.MaxTimestamp()
+	}
+	t := b.elms[0].Timestamp
+	for _, e := range b.elms[1:] {
+		t = mtime.Min(t, e.Timestamp)
+	}
+	return t
+}
+
+// BatchParDo is a DoFn executor that buffers elements per window and invokes
+// the DoFn once per batch. A batch is flushed when it reaches BatchSize
+// elements, when FlushInterval has elapsed since its first element, or on
+// FinishBundle. The interval is only checked as elements arrive, so that the
+// DoFn is never invoked concurrently with the element path. If Adaptive is
+// set, it controls the batch size instead of BatchSize.
+type BatchParDo struct {
+	UID UnitID
+	Fn  *graph.DoFn
+	PID string
+	Out Node
+
+	// BatchSize is the maximum number of elements in a batch.
+	BatchSize int
+	// FlushInterval is the maximum time an element is buffered. If zero,
+	// batches are only flushed by size or at the end of the bundle.
+	FlushInterval time.Duration
+	// Adaptive, if set, adapts the batch size to the processing time of the
+	// batches.
+	Adaptive *AdaptiveBatchSize
+
+	batcher BatchProcessor
+	batches []*pendingBatch
+	ctx     context.Context
+
+	status Status
+	err    errorx.GuardedError
+}
+
+// pendingBatch holds the buffered elements of a single window.
+type pendingBatch struct {
+	Batch
+	start time.Time
+	// size is the batch size in effect when the batch was started.
+	size int
+}
+
+// NewBatchParDo returns a BatchParDo for the given DoFn. It returns an error
+// if the DoFn doesn't implement BatchProcessor.
+func NewBatchParDo(uid UnitID, fn *graph.DoFn, pid string, out Node, batchSize int, flushInterval time.Duration) (*BatchParDo, error) {
+	n := &BatchParDo{UID: uid, Fn: fn, PID: pid, Out: out, BatchSize: batchSize, FlushInterval: flushInterval}
+	if err := n.validate(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *BatchParDo) validate() error {
+	if n.Adaptive != nil {
+		if err := n.Adaptive.validate(); err != nil {
+			return errors.WithContextf(err, "batching pardo %v", n.UID)
+		}
+	} else if n.BatchSize < 1 {
+		return errors.Errorf("invalid batch size for batching pardo %v: %v, want > 0", n.UID, n.BatchSize)
+	}
+	if n.FlushInterval < 0 {
+		return errors.Errorf("invalid flush interval for batching pardo %v: %v, want >= 0", n.UID, n.FlushInterval)
+	}
+	b, ok := n.Fn.Recv.(BatchProcessor)
+	if !ok {
+		return errors.Errorf("DoFn %v does not support batch processing: it must implement ProcessBatch(context.Context, *exec.Batch) error", n.Fn.Name())
+	}
+	n.batcher = b
+	return nil
+}
+
+// GetPID returns the PTransformID for this BatchParDo.
+func (n *BatchParDo) GetPID() string {
+	return n.PID
+}
+
+// ID returns the UnitID for this BatchParDo.
+func (n *BatchParDo) ID() UnitID {
+	return n.UID
+}
+
+// Up initializes this BatchParDo and does one-time DoFn setup.
+func (n *BatchParDo) Up(ctx context.Context) error {
+	if n.status != Initializing {
+		return errors.Errorf("invalid status for batching pardo %v: %v, want Initializing", n.UID, n.status)
+	}
+	n.status = Up
+	if err := n.validate(); err != nil {
+		return n.fail(err)
+	}
+
+	setupCtx := metrics.SetPTransformID(ctx, n.PID)
+	if _, err := InvokeWithoutEventTime(setupCtx, n.Fn.SetupFn(), nil); err != nil {
+		return n.fail(err)
+	}
+	return nil
+}
+
+// StartBundle starts the bundle downstream.
+func (n *BatchParDo) StartBundle(ctx context.Context, id string, data DataContext) error {
+	if n.status != Up {
+		return errors.Errorf("invalid status for batching pardo %v: %v, want Up", n.UID, n.status)
+	}
+	n.status = Active
+	n.ctx = metrics.SetPTransformID(ctx, n.PID)
+	if n.Adaptive != nil {
+		batchParDoSize.Set(n.ctx, int64(n.Adaptive.Size()))
+	}
+
+	if err := MultiStartBundle(n.ctx, id, data, n.Out); err != nil {
+		return n.fail(err)
+	}
+	return nil
+}
+
+// ProcessElement buffers the element in the batch of each of its windows,
+// flushing any batch that is full or has expired.
+func (n *BatchParDo) ProcessElement(_ context.Context, elm *FullValue, values ...ReStream) error {
+	if n.status != Active {
+		return errors.Errorf("invalid status for batching pardo %v: %v, want Active", n.UID, n.status)
+	}
+	if len(values) > 0 {
+		return n.fail(errors.Errorf("batching pardo %v does not support GBK/CoGBK results", n.UID))
+	}
+
+	for _, w := range elm.Windows {
+		b := n.batchFor(w)
+		if len(b.elms) == 0 {
+			b.start = time.Now()
+			b.size = n.BatchSize
+			if n.Adaptive != nil {
+				b.size = n.Adaptive.start(b.start)
+			}
+		}
+		b.elms = append(b.elms, FullValue{Elm: elm.Elm, Elm2: elm.Elm2, Timestamp: elm.Timestamp, Windows: []typex.Window{w}, Pane: elm.Pane})
+		if len(b.elms) >= b.size {
+			if err := n.flush(b); err != nil {
+				return n.fail(err)
+			}
+		}
+	}
+
+	if n.FlushInterval > 0 {
+		for _, b := range n.batches {
+			if len(b.elms) > 0 && time.Since(b.start) >= n.FlushInterval {
+				if err := n.flush(b); err != nil {
+					return n.fail(err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (n *BatchParDo) batchFor(w typex.Window) *pendingBatch {
+	for _, b := range n.batches {
+		if b.window.Equals(w) {
+			return b
+		}
+	}
+	b := &pendingBatch{Batch: Batch{window: w}}
+	n.batches = append(n.batches, b)
+	return b
+}
+
+// flush invokes the DoFn on the buffered elements of the batch and emits the
+// results downstream. The adaptive batch size, if any, observes the time
+// taken by both.
+func (n *BatchParDo) flush(b *pendingBatch) error {
+	if len(b.elms) == 0 {
+		return nil
+	}
+	start := time.Now()
+	size := len(b.elms)
+	err := n.batcher.ProcessBatch(n.ctx, &b.Batch)
+	out := b.out
+	b.elms, b.out = nil, nil
+	if err != nil {
+		return err
+	}
+	for i := range out {
+		if err := n.Out.ProcessElement(n.ctx, &out[i]); err != nil {
+			return err
+		}
+	}
+	if n.Adaptive != nil {
+		now := time.Now()
+		n.Adaptive.observe(size, now.Sub(start), now)
+		batchParDoSize.Set(n.ctx, int64(n.Adaptive.Size()))
+	}
+	return nil
+}
+
+// FinishBundle flushes all outstanding batches and finishes the bundle
+// downstream.
+func (n *BatchParDo) FinishBundle(_ context.Context) error {
+	if n.status != Active {
+		return errors.Errorf("invalid status for batching pardo %v: %v, want Active", n.UID, n.status)
+	}
+	n.status = Up
+
+	for _, b := range n.batches {
+		if err := n.flush(b); err != nil {
+			return n.fail(err)
+		}
+	}
+	n.batches = nil
+
+	if err := MultiFinishBundle(n.ctx, n.Out); err != nil {
+		return n.fail(err)
+	}
+	return nil
+}
+
+// Down performs best-effort teardown of DoFn resources. (May not run.)
+func (n *BatchParDo) Down(ctx context.Context) error {
+	if n.status == Down {
+		return n.err.Error()
+	}
+	n.status = Down
+	n.batches = nil
+
+	if _, err := InvokeWithoutEventTime(ctx, n.Fn.TeardownFn(), nil); err != nil {
+		n.err.TrySetError(err)
+	}
+	return n.err.Error()
+}
+
+func (n *BatchParDo) fail(err error) error {
+	n.status = Broken
+	if err2, ok := err.(*doFnError); ok {
+		return err2
+	}
+
+	batchErr := &doFnError{
+		doFn: n.Fn.Name(),
+		err:  err,
+		uid:  n.UID,
+		pid:  n.PID,
+	}
+	n.err.TrySetError(batchErr)
+	return batchErr
+}
+
+func (n *BatchParDo) String() string {
+	if n.Adaptive != nil {
+		return fmt.Sprintf("BatchParDo[%v, size:adaptive %v-%v, interval:%v] Out:%v", path.Base(n.Fn.Name()), n.Adaptive.Min, n.Adaptive.Max, n.FlushInterval, n.Out.ID())
+	}
+	return fmt.Sprintf("BatchParDo[%v, size:%v, interval:%v] Out:%v", path.Base(n.Fn.Name()), n.BatchSize, n.FlushInterval, n.Out.ID())
+}
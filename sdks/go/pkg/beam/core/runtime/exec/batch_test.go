@@ -0,0 +1,296 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// batchSumFn is a DoFn that sums the elements of each batch.
+type batchSumFn struct {
+	graph.BatchProcessing
+}
+
+func (f *batchSumFn) ProcessElement(n int) int {
+	return n
+}
+
+func (f *batchSumFn) ProcessBatch(ctx context.Context, batch *Batch) error {
+	sum := 0
+	for _, v := range batch.Elements() {
+		sum += v.Elm.(int)
+	}
+	batch.Emit(FullValue{Elm: sum, Timestamp: batch.Elements()[0].Timestamp})
+	return nil
+}
+
+// TestBatchParDo verifies that elements are batched by size and flushed at
+// the end of the bundle.
+func TestBatchParDo(t *testing.T) {
+	fn, err := graph.NewDoFn(&batchSumFn{})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	out := &CaptureNode{UID: 1}
+	pardo, err := NewBatchParDo(2, fn, "batch", out, 2, 0)
+	if err != nil {
+		t.Fatalf("NewBatchParDo failed: %v", err)
+	}
+	n := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3, 4, 5), Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := p.Down(context.Background()); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+
+	expected := makeValues(3, 7, 5)
+	if !equalList(out.Elements, expected) {
+		t.Errorf("batchpardo(batchSumFn) = %v, want %v", extractValues(out.Elements...), extractValues(expected...))
+	}
+}
+
+// TestBatchParDo_windows verifies that batches never span windows.
+func TestBatchParDo_windows(t *testing.T) {
+	fn, err := graph.NewDoFn(&batchSumFn{})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	wA := window.IntervalWindow{Start: 0, End: 10}
+	wB := window.IntervalWindow{Start: 10, End: 20}
+	in := []MainInput{
+		{Key: FullValue{Elm: 1, Windows: []typex.Window{wA}}},
+		{Key: FullValue{Elm: 2, Windows: []typex.Window{wB}}},
+		{Key: FullValue{Elm: 3, Windows: []typex.Window{wA}}},
+		{Key: FullValue{Elm: 4, Windows: []typex.Window{wA, wB}}},
+	}
+
+	out := &CaptureNode{UID: 1}
+	pardo, err := NewBatchParDo(2, fn, "batch", out, 10, 0)
+	if err != nil {
+		t.Fatalf("NewBatchParDo failed: %v", err)
+	}
+	n := &FixedRoot{UID: 3, Elements: in, Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	expected := []FullValue{
+		{Elm: 8, Timestamp: mtime.ZeroTimestamp, Windows: []typex.Window{wA}},
+		{Elm: 6, Timestamp: mtime.ZeroTimestamp, Windows: []typex.Window{wB}},
+	}
+	if !equalList(out.Elements, expected) {
+		t.Errorf("batchpardo(batchSumFn) = %v, want %v", out.Elements, expected)
+	}
+}
+
+// batchCountFn is a DoFn that counts the elements of each batch, leaving the
+// timestamp of the outputs to the batch.
+type batchCountFn struct {
+	graph.BatchProcessing
+}
+
+func (f *batchCountFn) ProcessElement(n int) int {
+	return n
+}
+
+func (f *batchCountFn) ProcessBatch(ctx context.Context, batch *Batch) error {
+	batch.Emit(FullValue{Elm: len(batch.Elements())})
+	return nil
+}
+
+// TestBatchParDo_timestamp verifies that outputs without a timestamp are
+// emitted at the earliest timestamp of their batch.
+func TestBatchParDo_timestamp(t *testing.T) {
+	fn, err := graph.NewDoFn(&batchCountFn{})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	in := []MainInput{
+		{Key: FullValue{Elm: 1, Timestamp: 30, Windows: window.SingleGlobalWindow}},
+		{Key: FullValue{Elm: 2, Timestamp: 10, Windows: window.SingleGlobalWindow}},
+		{Key: FullValue{Elm: 3, Timestamp: 20, Windows: window.SingleGlobalWindow}},
+		{Key: FullValue{Elm: 4, Timestamp: 40, Windows: window.SingleGlobalWindow}},
+	}
+
+	out := &CaptureNode{UID: 1}
+	pardo, err := NewBatchParDo(2, fn, "batch", out, 3, 0)
+	if err != nil {
+		t.Fatalf("NewBatchParDo failed: %v", err)
+	}
+	n := &FixedRoot{UID: 3, Elements: in, Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	expected := []FullValue{
+		{Elm: 3, Timestamp: 10, Windows: window.SingleGlobalWindow},
+		{Elm: 1, Timestamp: 40, Windows: window.SingleGlobalWindow},
+	}
+	if !equalList(out.Elements, expected) {
+		t.Errorf("batchpardo(batchCountFn) = %v, want %v", out.Elements, expected)
+	}
+}
+
+// TestNewBatchParDo_unsupported verifies that DoFns without batch support are
+// rejected.
+func TestNewBatchParDo_unsupported(t *testing.T) {
+	fn, err := graph.NewDoFn(emitSumFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	if _, err := NewBatchParDo(1, fn, "batch", &Discard{UID: 2}, 2, 0); err == nil || !strings.Contains(err.Error(), "does not support batch processing") {
+		t.Errorf("NewBatchParDo(emitSumFn) = %v, want unsupported error", err)
+	}
+}
+
+// TestAdaptiveBatchSize verifies that the batch size converges to the one
+// taking the target time without oscillating, stays within its bounds and
+// returns to the initial size when idle.
+func TestAdaptiveBatchSize(t *testing.T) {
+	c := NewAdaptiveBatchSize(10, 2, 1000, 100*time.Millisecond)
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	now := time.Unix(0, 0)
+	// run processes batches at the given time per element, and returns the
+	// sizes.
+	run := func(batches int, perElement time.Duration) []int {
+		var sizes []int
+		for i := 0; i < batches; i++ {
+			size := c.start(now)
+			now = now.Add(time.Duration(size) * perElement)
+			c.observe(size, time.Duration(size)*perElement, now)
+			sizes = append(sizes, c.Size())
+		}
+		return sizes
+	}
+
+	// At 1ms per element, 100 elements take the target time.
+	sizes := run(20, time.Millisecond)
+	for i := 1; i < len(sizes); i++ {
+		if sizes[i] < sizes[i-1] {
+			t.Fatalf("sizes = %v, want them to grow monotonically", sizes)
+		}
+	}
+	if got := sizes[len(sizes)-1]; got < 80 || got > 120 {
+		t.Errorf("size = %v, want it to converge near 100", got)
+	}
+	if got, want := run(5, time.Millisecond), sizes[len(sizes)-1]; got[4] != want {
+		t.Errorf("sizes = %v at constant latency, want them to stay at %v", got, want)
+	}
+
+	// Rising latency shrinks the batches, down to the minimum.
+	sizes = run(20, time.Second)
+	for i := 1; i < len(sizes); i++ {
+		if sizes[i] > sizes[i-1] {
+			t.Fatalf("sizes = %v, want them to shrink monotonically", sizes)
+		}
+	}
+	if got, want := sizes[len(sizes)-1], 2; got != want {
+		t.Errorf("size = %v, want minimum %v", got, want)
+	}
+
+	// Very low latency grows the batches up to the maximum.
+	if got, want := run(30, time.Microsecond), 1000; got[len(got)-1] != want {
+		t.Errorf("size = %v, want maximum %v", got[len(got)-1], want)
+	}
+
+	now = now.Add(c.IdleAfter)
+	if got, want := c.start(now), 10; got != want {
+		t.Errorf("size after idling = %v, want initial %v", got, want)
+	}
+
+	for _, bad := range []*AdaptiveBatchSize{
+		NewAdaptiveBatchSize(10, 0, 100, time.Second),
+		NewAdaptiveBatchSize(10, 20, 100, time.Second),
+		NewAdaptiveBatchSize(10, 1, 5, time.Second),
+		NewAdaptiveBatchSize(10, 1, 100, 0),
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+// TestBatchParDo_adaptive verifies that an adaptive batch size controls the
+// batches and is reported as a gauge.
+func TestBatchParDo_adaptive(t *testing.T) {
+	fn, err := graph.NewDoFn(&batchSumFn{})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	out := &CaptureNode{UID: 1}
+	pardo, err := NewBatchParDo(2, fn, "batch", out, 1, 0)
+	if err != nil {
+		t.Fatalf("NewBatchParDo failed: %v", err)
+	}
+	pardo.Adaptive = NewAdaptiveBatchSize(2, 2, 4, time.Hour)
+	n := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	// Fast batches grow to the maximum size.
+	expected := makeValues(3, 18, 34)
+	if !equalList(out.Elements, expected) {
+		t.Errorf("batchpardo(batchSumFn) = %v, want %v", extractValues(out.Elements...), extractValues(expected...))
+	}
+	var size int64 = -1
+	metrics.Extractor{
+		GaugeInt64: func(l metrics.Labels, v int64, t time.Time) {
+			if l.Name() == "batchParDo.batchSize" && l.Transform() == "batch" {
+				size = v
+			}
+		},
+	}.ExtractFrom(p.Store())
+	if size != 4 {
+		t.Errorf("batch size gauge = %v, want 4", size)
+	}
+}
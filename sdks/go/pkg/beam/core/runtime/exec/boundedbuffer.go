@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// BoundedBuffer holds elements for buffering nodes, such as ones sorting,
+// deduplicating or combining elements, within a memory budget. Elements are
+// accounted with their encoded size. If the coder is a windowed value coder,
+// the size includes the windowed value header, with the windows, timestamp
+// and pane, as on the data channel. Whenever the buffered elements exceed the
+// budget, the oldest ones are evicted and passed to the eviction callback,
+// which may spill or flush them, until the rest fits the budget again. An
+// element that alone exceeds the budget is thus evicted as soon as it is
+// added. It is not safe for concurrent use.
+type BoundedBuffer struct {
+	MaxBytes int64
+
+	onEvict func(*FullValue)
+	enc     ElementEncoder
+	wEnc    WindowEncoder
+
+	elms  []bufferedElement
+	size  int64
+	count sizeCounter
+}
+
+// bufferedElement is an element held by a BoundedBuffer, with its size.
+type bufferedElement struct {
+	elm  *FullValue
+	size int64
+}
+
+// sizeCounter is a writer that only counts the bytes written to it.
+type sizeCounter int64
+
+func (c *sizeCounter) Write(p []byte) (int, error) {
+	*c += sizeCounter(len(p))
+	return len(p), nil
+}
+
+// NewBoundedBuffer returns an empty buffer of at most maxBytes of elements
+// encoded with the given coder, which passes evicted elements to onEvict.
+func NewBoundedBuffer(maxBytes int64, c *coder.Coder, onEvict func(*FullValue)) *BoundedBuffer {
+	b := &BoundedBuffer{MaxBytes: maxBytes, onEvict: onEvict, enc: MakeElementEncoder(coder.SkipW(c))}
+	if coder.IsW(c) {
+		b.wEnc = MakeWindowEncoder(c.Window)
+	}
+	return b
+}
+
+// Add buffers a copy of the element, evicting the oldest elements if the
+// budget is exceeded. The copy shares no windows or byte slice values with
+// the element, which the caller may then reuse, as with CaptureNode. It
+// returns an error if the element cannot be encoded, in which case it is not
+// buffered.
+func (b *BoundedBuffer) Add(elm *FullValue) error {
+	size, err := b.sizeOf(elm)
+	if err != nil {
+		return err
+	}
+	v := CloneFullValue(elm, CloneValuesWith(copyBytes))
+	b.elms = append(b.elms, bufferedElement{elm: v, size: size})
+	b.size += size
+	for b.size > b.MaxBytes && len(b.elms) > 0 {
+		b.evict()
+	}
+	return nil
+}
+
+// sizeOf returns the encoded size of the element.
+func (b *BoundedBuffer) sizeOf(elm *FullValue) (int64, error) {
+	b.count = 0
+	if b.wEnc != nil {
+		if err := EncodeWindowedValueHeader(b.wEnc, elm.Windows, elm.Timestamp, elm.Pane, &b.count); err != nil {
+			return 0, errors.WithContextf(err, "measuring buffered element %v", elm)
+		}
+	}
+	if err := b.enc.Encode(elm, &b.count); err != nil {
+		return 0, errors.WithContextf(err, "measuring buffered element %v", elm)
+	}
+	return int64(b.count), nil
+}
+
+// evict removes the oldest element and passes it to the eviction callback.
+func (b *BoundedBuffer) evict() {
+	e := b.elms[0]
+	b.elms[0] = bufferedElement{}
+	b.elms = b.elms[1:]
+	b.size -= e.size
+	if b.onEvict != nil {
+		b.onEvict(e.elm)
+	}
+}
+
+// Len returns the number of buffered elements.
+func (b *BoundedBuffer) Len() int {
+	return len(b.elms)
+}
+
+// Size returns the encoded size of the buffered elements, in bytes.
+func (b *BoundedBuffer) Size() int64 {
+	return b.size
+}
+
+// Iterate calls fn on the buffered elements, from the oldest to the newest,
+// until it returns an error, which is returned. Elements must not be added or
+// evicted during the iteration.
+func (b *BoundedBuffer) Iterate(fn func(*FullValue) error) error {
+	for _, e := range b.elms {
+		if err := fn(e.elm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EvictAll passes all buffered elements to the eviction callback, from the
+// oldest to the newest, such as to flush them at the end of a bundle, and
+// empties the buffer.
+func (b *BoundedBuffer) EvictAll() {
+	for len(b.elms) > 0 {
+		b.evict()
+	}
+	b.elms = nil
+}
+
+// Reset empties the buffer without evicting the buffered elements.
+func (b *BoundedBuffer) Reset() {
+	b.elms = nil
+	b.size = 0
+}
@@ -0,0 +1,139 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestBoundedBuffer verifies that the buffer accounts for the encoded size
+// of elements, including their windows, and evicts the oldest elements once
+// the budget is exceeded.
+func TestBoundedBuffer(t *testing.T) {
+	c := coder.NewW(coder.NewString(), coder.NewIntervalWindow())
+	encodedSize := func(elm *FullValue) int64 {
+		var buf bytes.Buffer
+		if err := EncodeWindowedValueHeader(MakeWindowEncoder(c.Window), elm.Windows, elm.Timestamp, elm.Pane, &buf); err != nil {
+			t.Fatalf("failed to encode header: %v", err)
+		}
+		if err := MakeElementEncoder(coder.SkipW(c)).Encode(elm, &buf); err != nil {
+			t.Fatalf("failed to encode element: %v", err)
+		}
+		return int64(buf.Len())
+	}
+	w1 := window.IntervalWindow{Start: 0, End: 1000}
+	w2 := window.IntervalWindow{Start: 1000, End: 2000}
+	elm := func(s string, ws ...typex.Window) *FullValue {
+		return &FullValue{Elm: s, Windows: ws}
+	}
+	a, b, d := elm("a", w1), elm("bb", w1, w2), elm("c", w2)
+	if encodedSize(b) <= encodedSize(elm("bb", w1)) {
+		t.Fatalf("size of element in two windows = %v, want it to exceed the size in one", encodedSize(b))
+	}
+
+	var evicted []string
+	buf := NewBoundedBuffer(encodedSize(a)+encodedSize(b), c, func(v *FullValue) {
+		evicted = append(evicted, v.Elm.(string))
+	})
+	for _, v := range []*FullValue{a, b} {
+		if err := buf.Add(v); err != nil {
+			t.Fatalf("Add(%v) failed: %v", v, err)
+		}
+	}
+	if got, want := buf.Size(), encodedSize(a)+encodedSize(b); got != want {
+		t.Errorf("Size() = %v, want %v", got, want)
+	}
+	if len(evicted) != 0 {
+		t.Errorf("evicted %v within budget, want none", evicted)
+	}
+
+	a.Elm = "modified"
+	if err := buf.Add(d); err != nil {
+		t.Fatalf("Add(%v) failed: %v", d, err)
+	}
+	if !equalStrings(evicted, []string{"a"}) {
+		t.Errorf("evicted %v, want [a]", evicted)
+	}
+	if got, want := buf.Size(), encodedSize(b)+encodedSize(d); got != want || buf.Len() != 2 {
+		t.Errorf("Size(), Len() = %v, %v, want %v, 2", got, buf.Len(), want)
+	}
+
+	// Buffered elements share no windows or byte slices with the added ones.
+	b.Windows[0] = w2
+	kv := &FullValue{Elm: []byte("k"), Elm2: []byte("v"), Windows: []typex.Window{w1}}
+	kvBuf := NewBoundedBuffer(100, coder.NewW(coder.NewKV([]*coder.Coder{coder.NewBytes(), coder.NewBytes()}), coder.NewIntervalWindow()), nil)
+	if err := kvBuf.Add(kv); err != nil {
+		t.Fatalf("Add(%v) failed: %v", kv, err)
+	}
+	kv.Elm.([]byte)[0], kv.Elm2.([]byte)[0], kv.Windows[0] = 'x', 'x', w2
+	if err := kvBuf.Iterate(func(v *FullValue) error {
+		if string(v.Elm.([]byte)) != "k" || string(v.Elm2.([]byte)) != "v" || v.Windows[0] != w1 {
+			t.Errorf("buffered element = %v, want KV<k,v> in %v", v, w1)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+
+	var iterated []string
+	if err := buf.Iterate(func(v *FullValue) error {
+		if v.Elm == "bb" && v.Windows[0] != w1 {
+			t.Errorf("buffered element bb in %v, want %v", v.Windows, []typex.Window{w1, w2})
+		}
+		iterated = append(iterated, v.Elm.(string))
+		return nil
+	}); err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	if !equalStrings(iterated, []string{"bb", "c"}) {
+		t.Errorf("Iterate = %v, want [bb c]", iterated)
+	}
+
+	// An element exceeding the budget evicts everything, including itself.
+	evicted = nil
+	if err := buf.Add(elm(string(make([]byte, 100)), w1)); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if len(evicted) != 3 || buf.Len() != 0 || buf.Size() != 0 {
+		t.Errorf("after adding oversized element evicted %v elements and holds %v of size %v, want 3, 0 and 0", len(evicted), buf.Len(), buf.Size())
+	}
+
+	evicted = nil
+	if err := buf.Add(d); err != nil {
+		t.Fatalf("Add(%v) failed: %v", d, err)
+	}
+	buf.EvictAll()
+	if !equalStrings(evicted, []string{"c"}) || buf.Len() != 0 || buf.Size() != 0 {
+		t.Errorf("EvictAll evicted %v and left %v elements of size %v, want [c], 0 and 0", evicted, buf.Len(), buf.Size())
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// CaptureNode is a terminal Node that captures all elements passed to it, for
+// verification in tests. The elements are copied, including their windows
+// and byte slice values, so that nodes reusing their buffers don't change
+// the captured elements. It also validates that it is invoked correctly.
+type CaptureNode struct {
+	UID      UnitID
+	Elements []FullValue
+
+	status Status
+}
+
+// NewCaptureNode returns a CaptureNode without captured elements.
+func NewCaptureNode() *CaptureNode {
+	return &CaptureNode{}
+}
+
+func (n *CaptureNode) ID() UnitID {
+	return n.UID
+}
+
+func (n *CaptureNode) Up(ctx context.Context) error {
+	if n.status != Initializing {
+		return errors.Errorf("invalid status for %v: %v, want Initializing", n.UID, n.status)
+	}
+	n.status = Up
+	return nil
+}
+
+func (n *CaptureNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	if n.status != Up {
+		return errors.Errorf("invalid status for %v: %v, want Up", n.UID, n.status)
+	}
+	n.status = Active
+	return nil
+}
+
+func (n *CaptureNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.status != Active {
+		return errors.Errorf("invalid status for pardo %v: %v, want Active", n.UID, n.status)
+	}
+
+	v := *elm
+	v.Elm, v.Elm2 = copyBytes(elm.Elm), copyBytes(elm.Elm2)
+	v.Windows = append([]typex.Window(nil), elm.Windows...)
+	n.Elements = append(n.Elements, v)
+	return nil
+}
+
+// copyBytes returns a copy of v if it is a byte slice, and v otherwise.
+func copyBytes(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return append([]byte(nil), b...)
+	}
+	return v
+}
+
+func (n *CaptureNode) FinishBundle(ctx context.Context) error {
+	if n.status != Active {
+		return errors.Errorf("invalid status for %v: %v, want Active", n.UID, n.status)
+	}
+	n.status = Up
+	return nil
+}
+
+func (n *CaptureNode) Down(ctx context.Context) error {
+	if n.status != Up {
+		return errors.Errorf("invalid status for %v: %v, want Up", n.UID, n.status)
+	}
+	n.status = Down
+	return nil
+}
+
+// Collected returns the captured elements, in the order they were passed to
+// the node.
+func (n *CaptureNode) Collected() []*FullValue {
+	ret := make([]*FullValue, len(n.Elements))
+	for i := range n.Elements {
+		ret[i] = &n.Elements[i]
+	}
+	return ret
+}
+
+// Reset drops the captured elements, such as between bundles.
+func (n *CaptureNode) Reset(ctx context.Context) error {
+	n.Elements = nil
+	return nil
+}
@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestCaptureNode verifies that captured elements are copied, so that reused
+// buffers don't change them, and are dropped on Reset.
+func TestCaptureNode(t *testing.T) {
+	ctx := context.Background()
+	n := NewCaptureNode()
+	if err := n.Up(ctx); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("start bundle failed: %v", err)
+	}
+	buf := &FullValue{Elm: []byte("a"), Elm2: 1, Windows: []typex.Window{window.IntervalWindow{Start: 0, End: 10}}}
+	for _, s := range []string{"a", "b"} {
+		copy(buf.Elm.([]byte), s)
+		buf.Windows[0] = window.IntervalWindow{Start: 0, End: 10}
+		if err := n.ProcessElement(ctx, buf); err != nil {
+			t.Fatalf("ProcessElement(%v) failed: %v", s, err)
+		}
+	}
+	buf.Windows[0] = window.GlobalWindow{}
+
+	got := n.Collected()
+	if len(got) != 2 {
+		t.Fatalf("collected %v elements, want 2", len(got))
+	}
+	for i, want := range []string{"a", "b"} {
+		if s := string(got[i].Elm.([]byte)); s != want {
+			t.Errorf("collected element %v = %q, want %q", i, s, want)
+		}
+		if w := got[i].Windows[0]; !w.Equals(window.IntervalWindow{Start: 0, End: 10}) {
+			t.Errorf("collected window %v = %v, want [0:10)", i, w)
+		}
+	}
+
+	if err := n.FinishBundle(ctx); err != nil {
+		t.Fatalf("finish bundle failed: %v", err)
+	}
+	if err := MultiReset(ctx, n); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if got := n.Collected(); len(got) != 0 {
+		t.Errorf("collected %v after reset, want none", got)
+	}
+}
@@ -0,0 +1,137 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// DefaultMaxEmissions is the maximum number of outputs per input element of
+// CardinalityGuards constructed with a max less than 1.
+var DefaultMaxEmissions = 1
+
+// CardinalityError indicates that a node emitted more outputs for a single
+// input element than allowed by its CardinalityGuard.
+type CardinalityError struct {
+	UID       UnitID
+	Timestamp typex.EventTime // The timestamp of the input element.
+	Max       int
+}
+
+func (e *CardinalityError) Error() string {
+	return fmt.Sprintf("node %v emitted more than %v outputs for input element at %v", e.UID, e.Max, e.Timestamp)
+}
+
+// CardinalityGuard wraps a node and fails the bundle with a CardinalityError if
+// the node emits more than Max outputs, across all its outputs, while
+// processing a single element. Outputs emitted outside of ProcessElement, such
+// as on FinishBundle, are not limited.
+type CardinalityGuard struct {
+	Node
+	Max int
+
+	active  bool
+	count   int
+	elm     *FullValue
+	err     error
+	outputs int
+}
+
+// emissionCounter is an identity node that counts the outputs of the node
+// wrapped by a CardinalityGuard.
+type emissionCounter struct {
+	Node
+	guard *CardinalityGuard
+}
+
+func (n *emissionCounter) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if err := n.guard.emit(); err != nil {
+		return err
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+// NewCardinalityGuard returns a node that limits the number of outputs of out
+// per input element to max, or DefaultMaxEmissions if max is less than 1.
+// The outputs are counted by interceptors inserted between out and the nodes
+// it feeds, which are found in its exported Node and []Node fields, so it
+// must be called before the plan is first executed.
+func NewCardinalityGuard(out Node, max int) *CardinalityGuard {
+	if max < 1 {
+		max = DefaultMaxEmissions
+	}
+	n := &CardinalityGuard{Node: out, Max: max}
+	for _, f := range nodeFields(reflect.ValueOf(out)) {
+		switch f.Type() {
+		case nodeType:
+			if !f.IsNil() {
+				f.Set(reflect.ValueOf(n.counter(f.Interface().(Node))))
+			}
+		case nodeSliceType:
+			for j := 0; j < f.Len(); j++ {
+				if e := f.Index(j); !e.IsNil() {
+					e.Set(reflect.ValueOf(n.counter(e.Interface().(Node))))
+				}
+			}
+		}
+	}
+	return n
+}
+
+func (n *CardinalityGuard) counter(out Node) Node {
+	n.outputs++
+	return &emissionCounter{Node: out, guard: n}
+}
+
+// emit counts an output of the wrapped node.
+func (n *CardinalityGuard) emit() error {
+	if !n.active {
+		return nil
+	}
+	n.count++
+	if n.count > n.Max {
+		n.err = &CardinalityError{UID: n.ID(), Timestamp: n.elm.Timestamp, Max: n.Max}
+		return n.err
+	}
+	return nil
+}
+
+// ProcessElement resets the output count and forwards the element to the
+// wrapped node.
+func (n *CardinalityGuard) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) (err error) {
+	n.active, n.count, n.elm, n.err = true, 0, elm, nil
+	defer func() {
+		n.active, n.elm = false, nil
+		if n.err == nil {
+			return
+		}
+		// Emitters panic with the error to halt the DoFn, which may also
+		// wrap it, so report it directly.
+		if r := recover(); r != nil && r != n.err {
+			panic(r)
+		}
+		err = n.err
+	}()
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *CardinalityGuard) String() string {
+	return fmt.Sprintf("CardinalityGuard[max %v, %v outputs]. Node:%v", n.Max, n.outputs, n.Node)
+}
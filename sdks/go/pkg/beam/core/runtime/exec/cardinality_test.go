@@ -0,0 +1,84 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// fanOutFn emits n copies of each element n.
+func fanOutFn(n int, emit func(int)) {
+	for i := 0; i < n; i++ {
+		emit(n)
+	}
+}
+
+// TestCardinalityGuard verifies that the bundle fails if a single element
+// results in more outputs than allowed.
+func TestCardinalityGuard(t *testing.T) {
+	fn, err := graph.NewDoFn(fanOutFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	tests := []struct {
+		name  string
+		max   int
+		input []interface{}
+		fail  bool
+	}{
+		{name: "default", input: []interface{}{1, 0, 1}},
+		{name: "exceeded", input: []interface{}{1, 2, 1}, fail: true},
+		{name: "max", max: 3, input: []interface{}{3, 1}},
+		{name: "max exceeded", max: 3, input: []interface{}{3, 4}, fail: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			pardo := &ParDo{UID: 2, Fn: fn, Out: []Node{out}}
+			guard := NewCardinalityGuard(pardo, test.max)
+			var in []MainInput
+			for i, v := range test.input {
+				in = append(in, MainInput{Key: FullValue{Elm: v, Windows: window.SingleGlobalWindow, Timestamp: typex.EventTime(i)}})
+			}
+			root := &FixedRoot{UID: 3, Elements: in, Out: guard}
+			p, err := NewPlan("a", []Unit{root, guard, out})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{})
+			if !test.fail {
+				if err != nil {
+					t.Fatalf("execute failed: %v", err)
+				}
+				return
+			}
+			var cerr *CardinalityError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("execute = %v, want CardinalityError", err)
+			}
+			// The second element fails.
+			if cerr.UID != 2 || cerr.Timestamp != 1 || cerr.Max != guard.Max {
+				t.Errorf("execute = %+v, want error for node 2 at timestamp 1", cerr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// ChannelSink is a terminal node that sends the elements passed to it, as
+// decoded values, to a channel, so that a plan can feed a Go program without
+// a runner. Each element is sent as a copy made with CloneFullValue, since
+// upstream nodes may reuse their values. Sending blocks while the channel is
+// full, and fails with the context error if the context is done first.
+//
+// The channel is owned by the caller: the sink never closes it, as it may be
+// shared by several sinks, or by bundles. The caller must not close it while
+// a bundle is being processed, and may close it once Plan.Execute returns.
+type ChannelSink struct {
+	UID UnitID
+	Out chan<- FullValue
+}
+
+// NewChannelSink returns a sink that sends elements to out. The caller sets
+// the UID of the node.
+func NewChannelSink(out chan<- FullValue) *ChannelSink {
+	return &ChannelSink{Out: out}
+}
+
+func (n *ChannelSink) ID() UnitID {
+	return n.UID
+}
+
+// Up validates the channel.
+func (n *ChannelSink) Up(ctx context.Context) error {
+	if n.Out == nil {
+		return errors.Errorf("missing channel for channel sink %v", n.UID)
+	}
+	return nil
+}
+
+func (n *ChannelSink) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return nil
+}
+
+// ProcessElement sends a copy of the element to the channel.
+func (n *ChannelSink) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("channel sink %v does not support GBK/CoGBK results", n.UID)
+	}
+	select {
+	case n.Out <- *CloneFullValue(elm):
+		return nil
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "blocked on full channel at channel sink %v", n.UID)
+	}
+}
+
+func (n *ChannelSink) FinishBundle(ctx context.Context) error {
+	return nil
+}
+
+func (n *ChannelSink) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *ChannelSink) String() string {
+	return fmt.Sprintf("ChannelSink[%v]", n.UID)
+}
@@ -0,0 +1,74 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestChannelSink verifies that elements are sent to the channel, while the
+// plan is executed concurrently with the receiver.
+func TestChannelSink(t *testing.T) {
+	ch := make(chan FullValue)
+	sink := NewChannelSink(ch)
+	sink.UID = 1
+	in := &FixedRoot{UID: 2, Elements: makeInput(1, 2, 3), Out: sink}
+	p, err := NewPlan("a", []Unit{in, sink})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Execute(context.Background(), "1", DataContext{})
+		close(ch)
+	}()
+	var got []FullValue
+	for v := range ch {
+		got = append(got, v)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(1, 2, 3); !equalList(got, want) {
+		t.Errorf("channel received %v, want %v", extractValues(got...), extractValues(want...))
+	}
+}
+
+// TestChannelSink_cancel verifies that a sink blocked on a full channel fails
+// once the context is done.
+func TestChannelSink_cancel(t *testing.T) {
+	ch := make(chan FullValue, 1)
+	sink := NewChannelSink(ch)
+	sink.UID = 1
+	in := &FixedRoot{UID: 2, Elements: makeInput(1, 2), Out: sink}
+	p, err := NewPlan("a", []Unit{in, sink})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = p.Execute(ctx, "1", DataContext{})
+	if err == nil || !strings.Contains(err.Error(), "blocked on full channel") {
+		t.Fatalf("execute = %v, want blocked on full channel", err)
+	}
+	if got := len(ch); got != 1 {
+		t.Errorf("channel holds %v elements, want 1", got)
+	}
+}
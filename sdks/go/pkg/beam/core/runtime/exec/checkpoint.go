@@ -0,0 +1,186 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// checkpointVersion is the version of the checkpoint encoding. It is the
+// first byte of each checkpoint.
+const checkpointVersion byte = 1
+
+// checkpointFraction is the fraction of the remaining restriction that is kept
+// by the primary when checkpointing, so that the position currently claimed
+// stays in the primary and is not processed again on restore.
+const checkpointFraction = math.SmallestNonzeroFloat64
+
+// ErrNoCheckpoint is returned by Checkpoint if no element is being processed.
+var ErrNoCheckpoint = errors.New("no element being processed to checkpoint")
+
+// Checkpointer is implemented by units that can checkpoint the progress of the
+// element they are processing, to resume from it after a failure.
+type Checkpointer interface {
+	// Checkpoint returns the encoded remaining work of the element being
+	// processed. Processing continues, but work covered by the checkpoint is
+	// never included in work done before it.
+	Checkpoint() ([]byte, error)
+	// Restore processes the remaining work in the given checkpoint. It must be
+	// called during a bundle.
+	Restore(checkpoint []byte) error
+}
+
+// PeriodicCheckpoint checkpoints the given unit at the given interval and
+// passes each checkpoint to save, until the context is done or save fails.
+// Intervals in which the unit is not processing an element are skipped, and
+// failed checkpoints are logged and retried at the next interval.
+func PeriodicCheckpoint(ctx context.Context, c Checkpointer, interval time.Duration, save func([]byte) error) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		b, err := c.Checkpoint()
+		if err == ErrNoCheckpoint {
+			continue
+		}
+		if err != nil {
+			log.Warnf(ctx, "checkpoint failed: %v", err)
+			continue
+		}
+		if err := save(b); err != nil {
+			return err
+		}
+	}
+}
+
+// Checkpoint splits the restriction of the element being processed at its
+// current position. The residual restriction is processed once the current
+// one is done, and is returned encoded with CheckpointCoder, together with
+// the windows left to process for window-observing DoFns. Elements emitted
+// before the checkpoint are thus never emitted again when the checkpoint is
+// restored.
+func (n *ProcessSizedElementsAndRestrictions) Checkpoint() ([]byte, error) {
+	if n.CheckpointCoder == nil {
+		return nil, errors.Errorf("checkpoint coder missing for %v", n)
+	}
+	var su SplittableUnit
+	select {
+	case su = <-n.SU:
+	default:
+		return nil, ErrNoCheckpoint
+	}
+	defer func() { n.SU <- su }()
+
+	if err := n.rt.GetError(); err != nil {
+		return nil, errors.WithContextf(err, "checkpointing %v", n)
+	}
+	var rest []*FullValue
+	if !n.rt.IsDone() {
+		_, r, err := n.rt.TrySplit(checkpointFraction)
+		if err != nil {
+			return nil, errors.WithContextf(err, "checkpointing %v", n)
+		}
+		if r != nil {
+			ws := n.elm.Windows
+			if n.numW > 1 {
+				ws = n.elm.Windows[n.currW : n.currW+1]
+			}
+			fv, err := n.newSplitResult(r, ws)
+			if err != nil {
+				return nil, err
+			}
+			rest = append(rest, fv)
+			n.resume = r
+		}
+	}
+	if n.numW > 1 && n.currW+1 < n.numW {
+		fv, err := n.newSplitResult(n.elm.Elm.(*FullValue).Elm2, n.elm.Windows[n.currW+1:n.numW])
+		if err != nil {
+			return nil, err
+		}
+		rest = append(rest, fv)
+	}
+	return n.encodeCheckpoint(rest)
+}
+
+func (n *ProcessSizedElementsAndRestrictions) encodeCheckpoint(rest []*FullValue) ([]byte, error) {
+	enc := MakeElementEncoder(coder.SkipW(n.CheckpointCoder))
+	wEnc := MakeWindowEncoder(n.CheckpointCoder.Window)
+
+	var b bytes.Buffer
+	b.WriteByte(checkpointVersion)
+	if err := coder.EncodeVarInt(int64(len(rest)), &b); err != nil {
+		return nil, err
+	}
+	for _, fv := range rest {
+		if err := EncodeWindowedValueHeader(wEnc, fv.Windows, fv.Timestamp, fv.Pane, &b); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(fv, &b); err != nil {
+			return nil, errors.WithContextf(err, "encoding checkpoint of %v", n)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// Restore decodes the given checkpoint and processes the remaining work in
+// it, as if received by ProcessElement.
+func (n *ProcessSizedElementsAndRestrictions) Restore(checkpoint []byte) error {
+	if n.CheckpointCoder == nil {
+		return errors.Errorf("checkpoint coder missing for %v", n)
+	}
+	if len(checkpoint) == 0 || checkpoint[0] != checkpointVersion {
+		return errors.Errorf("unsupported checkpoint version for %v, want %v", n, checkpointVersion)
+	}
+	dec := MakeElementDecoder(coder.SkipW(n.CheckpointCoder))
+	wDec := MakeWindowDecoder(n.CheckpointCoder.Window)
+
+	r := bytes.NewReader(checkpoint[1:])
+	count, err := coder.DecodeVarInt(r)
+	if err != nil {
+		return errors.WithContextf(err, "decoding checkpoint of %v", n)
+	}
+	rest := make([]*FullValue, count)
+	for i := range rest {
+		ws, t, pn, err := DecodeWindowedValueHeader(wDec, r)
+		if err != nil {
+			return errors.WithContextf(err, "decoding checkpoint of %v", n)
+		}
+		fv, err := dec.Decode(r)
+		if err != nil {
+			return errors.WithContextf(err, "decoding checkpoint of %v", n)
+		}
+		fv.Windows, fv.Timestamp, fv.Pane = ws, t, pn
+		rest[i] = fv
+	}
+	for _, fv := range rest {
+		if err := n.ProcessElement(n.PDo.ctx, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
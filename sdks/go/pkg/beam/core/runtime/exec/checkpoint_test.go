@@ -0,0 +1,200 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/io/rtrackers/offsetrange"
+)
+
+// PositionSdf is a basic SDF that emits each claimed position. If the reached
+// channel is set, it signals it after emitting position claim, and waits for
+// the resume channel to be closed before continuing.
+type PositionSdf struct {
+	reached chan struct{}
+	resume  chan struct{}
+	claim   int64
+}
+
+// CreateInitialRestriction creates a five-element offset range.
+func (fn *PositionSdf) CreateInitialRestriction(_ int64) offsetrange.Restriction {
+	return offsetrange.Restriction{Start: 0, End: 5}
+}
+
+// SplitRestriction is a no-op, and does not split.
+func (fn *PositionSdf) SplitRestriction(_ int64, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return []offsetrange.Restriction{rest}
+}
+
+// RestrictionSize defers to the default offset range restriction size.
+func (fn *PositionSdf) RestrictionSize(_ int64, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates a LockRTracker wrapping an offset range RTracker.
+func (fn *PositionSdf) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+// ProcessElement emits each claimed position.
+func (fn *PositionSdf) ProcessElement(rt *sdf.LockRTracker, _ int64, emit func(int64)) {
+	for i := rt.GetRestriction().(offsetrange.Restriction).Start; rt.TryClaim(i); i++ {
+		emit(i)
+		if fn.reached != nil && i == fn.claim {
+			select {
+			case fn.reached <- struct{}{}:
+			default:
+			}
+			<-fn.resume
+		}
+	}
+}
+
+func positionCheckpointCoder() *coder.Coder {
+	rest := coder.NewR(typex.New(reflect.TypeOf(offsetrange.Restriction{})))
+	pair := coder.NewKV([]*coder.Coder{coder.NewVarInt(), rest})
+	return coder.NewW(coder.NewKV([]*coder.Coder{pair, coder.NewDouble()}), coder.NewGlobalWindow())
+}
+
+// TestProcessSizedElementsAndRestrictions_Checkpoint verifies that a
+// checkpoint doesn't interrupt processing, and that restoring it resumes
+// processing after the last position claimed before the checkpoint.
+func TestProcessSizedElementsAndRestrictions_Checkpoint(t *testing.T) {
+	fn := &PositionSdf{reached: make(chan struct{}, 1), resume: make(chan struct{}), claim: 1}
+	dfn, err := graph.NewDoFn(fn, graph.NumMainInputs(graph.MainSingle))
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	in := FullValue{
+		Elm: &FullValue{
+			Elm:  int64(7),
+			Elm2: offsetrange.Restriction{Start: 0, End: 5},
+		},
+		Elm2:      5.0,
+		Timestamp: testTimestamp,
+		Windows:   window.SingleGlobalWindow,
+	}
+	capt := &CaptureNode{UID: 2}
+	node := &ProcessSizedElementsAndRestrictions{PDo: &ParDo{UID: 1, Fn: dfn, Out: []Node{capt}}, CheckpointCoder: positionCheckpointCoder()}
+	root := &FixedRoot{UID: 0, Elements: []MainInput{{Key: in}}, Out: node}
+	p, err := NewPlan("a", []Unit{root, node, capt})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- p.Execute(context.Background(), "1", DataContext{})
+	}()
+	<-fn.reached
+	cp, err := node.Checkpoint()
+	close(fn.resume)
+	if err := <-done; err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Checkpoint() failed: %v", err)
+	}
+	if got, want := extractValues(capt.Elements...), []interface{}{int64(0), int64(1), int64(2), int64(3), int64(4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checkpointed bundle emitted %v, want %v", got, want)
+	}
+	if _, err := node.Checkpoint(); err != ErrNoCheckpoint {
+		t.Errorf("Checkpoint() without an element being processed = %v, want %v", err, ErrNoCheckpoint)
+	}
+
+	// Restore the checkpoint in a fresh unit.
+	fn2 := &PositionSdf{}
+	dfn2, err := graph.NewDoFn(fn2, graph.NumMainInputs(graph.MainSingle))
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	ctx := context.Background()
+	capt2 := &CaptureNode{UID: 2}
+	node2 := &ProcessSizedElementsAndRestrictions{PDo: &ParDo{UID: 1, Fn: dfn2, Out: []Node{capt2}}, CheckpointCoder: positionCheckpointCoder()}
+	for _, u := range []Unit{capt2, node2} {
+		if err := u.Up(ctx); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+	}
+	if err := node2.StartBundle(ctx, "2", DataContext{}); err != nil {
+		t.Fatalf("start bundle failed: %v", err)
+	}
+	if err := node2.Restore(cp); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if err := node2.FinishBundle(ctx); err != nil {
+		t.Fatalf("finish bundle failed: %v", err)
+	}
+	if got, want := extractValues(capt2.Elements...), []interface{}{int64(2), int64(3), int64(4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("restored checkpoint emitted %v, want %v", got, want)
+	}
+
+	if err := node2.Restore([]byte{checkpointVersion + 1}); err == nil {
+		t.Errorf("Restore() with unknown version succeeded, want error")
+	}
+}
+
+type scriptedCheckpointer struct {
+	errs []error
+}
+
+func (c *scriptedCheckpointer) Checkpoint() ([]byte, error) {
+	if len(c.errs) == 0 {
+		return []byte("cp"), nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return nil, err
+}
+
+func (c *scriptedCheckpointer) Restore(checkpoint []byte) error {
+	return nil
+}
+
+// TestPeriodicCheckpoint verifies that intervals without an element and failed
+// checkpoints are skipped, and that a failed save stops checkpointing.
+func TestPeriodicCheckpoint(t *testing.T) {
+	c := &scriptedCheckpointer{errs: []error{ErrNoCheckpoint, errors.New("boom"), ErrNoCheckpoint}}
+	var saved [][]byte
+	err := PeriodicCheckpoint(context.Background(), c, time.Millisecond, func(b []byte) error {
+		saved = append(saved, b)
+		if len(saved) == 2 {
+			return errors.New("save failed")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Errorf("PeriodicCheckpoint() succeeded despite failed save, want error")
+	}
+	if got, want := len(saved), 2; got != want {
+		t.Errorf("PeriodicCheckpoint() saved %v checkpoints, want %v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := PeriodicCheckpoint(ctx, c, time.Millisecond, nil); err != nil {
+		t.Errorf("PeriodicCheckpoint() with done context = %v, want nil", err)
+	}
+}
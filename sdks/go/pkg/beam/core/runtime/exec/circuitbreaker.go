@@ -0,0 +1,129 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// Metrics of circuit breakers, reported per transform of the wrapped node, if
+// known. The open gauge is 1 while the breaker is open, and 0 otherwise.
+var (
+	circuitBreakerOpen     = metrics.NewGauge("exec", "circuitBreaker.open")
+	circuitBreakerRejected = metrics.NewCounter("exec", "circuitBreaker.rejected")
+)
+
+// CircuitOpenError is returned for elements rejected by an open
+// CircuitBreaker without being passed to the wrapped node.
+type CircuitOpenError struct {
+	UID      UnitID
+	Failures int       // The number of consecutive failures.
+	Until    time.Time // The end of the cooldown.
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of node %v open after %v consecutive failures, until %v", e.UID, e.Failures, e.Until.Format(time.RFC3339Nano))
+}
+
+// CircuitBreaker wraps a node, such as one calling an external service, and
+// stops passing elements to it after Threshold consecutive failed elements.
+// While the breaker is open, elements are rejected with a CircuitOpenError, so
+// that they fail fast. Once Cooldown has elapsed, the next element is passed to
+// the wrapped node again: if it succeeds, the breaker closes and the failure
+// count is reset; if it fails, the breaker opens for another cooldown. The
+// state is kept across bundles.
+//
+// The breaker only counts failures, and returns their errors. To trip within
+// a bundle, with a Threshold above 1, the failures must be absorbed by an
+// enclosing node, such as a DeadLetterNode, which also keeps a wrapped ParDo
+// usable after the errors it absorbs. Otherwise, the first failure fails the
+// bundle, and only the consecutive failures of bundles executed by the same
+// plan count towards the Threshold.
+type CircuitBreaker struct {
+	Node
+	Threshold int
+	Cooldown  time.Duration
+
+	failures int
+	until    time.Time
+	now      func() time.Time
+	ctx      context.Context
+}
+
+// NewCircuitBreaker returns a node that stops passing elements to out for
+// cooldown after threshold consecutive failures.
+func NewCircuitBreaker(out Node, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{Node: out, Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// StartBundle reports the state of the breaker and starts the wrapped node.
+func (n *CircuitBreaker) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.ctx = ctx
+	if p, ok := n.Node.(hasPID); ok {
+		n.ctx = metrics.SetPTransformID(ctx, p.GetPID())
+	}
+	n.report()
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement forwards the element to the wrapped node, unless the breaker
+// is open.
+func (n *CircuitBreaker) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	now := n.now()
+	if n.open() && now.Before(n.until) {
+		circuitBreakerRejected.Inc(n.ctx, 1)
+		return &CircuitOpenError{UID: n.ID(), Failures: n.failures, Until: n.until}
+	}
+	err := n.Node.ProcessElement(ctx, elm, values...)
+	if err == nil {
+		wasOpen := n.open()
+		n.failures = 0
+		if wasOpen {
+			n.report()
+		}
+		return nil
+	}
+	n.failures++
+	if n.open() {
+		n.until = now.Add(n.Cooldown)
+		n.report()
+	}
+	return err
+}
+
+func (n *CircuitBreaker) open() bool {
+	return n.failures >= n.Threshold
+}
+
+// report sets the open gauge to the state of the breaker.
+func (n *CircuitBreaker) report() {
+	var v int64
+	if n.open() {
+		v = 1
+	}
+	circuitBreakerOpen.Set(n.ctx, v)
+}
+
+func (n *CircuitBreaker) String() string {
+	return fmt.Sprintf("CircuitBreaker[%v, %v]. Node:%v", n.Threshold, n.Cooldown, n.Node)
+}
@@ -0,0 +1,137 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// serviceCalls counts the calls of serviceFn.
+var serviceCalls int
+
+// serviceFn fails on negative elements, like a service that is down.
+func serviceFn(n int) (int, error) {
+	serviceCalls++
+	if n < 0 {
+		return 0, errors.New("service unavailable")
+	}
+	return n, nil
+}
+
+// TestCircuitBreaker verifies that the breaker opens after consecutive
+// failures, rejects elements during the cooldown, and closes again on the
+// first success after it.
+func TestCircuitBreaker(t *testing.T) {
+	fn, err := graph.NewDoFn(serviceFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "service", Fn: fn, Out: []Node{out}}
+	breaker := NewCircuitBreaker(pardo, 2, time.Minute)
+	clock := time.Unix(0, 0)
+	breaker.now = func() time.Time { return clock }
+	dead := &CaptureNode{UID: 3}
+	dl := NewDeadLetterNode(breaker, dead, func(error) bool { return true })
+	root := &FixedRoot{UID: 4, Out: dl}
+	p, err := NewPlan("a", []Unit{root, dl, out, dead})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	bundles := []struct {
+		name     string
+		advance  time.Duration
+		in       []interface{}
+		calls    int
+		rejected int
+		open     int64
+	}{
+		// The third and fourth elements are rejected without calls.
+		{name: "opens", in: []interface{}{1, -1, -2, -3, 4}, calls: 3, rejected: 2, open: 1},
+		{name: "cooldown", advance: time.Second, in: []interface{}{5}, calls: 0, rejected: 1, open: 1},
+		{name: "reopens", advance: time.Minute, in: []interface{}{-6, 7}, calls: 1, rejected: 1, open: 1},
+		{name: "closes", advance: time.Minute, in: []interface{}{8, -9, 10}, calls: 3, open: 0},
+	}
+	for _, b := range bundles {
+		clock = clock.Add(b.advance)
+		serviceCalls = 0
+		dead.Elements = nil
+		root.Elements = makeInput(b.in...)
+		if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+			t.Fatalf("execute %v failed: %v", b.name, err)
+		}
+		if serviceCalls != b.calls {
+			t.Errorf("bundle %v made %v calls, want %v", b.name, serviceCalls, b.calls)
+		}
+		// Rejections are the dead letters not attributed to the DoFn.
+		var rejected int
+		for _, v := range dead.Elements {
+			if v.Elm.(*DeadLetter).DoFn == "" {
+				rejected++
+			}
+		}
+		if rejected != b.rejected {
+			t.Errorf("bundle %v rejected %v elements, want %v", b.name, rejected, b.rejected)
+		}
+		var open int64 = -1
+		metrics.Extractor{
+			GaugeInt64: func(l metrics.Labels, v int64, t time.Time) {
+				if l.Name() == "circuitBreaker.open" && l.Transform() == "service" {
+					open = v
+				}
+			},
+		}.ExtractFrom(p.Store())
+		if open != b.open {
+			t.Errorf("bundle %v open gauge = %v, want %v", b.name, open, b.open)
+		}
+	}
+}
+
+// TestCircuitBreaker_unhandled verifies that the breaker returns the errors
+// of failed elements without making them recoverable, so that a wrapped
+// ParDo fails the bundle unless an enclosing node absorbs them.
+func TestCircuitBreaker_unhandled(t *testing.T) {
+	fn, err := graph.NewDoFn(serviceFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "service", Fn: fn, Out: []Node{out}}
+	breaker := NewCircuitBreaker(pardo, 2, time.Minute)
+	root := &FixedRoot{UID: 3, Elements: makeInput(-1, 2), Out: breaker}
+	p, err := NewPlan("a", []Unit{root, breaker, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	err = p.Execute(context.Background(), "1", DataContext{})
+	var open *CircuitOpenError
+	if err == nil || errors.As(err, &open) {
+		t.Fatalf("execute = %v, want the DoFn error", err)
+	}
+	if pardo.recoverable != nil {
+		t.Error("breaker made the errors of the wrapped ParDo recoverable")
+	}
+	if breaker.failures != 1 {
+		t.Errorf("breaker counted %v failures, want 1", breaker.failures)
+	}
+}
@@ -0,0 +1,106 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// bundleCleanups collects the cleanup functions registered during a bundle.
+// It is safe for concurrent use.
+type bundleCleanups struct {
+	mu   sync.Mutex
+	fns  []func() error
+	done bool
+}
+
+// RegisterBundleCleanup registers a function to be called once the bundle
+// being processed with the given context ends, whether it succeeds, fails or
+// is cancelled, such as to close files or connections opened in StartBundle.
+// Cleanups are called exactly once, in reverse order of registration, after
+// all nodes have finished or failed. Their errors fail the bundle: they are
+// returned by Plan.Execute, in addition to the error of the bundle, if any. It
+// returns an error if the context isn't that of a bundle, or if the bundle has
+// already ended.
+func RegisterBundleCleanup(ctx context.Context, fn func() error) error {
+	c, ok := ctx.Value(bundleCleanupKey).(*bundleCleanups)
+	if !ok {
+		return errors.New("no bundle to register cleanup with")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return errors.New("bundle already ended: cleanup not registered")
+	}
+	c.fns = append(c.fns, fn)
+	return nil
+}
+
+// run calls the registered cleanups in reverse order, and returns their
+// errors. Panics are returned as errors, so that all cleanups are called.
+func (c *bundleCleanups) run() []error {
+	c.mu.Lock()
+	fns := c.fns
+	c.fns, c.done = nil, true
+	c.mu.Unlock()
+
+	var errs []error
+	for i := len(fns) - 1; i >= 0; i-- {
+		if err := callCleanup(fns[i]); err != nil {
+			errs = append(errs, errors.Wrapf(err, "bundle cleanup %v failed", i))
+		}
+	}
+	return errs
+}
+
+func callCleanup(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// withCleanupErrors adds the errors of the bundle cleanups to the result of
+// the bundle, failing the plan if the bundle succeeded. Aborted bundles and
+// exceeded deadlines are reported as is, since the harness acts on their type,
+// so their cleanup errors are only logged.
+func (p *Plan) withCleanupErrors(ctx context.Context, err error, errs []error) error {
+	if len(errs) == 0 {
+		return err
+	}
+	var cause interface{} = errs
+	if len(errs) == 1 {
+		cause = errs[0]
+	}
+	switch err.(type) {
+	case nil:
+		if len(errs) == 1 {
+			return p.fail(errs[0], "bundle cleanup")
+		}
+		return p.fail(errors.Errorf("multiple bundle cleanups failed: %v", errs), "bundle cleanup")
+	case *BundleAbortedError, *BundleDeadlineExceeded:
+		log.Warnf(ctx, "%v, after the bundle failed with: %v", cause, err)
+		return err
+	default:
+		return errors.WithContextf(err, "bundle cleanup also failed: %v", cause)
+	}
+}
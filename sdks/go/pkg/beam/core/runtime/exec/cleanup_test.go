@@ -0,0 +1,155 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cleanupNode registers cleanups in StartBundle, which record their calls in
+// order and return the given errors.
+type cleanupNode struct {
+	Node
+	errs  []error
+	calls *[]int
+}
+
+func (n *cleanupNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	for i, err := range n.errs {
+		i, err := i, err
+		if err := RegisterBundleCleanup(ctx, func() error {
+			*n.calls = append(*n.calls, i)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+func TestRegisterBundleCleanup(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     Node
+		errs    []error
+		wantErr []string
+	}{
+		{
+			name: "success",
+			out:  &CaptureNode{UID: 1},
+			errs: []error{nil, nil, nil},
+		},
+		{
+			name:    "cleanupFails",
+			out:     &CaptureNode{UID: 1},
+			errs:    []error{errors.New("close failed"), nil, errors.New("flush failed")},
+			wantErr: []string{"bundle cleanup", "close failed", "flush failed"},
+		},
+		{
+			name:    "bundleFails",
+			out:     &ErrorNode{UID: 1, Err: errors.New("process failed")},
+			errs:    []error{nil, errors.New("close failed")},
+			wantErr: []string{"process failed", "close failed"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []int
+			n := &cleanupNode{Node: test.out, errs: test.errs, calls: &calls}
+			in := &FixedRoot{UID: 2, Elements: makeInput(1, 2), Out: n}
+			p, err := NewPlan("a", []Unit{in, test.out})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{})
+			if len(test.wantErr) == 0 && err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			for _, want := range test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("execute = %v, want error containing %q", err, want)
+				}
+			}
+			var want []int
+			for i := len(test.errs) - 1; i >= 0; i-- {
+				want = append(want, i)
+			}
+			if !reflect.DeepEqual(calls, want) {
+				t.Errorf("cleanups called in order %v, want %v", calls, want)
+			}
+		})
+	}
+}
+
+// TestRegisterBundleCleanup_abort verifies that aborted bundles are cleaned
+// up and still reported as aborted.
+func TestRegisterBundleCleanup_abort(t *testing.T) {
+	var calls []int
+	out := &ErrorNode{UID: 1, Err: AbortBundle("retry")}
+	n := &cleanupNode{Node: out, errs: []error{errors.New("close failed")}, calls: &calls}
+	in := &FixedRoot{UID: 2, Elements: makeInput(1), Out: n}
+	p, err := NewPlan("a", []Unit{in, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	err = p.Execute(context.Background(), "1", DataContext{})
+	if _, ok := err.(*BundleAbortedError); !ok {
+		t.Errorf("execute = %v, want BundleAbortedError", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("cleanup called %v times, want 1", len(calls))
+	}
+}
+
+func TestRegisterBundleCleanup_noBundle(t *testing.T) {
+	fn := func() error { return nil }
+	if err := RegisterBundleCleanup(context.Background(), fn); err == nil {
+		t.Error("RegisterBundleCleanup outside of a bundle succeeded, want error")
+	}
+
+	c := &bundleCleanups{}
+	ctx := context.WithValue(context.Background(), bundleCleanupKey, c)
+	if err := RegisterBundleCleanup(ctx, fn); err != nil {
+		t.Fatalf("RegisterBundleCleanup failed: %v", err)
+	}
+	c.run()
+	if err := RegisterBundleCleanup(ctx, fn); err == nil {
+		t.Error("RegisterBundleCleanup after the bundle ended succeeded, want error")
+	}
+	if errs := c.run(); len(errs) != 0 {
+		t.Errorf("run after the bundle ended = %v, want no errors", errs)
+	}
+}
+
+func TestBundleCleanups_panic(t *testing.T) {
+	c := &bundleCleanups{}
+	var called bool
+	c.fns = []func() error{
+		func() error { called = true; return nil },
+		func() error { panic("oops") },
+	}
+	errs := c.run()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "oops") {
+		t.Errorf("run = %v, want panic error", errs)
+	}
+	if !called {
+		t.Error("cleanup after panicking cleanup not called")
+	}
+}
@@ -0,0 +1,132 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// Codec converts between typed values and their encoding in some format, such
+// as JSON, Avro or proto. It is used by DecodeNode and EncodeNode to cross a
+// boundary with external byte streams.
+type Codec interface {
+	// Encode returns the encoding of the value.
+	Encode(v interface{}) ([]byte, error)
+	// Decode returns the value of the encoding.
+	Decode(data []byte) (interface{}, error)
+}
+
+// UnknownContentTypeError indicates that an element has a content type for
+// which a DecodeNode or EncodeNode has no codec.
+type UnknownContentTypeError struct {
+	UID         UnitID
+	ContentType string
+}
+
+func (e *UnknownContentTypeError) Error() string {
+	return fmt.Sprintf("no codec for content type %q at node %v", e.ContentType, e.UID)
+}
+
+// DecodeNode wraps a node and decodes the elements passed to it with the codec
+// of their content type. Elements must be KVs of a content type string and the
+// []byte encoding, and are passed on as KVs of the content type and the decoded
+// value, so that the format can be selected per element, and kept for encoding
+// the value again. Elements of a content type without a codec fail the bundle
+// with an UnknownContentTypeError.
+type DecodeNode struct {
+	Node
+	Codecs map[string]Codec
+}
+
+// NewDecodeNode returns a node that decodes elements with the codecs keyed by
+// content type before passing them to out.
+func NewDecodeNode(out Node, codecs map[string]Codec) *DecodeNode {
+	return &DecodeNode{Node: out, Codecs: codecs}
+}
+
+// ProcessElement decodes the element and forwards it to the wrapped node.
+func (n *DecodeNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	ct, c, err := codecOf(n.ID(), n.Codecs, elm)
+	if err != nil {
+		return err
+	}
+	data, ok := elm.Elm2.([]byte)
+	if !ok {
+		return errors.Errorf("invalid element %v at node %v: got value of type %T, want []byte", elm, n.ID(), elm.Elm2)
+	}
+	v, err := c.Decode(data)
+	if err != nil {
+		return errors.WithContextf(err, "decoding %q element at node %v", ct, n.ID())
+	}
+	out := *elm
+	out.Elm2 = v
+	return n.Node.ProcessElement(ctx, &out, values...)
+}
+
+func (n *DecodeNode) String() string {
+	return fmt.Sprintf("DecodeNode[%v]. Node:%v", len(n.Codecs), n.Node)
+}
+
+// EncodeNode wraps a node and encodes the elements passed to it with the codec
+// of their content type, the inverse of DecodeNode. Elements must be KVs of a
+// content type string and a value, and are passed on as KVs of the content type
+// and the []byte encoding. Elements of a content type without a codec fail the
+// bundle with an UnknownContentTypeError.
+type EncodeNode struct {
+	Node
+	Codecs map[string]Codec
+}
+
+// NewEncodeNode returns a node that encodes elements with the codecs keyed by
+// content type before passing them to out.
+func NewEncodeNode(out Node, codecs map[string]Codec) *EncodeNode {
+	return &EncodeNode{Node: out, Codecs: codecs}
+}
+
+// ProcessElement encodes the element and forwards it to the wrapped node.
+func (n *EncodeNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	ct, c, err := codecOf(n.ID(), n.Codecs, elm)
+	if err != nil {
+		return err
+	}
+	data, err := c.Encode(elm.Elm2)
+	if err != nil {
+		return errors.WithContextf(err, "encoding %q element at node %v", ct, n.ID())
+	}
+	out := *elm
+	out.Elm2 = data
+	return n.Node.ProcessElement(ctx, &out, values...)
+}
+
+func (n *EncodeNode) String() string {
+	return fmt.Sprintf("EncodeNode[%v]. Node:%v", len(n.Codecs), n.Node)
+}
+
+// codecOf returns the content type of the element and its codec.
+func codecOf(uid UnitID, codecs map[string]Codec, elm *FullValue) (string, Codec, error) {
+	ct, ok := elm.Elm.(string)
+	if !ok || elm.Elm2 == nil {
+		return "", nil, errors.Errorf("invalid element %v at node %v: want KV of a content type string and a value", elm, uid)
+	}
+	c, ok := codecs[ct]
+	if !ok {
+		return "", nil, &UnknownContentTypeError{UID: uid, ContentType: ct}
+	}
+	return ct, c, nil
+}
@@ -0,0 +1,117 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonCodec encodes values as JSON, decoding them into generic values.
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(data []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// textCodec encodes strings as their bytes.
+type textCodec struct{}
+
+func (textCodec) Encode(v interface{}) ([]byte, error) {
+	return []byte(v.(string)), nil
+}
+
+func (textCodec) Decode(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+// TestDecodeEncodeNode verifies that elements are decoded and encoded with the
+// codec of their content type, and that encoding reverses decoding.
+func TestDecodeEncodeNode(t *testing.T) {
+	ctx := context.Background()
+	codecs := map[string]Codec{"application/json": jsonCodec{}, "text/plain": textCodec{}}
+	in := []FullValue{
+		{Elm: "application/json", Elm2: []byte(`{"a":1}`)},
+		{Elm: "text/plain", Elm2: []byte("hello")},
+	}
+
+	out := &CaptureNode{UID: 1}
+	enc := NewEncodeNode(out, codecs)
+	dec := NewDecodeNode(enc, codecs)
+	decoded := &CaptureNode{UID: 3}
+	decOnly := NewDecodeNode(decoded, codecs)
+	for _, n := range []Node{out, decoded} {
+		if err := n.Up(ctx); err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("StartBundle failed: %v", err)
+		}
+	}
+	for i := range in {
+		if err := decOnly.ProcessElement(ctx, &in[i]); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if err := dec.ProcessElement(ctx, &in[i]); err != nil {
+			t.Fatalf("decode and encode failed: %v", err)
+		}
+	}
+
+	wantDecoded := []interface{}{map[string]interface{}{"a": 1.0}, "hello"}
+	var gotDecoded []interface{}
+	for _, v := range decoded.Elements {
+		gotDecoded = append(gotDecoded, v.Elm2)
+	}
+	if !reflect.DeepEqual(gotDecoded, wantDecoded) {
+		t.Errorf("decoded = %v, want %v", gotDecoded, wantDecoded)
+	}
+	if !reflect.DeepEqual(out.Elements, in) {
+		t.Errorf("round trip = %v, want %v", out.Elements, in)
+	}
+}
+
+// TestDecodeNode_errors verifies that elements of unknown content types fail
+// with an UnknownContentTypeError, and that codec errors fail the element.
+func TestDecodeNode_errors(t *testing.T) {
+	ctx := context.Background()
+	n := NewDecodeNode(&CaptureNode{UID: 1}, map[string]Codec{"application/json": jsonCodec{}})
+
+	err := n.ProcessElement(ctx, &FullValue{Elm: "application/avro", Elm2: []byte{0}})
+	var ue *UnknownContentTypeError
+	if !errors.As(err, &ue) || ue.ContentType != "application/avro" || ue.UID != 1 {
+		t.Errorf("decode = %v, want UnknownContentTypeError for application/avro at node 1", err)
+	}
+
+	err = n.ProcessElement(ctx, &FullValue{Elm: "application/json", Elm2: []byte("{")})
+	if err == nil || !strings.Contains(err.Error(), `decoding "application/json" element at node 1`) {
+		t.Errorf("decode = %v, want decoding error", err)
+	}
+
+	err = n.ProcessElement(ctx, &FullValue{Elm: []byte("application/json")})
+	if err == nil || !strings.Contains(err.Error(), "want KV of a content type string") {
+		t.Errorf("decode = %v, want invalid element error", err)
+	}
+}
@@ -752,7 +752,7 @@ type windowedValueEncoder struct {
 }
 
 func (e *windowedValueEncoder) Encode(val *FullValue, w io.Writer) error {
-	if err := EncodeWindowedValueHeader(e.win, val.Windows, val.Timestamp, w); err != nil {
+	if err := EncodeWindowedValueHeader(e.win, val.Windows, val.Timestamp, val.Pane, w); err != nil {
 		return err
 	}
 	return e.elm.Encode(val, w)
@@ -765,7 +765,7 @@ type windowedValueDecoder struct {
 
 func (d *windowedValueDecoder) DecodeTo(r io.Reader, fv *FullValue) error {
 	// Encoding: beam utf8 string (length prefix + run of bytes)
-	w, et, err := DecodeWindowedValueHeader(d.win, r)
+	w, et, pn, err := DecodeWindowedValueHeader(d.win, r)
 	if err != nil {
 		return err
 	}
@@ -774,6 +774,7 @@ func (d *windowedValueDecoder) DecodeTo(r io.Reader, fv *FullValue) error {
 	}
 	fv.Windows = w
 	fv.Timestamp = et
+	fv.Pane = pn
 	return nil
 }
 
@@ -934,6 +935,20 @@ func MakeWindowDecoder(c *coder.WindowCoder) WindowDecoder {
 	return w
 }
 
+// checkWindowedValueCoder returns an error unless the given coder is a
+// windowed value coder with a supported window coder.
+func checkWindowedValueCoder(c *coder.Coder) error {
+	if c == nil || !coder.IsW(c) || c.Window == nil {
+		return errors.Errorf("not a windowed value coder: %v", c)
+	}
+	switch c.Window.Kind {
+	case coder.GlobalWindow, coder.IntervalWindow:
+		return nil
+	default:
+		return errors.Errorf("unexpected window coder %v of windowed value coder %v", c.Window, c)
+	}
+}
+
 // wrappedWindowEncoder wraps a WindowEncoder for the ElementEncoder interface.
 type wrappedWindowEncoder struct {
 	enc WindowEncoder
@@ -1076,10 +1091,9 @@ func (*intervalWindowDecoder) DecodeSingle(r io.Reader) (typex.Window, error) {
 	return window.IntervalWindow{Start: mtime.FromMilliseconds(end.Milliseconds() - int64(duration)), End: end}, nil
 }
 
-var paneNoFiring = []byte{0xf}
-
-// EncodeWindowedValueHeader serializes a windowed value header.
-func EncodeWindowedValueHeader(enc WindowEncoder, ws []typex.Window, t typex.EventTime, w io.Writer) error {
+// EncodeWindowedValueHeader serializes a windowed value header. Elements
+// without a pane are encoded in the NoFiringPane.
+func EncodeWindowedValueHeader(enc WindowEncoder, ws []typex.Window, t typex.EventTime, p typex.PaneInfo, w io.Writer) error {
 	// Encoding: Timestamp, Window, Pane (header) + Element
 
 	if err := coder.EncodeEventTime(t, w); err != nil {
@@ -1088,25 +1102,27 @@ func EncodeWindowedValueHeader(enc WindowEncoder, ws []typex.Window, t typex.Eve
 	if err := enc.Encode(ws, w); err != nil {
 		return err
 	}
-	_, err := w.Write(paneNoFiring)
-	return err
+	if p == (typex.PaneInfo{}) {
+		p = typex.NoFiringPane()
+	}
+	return coder.EncodePane(p, w)
 }
 
 // DecodeWindowedValueHeader deserializes a windowed value header.
-func DecodeWindowedValueHeader(dec WindowDecoder, r io.Reader) ([]typex.Window, typex.EventTime, error) {
+func DecodeWindowedValueHeader(dec WindowDecoder, r io.Reader) ([]typex.Window, typex.EventTime, typex.PaneInfo, error) {
 	// Encoding: Timestamp, Window, Pane (header) + Element
 
 	t, err := coder.DecodeEventTime(r)
 	if err != nil {
-		return nil, mtime.ZeroTimestamp, err
+		return nil, mtime.ZeroTimestamp, typex.PaneInfo{}, err
 	}
 	ws, err := dec.Decode(r)
 	if err != nil {
-		return nil, mtime.ZeroTimestamp, err
+		return nil, mtime.ZeroTimestamp, typex.PaneInfo{}, err
 	}
-	var data [1]byte
-	if err := ioutilx.ReadNBufUnsafe(r, data[:]); err != nil { // NO_FIRING pane
-		return nil, mtime.ZeroTimestamp, err
+	p, err := coder.DecodePane(r)
+	if err != nil {
+		return nil, mtime.ZeroTimestamp, typex.PaneInfo{}, err
 	}
-	return ws, t, nil
+	return ws, t, p, nil
 }
@@ -0,0 +1,101 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// jsonCoderName is the name of the reflective JSON coder used for types
+// without a registered coder at pipeline construction.
+const jsonCoderName = "json"
+
+var (
+	codersMu sync.RWMutex
+	coders   = make(map[reflect.Type]*coder.Coder)
+	warned   = make(map[reflect.Type]bool)
+)
+
+// RegisterCoder makes plans built by UnmarshalPlan use the given coder for
+// elements of type t that the pipeline encodes with the slow reflective JSON
+// coder. The coder must be registered identically in all workers of a job
+// before their plans are built, since it changes the encoding of the
+// elements. A nil coder removes the registration. It panics if the coder
+// isn't a coder of t. It is safe for concurrent use.
+func RegisterCoder(t reflect.Type, c *coder.Coder) {
+	if c != nil && (c.T == nil || c.T.Type() != t) {
+		panic(errors.Errorf("RegisterCoder failed for type %v: coder %v is for type %v", t, c, c.T))
+	}
+	codersMu.Lock()
+	defer codersMu.Unlock()
+	if c == nil {
+		delete(coders, t)
+		return
+	}
+	coders[t] = c
+}
+
+// withRegisteredCoders returns the coder with all JSON coders of types with
+// a registered coder replaced by it. Unregistered types are logged once. The
+// coder itself is not modified.
+func withRegisteredCoders(c *coder.Coder) *coder.Coder {
+	if c.Kind == coder.Custom && c.Custom.Name == jsonCoderName {
+		return registeredCoder(c)
+	}
+	var comps []*coder.Coder
+	for i, comp := range c.Components {
+		r := withRegisteredCoders(comp)
+		if r != comp && comps == nil {
+			comps = append([]*coder.Coder(nil), c.Components...)
+		}
+		if comps != nil {
+			comps[i] = r
+		}
+	}
+	if comps == nil {
+		return c
+	}
+	ret := *c
+	ret.Components = comps
+	return &ret
+}
+
+// registeredCoder returns the registered coder for the type of the JSON
+// coder, or the JSON coder itself.
+func registeredCoder(c *coder.Coder) *coder.Coder {
+	t := c.Custom.Type
+	codersMu.RLock()
+	rc, ok := coders[t]
+	codersMu.RUnlock()
+	if ok {
+		return rc
+	}
+
+	codersMu.Lock()
+	warn := !warned[t]
+	warned[t] = true
+	codersMu.Unlock()
+	if warn {
+		log.Warnf(context.Background(), "using the slow JSON coder for type %v, which has no coder registered with exec.RegisterCoder", t)
+	}
+	return c
+}
@@ -0,0 +1,173 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+type registeredType struct {
+	A int
+}
+
+func jsonEncRegistered(v registeredType) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func jsonDecRegistered(b []byte) (registeredType, error) {
+	var v registeredType
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// fastEncRegistered encodes the value as a decimal number, which isn't valid
+// JSON for it.
+func fastEncRegistered(v registeredType) []byte {
+	return []byte(strconv.Itoa(v.A))
+}
+
+func fastDecRegistered(b []byte) (registeredType, error) {
+	a, err := strconv.Atoi(string(b))
+	return registeredType{A: a}, err
+}
+
+func init() {
+	runtime.RegisterType(reflect.TypeOf(registeredType{}))
+	runtime.RegisterFunction(jsonEncRegistered)
+	runtime.RegisterFunction(jsonDecRegistered)
+}
+
+// TestRegisterCoder verifies that JSON coders of registered types are
+// replaced in coders of plans, without changing the original coders.
+func TestRegisterCoder(t *testing.T) {
+	rt := reflect.TypeOf(registeredType{})
+	jc, err := coder.NewCustomCoder(jsonCoderName, rt, jsonEncRegistered, jsonDecRegistered)
+	if err != nil {
+		t.Fatalf("failed to create JSON coder: %v", err)
+	}
+	slow := &coder.Coder{Kind: coder.Custom, T: typex.New(rt), Custom: jc}
+	c := coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), slow}), coder.NewGlobalWindow())
+
+	if got := withRegisteredCoders(c); got != c {
+		t.Errorf("withRegisteredCoders(%v) = %v without registration, want it unchanged", c, got)
+	}
+
+	registered := coder.NewW(coder.NewBytes(), coder.NewGlobalWindow())
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("RegisterCoder(%v, %v) didn't panic", rt, registered)
+			}
+		}()
+		RegisterCoder(rt, registered)
+	}()
+
+	fc, err := coder.NewCustomCoder("fast", rt, fastEncRegistered, fastDecRegistered)
+	if err != nil {
+		t.Fatalf("failed to create fast coder: %v", err)
+	}
+	registered = &coder.Coder{Kind: coder.Custom, T: typex.New(rt), Custom: fc}
+	RegisterCoder(rt, registered)
+	defer RegisterCoder(rt, nil)
+	got := withRegisteredCoders(c)
+	if kv := coder.SkipW(got); kv.Components[1] != registered {
+		t.Errorf("withRegisteredCoders(%v) = %v, want registered value coder %v", c, got, registered)
+	}
+	if kv := coder.SkipW(c); kv.Components[1] != slow {
+		t.Errorf("withRegisteredCoders modified the original coder to %v", c)
+	}
+}
+
+// TestRegisterCoder_plan verifies that a plan built by UnmarshalPlan decodes
+// and encodes elements of a type with a registered coder with it.
+func TestRegisterCoder_plan(t *testing.T) {
+	rt := reflect.TypeOf(registeredType{})
+	jc, err := coder.NewCustomCoder(jsonCoderName, rt, jsonEncRegistered, jsonDecRegistered)
+	if err != nil {
+		t.Fatalf("failed to create JSON coder: %v", err)
+	}
+	slow := coder.NewW(&coder.Coder{Kind: coder.Custom, T: typex.New(rt), Custom: jc}, coder.NewGlobalWindow())
+	ids, coders, err := graphx.MarshalCoders([]*coder.Coder{slow})
+	if err != nil {
+		t.Fatalf("failed to marshal coder %v: %v", slow, err)
+	}
+	port, err := proto.Marshal(&fnpb.RemoteGrpcPort{CoderId: ids[0]})
+	if err != nil {
+		t.Fatalf("bad port: %v", err)
+	}
+	desc := &fnpb.ProcessBundleDescriptor{
+		Id: "test",
+		Transforms: map[string]*pipepb.PTransform{
+			"source": {
+				Spec:    &pipepb.FunctionSpec{Urn: "beam:runner:source:v1", Payload: port},
+				Outputs: map[string]string{"o1": "p1"},
+			},
+			"sink": {
+				Spec:   &pipepb.FunctionSpec{Urn: "beam:runner:sink:v1", Payload: port},
+				Inputs: map[string]string{"i1": "p1"},
+			},
+		},
+		Pcollections: map[string]*pipepb.PCollection{
+			"p1": {CoderId: ids[0]},
+		},
+		Coders: coders,
+	}
+
+	fc, err := coder.NewCustomCoder("fast", rt, fastEncRegistered, fastDecRegistered)
+	if err != nil {
+		t.Fatalf("failed to create fast coder: %v", err)
+	}
+	fast := &coder.Coder{Kind: coder.Custom, T: typex.New(rt), Custom: fc}
+	RegisterCoder(rt, fast)
+	defer RegisterCoder(rt, nil)
+	p, err := UnmarshalPlan(desc)
+	if err != nil {
+		t.Fatalf("UnmarshalPlan failed: %v", err)
+	}
+
+	var in bytes.Buffer
+	if err := EncodeWindowedValueHeader(MakeWindowEncoder(coder.NewGlobalWindow()), window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &in); err != nil {
+		t.Fatalf("encoding header failed: %v", err)
+	}
+	if err := MakeElementEncoder(fast).Encode(&FullValue{Elm: registeredType{A: 7}}, &in); err != nil {
+		t.Fatalf("encoding element failed: %v", err)
+	}
+	want := append([]byte(nil), in.Bytes()...)
+	out := &nopWriteCloser{}
+	data := &TestDataManager{R: ioutil.NopCloser(&in), W: out}
+	if err := p.Execute(context.Background(), "1", DataContext{Data: data}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got := out.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("plan wrote %q, want %q", got, want)
+	}
+}
@@ -50,7 +50,7 @@ func (n *Inject) Up(ctx context.Context) error {
 }
 
 func (n *Inject) StartBundle(ctx context.Context, id string, data DataContext) error {
-	return n.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, n.Out)
 }
 
 func (n *Inject) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
@@ -74,7 +74,7 @@ func (n *Inject) ProcessElement(ctx context.Context, elm *FullValue, values ...R
 }
 
 func (n *Inject) FinishBundle(ctx context.Context) error {
-	return n.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, n.Out)
 }
 
 func (n *Inject) Down(ctx context.Context) error {
@@ -103,7 +103,7 @@ func (n *Expand) Up(ctx context.Context) error {
 }
 
 func (n *Expand) StartBundle(ctx context.Context, id string, data DataContext) error {
-	return n.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, n.Out)
 }
 
 func (n *Expand) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
@@ -116,7 +116,7 @@ func (n *Expand) ProcessElement(ctx context.Context, elm *FullValue, values ...R
 }
 
 func (n *Expand) FinishBundle(ctx context.Context) error {
-	return n.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, n.Out)
 }
 
 func (n *Expand) Down(ctx context.Context) error {
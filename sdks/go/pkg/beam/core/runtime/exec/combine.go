@@ -41,6 +41,19 @@ type Combine struct {
 	PID string
 	ctx context.Context
 
+	// Hints are the resource hints of the transform.
+	Hints ResourceHints
+
+	// FlushCount, if positive, is the number of grouped values of a key and
+	// window after which the Combine, or MergeAccumulators, fires early: the
+	// result so far is passed to Out in an early pane, and the counter is
+	// reset. Accumulation continues, as for accumulating panes, and the
+	// complete result is passed when the group ends, in the pane of the
+	// group following the early ones. Watermark-driven firings are unchanged,
+	// as the runner decides when groups end. MergeAccumulators counts the
+	// accumulators it merges, since the inputs they hold aren't known.
+	FlushCount int
+
 	binaryMergeFn reflectx.Func2x1 // optimized caller in the case of binary merge accumulators
 
 	status Status
@@ -57,6 +70,11 @@ func (n *Combine) GetPID() string {
 	return n.PID
 }
 
+// ResourceHints returns the resource hints of the transform.
+func (n *Combine) ResourceHints() ResourceHints {
+	return n.Hints
+}
+
 // ID returns the UnitID for this node.
 func (n *Combine) ID() UnitID {
 	return n.UID
@@ -121,7 +139,7 @@ func (n *Combine) StartBundle(ctx context.Context, id string, data DataContext)
 	// per-unit, to avoid the constant allocation overhead.
 	n.ctx = metrics.SetPTransformID(ctx, n.PID)
 
-	if err := n.Out.StartBundle(n.ctx, id, data); err != nil {
+	if err := MultiStartBundle(n.ctx, id, data, n.Out); err != nil {
 		return n.fail(err)
 	}
 	return nil
@@ -142,6 +160,7 @@ func (n *Combine) ProcessElement(ctx context.Context, value *FullValue, values .
 		return n.fail(err)
 	}
 	first := true
+	var early countTrigger
 
 	stream, err := values[0].Open()
 	if err != nil {
@@ -162,13 +181,60 @@ func (n *Combine) ProcessElement(ctx context.Context, value *FullValue, values .
 			return n.fail(err)
 		}
 		first = false
+		if early.add(n.FlushCount) {
+			out, err := n.extract(n.ctx, a)
+			if err != nil {
+				return n.fail(err)
+			}
+			if err := n.Out.ProcessElement(n.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: out, Timestamp: value.Timestamp, Pane: early.pane(value.Pane)}); err != nil {
+				return err
+			}
+		}
 	}
 
 	out, err := n.extract(n.ctx, a)
 	if err != nil {
 		return n.fail(err)
 	}
-	return n.Out.ProcessElement(n.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: out, Timestamp: value.Timestamp})
+	return n.Out.ProcessElement(n.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: out, Timestamp: value.Timestamp, Pane: early.final(value.Pane)})
+}
+
+// countTrigger counts the grouped values of a key and window, to fire early
+// every FlushCount values.
+type countTrigger struct {
+	count   int
+	firings int64
+}
+
+// add counts a value, and returns whether to fire early.
+func (t *countTrigger) add(flushCount int) bool {
+	if flushCount <= 0 {
+		return false
+	}
+	t.count++
+	if t.count < flushCount {
+		return false
+	}
+	t.count = 0
+	return true
+}
+
+// pane returns the pane of the next early firing in the group of the given
+// pane.
+func (t *countTrigger) pane(p typex.PaneInfo) typex.PaneInfo {
+	early := typex.PaneInfo{Timing: typex.PaneEarly, IsFirst: p.IsFirst && t.firings == 0, Index: p.Index + t.firings, NonSpeculativeIndex: -1}
+	t.firings++
+	return early
+}
+
+// final returns the pane of the complete result of the group of the given
+// pane, following the early firings.
+func (t *countTrigger) final(p typex.PaneInfo) typex.PaneInfo {
+	if t.firings > 0 {
+		p.IsFirst = false
+		p.Index += t.firings
+	}
+	return p
 }
 
 // FinishBundle completes this node's processing of a bundle.
@@ -190,7 +256,7 @@ func (n *Combine) FinishBundle(ctx context.Context) error {
 		n.extractOutputInv.Reset()
 	}
 
-	if err := n.Out.FinishBundle(n.ctx); err != nil {
+	if err := MultiFinishBundle(n.ctx, n.Out); err != nil {
 		return n.fail(err)
 	}
 	return nil
@@ -315,6 +381,12 @@ type LiftedCombine struct {
 	KeyCoder    *coder.Coder
 	WindowCoder *coder.WindowCoder
 
+	// CheckKeys makes the node verify that KeyCoder encodes the first keys
+	// of each bundle deterministically, as a DeterministicKeyCheck does. It
+	// is set by UnmarshalPlan for key coders not known to be deterministic.
+	CheckKeys bool
+
+	keys    *keyCheck
 	keyHash elementHasher
 	cache   map[uint64]FullValue
 }
@@ -329,6 +401,10 @@ func (n *LiftedCombine) Up(ctx context.Context) error {
 		return err
 	}
 	n.keyHash = makeElementHasher(n.KeyCoder, n.WindowCoder)
+	n.keys = nil
+	if n.CheckKeys {
+		n.keys = newKeyCheck(n.PID, n.KeyCoder)
+	}
 	return nil
 }
 
@@ -338,6 +414,9 @@ func (n *LiftedCombine) StartBundle(ctx context.Context, id string, data DataCon
 		return err
 	}
 	n.cache = make(map[uint64]FullValue)
+	if n.keys != nil {
+		n.keys.reset()
+	}
 	return nil
 }
 
@@ -348,6 +427,11 @@ func (n *LiftedCombine) ProcessElement(ctx context.Context, value *FullValue, va
 	if n.status != Active {
 		return errors.Errorf("invalid status for precombine %v: %v", n.UID, n.status)
 	}
+	if n.keys != nil {
+		if err := n.keys.check(value.Elm); err != nil {
+			return n.fail(err)
+		}
+	}
 	// The cache layer in lifted combines implicitly observes windows. Process each individually.
 	for _, w := range value.Windows {
 		err := n.processElementPerWindow(ctx, value, w)
@@ -413,7 +497,7 @@ func (n *LiftedCombine) processElementPerWindow(ctx context.Context, value *Full
 	}
 
 	// Cache the accumulator with the key
-	n.cache[key] = FullValue{Windows: []typex.Window{w}, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp}
+	n.cache[key] = FullValue{Windows: []typex.Window{w}, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp, Pane: value.Pane}
 
 	return nil
 }
@@ -464,6 +548,7 @@ func (n *MergeAccumulators) ProcessElement(ctx context.Context, value *FullValue
 		return n.fail(err)
 	}
 	first := true
+	var early countTrigger
 
 	stream, err := values[0].Open()
 	if err != nil {
@@ -481,14 +566,16 @@ func (n *MergeAccumulators) ProcessElement(ctx context.Context, value *FullValue
 		if first {
 			a = v.Elm
 			first = false
-			continue
-		}
-		a, err = n.mergeAccumulators(n.Combine.ctx, a, v.Elm)
-		if err != nil {
+		} else if a, err = n.mergeAccumulators(n.Combine.ctx, a, v.Elm); err != nil {
 			return err
 		}
+		if early.add(n.FlushCount) {
+			if err := n.Out.ProcessElement(n.Combine.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp, Pane: early.pane(value.Pane)}); err != nil {
+				return err
+			}
+		}
 	}
-	return n.Out.ProcessElement(n.Combine.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp})
+	return n.Out.ProcessElement(n.Combine.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp, Pane: early.final(value.Pane)})
 }
 
 // Up eagerly gets the optimized binary merge function.
@@ -518,7 +605,7 @@ func (n *ExtractOutput) ProcessElement(ctx context.Context, value *FullValue, va
 	if err != nil {
 		return n.fail(err)
 	}
-	return n.Out.ProcessElement(n.Combine.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: out, Timestamp: value.Timestamp})
+	return n.Out.ProcessElement(n.Combine.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: out, Timestamp: value.Timestamp, Pane: value.Pane})
 }
 
 // ConvertToAccumulators is an executor for converting an input value to an accumulator value.
@@ -545,5 +632,5 @@ func (n *ConvertToAccumulators) ProcessElement(ctx context.Context, value *FullV
 	if err != nil {
 		return n.fail(err)
 	}
-	return n.Out.ProcessElement(n.Combine.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp})
+	return n.Out.ProcessElement(n.Combine.ctx, &FullValue{Windows: value.Windows, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp, Pane: value.Pane})
 }
@@ -116,6 +116,63 @@ func TestLiftedCombine(t *testing.T) {
 
 }
 
+// TestCombine_flushCount verifies that the Combine and MergeAccumulators
+// nodes fire early every given number of grouped values, in early panes
+// preceding the complete result in the pane of the group.
+func TestCombine_flushCount(t *testing.T) {
+	edge := getCombineEdge(t, mergeFn, reflectx.Int, intCoder(reflectx.Int))
+	onTime := typex.PaneInfo{Timing: typex.PaneOnTime, IsFirst: true, IsLast: true}
+	groupKey := func(elms []MainInput) []MainInput {
+		elms[0].Key.Pane = onTime
+		return elms
+	}
+	chains := map[string]func(out Node, flush int) []Unit{
+		"combine": func(out Node, flush int) []Unit {
+			combine := &Combine{UID: 2, Fn: edge.CombineFn, Out: out, FlushCount: flush}
+			n := &FixedRoot{UID: 3, Elements: groupKey(makeKeyedInput(42, intInput...)), Out: combine}
+			return []Unit{n, combine}
+		},
+		"mergeAccumulators": func(out Node, flush int) []Unit {
+			extract := &ExtractOutput{Combine: &Combine{UID: 2, Fn: edge.CombineFn, Out: out}}
+			merge := &MergeAccumulators{Combine: &Combine{UID: 3, Fn: edge.CombineFn, Out: extract, FlushCount: flush}}
+			n := &FixedRoot{UID: 4, Elements: groupKey(makeKeyedInput(42, intInput...)), Out: merge}
+			return []Unit{n, merge, extract}
+		},
+	}
+	early := func(i int64) typex.PaneInfo {
+		return typex.PaneInfo{Timing: typex.PaneEarly, IsFirst: i == 0, Index: i, NonSpeculativeIndex: -1}
+	}
+	final := func(i int64) typex.PaneInfo {
+		return typex.PaneInfo{Timing: typex.PaneOnTime, IsFirst: i == 0, IsLast: true, Index: i}
+	}
+	tests := []struct {
+		flush int
+		want  []interface{}
+		panes []typex.PaneInfo
+	}{
+		{0, []interface{}{21}, []typex.PaneInfo{final(0)}},
+		{2, []interface{}{3, 10, 21, 21}, []typex.PaneInfo{early(0), early(1), early(2), final(3)}},
+		{4, []interface{}{10, 21}, []typex.PaneInfo{early(0), final(1)}},
+		{7, []interface{}{21}, []typex.PaneInfo{final(0)}},
+	}
+	for name, chain := range chains {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%v_%v", name, test.flush), func(t *testing.T) {
+				out := &CaptureNode{UID: 1}
+				constructAndExecutePlan(t, append(chain(out, test.flush), out))
+				if len(out.Elements) != len(test.want) {
+					t.Fatalf("flush count %v = %v, want %v", test.flush, extractKeyedValues(out.Elements...), test.want)
+				}
+				for i, elm := range out.Elements {
+					if elm.Elm != 42 || elm.Elm2 != test.want[i] || elm.Pane != test.panes[i] {
+						t.Errorf("flush count %v: output %v = %v, %v in pane %+v, want 42, %v in pane %+v", test.flush, i, elm.Elm, elm.Elm2, elm.Pane, test.want[i], test.panes[i])
+					}
+				}
+			})
+		}
+	}
+}
+
 // TestConvertToAccumulators verifies that the ConvertToAccumulators phase
 // correctly doesn't accumulate values at all.
 func TestConvertToAccumulators(t *testing.T) {
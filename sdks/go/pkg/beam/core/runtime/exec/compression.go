@@ -0,0 +1,118 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// StreamCodec compresses the data streams of DataSources and DataSinks. The
+// runner passes the compressed bytes through unchanged, so both ends of a
+// stream must use the same codec.
+type StreamCodec interface {
+	// Name returns the name of the codec, such as for errors.
+	Name() string
+	// NewReader returns a reader of the decompressed bytes of r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	// NewWriter returns a writer compressing to w. Closing it must flush
+	// all data to w, but not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// GzipCodec is a StreamCodec using gzip compression at the given Level, or
+// the default compression level if 0.
+type GzipCodec struct {
+	Level int
+}
+
+func (GzipCodec) Name() string {
+	return "gzip"
+}
+
+func (GzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (c GzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// WithCompression makes UnmarshalPlan compress the data streams of the
+// DataSources and DataSinks of the given transforms, by ID, with the codec.
+// Streams of other transforms keep the standard uncompressed encoding.
+func WithCompression(c StreamCodec, transforms ...string) BuildOption {
+	return func(b *builder) {
+		if b.compression == nil {
+			b.compression = make(map[string]StreamCodec)
+		}
+		for _, t := range transforms {
+			b.compression[t] = c
+		}
+	}
+}
+
+// compressedReader decompresses a data stream, and closes both the
+// decompressor and the stream.
+type compressedReader struct {
+	io.ReadCloser
+	stream io.Closer
+}
+
+func newCompressedReader(c StreamCodec, r io.ReadCloser) (io.ReadCloser, error) {
+	cr, err := c.NewReader(r)
+	if err != nil {
+		return nil, errors.WithContextf(err, "opening %v stream", c.Name())
+	}
+	return &compressedReader{ReadCloser: cr, stream: r}, nil
+}
+
+func (r *compressedReader) Close() error {
+	err := r.ReadCloser.Close()
+	if serr := r.stream.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// compressedWriter compresses a data stream. Close flushes the compressor
+// and closes the stream.
+type compressedWriter struct {
+	io.WriteCloser
+	stream io.Closer
+}
+
+func newCompressedWriter(c StreamCodec, w io.WriteCloser) (io.WriteCloser, error) {
+	cw, err := c.NewWriter(w)
+	if err != nil {
+		return nil, errors.WithContextf(err, "opening %v stream", c.Name())
+	}
+	return &compressedWriter{WriteCloser: cw, stream: w}, nil
+}
+
+func (w *compressedWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		w.stream.Close() // ok: the flush error takes precedence.
+		return err
+	}
+	return w.stream.Close()
+}
@@ -0,0 +1,151 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+)
+
+// compressionInput returns a representative mix of KV<string,int> elements,
+// with keys repeating across a thousand users.
+func compressionInput(n int) []FullValue {
+	var ret []FullValue
+	for i := 0; i < n; i++ {
+		ret = append(ret, FullValue{
+			Elm:       fmt.Sprintf("user-%04d", i%1000),
+			Elm2:      int64(i * 7919 % 100000),
+			Timestamp: mtime.FromMilliseconds(int64(i)),
+			Windows:   window.SingleGlobalWindow,
+		})
+	}
+	return ret
+}
+
+var compressionCoder = coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()}), coder.NewGlobalWindow())
+
+func codecName(c StreamCodec) string {
+	if c == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%v-%v", c.Name(), c.(GzipCodec).Level)
+}
+
+// writeStream writes the elements with a DataSink using the codec, and
+// returns the written stream.
+func writeStream(codec StreamCodec, elms []FullValue) (*bytes.Buffer, error) {
+	ctx := context.Background()
+	out := &nopWriteCloser{}
+	sink := &DataSink{UID: 1, Coder: compressionCoder, Codec: codec}
+	if err := sink.Up(ctx); err != nil {
+		return nil, err
+	}
+	if err := sink.StartBundle(ctx, "1", DataContext{Data: &TestDataManager{W: out}}); err != nil {
+		return nil, err
+	}
+	for i := range elms {
+		if err := sink.ProcessElement(ctx, &elms[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := sink.FinishBundle(ctx); err != nil {
+		return nil, err
+	}
+	return &out.Buffer, nil
+}
+
+// TestCompression verifies that streams compressed by a DataSink are read
+// back by a DataSource with the same codec, and that streams without a codec
+// are unchanged.
+func TestCompression(t *testing.T) {
+	elms := compressionInput(5000)
+	plain, err := writeStream(nil, elms)
+	if err != nil {
+		t.Fatalf("writing uncompressed stream failed: %v", err)
+	}
+	for _, codec := range []StreamCodec{nil, GzipCodec{}, GzipCodec{Level: 1}} {
+		t.Run(codecName(codec), func(t *testing.T) {
+			stream, err := writeStream(codec, elms)
+			if err != nil {
+				t.Fatalf("writing stream failed: %v", err)
+			}
+			if codec == nil && !bytes.Equal(stream.Bytes(), plain.Bytes()) {
+				t.Errorf("stream without codec changed")
+			}
+			if codec != nil && stream.Len() >= plain.Len() {
+				t.Errorf("compressed stream has %v bytes, want less than %v", stream.Len(), plain.Len())
+			}
+
+			out := &CaptureNode{UID: 2}
+			source := &DataSource{UID: 3, Coder: compressionCoder, Codec: codec, Out: out}
+			constructAndExecutePlanWithContext(t, []Unit{out, source}, DataContext{
+				Data: &TestDataManager{R: ioutil.NopCloser(stream)},
+			})
+			if !equalList(out.Elements, elms) {
+				t.Errorf("read %v elements, which differ from the %v written", len(out.Elements), len(elms))
+			}
+		})
+	}
+}
+
+// BenchmarkCompression measures the throughput of writing and reading data
+// streams with each codec, relative to the uncompressed stream size, and
+// reports the compression ratio.
+func BenchmarkCompression(b *testing.B) {
+	elms := compressionInput(10000)
+	plain, err := writeStream(nil, elms)
+	if err != nil {
+		b.Fatalf("writing uncompressed stream failed: %v", err)
+	}
+	for _, codec := range []StreamCodec{nil, GzipCodec{Level: 1}, GzipCodec{}} {
+		stream, err := writeStream(codec, elms)
+		if err != nil {
+			b.Fatalf("writing stream failed: %v", err)
+		}
+		name := codecName(codec)
+		b.Run(name+"/write", func(b *testing.B) {
+			b.SetBytes(int64(plain.Len()))
+			for i := 0; i < b.N; i++ {
+				if _, err := writeStream(codec, elms); err != nil {
+					b.Fatalf("writing stream failed: %v", err)
+				}
+			}
+			b.ReportMetric(float64(plain.Len())/float64(stream.Len()), "ratio")
+		})
+		b.Run(name+"/read", func(b *testing.B) {
+			ctx := context.Background()
+			out := &Discard{UID: 2}
+			source := &DataSource{UID: 1, Coder: compressionCoder, Codec: codec, Out: out}
+			b.SetBytes(int64(plain.Len()))
+			for i := 0; i < b.N; i++ {
+				data := DataContext{Data: &TestDataManager{R: ioutil.NopCloser(bytes.NewReader(stream.Bytes()))}}
+				if err := source.StartBundle(ctx, "1", data); err != nil {
+					b.Fatalf("start bundle failed: %v", err)
+				}
+				if err := source.Process(ctx); err != nil {
+					b.Fatalf("reading stream failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// CountAssertError indicates that the number of elements of a bundle passed
+// to a CountAssertNode is out of its bounds.
+type CountAssertError struct {
+	UID      UnitID
+	Count    int
+	Min, Max int
+}
+
+func (e *CountAssertError) Error() string {
+	switch {
+	case e.Min == e.Max:
+		return fmt.Sprintf("node %v got %v element(s) in bundle, want %v", e.UID, e.Count, e.Min)
+	case e.Max < 0:
+		return fmt.Sprintf("node %v got %v element(s) in bundle, want at least %v", e.UID, e.Count, e.Min)
+	default:
+		return fmt.Sprintf("node %v got %v element(s) in bundle, want between %v and %v", e.UID, e.Count, e.Min, e.Max)
+	}
+}
+
+// CountAssertNode wraps a node and counts the elements passed to it, for tests
+// and validation asserting how many elements a stage emits. Once the bundle is
+// finished upstream, it fails with a CountAssertError if fewer than Min or,
+// unless Max is negative, more than Max elements were passed to it, and
+// finishes the wrapped node otherwise. Elements are counted once the wrapped
+// node processed them successfully.
+type CountAssertNode struct {
+	Node
+	Min, Max int
+
+	count int
+}
+
+// NewCountAssertNode returns a node that passes elements to out, and fails
+// bundles with other than expected elements.
+func NewCountAssertNode(out Node, expected int) *CountAssertNode {
+	return &CountAssertNode{Node: out, Min: expected, Max: expected}
+}
+
+// NewCountRangeAssertNode returns a node that passes elements to out, and
+// fails bundles with fewer than min or more than max elements. A negative max
+// sets no upper bound.
+func NewCountRangeAssertNode(out Node, min, max int) *CountAssertNode {
+	return &CountAssertNode{Node: out, Min: min, Max: max}
+}
+
+// Up validates the bounds and brings up the wrapped node.
+func (n *CountAssertNode) Up(ctx context.Context) error {
+	if n.Min < 0 || (n.Max >= 0 && n.Max < n.Min) {
+		return errors.Errorf("invalid bounds for count assert node %v: [%v, %v]", n.ID(), n.Min, n.Max)
+	}
+	return n.Node.Up(ctx)
+}
+
+// StartBundle resets the count and starts the wrapped node.
+func (n *CountAssertNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.count = 0
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement forwards the element to the wrapped node and counts it.
+func (n *CountAssertNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if err := n.Node.ProcessElement(ctx, elm, values...); err != nil {
+		return err
+	}
+	n.count++
+	return nil
+}
+
+// FinishBundle checks the count, and finishes the wrapped node.
+func (n *CountAssertNode) FinishBundle(ctx context.Context) error {
+	if n.count < n.Min || (n.Max >= 0 && n.count > n.Max) {
+		return &CountAssertError{UID: n.ID(), Count: n.count, Min: n.Min, Max: n.Max}
+	}
+	return n.Node.FinishBundle(ctx)
+}
+
+func (n *CountAssertNode) String() string {
+	return fmt.Sprintf("CountAssertNode[%v, %v]. Node:%v", n.Min, n.Max, n.Node)
+}
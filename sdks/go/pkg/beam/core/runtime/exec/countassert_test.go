@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestCountAssertNode verifies that bundles with element counts out of the
+// bounds fail with a CountAssertError, and that others pass.
+func TestCountAssertNode(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max int
+		in       []MainInput
+		wantErr  bool
+	}{
+		{name: "exact", min: 2, max: 2, in: makeInput(1, 2)},
+		{name: "too few", min: 3, max: 3, in: makeInput(1, 2), wantErr: true},
+		{name: "too many", min: 1, max: 1, in: makeInput(1, 2), wantErr: true},
+		{name: "in range", min: 1, max: 3, in: makeInput(1, 2)},
+		{name: "empty", min: 0, max: 0},
+		{name: "unbounded", min: 1, max: -1, in: makeInput(1, 2, 3)},
+		{name: "below unbounded", min: 1, max: -1, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewCountRangeAssertNode(out, test.min, test.max)
+			root := &FixedRoot{UID: 2, Elements: test.in, Out: n}
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+
+			err = p.Execute(context.Background(), "1", DataContext{})
+			var ce *CountAssertError
+			if got := errors.As(err, &ce); got != test.wantErr {
+				t.Fatalf("execute = %v, want CountAssertError: %v", err, test.wantErr)
+			}
+			if test.wantErr && (ce.Count != len(test.in) || ce.UID != 1) {
+				t.Errorf("error = %+v, want count %v at node 1", ce, len(test.in))
+			}
+			if !test.wantErr && len(out.Elements) != len(test.in) {
+				t.Errorf("forwarded %v elements, want %v", len(out.Elements), len(test.in))
+			}
+		})
+	}
+}
+
+// TestNewCountAssertNode verifies that the exact variant fails bundles with
+// another count, and that invalid bounds are rejected.
+func TestNewCountAssertNode(t *testing.T) {
+	ctx := context.Background()
+	n := NewCountAssertNode(&CaptureNode{UID: 1}, 1)
+	if err := n.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	err := n.FinishBundle(ctx)
+	if want := "node 1 got 0 element(s) in bundle, want 1"; err == nil || err.Error() != want {
+		t.Errorf("FinishBundle = %v, want %v", err, want)
+	}
+
+	if err := NewCountRangeAssertNode(&CaptureNode{UID: 1}, 3, 2).Up(ctx); err == nil {
+		t.Errorf("Up with max below min succeeded, want error")
+	}
+}
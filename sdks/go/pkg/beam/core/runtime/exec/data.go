@@ -19,6 +19,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
 )
 
 // Port represents the connection port of external operations.
@@ -42,6 +44,105 @@ func (id StreamID) String() string {
 type DataContext struct {
 	Data  DataManager
 	State StateReader
+
+	// Counters optionally collects per-stream element and byte counts for
+	// the bundle. If nil, nothing is counted.
+	Counters *StreamCounters
+
+	// CacheTokens are the tokens under which state read in the bundle may be
+	// cached across bundles, if enabled with WithStateCache.
+	CacheTokens []CacheToken
+}
+
+// StreamCounters holds running element and byte counts for each data stream
+// of a bundle. It is safe for concurrent use, and may be read after the
+// bundle has finished.
+type StreamCounters struct {
+	mu     sync.Mutex
+	counts map[StreamID]*StreamCount
+}
+
+// For returns the counts for the given stream, creating them if needed.
+// Returns nil if the receiver is nil.
+func (c *StreamCounters) For(id StreamID) *StreamCount {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[StreamID]*StreamCount)
+	}
+	sc, ok := c.counts[id]
+	if !ok {
+		sc = &StreamCount{}
+		c.counts[id] = sc
+	}
+	return sc
+}
+
+// Streams returns the IDs of all streams with counts. Returns nil if the
+// receiver is nil.
+func (c *StreamCounters) Streams() []StreamID {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ret []StreamID
+	for id := range c.counts {
+		ret = append(ret, id)
+	}
+	return ret
+}
+
+// StreamCount holds the running counts of a single data stream. All methods
+// are no-ops on a nil receiver.
+type StreamCount struct {
+	elements, bytes int64
+}
+
+// AddElements increments the element count by n.
+func (c *StreamCount) AddElements(n int64) {
+	if c != nil {
+		atomic.AddInt64(&c.elements, n)
+	}
+}
+
+// AddBytes increments the byte count by n.
+func (c *StreamCount) AddBytes(n int64) {
+	if c != nil {
+		atomic.AddInt64(&c.bytes, n)
+	}
+}
+
+// Elements returns the current element count.
+func (c *StreamCount) Elements() int64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&c.elements)
+}
+
+// Bytes returns the current encoded byte count. Only the encoded values are
+// counted, excluding any framing of the stream.
+func (c *StreamCount) Bytes() int64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&c.bytes)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	count *StreamCount
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count.AddBytes(int64(n))
+	return n, err
 }
 
 // DataManager manages external data byte streams. Each data stream can be
@@ -24,21 +24,103 @@ import (
 	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 )
 
-// DataSink is a Node.
+// WithSinkBatching returns a context in which DataSinks coalesce the encoded
+// elements of a bundle into writes of batchBytes to their stream, instead of
+// writing each element separately, and write the remainder on FinishBundle.
+// Elements larger than batchBytes are written directly, after any batched
+// ones, so the order of elements is kept. Values less than 1 disable
+// batching, which is the default.
+func WithSinkBatching(ctx context.Context, batchBytes int) context.Context {
+	return context.WithValue(ctx, sinkBatchKey, batchBytes)
+}
+
+func getSinkBatching(ctx context.Context) int {
+	v, _ := ctx.Value(sinkBatchKey).(int)
+	return v
+}
+
+// batchedWriter coalesces writes to a data stream into batches of at least
+// size bytes. Unlike a bufio.Writer, it never splits a write across batches,
+// so that elements are not split across the messages of the data channel.
+// Close writes the remaining batch and closes the stream.
+type batchedWriter struct {
+	stream io.WriteCloser
+	size   int
+	buf    []byte
+}
+
+func (w *batchedWriter) Write(p []byte) (int, error) {
+	if len(w.buf) > 0 && len(w.buf)+len(p) > w.size {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) >= w.size {
+		return w.stream.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *batchedWriter) flush() error {
+	_, err := w.stream.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *batchedWriter) Close() error {
+	if len(w.buf) > 0 {
+		if err := w.flush(); err != nil {
+			w.stream.Close() // ok: the write error takes precedence.
+			return err
+		}
+	}
+	return w.stream.Close()
+}
+
+// DataSink is a Node that writes elements to a data stream, encoded as
+// windowed values as determined by Coder, which must be a windowed value
+// coder. Elements without a pane are encoded in the NoFiringPane, unless the
+// bundle is checked for them with WithPaneCheck. If Codec is set, the stream
+// is compressed with it.
 type DataSink struct {
 	UID   UnitID
 	SID   StreamID
 	Coder *coder.Coder
+	Codec StreamCodec
+	// CheckKeys makes the sink verify that the key coder of its KV Coder
+	// encodes the first keys of each bundle deterministically, as they are
+	// grouped by the runner. It is set by UnmarshalPlan for the sinks named
+	// with WithGroupedSinks, if the key coder is not known to be
+	// deterministic.
+	CheckKeys bool
+	// Grouped makes StartBundle fail with a KVCoderError unless Coder is a KV
+	// coder of KeyCoder and ValueCoder, if set. UnmarshalPlan sets them for
+	// the sinks named with WithGroupedSinks, from the coder of their input
+	// PCollection.
+	Grouped              bool
+	KeyCoder, ValueCoder *coder.Coder
+
+	keys *keyCheck
 
 	enc   ElementEncoder
 	wEnc  WindowEncoder
 	w     io.WriteCloser
+	fw    FrameWriter
+	sc    *StreamCount
+	rt    *roundTripper
+	bw    *boundedWriter
+	batch *batchedWriter
 	count int64
 	start time.Time
+
+	checkPane bool
+	checkKV   bool
 }
 
 func (n *DataSink) ID() UnitID {
@@ -48,15 +130,71 @@ func (n *DataSink) ID() UnitID {
 func (n *DataSink) Up(ctx context.Context) error {
 	n.enc = MakeElementEncoder(coder.SkipW(n.Coder))
 	n.wEnc = MakeWindowEncoder(n.Coder.Window)
+	n.keys = nil
+	if n.CheckKeys {
+		kv := coder.SkipW(n.Coder)
+		if !coder.IsKV(kv) {
+			return errors.Errorf("DataSink %v checking keys has no KV coder: %v", n.UID, n.Coder)
+		}
+		n.keys = newKeyCheck(n.SID.PtransformID, kv.Components[0])
+	}
 	return nil
 }
 
 func (n *DataSink) StartBundle(ctx context.Context, id string, data DataContext) error {
+	if n.Grouped {
+		if err := checkKVCoder(n.UID, n.SID.PtransformID, n.Coder, n.KeyCoder, n.ValueCoder); err != nil {
+			return err
+		}
+	}
+	if n.bw != nil {
+		// Stop the writer of a failed bundle that wasn't taken down.
+		n.bw.abort()
+		n.bw = nil
+	}
 	w, err := data.Data.OpenWrite(ctx, n.SID)
 	if err != nil {
 		return err
 	}
+	if size := getSinkBatching(ctx); size > 0 {
+		// The batch buffer is reused across bundles.
+		if n.batch == nil || n.batch.size != size {
+			n.batch = &batchedWriter{size: size, buf: make([]byte, 0, size)}
+		}
+		n.batch.stream, n.batch.buf = w, n.batch.buf[:0]
+		w = n.batch
+	}
+	if n.Codec != nil {
+		cw, err := newCompressedWriter(n.Codec, w)
+		if err != nil {
+			w.Close()
+			return errors.WithContextf(err, "DataSink %v", n.UID)
+		}
+		w = cw
+	}
 	n.w = w
+	n.fw = lookupFramer(n.SID)
+	n.sc = data.Counters.For(n.SID)
+	n.rt = nil
+	if isCoderRoundTripCheck(ctx) {
+		n.rt = &roundTripper{
+			enc:  n.enc,
+			wEnc: n.wEnc,
+			dec:  MakeElementDecoder(coder.SkipW(n.Coder)),
+			wDec: MakeWindowDecoder(n.Coder.Window),
+		}
+	}
+	if max := getSinkBuffer(ctx); max > 0 {
+		// Bind the writer to the stream of this bundle, as an aborted writer
+		// may still be completing a write once the next bundle starts.
+		w, fw := n.w, n.fw
+		n.bw = newBoundedWriter(func(b []byte) error { return writeElement(w, fw, b) }, max)
+	}
+	n.checkPane = isPaneCheck(ctx)
+	n.checkKV = isKVCheck(ctx, n.Coder)
+	if n.keys != nil {
+		n.keys.reset()
+	}
 	atomic.StoreInt64(&n.count, 0)
 	n.start = time.Now()
 	return nil
@@ -68,24 +206,73 @@ func (n *DataSink) ProcessElement(ctx context.Context, value *FullValue, values
 	var b bytes.Buffer
 
 	atomic.AddInt64(&n.count, 1)
-	if err := EncodeWindowedValueHeader(n.wEnc, value.Windows, value.Timestamp, &b); err != nil {
+	if n.checkPane && value.Pane == (typex.PaneInfo{}) {
+		return errors.Errorf("element %v without pane at DataSink %v", value, n.UID)
+	}
+	if n.checkKV && value.Elm2 == nil {
+		return errors.Errorf("KV element %v without value at DataSink %v", value, n.UID)
+	}
+	if n.keys != nil {
+		if err := n.keys.check(value.Elm); err != nil {
+			return errors.WithContextf(err, "DataSink %v", n.UID)
+		}
+	}
+	if err := EncodeWindowedValueHeader(n.wEnc, value.Windows, value.Timestamp, value.Pane, &b); err != nil {
 		return err
 	}
 	if err := n.enc.Encode(value, &b); err != nil {
 		return errors.WithContextf(err, "encoding element %v with coder %v", value, n.enc)
 	}
-	if _, err := n.w.Write(b.Bytes()); err != nil {
+	if n.rt != nil {
+		if err := n.rt.checkEncoded(b.Bytes()); err != nil {
+			return errors.WithContextf(err, "verifying element %v with coder %v", value, n.Coder)
+		}
+	}
+	if n.bw != nil {
+		if err := n.bw.Write(ctx, b.Bytes()); err != nil {
+			return err
+		}
+	} else if err := n.write(b.Bytes()); err != nil {
 		return err
 	}
+	n.sc.AddElements(1)
+	n.sc.AddBytes(int64(b.Len()))
 	return nil
 }
 
+// write writes an encoded element to the stream, framing it if needed.
+func (n *DataSink) write(b []byte) error {
+	return writeElement(n.w, n.fw, b)
+}
+
+// writeElement writes an encoded element to w, framed by fw if not nil.
+func writeElement(w io.Writer, fw FrameWriter, b []byte) error {
+	if fw != nil {
+		return fw.WriteFrame(w, b)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
 func (n *DataSink) FinishBundle(ctx context.Context) error {
 	log.Infof(ctx, "DataSink: %d elements in %d ns", atomic.LoadInt64(&n.count), time.Now().Sub(n.start))
+	if n.bw != nil {
+		err := n.bw.Close()
+		n.bw = nil
+		if err != nil {
+			n.w.Close() // ok: the write error takes precedence.
+			return err
+		}
+	}
 	return n.w.Close()
 }
 
 func (n *DataSink) Down(ctx context.Context) error {
+	if n.bw != nil {
+		// Stop the writer of a failed bundle.
+		n.bw.abort()
+		n.bw = nil
+	}
 	return nil
 }
 
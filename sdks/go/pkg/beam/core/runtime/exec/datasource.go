@@ -26,27 +26,69 @@ import (
 	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/ioutilx"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 )
 
-// DataSource is a Root execution unit.
+// defaultCancelCheckInterval is the default number of elements between
+// checks for context cancellation in DataSource.Process.
+const defaultCancelCheckInterval = 100
+
+// WithCancelCheckInterval returns a context in which sources check for
+// cancellation every k elements. A cancelled context causes the bundle to
+// fail with the context error. Values less than 1 disable the check.
+func WithCancelCheckInterval(ctx context.Context, k int) context.Context {
+	return context.WithValue(ctx, cancelCheckKey, k)
+}
+
+func getCancelCheckInterval(ctx context.Context) int {
+	if k, ok := ctx.Value(cancelCheckKey).(int); ok {
+		return k
+	}
+	return defaultCancelCheckInterval
+}
+
+// DataSource is a Root execution unit. If Codec is set, the data stream is
+// decompressed with it. Unbounded sources pass reported watermarks downstream
+// while no data arrives, see ReportWatermark, and if HeartbeatIdle is
+// positive, also send heartbeats, see WithHeartbeat.
 type DataSource struct {
 	UID   UnitID
 	SID   StreamID
 	Name  string
 	Coder *coder.Coder
+	Codec StreamCodec
 	Out   Node
 
+	Unbounded     bool
+	HeartbeatIdle time.Duration
+
 	source DataManager
 	state  StateReader
+	counts *StreamCount
 	// TODO(lostluck) 2020/02/06: refactor to support more general PCollection metrics on nodes.
 	outputPID string // The index is the output count for the PCollection.
 	index     int64
 	splitIdx  int64
 	start     time.Time
 
+	// sizeHint is the estimated number of elements in the bundle, as last
+	// reported by the runner on a split request, or 0 if unknown.
+	sizeHint int64
+	// exhausted is set once all elements of the bundle have been read.
+	exhausted bool
+	// lastDone is the last fraction returned by ProgressFraction.
+	lastDone float64
+	// watermark is the last watermark reported in the bundle, which is
+	// pending delivery downstream if wmPending is set.
+	watermark mtime.Time
+	wmPending bool
+	// wmWake is signalled by ReportWatermark, to deliver the watermark while
+	// the source waits for data.
+	wmWake chan struct{}
+
 	// su is non-nil if this DataSource feeds directly to a splittable unit,
 	// and receives that splittable unit when it is available for splitting.
 	// While the splittable unit is received, it is blocked from processing
@@ -83,11 +125,18 @@ func (n *DataSource) StartBundle(ctx context.Context, id string, data DataContex
 	n.mu.Lock()
 	n.source = data.Data
 	n.state = data.State
+	n.counts = data.Counters.For(n.SID)
 	n.start = time.Now()
 	n.index = -1
 	n.splitIdx = math.MaxInt64
+	n.sizeHint = 0
+	n.exhausted = false
+	n.lastDone = 0
+	n.watermark = mtime.MinTimestamp
+	n.wmPending = false
+	n.wmWake = make(chan struct{}, 1)
 	n.mu.Unlock()
-	return n.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, n.Out)
 }
 
 // Process opens the data source, reads and decodes data, kicking off element processing.
@@ -96,7 +145,29 @@ func (n *DataSource) Process(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	// The stream may be closed early to stop prefetching, and is closed again
+	// once done.
+	stream := &onceCloser{ReadCloser: r}
+	r = stream
+	if d := getReadTimeout(ctx); d > 0 {
+		r = &deadlineReader{ReadCloser: r, sid: n.SID, timeout: d}
+	}
+	if n.Codec != nil {
+		cr, err := newCompressedReader(n.Codec, r)
+		if err != nil {
+			r.Close()
+			return errors.WithContextf(err, "DataSource %v", n.UID)
+		}
+		r = cr
+	}
 	defer r.Close()
+	if f := lookupFramer(n.SID); f != nil {
+		r = &framedReader{ReadCloser: r, fr: f}
+	}
+	if n.counts != nil {
+		// Count the encoded values without framing, like DataSinks.
+		r = &countingReader{ReadCloser: r, count: n.counts}
+	}
 
 	c := coder.SkipW(n.Coder)
 	wc := MakeWindowDecoder(n.Coder.Window)
@@ -115,41 +186,138 @@ func (n *DataSource) Process(ctx context.Context) error {
 		cp = MakeElementDecoder(c)
 	}
 
-	for {
-		if n.incrementIndexAndCheckSplit() {
-			return nil
+	// In round trip check mode, the bytes of each element are recorded so
+	// they can be compared with the re-encoded element.
+	var rec *recordingReader
+	var rt *roundTripper
+	if isCoderRoundTripCheck(ctx) {
+		rec = &recordingReader{ReadCloser: r}
+		r = rec
+		if cvs == nil {
+			rt = &roundTripper{enc: MakeElementEncoder(c), wEnc: MakeWindowEncoder(n.Coder.Window)}
+		} else {
+			// The key is checked with the windowed value header, and each
+			// value separately as it is decoded from the stream.
+			rt = &roundTripper{enc: MakeElementEncoder(c.Components[0]), wEnc: MakeWindowEncoder(n.Coder.Window)}
+			for i, cv := range cvs {
+				cvs[i] = &checkingDecoder{ElementDecoder: cv, enc: MakeElementEncoder(c.Components[i+1]), rec: rec}
+			}
 		}
-		ws, t, err := DecodeWindowedValueHeader(wc, r)
+	}
+
+	// decode reads and decodes the next element and its value streams, if
+	// any, or returns io.EOF at the end of the data.
+	decode := func() (*FullValue, []ReStream, error) {
+		if rec != nil {
+			rec.reset()
+		}
+		ws, t, pn, err := DecodeWindowedValueHeader(wc, r)
 		if err != nil {
 			if err == io.EOF {
-				return nil
+				return nil, nil, err
 			}
-			return errors.Wrap(err, "source failed")
+			return nil, nil, errors.Wrap(err, "source failed")
 		}
 
 		// Decode key or parallel element.
 		pe, err := cp.Decode(r)
 		if err != nil {
-			return errors.Wrap(err, "source decode failed")
+			return nil, nil, errors.Wrap(err, "source decode failed")
 		}
 		pe.Timestamp = t
 		pe.Windows = ws
+		pe.Pane = pn
+		if rt != nil {
+			if err := rt.check(pe, rec.recorded()); err != nil {
+				return nil, nil, errors.WithContextf(err, "verifying element with coder %v", n.Coder)
+			}
+		}
 
 		var valReStreams []ReStream
 		for _, cv := range cvs {
 			values, err := n.makeReStream(ctx, pe, cv, r)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
 			valReStreams = append(valReStreams, values)
 		}
+		return pe, valReStreams, nil
+	}
+	next := decode
+	depth, idle := getPrefetchDepth(ctx), n.heartbeatIdle()
+	if depth > 0 || n.Unbounded {
+		// Reported watermarks and heartbeats are delivered while the decoding
+		// goroutine waits for data.
+		if depth < 1 {
+			depth = 1
+		}
+		pf := startPrefetch(ctx, decode, depth, stream)
+		defer pf.stop()
+		next = pf.next
+		if n.Unbounded {
+			n.mu.Lock()
+			wake := n.wmWake
+			n.mu.Unlock()
+			next = func() (*FullValue, []ReStream, error) {
+				return pf.nextOr(wake, func() error { return n.deliverWatermark(ctx) }, idle, func() error { return n.heartbeat(ctx) })
+			}
+		}
+	}
+
+	checkEvery := getCancelCheckInterval(ctx)
+	gate := getPauseGate(ctx)
+	deadline := getBundleDeadlineState(ctx)
+	for i := 0; ; i++ {
+		if checkEvery > 0 && i%checkEvery == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+		if n.incrementIndexAndCheckSplit() {
+			n.markExhausted()
+			return n.deliverWatermark(ctx)
+		}
+		pe, valReStreams, err := next()
+		if err != nil {
+			if err == io.EOF {
+				n.markExhausted()
+				return n.deliverWatermark(ctx)
+			}
+			return err
+		}
+		n.counts.AddElements(1)
 
+		if err := n.deliverWatermark(ctx); err != nil {
+			return err
+		}
+		if err := gate.wait(ctx); err != nil {
+			return err
+		}
+		if err := deadline.check(n.UID); err != nil {
+			return err
+		}
 		if err := n.Out.ProcessElement(ctx, pe, valReStreams...); err != nil {
 			return err
 		}
 	}
 }
 
+// onceCloser is a ReadCloser that closes the underlying one only once.
+type onceCloser struct {
+	io.ReadCloser
+	once sync.Once
+	err  error
+}
+
+func (c *onceCloser) Close() error {
+	c.once.Do(func() {
+		c.err = c.ReadCloser.Close()
+	})
+	return c.err
+}
+
 func (n *DataSource) makeReStream(ctx context.Context, key *FullValue, cv ElementDecoder, r io.ReadCloser) (ReStream, error) {
 	size, err := coder.DecodeInt32(r)
 	if err != nil {
@@ -232,7 +400,7 @@ func (n *DataSource) FinishBundle(ctx context.Context) error {
 	log.Infof(ctx, "DataSource: %d elements in %d ns", n.index, time.Now().Sub(n.start))
 	n.source = nil
 	n.splitIdx = 0 // Ensure errors are returned for split requests if this plan is re-used.
-	return n.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, n.Out)
 }
 
 // Down resets the source.
@@ -245,6 +413,55 @@ func (n *DataSource) String() string {
 	return fmt.Sprintf("DataSource[%v, %v] Coder:%v Out:%v", n.SID, n.Name, n.Coder, n.Out.ID())
 }
 
+// ReportWatermark advances the output watermark of the source to wm, for
+// unbounded sources that know no further elements of the bundle have earlier
+// timestamps. The watermark is held for the harness, see Watermark, and passed
+// to Out if it is a WatermarkObserver as soon as the source is waiting for
+// data, and otherwise before the next element is processed or once the input
+// is exhausted, so that downstream windows close even if no elements arrive.
+// It is safe to call concurrently with element processing. Watermarks must not
+// move backwards within a bundle.
+func (n *DataSource) ReportWatermark(wm time.Time) error {
+	t := mtime.FromTime(wm)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if t < n.watermark {
+		return errors.Errorf("watermark %v of DataSource %v is before its previous watermark %v", t, n.UID, n.watermark)
+	}
+	if t > n.watermark {
+		n.watermark, n.wmPending = t, true
+		select {
+		case n.wmWake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Watermark returns the last watermark reported with ReportWatermark in the
+// current bundle, or mtime.MinTimestamp if there is none.
+func (n *DataSource) Watermark() mtime.Time {
+	if n == nil {
+		return mtime.MinTimestamp
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.watermark
+}
+
+// deliverWatermark passes a pending watermark to Out, if it observes
+// watermarks.
+func (n *DataSource) deliverWatermark(ctx context.Context) error {
+	n.mu.Lock()
+	wm, pending := n.watermark, n.wmPending
+	n.wmPending = false
+	n.mu.Unlock()
+	if o, ok := n.Out.(WatermarkObserver); ok && pending {
+		return o.ProcessWatermark(ctx, wm)
+	}
+	return nil
+}
+
 // incrementIndexAndCheckSplit increments DataSource.index by one and checks if
 // the caller should abort further element processing, and finish the bundle.
 // Returns true if the new value of index is greater than or equal to the split
@@ -260,6 +477,63 @@ func (n *DataSource) incrementIndexAndCheckSplit() bool {
 	return b
 }
 
+// markExhausted records that all elements of the bundle have been read.
+func (n *DataSource) markExhausted() {
+	n.mu.Lock()
+	n.exhausted = true
+	n.mu.Unlock()
+}
+
+// ProgressFraction returns the fraction of the bundle's elements that have
+// been completely processed, and the number of elements remaining, or -1 if
+// the number of elements in the bundle is unknown. It is safe to call while
+// the bundle is being processed, and the fraction never decreases within a
+// bundle.
+//
+// The size of the bundle is known once the runner has reported an estimate
+// with a split request, or once a split has bounded it. Until then, the
+// fraction remains 0, and it becomes 1 once all elements have been read.
+func (n *DataSource) ProgressFraction() (done float64, remaining int64) {
+	if n == nil {
+		return 0, -1
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// index is the index of the currently processing element, and so also the
+	// number of completely processed elements.
+	c := n.index
+	if c < 0 {
+		c = 0
+	}
+	total := n.splitIdx
+	if n.sizeHint > 0 && n.sizeHint < total {
+		total = n.sizeHint
+	}
+	switch {
+	case n.exhausted:
+		done, remaining = 1, 0
+	case total == math.MaxInt64:
+		done, remaining = 0, -1
+	default:
+		remaining = total - c
+		if remaining < 0 {
+			remaining = 0
+		}
+		if total > 0 {
+			done = float64(c) / float64(total)
+		}
+	}
+	if done > 1 {
+		done = 1
+	}
+	if done < n.lastDone {
+		done = n.lastDone
+	}
+	n.lastDone = done
+	return done, remaining
+}
+
 // ProgressReportSnapshot captures the progress reading an input source.
 //
 // TODO(lostluck) 2020/02/06: Add a visitor pattern for collecting progress
@@ -286,6 +560,62 @@ func (n *DataSource) Progress() ProgressReportSnapshot {
 	return ProgressReportSnapshot{PID: n.outputPID, ID: n.SID.PtransformID, Name: n.Name, Count: c}
 }
 
+// Split describes a range of elements of a DataSource's input, from Start up
+// to but excluding End, by their index in the bundle. An End of -1 denotes
+// the end of the input.
+type Split struct {
+	TransformID string
+	Start, End  int64
+}
+
+// SplitAtFraction splits the remaining input of the bundle at the element
+// boundary closest to the given fraction of the bundle. The primary is
+// processed by this bundle, while the residual must be processed elsewhere.
+// It is safe to call concurrently with element processing.
+//
+// The bundle size must be known, either from a split request carrying an
+// estimate or from a previous split. It returns an error if the size is
+// unknown, if processing is already past the split point, or if no elements
+// would remain in the residual.
+func (n *DataSource) SplitAtFraction(fraction float64) (primary, residual Split, err error) {
+	if n == nil {
+		return Split{}, Split{}, errors.Errorf("failed to split at fraction %v: DataSource not initialized", fraction)
+	}
+	if fraction < 0 || fraction > 1 {
+		return Split{}, Split{}, errors.Errorf("failed to split %v at fraction %v: want a fraction in [0, 1]", n.SID, fraction)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.source == nil {
+		return Split{}, Split{}, errors.Errorf("failed to split %v at fraction %v: no bundle in progress", n.SID, fraction)
+	}
+	total := n.splitIdx
+	if n.sizeHint > 0 && n.sizeHint < total {
+		total = n.sizeHint
+	}
+	if total == math.MaxInt64 {
+		return Split{}, Split{}, errors.Errorf("failed to split %v at fraction %v: bundle size unknown", n.SID, fraction)
+	}
+	at := int64(math.Ceil(fraction * float64(total)))
+	// The currently processing element, if any, belongs to the primary.
+	if at <= n.index {
+		return Split{}, Split{}, errors.Errorf("failed to split %v at fraction %v: already processing element %v, past split point %v", n.SID, fraction, n.index, at)
+	}
+	if at >= total {
+		return Split{}, Split{}, errors.Errorf("failed to split %v at fraction %v: no elements remain after split point %v", n.SID, fraction, at)
+	}
+
+	end := int64(-1)
+	if n.splitIdx != math.MaxInt64 {
+		end = n.splitIdx
+	}
+	n.splitIdx = at
+	id := n.SID.PtransformID
+	return Split{TransformID: id, Start: 0, End: at}, Split{TransformID: id, Start: at, End: end}, nil
+}
+
 // Split takes a sorted set of potential split indices and a fraction of the
 // remainder to split at, selects and actuates a split on an appropriate split
 // index, and returns the selected split index in a SplitResult if successful or
@@ -313,6 +643,10 @@ func (n *DataSource) Split(splits []int64, frac float64, bufSize int64) (SplitRe
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if bufSize > 0 {
+		n.sizeHint = bufSize
+	}
+
 	var currProg float64 // Current element progress.
 	var su SplittableUnit
 	if n.index < 0 { // Progress is at the end of the non-existant -1st element.
@@ -506,7 +840,7 @@ func splitHelper(
 
 func encodeElm(elm *FullValue, wc WindowEncoder, ec ElementEncoder) ([]byte, error) {
 	var b bytes.Buffer
-	if err := EncodeWindowedValueHeader(wc, elm.Windows, elm.Timestamp, &b); err != nil {
+	if err := EncodeWindowedValueHeader(wc, elm.Windows, elm.Timestamp, elm.Pane, &b); err != nil {
 		return nil, err
 	}
 	if err := ec.Encode(elm, &b); err != nil {
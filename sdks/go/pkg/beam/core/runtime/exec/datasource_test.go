@@ -16,15 +16,20 @@
 package exec
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
 
@@ -43,7 +48,7 @@ func TestDataSource_PerElement(t *testing.T) {
 				wc := MakeWindowEncoder(c.Window)
 				ec := MakeElementEncoder(coder.SkipW(c))
 				for _, v := range expected {
-					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, pw)
+					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), pw)
 					ec.Encode(&FullValue{Elm: v}, pw)
 				}
 				pw.Close()
@@ -72,6 +77,655 @@ func TestDataSource_PerElement(t *testing.T) {
 	}
 }
 
+// nopWriteCloser is an in-memory io.WriteCloser for tests.
+type nopWriteCloser struct {
+	bytes.Buffer
+}
+
+func (w *nopWriteCloser) Close() error {
+	return nil
+}
+
+// TestDataSource_Counters verifies that sources and sinks count the elements
+// and bytes of their streams.
+func TestDataSource_Counters(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	elms := []interface{}{int64(1), int64(2), int64(300)}
+
+	var in bytes.Buffer
+	wc := MakeWindowEncoder(c.Window)
+	ec := MakeElementEncoder(coder.SkipW(c))
+	for _, v := range elms {
+		EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &in)
+		ec.Encode(&FullValue{Elm: v}, &in)
+	}
+	size := int64(in.Len())
+
+	sinkID := StreamID{PtransformID: "mySink"}
+	sourceID := StreamID{PtransformID: "mySource"}
+	sink := &DataSink{UID: 1, SID: sinkID, Coder: c}
+	source := &DataSource{UID: 2, SID: sourceID, Name: "counters", Coder: c, Out: sink}
+
+	counters := &StreamCounters{}
+	out := &nopWriteCloser{}
+	constructAndExecutePlanWithContext(t, []Unit{sink, source}, DataContext{
+		Data:     &TestDataManager{R: ioutil.NopCloser(&in), W: out},
+		Counters: counters,
+	})
+
+	for _, id := range []StreamID{sourceID, sinkID} {
+		sc := counters.For(id)
+		if got, want := sc.Elements(), int64(len(elms)); got != want {
+			t.Errorf("%v element count = %v, want %v", id, got, want)
+		}
+		if got, want := sc.Bytes(), size; got != want {
+			t.Errorf("%v byte count = %v, want %v", id, got, want)
+		}
+	}
+	if got, want := int64(out.Len()), size; got != want {
+		t.Errorf("sink wrote %v bytes, want %v", got, want)
+	}
+	if got, want := len(counters.Streams()), 2; got != want {
+		t.Errorf("counted %v streams, want %v", got, want)
+	}
+}
+
+// TestDataSink_windowedValues verifies that elements written by a sink are
+// read back by a source with the same windows and timestamps.
+func TestDataSink_windowedValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  *coder.WindowCoder
+		windows [][]typex.Window
+	}{
+		{name: "global", window: coder.NewGlobalWindow(), windows: [][]typex.Window{window.SingleGlobalWindow}},
+		{name: "interval", window: coder.NewIntervalWindow(), windows: [][]typex.Window{
+			{window.IntervalWindow{Start: 0, End: 10}},
+			{window.IntervalWindow{Start: 5, End: 15}, window.IntervalWindow{Start: 10, End: 20}},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := coder.NewW(coder.NewVarInt(), test.window)
+			var in []MainInput
+			var want []FullValue
+			for i, ws := range test.windows {
+				v := FullValue{Elm: int64(i), Timestamp: mtime.FromMilliseconds(int64(i + 1)), Windows: ws}
+				in = append(in, MainInput{Key: v})
+				want = append(want, v)
+			}
+
+			out := &nopWriteCloser{}
+			sink := &DataSink{UID: 1, Coder: c}
+			root := &FixedRoot{UID: 2, Elements: in, Out: sink}
+			p, err := NewPlan("sink", []Unit{root, sink})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{Data: &TestDataManager{W: out}}); err != nil {
+				t.Fatalf("execute sink failed: %v", err)
+			}
+
+			capture := &CaptureNode{UID: 3}
+			source := &DataSource{UID: 4, Coder: c, Out: capture}
+			constructAndExecutePlanWithContext(t, []Unit{capture, source}, DataContext{
+				Data: &TestDataManager{R: ioutil.NopCloser(&out.Buffer)},
+			})
+			if !equalList(capture.Elements, want) {
+				t.Errorf("source read %v, want %v", capture.Elements, want)
+			}
+		})
+	}
+}
+
+// countingWriteCloser is an in-memory io.WriteCloser that counts the writes
+// to it.
+type countingWriteCloser struct {
+	nopWriteCloser
+	writes int
+}
+
+func (w *countingWriteCloser) Write(p []byte) (int, error) {
+	w.writes++
+	return w.nopWriteCloser.Write(p)
+}
+
+// TestDataSink_batching verifies that batching sinks coalesce small elements
+// into fewer writes, write large elements whole and in order, and write the
+// remainder on FinishBundle.
+func TestDataSink_batching(t *testing.T) {
+	c := coder.NewW(coder.NewBytes(), coder.NewGlobalWindow())
+	var elms []interface{}
+	for i := 0; i < 20; i++ {
+		elms = append(elms, []byte(fmt.Sprintf("element-%02d", i)))
+	}
+	elms[10] = bytes.Repeat([]byte("x"), 100)
+
+	run := func(ctx context.Context) *countingWriteCloser {
+		out := &countingWriteCloser{}
+		sink := &DataSink{UID: 1, Coder: c}
+		root := &FixedRoot{UID: 2, Elements: makeInput(elms...), Out: sink}
+		p, err := NewPlan("sink", []Unit{root, sink})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(ctx, "1", DataContext{Data: &TestDataManager{W: out}}); err != nil {
+			t.Fatalf("execute sink failed: %v", err)
+		}
+		return out
+	}
+	plain := run(context.Background())
+	batched := run(WithSinkBatching(context.Background(), 64))
+	if !bytes.Equal(batched.Bytes(), plain.Bytes()) {
+		t.Errorf("batched stream differs from unbatched stream")
+	}
+	// The 24 byte elements are batched in pairs around the large one, which
+	// is written whole, and the last one is written on FinishBundle.
+	if got, want := batched.writes, 11; got != want {
+		t.Errorf("batched sink wrote %v times, want %v", got, want)
+	}
+	if got, want := plain.writes, len(elms); got != want {
+		t.Errorf("unbatched sink wrote %v times, want %v", got, want)
+	}
+}
+
+// BenchmarkDataSink_batching measures the number of writes to the stream
+// of a sink writing small elements, with and without batching.
+func BenchmarkDataSink_batching(b *testing.B) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	elms := makeValues(int64(1), int64(2), int64(3), int64(4))
+	for _, size := range []int{0, 4 << 10, 64 << 10} {
+		b.Run(fmt.Sprintf("batch-%v", size), func(b *testing.B) {
+			ctx := WithSinkBatching(context.Background(), size)
+			out := &countingWriteCloser{}
+			sink := &DataSink{UID: 1, Coder: c}
+			if err := sink.Up(ctx); err != nil {
+				b.Fatalf("up failed: %v", err)
+			}
+			if err := sink.StartBundle(ctx, "1", DataContext{Data: &TestDataManager{W: out}}); err != nil {
+				b.Fatalf("start bundle failed: %v", err)
+			}
+			for i := 0; i < b.N; i++ {
+				if err := sink.ProcessElement(ctx, &elms[i%len(elms)]); err != nil {
+					b.Fatalf("process element failed: %v", err)
+				}
+				if out.Len() > 1<<20 {
+					out.Reset()
+				}
+			}
+			if err := sink.FinishBundle(ctx); err != nil {
+				b.Fatalf("finish bundle failed: %v", err)
+			}
+			b.ReportMetric(float64(out.writes)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+// TestDataSource_RoundTripCheck verifies that the coder round trip check
+// passes canonical encodings and fails on encodings that don't survive a
+// decode/encode cycle.
+func TestDataSource_RoundTripCheck(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	wc := MakeWindowEncoder(c.Window)
+	ec := MakeElementEncoder(coder.SkipW(c))
+	encode := func(elms ...interface{}) *bytes.Buffer {
+		var in bytes.Buffer
+		for _, v := range elms {
+			EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &in)
+			ec.Encode(&FullValue{Elm: v}, &in)
+		}
+		return &in
+	}
+	run := func(in io.Reader) error {
+		sink := &DataSink{UID: 1, SID: StreamID{PtransformID: "mySink"}, Coder: c}
+		source := &DataSource{UID: 2, SID: StreamID{PtransformID: "mySource"}, Name: "roundtrip", Coder: c, Out: sink}
+		p, err := NewPlan("a", []Unit{sink, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		ctx := WithCoderRoundTripCheck(context.Background())
+		err = p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: ioutil.NopCloser(in), W: &nopWriteCloser{}}})
+		p.Down(ctx)
+		return err
+	}
+
+	t.Run("canonical", func(t *testing.T) {
+		if err := run(encode(int64(1), int64(300))); err != nil {
+			t.Errorf("execute failed: %v", err)
+		}
+	})
+	t.Run("mismatch", func(t *testing.T) {
+		in := encode(int64(1))
+		// A non-canonical varint encoding of 2, which re-encodes as 0x02.
+		EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), in)
+		in.Write([]byte{0x82, 0x00})
+
+		err := run(in)
+		if err == nil {
+			t.Fatal("execute succeeded, want round trip mismatch")
+		}
+		for _, want := range []string{"round trip mismatch", "in windows [[*]]", "82 00"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("execute error = %v, want it to contain %q", err, want)
+			}
+		}
+	})
+}
+
+// TestDataSource_RoundTripCheckCoGBK verifies that the coder round trip check
+// covers both the keys and the values of CoGBKs.
+func TestDataSource_RoundTripCheckCoGBK(t *testing.T) {
+	c := coder.NewW(coder.NewCoGBK([]*coder.Coder{coder.NewVarInt(), coder.NewVarInt()}), coder.NewGlobalWindow())
+	wc := MakeWindowEncoder(c.Window)
+	kc := MakeElementEncoder(coder.SkipW(c).Components[0])
+	header := func(in *bytes.Buffer) {
+		EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), in)
+	}
+	run := func(in io.Reader) error {
+		out := &IteratorCaptureNode{CaptureNode: CaptureNode{UID: 1}}
+		source := &DataSource{UID: 2, SID: StreamID{PtransformID: "mySource"}, Name: "roundtrip", Coder: c, Out: out}
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		ctx := WithCoderRoundTripCheck(context.Background())
+		err = p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: ioutil.NopCloser(in)}})
+		p.Down(ctx)
+		return err
+	}
+
+	t.Run("canonical", func(t *testing.T) {
+		var in bytes.Buffer
+		header(&in)
+		kc.Encode(&FullValue{Elm: int64(1)}, &in)
+		coder.EncodeInt32(2, &in)
+		in.Write([]byte{0x01, 0xac, 0x02}) // 1, 300
+		if err := run(&in); err != nil {
+			t.Errorf("execute failed: %v", err)
+		}
+	})
+	t.Run("key mismatch", func(t *testing.T) {
+		var in bytes.Buffer
+		header(&in)
+		in.Write([]byte{0x82, 0x00}) // A non-canonical varint encoding of 2.
+		coder.EncodeInt32(1, &in)
+		in.Write([]byte{0x01})
+		if err := run(&in); err == nil || !strings.Contains(err.Error(), "round trip mismatch") {
+			t.Errorf("execute error = %v, want round trip mismatch", err)
+		}
+	})
+	t.Run("value mismatch", func(t *testing.T) {
+		var in bytes.Buffer
+		header(&in)
+		kc.Encode(&FullValue{Elm: int64(1)}, &in)
+		coder.EncodeInt32(2, &in)
+		in.Write([]byte{0x01, 0x82, 0x00})
+		err := run(&in)
+		if err == nil {
+			t.Fatal("execute succeeded, want round trip mismatch")
+		}
+		for _, want := range []string{"round trip mismatch", "82 00"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("execute error = %v, want it to contain %q", err, want)
+			}
+		}
+	})
+}
+
+// progressNode is a test Node that records the progress of a DataSource for
+// each element.
+type progressNode struct {
+	CaptureNode
+	source    *DataSource
+	done      []float64
+	remaining []int64
+}
+
+func (n *progressNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	d, r := n.source.ProgressFraction()
+	n.done = append(n.done, d)
+	n.remaining = append(n.remaining, r)
+	return n.CaptureNode.ProcessElement(ctx, elm, values...)
+}
+
+// TestDataSource_ProgressFraction verifies that the done fraction grows
+// monotonically, and that remaining elements are reported once the bundle
+// size is known.
+func TestDataSource_ProgressFraction(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	var in bytes.Buffer
+	wc := MakeWindowEncoder(c.Window)
+	ec := MakeElementEncoder(coder.SkipW(c))
+	for _, v := range []int64{1, 2, 3} {
+		EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &in)
+		ec.Encode(&FullValue{Elm: v}, &in)
+	}
+
+	out := &progressNode{CaptureNode: CaptureNode{UID: 1}}
+	source := &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c, Out: out}
+	out.source = source
+	constructAndExecutePlanWithContext(t, []Unit{out, source}, DataContext{
+		Data: &TestDataManager{R: ioutil.NopCloser(&in)},
+	})
+	for i := range out.done {
+		if out.done[i] != 0 || out.remaining[i] != -1 {
+			t.Errorf("ProgressFraction() at element %v = (%v, %v), want (0, -1)", i, out.done[i], out.remaining[i])
+		}
+	}
+	if d, r := source.ProgressFraction(); d != 1 || r != 0 {
+		t.Errorf("ProgressFraction() after bundle = (%v, %v), want (1, 0)", d, r)
+	}
+
+	// Once the size is known, progress follows the index.
+	source.exhausted = false
+	source.lastDone = 0
+	source.splitIdx = math.MaxInt64
+	source.sizeHint = 4
+	source.index = 1
+	if d, r := source.ProgressFraction(); d != 0.25 || r != 3 {
+		t.Errorf("ProgressFraction() with size hint = (%v, %v), want (0.25, 3)", d, r)
+	}
+	// A split bounds the size, which never moves progress backwards.
+	source.splitIdx = 2
+	if d, r := source.ProgressFraction(); d != 0.5 || r != 1 {
+		t.Errorf("ProgressFraction() after split = (%v, %v), want (0.5, 1)", d, r)
+	}
+	source.splitIdx = 8
+	source.sizeHint = 0
+	if d, _ := source.ProgressFraction(); d != 0.5 {
+		t.Errorf("ProgressFraction() after size increase = %v, want 0.5", d)
+	}
+}
+
+// splitNode is a test Node that splits its DataSource at a fraction when it
+// receives the element with the given index.
+type splitNode struct {
+	CaptureNode
+	source   *DataSource
+	size     int64
+	at       int
+	fraction float64
+
+	primary, residual Split
+	err               error
+}
+
+func (n *splitNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(n.Elements) == n.at {
+		if n.size > 0 {
+			n.source.mu.Lock()
+			n.source.sizeHint = n.size
+			n.source.mu.Unlock()
+		}
+		n.primary, n.residual, n.err = n.source.SplitAtFraction(n.fraction)
+	}
+	return n.CaptureNode.ProcessElement(ctx, elm, values...)
+}
+
+// TestDataSource_SplitAtFraction verifies that splitting mid-bundle neither
+// drops nor duplicates elements, and that invalid splits fail.
+func TestDataSource_SplitAtFraction(t *testing.T) {
+	elements := []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6)}
+	tests := []struct {
+		name     string
+		size     int64
+		at       int
+		fraction float64
+		wantErr  bool
+		wantAt   int64
+	}{
+		{name: "half", size: 6, at: 1, fraction: 0.5, wantAt: 3},
+		{name: "rounded", size: 6, at: 0, fraction: 0.1, wantAt: 1},
+		{name: "pastFraction", size: 6, at: 3, fraction: 0.5, wantErr: true},
+		{name: "nothingRemains", size: 6, at: 0, fraction: 1, wantErr: true},
+		{name: "unknownSize", at: 0, fraction: 0.5, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+			var in bytes.Buffer
+			wc := MakeWindowEncoder(c.Window)
+			ec := MakeElementEncoder(coder.SkipW(c))
+			for _, v := range elements {
+				EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &in)
+				ec.Encode(&FullValue{Elm: v}, &in)
+			}
+
+			out := &splitNode{CaptureNode: CaptureNode{UID: 1}, size: test.size, at: test.at, fraction: test.fraction}
+			source := &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c, Out: out}
+			out.source = source
+			constructAndExecutePlanWithContext(t, []Unit{out, source}, DataContext{
+				Data: &TestDataManager{R: ioutil.NopCloser(&in)},
+			})
+
+			if test.wantErr {
+				if out.err == nil {
+					t.Errorf("SplitAtFraction(%v) = (%v, %v), want error", test.fraction, out.primary, out.residual)
+				}
+				if got, want := len(out.Elements), len(elements); got != want {
+					t.Errorf("failed split processed %v elements, want %v", got, want)
+				}
+				return
+			}
+			if out.err != nil {
+				t.Fatalf("SplitAtFraction(%v) failed: %v", test.fraction, out.err)
+			}
+			wantPrimary := Split{TransformID: "myPTransform", Start: 0, End: test.wantAt}
+			wantResidual := Split{TransformID: "myPTransform", Start: test.wantAt, End: -1}
+			if out.primary != wantPrimary || out.residual != wantResidual {
+				t.Errorf("SplitAtFraction(%v) = (%v, %v), want (%v, %v)", test.fraction, out.primary, out.residual, wantPrimary, wantResidual)
+			}
+			if want := makeValues(elements[:test.wantAt]...); !equalList(out.Elements, want) {
+				t.Errorf("primary processed %v, want %v", extractValues(out.Elements...), extractValues(want...))
+			}
+		})
+	}
+}
+
+// cancelNode is a test Node that cancels the context after a number of
+// elements.
+type cancelNode struct {
+	CaptureNode
+	after  int
+	cancel context.CancelFunc
+}
+
+func (n *cancelNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if err := n.CaptureNode.ProcessElement(ctx, elm, values...); err != nil {
+		return err
+	}
+	if len(n.Elements) == n.after {
+		n.cancel()
+	}
+	return nil
+}
+
+// TestDataSource_Cancel verifies that a cancelled context stops processing
+// within the configured check interval.
+func TestDataSource_Cancel(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	var elms []interface{}
+	for i := int64(0); i < 20; i++ {
+		elms = append(elms, i)
+	}
+
+	ctx, cancel := context.WithCancel(WithCancelCheckInterval(context.Background(), 4))
+	defer cancel()
+	out := &cancelNode{CaptureNode: CaptureNode{UID: 1}, after: 5, cancel: cancel}
+	source := &DataSource{
+		UID:   2,
+		SID:   StreamID{PtransformID: "myPTransform"},
+		Name:  "cancel",
+		Coder: c,
+		Out:   out,
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		wc := MakeWindowEncoder(c.Window)
+		ec := MakeElementEncoder(coder.SkipW(c))
+		for _, v := range elms {
+			EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), pw)
+			ec.Encode(&FullValue{Elm: v}, pw)
+		}
+		pw.Close()
+	}()
+	defer pr.Close()
+
+	p, err := NewPlan("a", []Unit{out, source})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	err = p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: pr}})
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Fatalf("execute with cancelled context = %v, want %v", err, context.Canceled)
+	}
+	if got, want := len(out.Elements), 8; got != want {
+		t.Errorf("processed %v elements before cancellation, want %v", got, want)
+	}
+}
+
+// fixedWindowNode is a test Node that counts elements in fixed windows of
+// Size, and passes the counts to Out as the watermark passes the windows.
+type fixedWindowNode struct {
+	UID  UnitID
+	Size time.Duration
+	Out  Node
+
+	counts map[window.IntervalWindow]int64
+}
+
+func (n *fixedWindowNode) ID() UnitID                   { return n.UID }
+func (n *fixedWindowNode) Up(ctx context.Context) error { return nil }
+func (n *fixedWindowNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.counts = make(map[window.IntervalWindow]int64)
+	return n.Out.StartBundle(ctx, id, data)
+}
+func (n *fixedWindowNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	start := elm.Timestamp.Subtract(time.Duration(elm.Timestamp.Milliseconds()%n.Size.Milliseconds()) * time.Millisecond)
+	n.counts[window.IntervalWindow{Start: start, End: start.Add(n.Size)}]++
+	return nil
+}
+func (n *fixedWindowNode) ProcessWatermark(ctx context.Context, wm mtime.Time) error {
+	for w, c := range n.counts {
+		if w.MaxTimestamp() < wm {
+			delete(n.counts, w)
+			if err := n.Out.ProcessElement(ctx, &FullValue{Elm: c, Timestamp: w.MaxTimestamp(), Windows: []typex.Window{w}}); err != nil {
+				return err
+			}
+		}
+	}
+	return MultiProcessWatermark(ctx, wm, n.Out)
+}
+func (n *fixedWindowNode) FinishBundle(ctx context.Context) error { return n.Out.FinishBundle(ctx) }
+func (n *fixedWindowNode) Down(ctx context.Context) error         { return nil }
+
+// TestDataSource_ReportWatermark verifies that a watermark reported while the
+// source awaits data fires downstream windows, is held for the harness, and
+// cannot move backwards.
+func TestDataSource_ReportWatermark(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	out := &CaptureNode{UID: 1}
+	windows := &fixedWindowNode{UID: 2, Size: 10 * time.Second, Out: out}
+	source := &DataSource{
+		UID:   3,
+		SID:   StreamID{PtransformID: "myPTransform"},
+		Name:  "watermark",
+		Coder: c,
+		Out:   windows,
+	}
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	wc := MakeWindowEncoder(c.Window)
+	ec := MakeElementEncoder(coder.SkipW(c))
+	write := func(ts ...time.Duration) {
+		for _, d := range ts {
+			EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.FromDuration(d), typex.NoFiringPane(), pw)
+			ec.Encode(&FullValue{Elm: int64(d)}, pw)
+		}
+	}
+	errs := make(chan error, 2)
+	go func() {
+		write(time.Second, 5*time.Second)
+		// The source emits only a watermark advance until the last element.
+		errs <- source.ReportWatermark(time.Unix(10, 0))
+		errs <- source.ReportWatermark(time.Unix(5, 0))
+		write(15 * time.Second)
+		pw.Close()
+	}()
+
+	p, err := NewPlan("a", []Unit{out, windows, source})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{Data: &TestDataManager{R: pr}}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("ReportWatermark(10s) failed: %v", err)
+	}
+	if err := <-errs; err == nil {
+		t.Errorf("ReportWatermark(5s) after 10s succeeded, want error")
+	}
+	want := []FullValue{{
+		Elm:       int64(2),
+		Timestamp: mtime.FromDuration(10 * time.Second).Subtract(time.Millisecond),
+		Windows:   []typex.Window{window.IntervalWindow{Start: 0, End: mtime.FromDuration(10 * time.Second)}},
+	}}
+	if !equalList(out.Elements, want) {
+		t.Errorf("fired windows = %v, want %v", out.Elements, want)
+	}
+	if wm, ok := p.Watermark(); !ok || wm != mtime.FromDuration(10*time.Second) {
+		t.Errorf("plan watermark = %v, %v, want %v, true", wm, ok, mtime.FromDuration(10*time.Second))
+	}
+}
+
+// TestDataSource_ReportWatermarkWaiting verifies that an unbounded source
+// passes a reported watermark downstream while it waits for data, without
+// heartbeats.
+func TestDataSource_ReportWatermarkWaiting(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	out := &watermarkNode{CaptureNode: CaptureNode{UID: 1}, wms: make(chan mtime.Time, 1)}
+	source := &DataSource{
+		UID:       2,
+		SID:       StreamID{PtransformID: "myPTransform"},
+		Coder:     c,
+		Out:       out,
+		Unbounded: true,
+	}
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	waiting := make(chan error, 1)
+	go func() {
+		defer pw.Close()
+		pw.Write(encodeElements(t, c, int64(1)).Bytes())
+		if err := source.ReportWatermark(time.Unix(10, 0)); err != nil {
+			waiting <- err
+			return
+		}
+		select {
+		case wm := <-out.wms:
+			if want := mtime.FromDuration(10 * time.Second); wm != want {
+				waiting <- errors.Errorf("watermark = %v, want %v", wm, want)
+				return
+			}
+			waiting <- nil
+		case <-time.After(5 * time.Second):
+			waiting <- errors.New("watermark wasn't delivered while waiting for data")
+		}
+	}()
+
+	p, err := NewPlan("a", []Unit{out, source})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{Data: &TestDataManager{R: pr}}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := <-waiting; err != nil {
+		t.Error(err)
+	}
+}
+
 const tokenString = "token"
 
 // TestDataSource_Iterators per wire protocols for ITERABLEs beam_runner_api.proto
@@ -98,7 +752,7 @@ func TestDataSource_Iterators(t *testing.T) {
 			driver: func(c *coder.Coder, dmw io.WriteCloser, _ func() io.WriteCloser, ks, vs []interface{}) {
 				wc, kc, vc := extractCoders(c)
 				for _, k := range ks {
-					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, dmw)
+					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), dmw)
 					kc.Encode(&FullValue{Elm: k}, dmw)
 					coder.EncodeInt32(int32(len(vs)), dmw) // Number of elements.
 					for _, v := range vs {
@@ -116,7 +770,7 @@ func TestDataSource_Iterators(t *testing.T) {
 			driver: func(c *coder.Coder, dmw io.WriteCloser, _ func() io.WriteCloser, ks, vs []interface{}) {
 				wc, kc, vc := extractCoders(c)
 				for _, k := range ks {
-					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, dmw)
+					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), dmw)
 					kc.Encode(&FullValue{Elm: k}, dmw)
 
 					coder.EncodeInt32(-1, dmw) // Mark this as a multi-Chunk (though beam runner proto says to use 0)
@@ -137,7 +791,7 @@ func TestDataSource_Iterators(t *testing.T) {
 			driver: func(c *coder.Coder, dmw io.WriteCloser, swFn func() io.WriteCloser, ks, vs []interface{}) {
 				wc, kc, vc := extractCoders(c)
 				for _, k := range ks {
-					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, dmw)
+					EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), dmw)
 					kc.Encode(&FullValue{Elm: k}, dmw)
 					coder.EncodeInt32(-1, dmw)  // Mark as multi-chunk (though beam, runner says to use 0)
 					coder.EncodeVarInt(-1, dmw) // Mark subsequent chunks as "state backed"
@@ -225,7 +879,7 @@ func TestDataSource_Split(t *testing.T) {
 			wc := MakeWindowEncoder(c.Window)
 			ec := MakeElementEncoder(coder.SkipW(c))
 			for _, v := range elements {
-				EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, pw)
+				EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), pw)
 				ec.Encode(&FullValue{Elm: v}, pw)
 			}
 			pw.Close()
@@ -837,6 +1491,7 @@ func runOnRoots(ctx context.Context, t *testing.T, p *Plan, name string, mthd fu
 
 type TestDataManager struct {
 	R io.ReadCloser
+	W io.WriteCloser
 }
 
 func (dm *TestDataManager) OpenRead(ctx context.Context, id StreamID) (io.ReadCloser, error) {
@@ -844,7 +1499,7 @@ func (dm *TestDataManager) OpenRead(ctx context.Context, id StreamID) (io.ReadCl
 }
 
 func (dm *TestDataManager) OpenWrite(ctx context.Context, id StreamID) (io.WriteCloser, error) {
-	return nil, nil
+	return dm.W, nil
 }
 
 // TestSideInputReader simulates state reads using channels.
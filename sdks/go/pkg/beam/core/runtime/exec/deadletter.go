@@ -0,0 +1,87 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeadLetter is the payload passed to the dead-letter node for an element
+// that failed processing.
+type DeadLetter struct {
+	// Element is the original element.
+	Element *FullValue
+	// Error is the message of the processing error.
+	Error string
+	// DoFn is the name of the failing DoFn, or "" if the error did not
+	// originate in a DoFn.
+	DoFn string
+}
+
+// DeadLetterNode wraps a node and routes elements that fail processing with an
+// error matching Predicate to the Dead node, instead of failing the bundle.
+// Dead receives a FullValue with a *DeadLetter as its element, in the windows
+// and at the timestamp of the original element. Errors not matching Predicate
+// fail the bundle as usual. Like any other output, Dead is brought up and down
+// as a unit of the plan. The wrapped node must remain usable after a failed
+// element.
+type DeadLetterNode struct {
+	Node
+	Dead      Node
+	Predicate func(error) bool
+}
+
+// NewDeadLetterNode returns a node that passes elements to main, and routes
+// those that fail with an error matching predicate to dead.
+func NewDeadLetterNode(main, dead Node, predicate func(error) bool) *DeadLetterNode {
+	return &DeadLetterNode{Node: main, Dead: dead, Predicate: predicate}
+}
+
+// Up brings up the wrapped node. A wrapped ParDo remains usable after elements
+// failing with an error matching Predicate.
+func (n *DeadLetterNode) Up(ctx context.Context) error {
+	recoverOn(n.Node, n.Predicate)
+	return n.Node.Up(ctx)
+}
+
+// StartBundle starts the wrapped and the dead-letter nodes.
+func (n *DeadLetterNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return MultiStartBundle(ctx, id, data, n.Node, n.Dead)
+}
+
+// ProcessElement forwards the element to the wrapped node, and to the
+// dead-letter node if it fails with a matching error.
+func (n *DeadLetterNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	err := n.Node.ProcessElement(ctx, elm, values...)
+	if err == nil || !n.Predicate(err) {
+		return err
+	}
+	dl := &DeadLetter{Element: elm, Error: err.Error()}
+	if e, ok := AsDoFnError(err); ok {
+		dl.DoFn = e.doFn
+	}
+	return n.Dead.ProcessElement(ctx, &FullValue{Elm: dl, Timestamp: elm.Timestamp, Windows: elm.Windows, Pane: elm.Pane})
+}
+
+// FinishBundle finishes the wrapped and the dead-letter nodes.
+func (n *DeadLetterNode) FinishBundle(ctx context.Context) error {
+	return MultiFinishBundle(ctx, n.Node, n.Dead)
+}
+
+func (n *DeadLetterNode) String() string {
+	return fmt.Sprintf("DeadLetterNode[Dead:%v]. Node:%v", n.Dead.ID(), n.Node)
+}
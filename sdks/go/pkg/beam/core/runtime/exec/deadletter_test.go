@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// poisonNode is a test Node that fails on a specific element.
+type poisonNode struct {
+	CaptureNode
+	poison interface{}
+	err    error
+}
+
+func (n *poisonNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if elm.Elm == n.poison {
+		return n.err
+	}
+	return n.CaptureNode.ProcessElement(ctx, elm, values...)
+}
+
+// TestDeadLetterNode verifies that matching failures are routed to the
+// dead-letter node, and that others fail the bundle.
+func TestDeadLetterNode(t *testing.T) {
+	poison := errors.New("poison")
+	isPoison := func(err error) bool { return strings.Contains(err.Error(), "poison") }
+	tests := []struct {
+		name     string
+		err      error
+		wantDoFn string
+	}{
+		{name: "plain", err: poison},
+		{name: "doFn", err: &doFnError{doFn: "myFn", err: poison, uid: 1}, wantDoFn: "myFn"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			main := &poisonNode{CaptureNode: CaptureNode{UID: 1}, poison: 2, err: test.err}
+			dead := &CaptureNode{UID: 2}
+			n := NewDeadLetterNode(main, dead, isPoison)
+			root := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: n}
+
+			p, err := NewPlan("a", []Unit{root, n, dead})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if want := makeValues(1, 3); !equalList(main.Elements, want) {
+				t.Errorf("main node got %v, want %v", extractValues(main.Elements...), extractValues(want...))
+			}
+			if len(dead.Elements) != 1 {
+				t.Fatalf("dead-letter node got %v elements, want 1", len(dead.Elements))
+			}
+			dl := dead.Elements[0].Elm.(*DeadLetter)
+			if got, want := dl.Element.Elm, 2; got != want {
+				t.Errorf("dead letter element = %v, want %v", got, want)
+			}
+			if !strings.Contains(dl.Error, "poison") {
+				t.Errorf("dead letter error = %q, want it to contain \"poison\"", dl.Error)
+			}
+			if dl.DoFn != test.wantDoFn {
+				t.Errorf("dead letter DoFn = %q, want %q", dl.DoFn, test.wantDoFn)
+			}
+		})
+	}
+
+	t.Run("unmatched", func(t *testing.T) {
+		main := &poisonNode{CaptureNode: CaptureNode{UID: 1}, poison: 2, err: errors.New("other")}
+		dead := &CaptureNode{UID: 2}
+		n := NewDeadLetterNode(main, dead, isPoison)
+		root := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: n}
+
+		p, err := NewPlan("a", []Unit{root, n, dead})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(context.Background(), "1", DataContext{}); err == nil || !strings.Contains(err.Error(), "other") {
+			t.Errorf("execute = %v, want error \"other\"", err)
+		}
+		if len(dead.Elements) != 0 {
+			t.Errorf("dead-letter node got %v elements, want 0", len(dead.Elements))
+		}
+	})
+}
+
+// poisonFn is a DoFn that fails on the element 2.
+type poisonFn struct{}
+
+func (fn *poisonFn) ProcessElement(v int, emit func(int)) error {
+	if v == 2 {
+		return errors.New("poison")
+	}
+	emit(v)
+	return nil
+}
+
+// TestDeadLetterNode_parDo verifies that a ParDo failing on an element routed
+// to the dead-letter node keeps processing the following elements.
+func TestDeadLetterNode_parDo(t *testing.T) {
+	dfn, err := graph.NewDoFn(&poisonFn{}, graph.NumMainInputs(graph.MainSingle))
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	dead := &CaptureNode{UID: 2}
+	isPoison := func(err error) bool { return strings.Contains(err.Error(), "poison") }
+	n := NewDeadLetterNode(&ParDo{UID: 3, Fn: dfn, Out: []Node{out}}, dead, isPoison)
+	root := &FixedRoot{UID: 4, Elements: makeInput(1, 2, 3, 2, 4), Out: n}
+
+	p, err := NewPlan("a", []Unit{root, n, out, dead})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(1, 3, 4); !equalList(out.Elements, want) {
+		t.Errorf("ParDo emitted %v, want %v", extractValues(out.Elements...), extractValues(want...))
+	}
+	if got, want := len(dead.Elements), 2; got != want {
+		t.Fatalf("dead-letter node got %v elements, want %v", got, want)
+	}
+	for _, e := range dead.Elements {
+		if dl := e.Elm.(*DeadLetter); dl.Element.Elm != 2 || dl.DoFn != dfn.Name() {
+			t.Errorf("dead letter = %+v, want element 2 from DoFn %v", dl, dfn.Name())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// BundleDeadlineExceeded indicates that a bundle took longer than the
+// deadline set with WithBundleDeadline. The bundle is abandoned at the next
+// node boundary, for the runner to split and retry the remaining work.
+type BundleDeadlineExceeded struct {
+	Deadline time.Duration
+	Elapsed  time.Duration
+	// UID is the node at whose boundary the deadline was detected.
+	UID UnitID
+}
+
+func (e *BundleDeadlineExceeded) Error() string {
+	return fmt.Sprintf("bundle deadline of %v exceeded after %v at node %v", e.Deadline, e.Elapsed, e.UID)
+}
+
+// WithBundleDeadline returns a context in which plans abandon bundles that
+// take longer than d, measured from the start of each bundle. Once exceeded,
+// the next element passed on by a DataSource or processed by a ParDo fails
+// the bundle with a BundleDeadlineExceeded, which the plan returns as is. A
+// timer marks the deadline, so the check at each node boundary only loads a
+// flag. StartBundle and FinishBundle are not interrupted.
+func WithBundleDeadline(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, bundleDeadlineKey, d)
+}
+
+func getBundleDeadline(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(bundleDeadlineKey).(time.Duration)
+	return d
+}
+
+// bundleDeadline tracks the deadline of a single bundle. The nil deadline is
+// never exceeded.
+type bundleDeadline struct {
+	d        time.Duration
+	start    time.Time
+	exceeded int32
+	timer    *time.Timer
+}
+
+// startBundleDeadline starts tracking a deadline of d from now.
+func startBundleDeadline(d time.Duration) *bundleDeadline {
+	dl := &bundleDeadline{d: d, start: time.Now()}
+	dl.timer = time.AfterFunc(d, func() { atomic.StoreInt32(&dl.exceeded, 1) })
+	return dl
+}
+
+func (dl *bundleDeadline) stop() {
+	dl.timer.Stop()
+}
+
+// check returns a BundleDeadlineExceeded at the given node, if the deadline
+// has passed.
+func (dl *bundleDeadline) check(uid UnitID) error {
+	if dl == nil || atomic.LoadInt32(&dl.exceeded) == 0 {
+		return nil
+	}
+	return &BundleDeadlineExceeded{Deadline: dl.d, Elapsed: time.Since(dl.start), UID: uid}
+}
+
+func withBundleDeadlineState(ctx context.Context, dl *bundleDeadline) context.Context {
+	return context.WithValue(ctx, deadlineStateKey, dl)
+}
+
+func getBundleDeadlineState(ctx context.Context) *bundleDeadline {
+	dl, _ := ctx.Value(deadlineStateKey).(*bundleDeadline)
+	return dl
+}
+
+// asBundleDeadlineExceeded returns the first BundleDeadlineExceeded in the
+// chain of wrapped errors, if present.
+func asBundleDeadlineExceeded(err error) (*BundleDeadlineExceeded, bool) {
+	for err != nil {
+		if e, ok := err.(*BundleDeadlineExceeded); ok {
+			return e, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
@@ -0,0 +1,79 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+)
+
+// TestPlan_bundleDeadline verifies that a bundle exceeding its deadline fails
+// with a BundleDeadlineExceeded at the next node boundary, and that the
+// deadline restarts with each bundle.
+func TestPlan_bundleDeadline(t *testing.T) {
+	fn, err := graph.NewDoFn(func(n int) int {
+		time.Sleep(time.Millisecond)
+		return n
+	})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	newPlan := func(t *testing.T, elms int) (*Plan, *CaptureNode) {
+		var in []interface{}
+		for i := 0; i < elms; i++ {
+			in = append(in, i)
+		}
+		out := &CaptureNode{UID: 3}
+		pardo := &ParDo{UID: 2, Fn: fn, Out: []Node{out}}
+		root := &FixedRoot{UID: 1, Elements: makeInput(in...), Out: pardo}
+		p, err := NewPlan("a", []Unit{root, pardo, out})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		return p, out
+	}
+
+	t.Run("exceeded", func(t *testing.T) {
+		p, out := newPlan(t, 1000)
+		ctx := WithBundleDeadline(context.Background(), 20*time.Millisecond)
+		err := p.Execute(ctx, "1", DataContext{})
+		dl, ok := err.(*BundleDeadlineExceeded)
+		if !ok {
+			t.Fatalf("Execute = %v, want BundleDeadlineExceeded", err)
+		}
+		if dl.UID != 2 || dl.Deadline != 20*time.Millisecond || dl.Elapsed < dl.Deadline {
+			t.Errorf("Execute = %+v, want deadline of 20ms exceeded at node 2", dl)
+		}
+		if len(out.Elements) == 0 || len(out.Elements) == 1000 {
+			t.Errorf("processed %v elements, want some but not all", len(out.Elements))
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		p, _ := newPlan(t, 5)
+		// The bundles together take longer than the deadline, but each one
+		// well within it.
+		ctx := WithBundleDeadline(context.Background(), 100*time.Millisecond)
+		for i := 0; i < 40; i++ {
+			if err := p.Execute(ctx, "1", DataContext{}); err != nil {
+				t.Fatalf("Execute failed for bundle %v: %v", i, err)
+			}
+		}
+	})
+}
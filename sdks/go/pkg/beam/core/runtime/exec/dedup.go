@@ -0,0 +1,104 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// DedupNode wraps a node and drops elements that are exact duplicates of the
+// immediately preceding element of the bundle. Elements are compared by their
+// encoding with Coder. If Coder is a windowed value coder, the windows and
+// timestamp are part of the comparison.
+//
+// Only adjacent duplicates are removed: a duplicate separated from its
+// original by any other element is forwarded. DedupNode does not support
+// GBK/CoGBK results.
+type DedupNode struct {
+	Node
+	Coder *coder.Coder
+
+	enc  ElementEncoder
+	wEnc WindowEncoder
+
+	cur     bytes.Buffer // Encoding of the current element.
+	last    []byte       // Encoding of the preceding element.
+	lastSum uint64
+	hasLast bool
+}
+
+// NewDedupNode returns a node that drops adjacent duplicate elements passed
+// to out, compared by their encoding with c.
+func NewDedupNode(out Node, c *coder.Coder) *DedupNode {
+	return &DedupNode{Node: out, Coder: c}
+}
+
+// Up initializes the encoders and brings up the wrapped node.
+func (n *DedupNode) Up(ctx context.Context) error {
+	if coder.IsW(n.Coder) {
+		n.enc = MakeElementEncoder(coder.SkipW(n.Coder))
+		n.wEnc = MakeWindowEncoder(n.Coder.Window)
+	} else {
+		n.enc = MakeElementEncoder(n.Coder)
+	}
+	return n.Node.Up(ctx)
+}
+
+// StartBundle forgets the preceding element and starts the wrapped node.
+func (n *DedupNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.hasLast = false
+	n.last = n.last[:0]
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement forwards the element to the wrapped node, unless it is equal
+// to the preceding element. Elements are first compared by the hash of their
+// encoding, and by the full encoding only if the hashes match.
+func (n *DedupNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("dedup node %v does not support GBK/CoGBK results", n.ID())
+	}
+	n.cur.Reset()
+	if n.wEnc != nil {
+		if err := EncodeWindowedValueHeader(n.wEnc, elm.Windows, elm.Timestamp, elm.Pane, &n.cur); err != nil {
+			return err
+		}
+	}
+	if err := n.enc.Encode(elm, &n.cur); err != nil {
+		return errors.WithContextf(err, "encoding element %v with coder %v", elm, n.Coder)
+	}
+	h := fnv.New64a()
+	h.Write(n.cur.Bytes())
+	sum := h.Sum64()
+
+	if n.hasLast && sum == n.lastSum && bytes.Equal(n.cur.Bytes(), n.last) {
+		return nil
+	}
+	n.last = append(n.last[:0], n.cur.Bytes()...)
+	n.lastSum = sum
+	n.hasLast = true
+	return n.Node.ProcessElement(ctx, elm)
+}
+
+func (n *DedupNode) String() string {
+	return fmt.Sprintf("DedupNode[%v]. Node:%v", n.Coder, n.Node)
+}
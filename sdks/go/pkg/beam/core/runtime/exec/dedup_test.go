@@ -0,0 +1,51 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+)
+
+// TestDedupNode verifies that only adjacent duplicates are dropped, and that
+// the preceding element is forgotten between bundles.
+func TestDedupNode(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	dedup := NewDedupNode(out, coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow()))
+	in := &FixedRoot{UID: 2, Elements: makeInput(int64(1), int64(1), int64(2), int64(1), int64(1), int64(1)), Out: dedup}
+
+	p, err := NewPlan("a", []Unit{in, dedup})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(int64(1), int64(2), int64(1)); !equalList(out.Elements, want) {
+		t.Errorf("dedup node passed %v, want %v", extractValues(out.Elements...), extractValues(want...))
+	}
+
+	out.Elements = nil
+	in.Elements = makeInput(int64(1))
+	if err := p.Execute(context.Background(), "2", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(int64(1)); !equalList(out.Elements, want) {
+		t.Errorf("dedup node passed %v in second bundle, want %v", extractValues(out.Elements...), extractValues(want...))
+	}
+}
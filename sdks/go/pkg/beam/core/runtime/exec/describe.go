@@ -0,0 +1,97 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import "reflect"
+
+// PlanGraph is a serializable snapshot of the topology of a plan.
+type PlanGraph struct {
+	// ID is the plan identifier.
+	ID string `json:"id"`
+	// Nodes holds the units of the plan, and any nodes reachable from them.
+	Nodes []PlanNode `json:"nodes"`
+}
+
+// PlanNode describes a unit of a plan. Nodes wrapping another node, such as
+// a RetryNode, share the UnitID of the wrapped node, which is then one of
+// their successors.
+type PlanNode struct {
+	// UID is the unit identifier.
+	UID UnitID `json:"uid"`
+	// Type is the name of the Go type of the unit, such as "ParDo".
+	Type string `json:"type"`
+	// Successors are the indices in PlanGraph.Nodes of the nodes the unit
+	// outputs to.
+	Successors []int `json:"successors,omitempty"`
+}
+
+// DescribePlan returns the topology of the given plan. Successors are found in
+// the exported Node and []Node fields of the units, as for InstallTap. It only
+// reads the plan, and may be called at any time.
+func DescribePlan(p *Plan) PlanGraph {
+	g := PlanGraph{ID: p.id}
+	index := make(map[Unit]int)
+
+	var add func(u Unit) int
+	add = func(u Unit) int {
+		if i, ok := index[u]; ok {
+			return i
+		}
+		i := len(g.Nodes)
+		index[u] = i
+		g.Nodes = append(g.Nodes, PlanNode{UID: u.ID(), Type: typeName(u)})
+		for _, out := range outputs(u) {
+			j := add(out)
+			g.Nodes[i].Successors = append(g.Nodes[i].Successors, j)
+		}
+		return i
+	}
+	for _, u := range p.units {
+		add(u)
+	}
+	return g
+}
+
+// outputs returns the nodes in the exported Node and []Node fields of u.
+func outputs(u Unit) []Node {
+	var ret []Node
+	for _, f := range nodeFields(reflect.ValueOf(u)) {
+		if f.Type() == nodeType {
+			if !f.IsNil() {
+				ret = append(ret, f.Interface().(Node))
+			}
+			continue
+		}
+		for j := 0; j < f.Len(); j++ {
+			if e := f.Index(j); !e.IsNil() {
+				ret = append(ret, e.Interface().(Node))
+			}
+		}
+	}
+	return ret
+}
+
+// typeName returns the unqualified name of the type of u.
+func typeName(u Unit) string {
+	t := reflect.TypeOf(u)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Name() == "" {
+		return t.String()
+	}
+	return t.Name()
+}
@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestDescribePlan verifies that the plan topology is described with all
+// units, wrapped nodes and edges, and that describing a plan doesn't affect
+// its execution.
+func TestDescribePlan(t *testing.T) {
+	out1 := &CaptureNode{UID: 1}
+	out2 := &CaptureNode{UID: 2}
+	retry := NewRetryNode(out2, RetryPolicy{MaxAttempts: 2})
+	m := &Multiplex{UID: 3, Out: []Node{out1, retry}}
+	root := &FixedRoot{UID: 4, Elements: makeInput(1, 2), Out: m}
+	p, err := NewPlan("a", []Unit{root, m, out1, retry})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	want := PlanGraph{ID: "a", Nodes: []PlanNode{
+		{UID: 4, Type: "FixedRoot", Successors: []int{1}},
+		{UID: 3, Type: "Multiplex", Successors: []int{2, 3}},
+		{UID: 1, Type: "CaptureNode"},
+		{UID: 2, Type: "RetryNode", Successors: []int{4}},
+		{UID: 2, Type: "CaptureNode"},
+	}}
+	if got := DescribePlan(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("DescribePlan = %+v, want %+v", got, want)
+	}
+	if _, err := json.Marshal(DescribePlan(p)); err != nil {
+		t.Errorf("failed to serialize plan graph: %v", err)
+	}
+
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(1, 2); !equalList(out1.Elements, want) || !equalList(out2.Elements, want) {
+		t.Errorf("outputs got %v and %v, want %v", extractValues(out1.Elements...), extractValues(out2.Elements...), extractValues(want...))
+	}
+}
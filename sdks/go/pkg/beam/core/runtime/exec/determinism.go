@@ -0,0 +1,207 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// NonDeterministicKeyError indicates that the coder of the keys grouped by a
+// transform doesn't encode equal keys identically, so that they would not be
+// grouped together.
+type NonDeterministicKeyError struct {
+	// PID is the transform grouping the keys, if known.
+	PID    string
+	Coder  *coder.Coder
+	Reason string
+}
+
+func (e *NonDeterministicKeyError) Error() string {
+	if e.PID == "" {
+		return fmt.Sprintf("key coder %v is not deterministic: %v", e.Coder, e.Reason)
+	}
+	return fmt.Sprintf("key coder %v of transform %v is not deterministic: %v", e.Coder, e.PID, e.Reason)
+}
+
+var (
+	deterministicMu sync.RWMutex
+	deterministic   = make(map[reflect.Type]bool)
+)
+
+// DeclareDeterministic declares whether the custom coders of type t encode
+// equal values identically, which is required of the coders of grouped keys.
+// Keys with custom coders without a declaration, such as the reflective JSON
+// coder, are checked at runtime instead. It is safe for concurrent use.
+func DeclareDeterministic(t reflect.Type, isDeterministic bool) {
+	deterministicMu.Lock()
+	defer deterministicMu.Unlock()
+	deterministic[t] = isDeterministic
+}
+
+// verifyDeterministic returns a NonDeterministicKeyError if the coder is
+// known not to be deterministic, and whether it is known to be deterministic
+// otherwise.
+func verifyDeterministic(pid string, c *coder.Coder) (bool, error) {
+	known, reason := isDeterministic(c)
+	if reason != "" {
+		return false, &NonDeterministicKeyError{PID: pid, Coder: c, Reason: reason}
+	}
+	return known, nil
+}
+
+// isDeterministic returns whether the coder is known to be deterministic,
+// or the reason why it is not.
+func isDeterministic(c *coder.Coder) (bool, string) {
+	switch c.Kind {
+	case coder.Bytes, coder.String, coder.Bool, coder.VarInt:
+		return true, ""
+	case coder.Double:
+		return false, "floating point values may be equal with different encodings"
+	case coder.Custom:
+		deterministicMu.RLock()
+		d, ok := deterministic[c.Custom.Type]
+		deterministicMu.RUnlock()
+		if ok && !d {
+			return false, fmt.Sprintf("custom coder %v of type %v is declared non-deterministic", c.Custom.Name, c.Custom.Type)
+		}
+		return ok, ""
+	case coder.KV, coder.LP, coder.Iterable, coder.WindowedValue:
+		known := true
+		for _, comp := range c.Components {
+			k, reason := isDeterministic(comp)
+			if reason != "" {
+				return false, reason
+			}
+			known = known && k
+		}
+		return known, ""
+	default:
+		return false, ""
+	}
+}
+
+// deterministicCheckSamples is the number of keys per bundle checked by a
+// keyCheck, which bounds its cost.
+const deterministicCheckSamples = 100
+
+// keyCheck verifies that a key coder encodes the first keys of each bundle
+// identically twice. It is used by the nodes grouping keys whose coders are
+// not known to be deterministic.
+type keyCheck struct {
+	pid     string
+	coder   *coder.Coder
+	enc     ElementEncoder
+	a, b    bytes.Buffer
+	checked int
+}
+
+func newKeyCheck(pid string, c *coder.Coder) *keyCheck {
+	return &keyCheck{pid: pid, coder: c, enc: MakeElementEncoder(c)}
+}
+
+// reset restarts the sampling of keys, for a new bundle.
+func (k *keyCheck) reset() {
+	k.checked = 0
+}
+
+// check returns a NonDeterministicKeyError if the key is sampled and encoded
+// differently twice.
+func (k *keyCheck) check(key interface{}) error {
+	if k.checked >= deterministicCheckSamples {
+		return nil
+	}
+	k.checked++
+	k.a.Reset()
+	k.b.Reset()
+	v := &FullValue{Elm: key}
+	if err := k.enc.Encode(v, &k.a); err != nil {
+		return errors.WithContextf(err, "encoding key %v", key)
+	}
+	if err := k.enc.Encode(v, &k.b); err != nil {
+		return errors.WithContextf(err, "encoding key %v", key)
+	}
+	if !bytes.Equal(k.a.Bytes(), k.b.Bytes()) {
+		return &NonDeterministicKeyError{PID: k.pid, Coder: k.coder, Reason: fmt.Sprintf("key %v was encoded differently twice", key)}
+	}
+	return nil
+}
+
+// DeterministicKeyCheck wraps a node grouping KV elements by key and fails
+// the bundle with a NonDeterministicKeyError if encoding a key twice with
+// KeyCoder yields different bytes. As a best-effort check, only the first
+// keys of each bundle are encoded twice. UnmarshalPlan doesn't insert it, as
+// the LiftedCombines, GroupByKeys and DataSinks it builds check their keys
+// themselves; it is intended for custom grouping nodes.
+type DeterministicKeyCheck struct {
+	Node
+	PID      string
+	KeyCoder *coder.Coder
+
+	keys *keyCheck
+}
+
+// NewDeterministicKeyCheck returns a node that checks that the keys passed to
+// out by the given transform are encoded deterministically by keyCoder.
+func NewDeterministicKeyCheck(out Node, pid string, keyCoder *coder.Coder) *DeterministicKeyCheck {
+	return &DeterministicKeyCheck{Node: out, PID: pid, KeyCoder: keyCoder}
+}
+
+// Up initializes the key encoder and brings up the wrapped node.
+func (n *DeterministicKeyCheck) Up(ctx context.Context) error {
+	n.keys = newKeyCheck(n.PID, n.KeyCoder)
+	return n.Node.Up(ctx)
+}
+
+// StartBundle restarts the sampling of keys and starts the wrapped node.
+func (n *DeterministicKeyCheck) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.keys.reset()
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement checks the encoding of the key of the element, if sampled,
+// and forwards the element to the wrapped node.
+func (n *DeterministicKeyCheck) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if err := n.keys.check(elm.Elm); err != nil {
+		return err
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *DeterministicKeyCheck) String() string {
+	return fmt.Sprintf("DeterministicKeyCheck[%v]. Node:%v", n.KeyCoder, n.Node)
+}
+
+// WithGroupedSinks makes UnmarshalPlan treat the DataSinks of the given
+// transforms, by ID, as feeding a GroupByKey on the runner, which the bundle
+// descriptor doesn't show. Their key coders are then verified to be
+// deterministic, as for the GroupByKeys and lifted combines of the plan.
+func WithGroupedSinks(transforms ...string) BuildOption {
+	return func(b *builder) {
+		if b.groupedSinks == nil {
+			b.groupedSinks = make(map[string]bool)
+		}
+		for _, t := range transforms {
+			b.groupedSinks[t] = true
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+// flakyKey is encoded differently each time.
+type flakyKey string
+
+var (
+	flakyKeyType = reflect.TypeOf(flakyKey(""))
+	flakyCount   int
+)
+
+func flakyKeyEncoder(k flakyKey) []byte {
+	flakyCount++
+	return []byte(fmt.Sprintf("%v-%v", k, flakyCount))
+}
+
+func flakyKeyDecoder(b []byte) flakyKey {
+	return flakyKey(b)
+}
+
+func flakyKeyCoder(t *testing.T) *coder.Coder {
+	c, err := coder.NewCustomCoder("flaky", flakyKeyType, flakyKeyEncoder, flakyKeyDecoder)
+	if err != nil {
+		t.Fatalf("NewCustomCoder failed: %v", err)
+	}
+	return &coder.Coder{Kind: coder.Custom, T: typex.New(flakyKeyType), Custom: c}
+}
+
+func TestVerifyDeterministic(t *testing.T) {
+	custom := flakyKeyCoder(t)
+	tests := []struct {
+		name  string
+		c     *coder.Coder
+		known bool
+		err   bool
+	}{
+		{"string", coder.NewString(), true, false},
+		{"varint", coder.NewVarInt(), true, false},
+		{"double", coder.NewDouble(), false, true},
+		{"kv", coder.NewKV([]*coder.Coder{coder.NewBytes(), coder.NewBool()}), true, false},
+		{"kvDouble", coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewDouble()}), false, true},
+		{"custom", custom, false, false},
+		{"kvCustom", coder.NewKV([]*coder.Coder{coder.NewString(), custom}), false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			known, err := verifyDeterministic("myTransform", test.c)
+			if known != test.known {
+				t.Errorf("verifyDeterministic(%v) = %v, want %v", test.c, known, test.known)
+			}
+			var nde *NonDeterministicKeyError
+			if got := errors.As(err, &nde); got != test.err {
+				t.Fatalf("verifyDeterministic(%v) error = %v, want NonDeterministicKeyError: %v", test.c, err, test.err)
+			}
+			if test.err && (nde.PID != "myTransform" || nde.Coder != test.c) {
+				t.Errorf("verifyDeterministic(%v) error = %+v, want transform and coder", test.c, nde)
+			}
+		})
+	}
+}
+
+func TestDeclareDeterministic(t *testing.T) {
+	c := flakyKeyCoder(t)
+	defer func() {
+		deterministicMu.Lock()
+		delete(deterministic, flakyKeyType)
+		deterministicMu.Unlock()
+	}()
+
+	DeclareDeterministic(flakyKeyType, true)
+	if known, err := verifyDeterministic("myTransform", c); !known || err != nil {
+		t.Errorf("verifyDeterministic(%v) = %v, %v, want true, nil", c, known, err)
+	}
+	DeclareDeterministic(flakyKeyType, false)
+	var nde *NonDeterministicKeyError
+	if _, err := verifyDeterministic("myTransform", c); !errors.As(err, &nde) {
+		t.Errorf("verifyDeterministic(%v) error = %v, want NonDeterministicKeyError", c, err)
+	}
+}
+
+func TestDeterministicKeyCheck(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deterministic", func(t *testing.T) {
+		out := &CaptureNode{UID: 1}
+		n := NewDeterministicKeyCheck(out, "myTransform", coder.NewString())
+		if err := n.Up(ctx); err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("StartBundle failed: %v", err)
+		}
+		for _, k := range []string{"a", "b", "a"} {
+			if err := n.ProcessElement(ctx, &FullValue{Elm: k, Elm2: int64(1)}); err != nil {
+				t.Fatalf("ProcessElement(%v) failed: %v", k, err)
+			}
+		}
+		if err := n.FinishBundle(ctx); err != nil {
+			t.Fatalf("FinishBundle failed: %v", err)
+		}
+		if got, want := len(out.Elements), 3; got != want {
+			t.Errorf("got %v elements, want %v", got, want)
+		}
+	})
+
+	t.Run("nonDeterministic", func(t *testing.T) {
+		out := &CaptureNode{UID: 1}
+		c := flakyKeyCoder(t)
+		n := NewDeterministicKeyCheck(out, "myTransform", c)
+		if err := n.Up(ctx); err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("StartBundle failed: %v", err)
+		}
+		err := n.ProcessElement(ctx, &FullValue{Elm: flakyKey("a"), Elm2: int64(1)})
+		var nde *NonDeterministicKeyError
+		if !errors.As(err, &nde) || nde.PID != "myTransform" || nde.Coder != c {
+			t.Fatalf("ProcessElement error = %v, want NonDeterministicKeyError for myTransform", err)
+		}
+		if len(out.Elements) != 0 {
+			t.Errorf("got %v elements, want none", len(out.Elements))
+		}
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		out := &CaptureNode{UID: 1}
+		n := NewDeterministicKeyCheck(out, "myTransform", flakyKeyCoder(t))
+		if err := n.Up(ctx); err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("StartBundle failed: %v", err)
+		}
+		// Keys past the sample are not checked.
+		n.keys.checked = deterministicCheckSamples
+		if err := n.ProcessElement(ctx, &FullValue{Elm: flakyKey("a"), Elm2: int64(1)}); err != nil {
+			t.Fatalf("ProcessElement failed: %v", err)
+		}
+		if got, want := len(out.Elements), 1; got != want {
+			t.Errorf("got %v elements, want %v", got, want)
+		}
+	})
+}
+
+// TestGroupingNodes_checkKeys verifies that the grouping nodes built by
+// UnmarshalPlan check the keys of coders not known to be deterministic
+// themselves, so that they aren't hidden behind a DeterministicKeyCheck.
+func TestGroupingNodes_checkKeys(t *testing.T) {
+	keyCoder := flakyKeyCoder(t)
+	wc := coder.NewGlobalWindow()
+	kvCoder := coder.NewW(coder.NewKV([]*coder.Coder{keyCoder, coder.NewVarInt()}), wc)
+	tests := []struct {
+		name string
+		node func(out Node) Node
+	}{
+		{"liftedCombine", func(out Node) Node {
+			edge := getCombineEdge(t, mergeFn, reflectx.Int, intCoder(reflectx.Int))
+			n := &LiftedCombine{Combine: &Combine{UID: 2, PID: "myTransform", Fn: edge.CombineFn, Out: out}, KeyCoder: keyCoder, WindowCoder: wc, CheckKeys: true}
+			if _, ok := precombine(n); !ok {
+				t.Errorf("precombine(%v) = false, want true", n)
+			}
+			return n
+		}},
+		{"groupByKey", func(out Node) Node {
+			return NewGroupByKey(2, kvCoder, out)
+		}},
+		{"dataSink", func(out Node) Node {
+			return &DataSink{UID: 2, SID: StreamID{PtransformID: "myTransform"}, Coder: kvCoder, CheckKeys: true}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := test.node(out)
+			root := &FixedRoot{UID: 3, Elements: []MainInput{{Key: FullValue{Elm: flakyKey("a"), Elm2: 1, Windows: window.SingleGlobalWindow}}}, Out: n}
+			p, err := NewPlan("a", []Unit{root, n, out})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{Data: &TestDataManager{W: &nopWriteCloser{}}})
+			var nde *NonDeterministicKeyError
+			if !errors.As(err, &nde) || nde.Coder != keyCoder {
+				t.Fatalf("execute = %v, want NonDeterministicKeyError for %v", err, keyCoder)
+			}
+		})
+	}
+
+	t.Run("groupByKeyKnown", func(t *testing.T) {
+		c := coder.NewW(coder.NewKV([]*coder.Coder{coder.NewDouble(), coder.NewVarInt()}), wc)
+		n := NewGroupByKey(1, c, &Discard{UID: 2})
+		var nde *NonDeterministicKeyError
+		if err := n.Up(context.Background()); !errors.As(err, &nde) {
+			t.Fatalf("Up = %v, want NonDeterministicKeyError", err)
+		}
+	})
+}
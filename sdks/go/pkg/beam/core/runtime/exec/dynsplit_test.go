@@ -279,7 +279,7 @@ func createSdfPlan(t *testing.T, name string, fn *graph.DoFn, cdr *coder.Coder)
 func writeElm(elm *FullValue, cdr *coder.Coder, pw *io.PipeWriter) {
 	wc := MakeWindowEncoder(cdr.Window)
 	ec := MakeElementEncoder(coder.SkipW(cdr))
-	if err := EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, pw); err != nil {
+	if err := EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), pw); err != nil {
 		panic("err")
 	}
 	if err := ec.Encode(elm, pw); err != nil {
@@ -294,7 +294,7 @@ func decodeDynSplitElm(elm []byte, cdr *coder.Coder) (*FullValue, error) {
 	wd := MakeWindowDecoder(cdr.Window)
 	ed := MakeElementDecoder(coder.SkipW(cdr))
 	b := bytes.NewBuffer(elm)
-	w, t, err := DecodeWindowedValueHeader(wd, b)
+	w, t, pn, err := DecodeWindowedValueHeader(wd, b)
 	if err != nil {
 		return nil, err
 	}
@@ -304,6 +304,7 @@ func decodeDynSplitElm(elm []byte, cdr *coder.Coder) (*FullValue, error) {
 	}
 	e.Windows = w
 	e.Timestamp = t
+	e.Pane = pn
 	return e, nil
 }
 
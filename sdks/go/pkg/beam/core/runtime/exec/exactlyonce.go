@@ -0,0 +1,214 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// exactlyOnceDropped counts the duplicate elements dropped by
+// ExactlyOnceGuards.
+var exactlyOnceDropped = metrics.NewCounter("exec", "exactlyOnce.dropped")
+
+// DedupStore is a persistent set of keys, in sets identified by state IDs,
+// that outlives bundles. The state API of the runner doesn't support user
+// state yet, so the store is provided to plans with WithDedupStore. Stores
+// must be safe for concurrent use.
+type DedupStore interface {
+	// Contains returns whether the key is in the set of the state ID, and
+	// hasn't expired.
+	Contains(ctx context.Context, stateID string, key []byte) (bool, error)
+	// Add adds the key to the set of the state ID, until the given expiry.
+	// The zero time never expires.
+	Add(ctx context.Context, stateID string, key []byte, expiry time.Time) error
+}
+
+// dedupCommitValidity is how long after a bundle finishes its staged keys may
+// still be added to the store, unless the TTL is shorter.
+const dedupCommitValidity = time.Hour
+
+// WithDedupStore returns a context in which ExactlyOnceGuards record the keys
+// of their elements in the given store.
+func WithDedupStore(ctx context.Context, s DedupStore) context.Context {
+	return context.WithValue(ctx, dedupStoreKey, s)
+}
+
+func getDedupStore(ctx context.Context) DedupStore {
+	s, _ := ctx.Value(dedupStoreKey).(DedupStore)
+	return s
+}
+
+// ExactlyOnceGuard wraps a node and drops the elements whose key, as
+// extracted by KeyFn, was already seen, in this or an earlier committed
+// bundle. The keys of new elements are staged during the bundle, and only
+// added to the DedupStore of the context under StateID once the bundle is
+// committed, by a bundle finalization callback, see Plan.Finalize. Elements
+// of a bundle that fails or is cancelled are thus processed again when it is
+// retried, rather than lost. Outside of a plan, keys are added once the
+// wrapped node finishes. Keys are forgotten after TTL, if set. A bundle
+// without a store, or whose store fails, fails rather than letting duplicates
+// through. Dropped elements are counted in the exactlyOnce.dropped counter.
+type ExactlyOnceGuard struct {
+	Node
+	KeyFn   func(*FullValue) []byte
+	StateID string
+	// TTL is how long keys are remembered. If zero, they are never
+	// forgotten.
+	TTL time.Duration
+
+	store DedupStore
+	// staged holds the keys of the bundle, to be added to the store once it
+	// is committed.
+	staged map[string]bool
+	keys   [][]byte
+}
+
+// NewExactlyOnceGuard returns a node that passes the elements with new keys
+// to out, recording their keys in the dedup state with the given ID.
+func NewExactlyOnceGuard(out Node, keyFn func(*FullValue) []byte, stateID string) *ExactlyOnceGuard {
+	return &ExactlyOnceGuard{Node: out, KeyFn: keyFn, StateID: stateID}
+}
+
+// Up validates the guard and brings up the wrapped node.
+func (n *ExactlyOnceGuard) Up(ctx context.Context) error {
+	if n.KeyFn == nil {
+		return errors.Errorf("missing key function for exactly once guard %v", n.ID())
+	}
+	if n.StateID == "" {
+		return errors.Errorf("missing state ID for exactly once guard %v", n.ID())
+	}
+	if n.TTL < 0 {
+		return errors.Errorf("invalid TTL for exactly once guard %v: %v, want >= 0", n.ID(), n.TTL)
+	}
+	return n.Node.Up(ctx)
+}
+
+// StartBundle looks up the dedup store of the bundle and starts the wrapped
+// node.
+func (n *ExactlyOnceGuard) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.staged, n.keys = make(map[string]bool), nil
+	n.store = getDedupStore(ctx)
+	if n.store == nil {
+		return errors.Errorf("no dedup store for exactly once guard %v: use exec.WithDedupStore", n.ID())
+	}
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement drops the element if its key was seen, and otherwise
+// forwards the element to the wrapped node and stages the key.
+func (n *ExactlyOnceGuard) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	key := n.KeyFn(elm)
+	seen := n.staged[string(key)]
+	if !seen {
+		var err error
+		if seen, err = n.store.Contains(ctx, n.StateID, key); err != nil {
+			return errors.Wrapf(err, "reading dedup state %v at node %v", n.StateID, n.ID())
+		}
+	}
+	if seen {
+		exactlyOnceDropped.Inc(ctx, 1)
+		return nil
+	}
+	if err := n.Node.ProcessElement(ctx, elm, values...); err != nil {
+		return err
+	}
+	n.staged[string(key)] = true
+	n.keys = append(n.keys, key)
+	return nil
+}
+
+// FinishBundle finishes the wrapped node, and then has the staged keys added
+// to the store once the bundle is committed.
+func (n *ExactlyOnceGuard) FinishBundle(ctx context.Context) error {
+	if err := n.Node.FinishBundle(ctx); err != nil {
+		return err
+	}
+	store, keys := n.store, n.keys
+	n.staged, n.keys = nil, nil
+	if len(keys) == 0 {
+		return nil
+	}
+	var expiry time.Time
+	if n.TTL > 0 {
+		expiry = time.Now().Add(n.TTL)
+	}
+	commit := func() error {
+		for _, key := range keys {
+			if err := store.Add(ctx, n.StateID, key, expiry); err != nil {
+				return errors.Wrapf(err, "writing dedup state %v at node %v", n.StateID, n.ID())
+			}
+		}
+		return nil
+	}
+	bf := GetBundleFinalizer(ctx)
+	if bf == nil {
+		return commit()
+	}
+	validFor := dedupCommitValidity
+	if n.TTL > 0 && n.TTL < validFor {
+		validFor = n.TTL
+	}
+	bf.RegisterCallback(validFor, commit)
+	return nil
+}
+
+func (n *ExactlyOnceGuard) String() string {
+	return fmt.Sprintf("ExactlyOnceGuard[%v, ttl %v]. Node:%v", n.StateID, n.TTL, n.Node)
+}
+
+// MemoryDedupStore is a DedupStore held in memory, for a single worker. It is
+// safe for concurrent use.
+type MemoryDedupStore struct {
+	mu   sync.Mutex
+	sets map[string]map[string]time.Time
+}
+
+// NewMemoryDedupStore returns an empty in-memory dedup store.
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{sets: make(map[string]map[string]time.Time)}
+}
+
+// Contains returns whether the unexpired key is in the set, dropping it if
+// it has expired.
+func (s *MemoryDedupStore) Contains(ctx context.Context, stateID string, key []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.sets[stateID][string(key)]
+	if ok && !expiry.IsZero() && !time.Now().Before(expiry) {
+		delete(s.sets[stateID], string(key))
+		return false, nil
+	}
+	return ok, nil
+}
+
+// Add adds the key to the set, until the given expiry.
+func (s *MemoryDedupStore) Add(ctx context.Context, stateID string, key []byte, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.sets[stateID]
+	if !ok {
+		set = make(map[string]time.Time)
+		s.sets[stateID] = set
+	}
+	set[string(key)] = expiry
+	return nil
+}
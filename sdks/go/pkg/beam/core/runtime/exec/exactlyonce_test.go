@@ -0,0 +1,207 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func elementKey(elm *FullValue) []byte {
+	return []byte(fmt.Sprint(elm.Elm))
+}
+
+// TestExactlyOnceGuard verifies that duplicates are dropped within and across
+// committed bundles, and across plans sharing a store.
+func TestExactlyOnceGuard(t *testing.T) {
+	store := NewMemoryDedupStore()
+	ctx := WithDedupStore(context.Background(), store)
+
+	run := func(elms ...interface{}) []interface{} {
+		t.Helper()
+		out := &CaptureNode{UID: 1}
+		n := NewExactlyOnceGuard(out, elementKey, "writes")
+		in := &FixedRoot{UID: 2, Elements: makeInput(elms...), Out: n}
+		p, err := NewPlan("a", []Unit{in, n})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if err := p.Finalize(); err != nil {
+			t.Fatalf("finalize failed: %v", err)
+		}
+		return extractValues(out.Elements...)
+	}
+	if got, want := run(1, 2, 1, 3, 2), []interface{}{1, 2, 3}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("first bundle forwarded %v, want %v", got, want)
+	}
+	if got, want := run(3, 4), []interface{}{4}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("second bundle forwarded %v, want %v", got, want)
+	}
+	// Other states don't share keys.
+	if seen, _ := store.Contains(ctx, "other", []byte("1")); seen {
+		t.Error("key seen in other state")
+	}
+}
+
+// TestExactlyOnceGuard_ttl verifies that keys are forgotten after the TTL.
+func TestExactlyOnceGuard_ttl(t *testing.T) {
+	store := NewMemoryDedupStore()
+	ctx := WithDedupStore(context.Background(), store)
+	out := &CaptureNode{UID: 1}
+	n := NewExactlyOnceGuard(out, elementKey, "writes")
+	n.TTL = 10 * time.Millisecond
+	if err := n.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	for _, wait := range []time.Duration{0, 0, 20 * time.Millisecond} {
+		time.Sleep(wait)
+		if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("StartBundle failed: %v", err)
+		}
+		if err := n.ProcessElement(ctx, &FullValue{Elm: 1}); err != nil {
+			t.Fatalf("ProcessElement failed: %v", err)
+		}
+		// Without a plan, the keys are added at FinishBundle.
+		if err := n.FinishBundle(ctx); err != nil {
+			t.Fatalf("FinishBundle failed: %v", err)
+		}
+	}
+	if got, want := len(out.Elements), 2; got != want {
+		t.Errorf("forwarded %v elements, want %v: the key should expire once", got, want)
+	}
+}
+
+// failAfterNode is a CaptureNode that fails on the elements after the first
+// after ones, unless after is negative.
+type failAfterNode struct {
+	CaptureNode
+	after int
+}
+
+func (n *failAfterNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.after >= 0 && len(n.Elements) >= n.after {
+		return errors.New("element failed")
+	}
+	return n.CaptureNode.ProcessElement(ctx, elm, values...)
+}
+
+// TestExactlyOnceGuard_retry verifies that the elements of a failed bundle
+// are processed again when it is retried, and that those of a bundle are only
+// dropped once it is committed.
+func TestExactlyOnceGuard_retry(t *testing.T) {
+	store := NewMemoryDedupStore()
+	ctx := WithDedupStore(context.Background(), store)
+
+	run := func(failAfter int, finalize bool, elms ...interface{}) ([]interface{}, error) {
+		t.Helper()
+		out := &CaptureNode{UID: 1}
+		fail := &failAfterNode{CaptureNode: CaptureNode{UID: 2}, after: failAfter}
+		n := NewExactlyOnceGuard(&Multiplex{UID: 3, Out: []Node{out, fail}}, elementKey, "writes")
+		in := &FixedRoot{UID: 4, Elements: makeInput(elms...), Out: n}
+		p, err := NewPlan("a", []Unit{in, n, out, fail})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		err = p.Execute(ctx, "1", DataContext{})
+		if err == nil && finalize {
+			err = p.Finalize()
+		}
+		return extractValues(out.Elements...), err
+	}
+	// The bundle fails after its first element was processed downstream.
+	if _, err := run(1, true, 1, 2); err == nil {
+		t.Fatal("execute of failing bundle succeeded, want error")
+	}
+	got, err := run(-1, false, 1, 2)
+	if err != nil {
+		t.Fatalf("execute of retried bundle failed: %v", err)
+	}
+	if want := []interface{}{1, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("retried bundle forwarded %v, want %v", got, want)
+	}
+	// The retried bundle wasn't committed, so it is processed again.
+	if got, err = run(-1, true, 1, 2); err != nil {
+		t.Fatalf("execute of committed bundle failed: %v", err)
+	}
+	if want := []interface{}{1, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("uncommitted bundle forwarded %v on retry, want %v", got, want)
+	}
+	if got, err = run(-1, true, 1, 2, 3); err != nil {
+		t.Fatalf("execute after committed bundle failed: %v", err)
+	}
+	if want := []interface{}{3}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("bundle after committed bundle forwarded %v, want %v", got, want)
+	}
+}
+
+// failingDedupStore fails reads or writes.
+type failingDedupStore struct {
+	readErr, writeErr error
+}
+
+func (s *failingDedupStore) Contains(ctx context.Context, stateID string, key []byte) (bool, error) {
+	return false, s.readErr
+}
+
+func (s *failingDedupStore) Add(ctx context.Context, stateID string, key []byte, expiry time.Time) error {
+	return s.writeErr
+}
+
+// TestExactlyOnceGuard_failures verifies that bundles fail rather than let
+// duplicates through.
+func TestExactlyOnceGuard_failures(t *testing.T) {
+	tests := []struct {
+		name  string
+		store DedupStore
+		want  string
+	}{
+		{name: "noStore", want: "no dedup store"},
+		{name: "read", store: &failingDedupStore{readErr: errors.New("read failed")}, want: "read failed"},
+		{name: "write", store: &failingDedupStore{writeErr: errors.New("write failed")}, want: "write failed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.store != nil {
+				ctx = WithDedupStore(ctx, test.store)
+			}
+			out := &CaptureNode{UID: 1}
+			n := NewExactlyOnceGuard(out, elementKey, "writes")
+			in := &FixedRoot{UID: 2, Elements: makeInput(1, 1), Out: n}
+			p, err := NewPlan("a", []Unit{in, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			// Keys are written once the bundle is committed.
+			if err = p.Execute(ctx, "1", DataContext{}); err == nil {
+				err = p.Finalize()
+			}
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("execute = %v, want error containing %q", err, test.want)
+			}
+			if len(out.Elements) > 1 {
+				t.Errorf("forwarded %v elements, want at most 1", len(out.Elements))
+			}
+		})
+	}
+}
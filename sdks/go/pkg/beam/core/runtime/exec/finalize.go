@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// maxFinalizationAttempts is the number of times a bundle finalization
+// callback failing with a Transient error is attempted.
+const maxFinalizationAttempts = 3
+
+// bundleFinalizer collects the finalization callbacks registered during a
+// bundle. It is safe for concurrent use.
+type bundleFinalizer struct {
+	mu        sync.Mutex
+	callbacks []bundleFinalizationCallback
+}
+
+type bundleFinalizationCallback struct {
+	callback   func() error
+	validUntil time.Time
+}
+
+// RegisterCallback registers a callback to be invoked once the bundle is
+// committed, unless validFor has elapsed by then.
+func (f *bundleFinalizer) RegisterCallback(validFor time.Duration, callback func() error) {
+	f.mu.Lock()
+	f.callbacks = append(f.callbacks, bundleFinalizationCallback{callback: callback, validUntil: time.Now().Add(validFor)})
+	f.mu.Unlock()
+}
+
+// expiry returns the time after which all callbacks have expired.
+func (f *bundleFinalizer) expiry() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var t time.Time
+	for _, c := range f.callbacks {
+		if c.validUntil.After(t) {
+			t = c.validUntil
+		}
+	}
+	return t
+}
+
+// finalize invokes all unexpired callbacks once, retrying Transient failures,
+// and clears them. It returns an error for each failed or expired callback.
+func (f *bundleFinalizer) finalize() []error {
+	f.mu.Lock()
+	callbacks := f.callbacks
+	f.callbacks = nil
+	f.mu.Unlock()
+
+	var errs []error
+	for i, c := range callbacks {
+		if time.Now().After(c.validUntil) {
+			errs = append(errs, errors.Errorf("finalization callback %v expired at %v", i, c.validUntil))
+			continue
+		}
+		var err error
+		for attempt := 1; attempt <= maxFinalizationAttempts; attempt++ {
+			if err = c.callback(); err == nil || Category(err) != Transient {
+				break
+			}
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "finalization callback %v failed", i))
+		}
+	}
+	return errs
+}
+
+// GetBundleFinalizer returns the bundle finalizer of the bundle being
+// processed with the given context, or nil if there is none. DoFns may also
+// receive it as a typex.BundleFinalizer parameter.
+func GetBundleFinalizer(ctx context.Context) typex.BundleFinalizer {
+	if f, ok := ctx.Value(bundleFinalizerKey).(*bundleFinalizer); ok {
+		return f
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+var (
+	finalized      []int
+	finalizeCounts = metrics.NewCounter("test", "finalize")
+)
+
+// finalizeFn registers a callback per element that records it.
+func finalizeFn(ctx context.Context, bf typex.BundleFinalizer, n int, emit func(int)) {
+	finalizeCounts.Inc(ctx, 1)
+	bf.RegisterCallback(time.Hour, func() error {
+		finalized = append(finalized, n)
+		return nil
+	})
+	emit(n)
+}
+
+// TestPlanFinalize verifies that callbacks registered by DoFns during a bundle
+// are only invoked when the plan is finalized, and only once.
+func TestPlanFinalize(t *testing.T) {
+	finalized = nil
+	fn, err := graph.NewDoFn(finalizeFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "finalize", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}}
+	n := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: pardo}
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if len(finalized) != 0 {
+		t.Fatalf("callbacks invoked before Finalize: %v", finalized)
+	}
+	// The finalizer must not hide the bundle's metric store from the DoFn.
+	var count int64
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			if l.Name() == "finalize" {
+				count = v
+			}
+		},
+	}.ExtractFrom(p.Store())
+	if count != 3 {
+		t.Errorf("DoFn counter in plan store = %v, want 3", count)
+	}
+	if exp := p.FinalizationExpiry(); time.Until(exp) < 59*time.Minute {
+		t.Errorf("FinalizationExpiry() = %v, want about an hour from now", exp)
+	}
+	if err := p.Finalize(); err != nil {
+		t.Fatalf("finalize failed: %v", err)
+	}
+	if err := p.Finalize(); err != nil {
+		t.Fatalf("second finalize failed: %v", err)
+	}
+	if err := p.Down(context.Background()); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if got, want := finalized, []int{1, 2, 3}; len(got) != len(want) || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("finalized = %v, want %v", got, want)
+	}
+}
+
+// TestBundleFinalizer verifies retries of transient failures and expiry of
+// finalization callbacks.
+func TestBundleFinalizer(t *testing.T) {
+	var f bundleFinalizer
+	var flaky, broken, expired int
+	f.RegisterCallback(time.Hour, func() error {
+		flaky++
+		if flaky < maxFinalizationAttempts {
+			return TransientError(errors.New("flaky"))
+		}
+		return nil
+	})
+	f.RegisterCallback(time.Hour, func() error {
+		broken++
+		return errors.New("broken")
+	})
+	f.RegisterCallback(-time.Second, func() error {
+		expired++
+		return nil
+	})
+
+	errs := f.finalize()
+	if flaky != maxFinalizationAttempts {
+		t.Errorf("transient callback invoked %v times, want %v", flaky, maxFinalizationAttempts)
+	}
+	if broken != 1 {
+		t.Errorf("failing callback invoked %v times, want 1", broken)
+	}
+	if expired != 0 {
+		t.Errorf("expired callback invoked %v times, want 0", expired)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "broken") || !strings.Contains(errs[1].Error(), "expired") {
+		t.Errorf("finalize() = %v, want failure and expiry errors", errs)
+	}
+	if errs := f.finalize(); len(errs) != 0 {
+		t.Errorf("second finalize() = %v, want no errors", errs)
+	}
+}
@@ -49,7 +49,7 @@ func (m *Flatten) StartBundle(ctx context.Context, id string, data DataContext)
 	m.active = true
 	m.seen = 0
 
-	return m.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, m.Out)
 }
 
 func (m *Flatten) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
@@ -63,7 +63,7 @@ func (m *Flatten) FinishBundle(ctx context.Context) error {
 	}
 	m.active = false
 
-	return m.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, m.Out)
 }
 
 func (m *Flatten) Down(ctx context.Context) error {
@@ -73,3 +73,104 @@ func (m *Flatten) Down(ctx context.Context) error {
 func (m *Flatten) String() string {
 	return fmt.Sprintf("Flatten[%v]. Out:%v", m.N, m.Out)
 }
+
+// TaggedFlatten is a fan-in node that tags each element with the index of the
+// input it arrived on. Each input is a separate Node, which forwards elements
+// downstream as KV<index, element>. If the element is itself a KV, it is
+// nested as a *FullValue. Timestamps and windows are unchanged. As with
+// Flatten, Start/FinishBundle are only called once downstream.
+type TaggedFlatten struct {
+	// UID is the unit identifier, shared by all inputs.
+	UID UnitID
+	// Out is the output node.
+	Out Node
+	// Inputs are the per-input nodes, in index order.
+	Inputs []Node
+
+	active bool
+	seen   int
+}
+
+// NewTaggedFlatten returns a TaggedFlatten with the given unit id and
+// numInputs inputs, each of which forwards to out.
+func NewTaggedFlatten(uid UnitID, out Node, numInputs int) *TaggedFlatten {
+	f := &TaggedFlatten{UID: uid, Out: out}
+	for i := 0; i < numInputs; i++ {
+		f.Inputs = append(f.Inputs, &taggedFlattenInput{flatten: f, index: i})
+	}
+	return f
+}
+
+// Input returns the node for the i'th input.
+func (m *TaggedFlatten) Input(i int) Node {
+	return m.Inputs[i]
+}
+
+func (m *TaggedFlatten) startBundle(ctx context.Context, id string, data DataContext) error {
+	if m.active {
+		return nil // ok: ignore multiple start bundles. We just want the first one.
+	}
+	m.active = true
+	m.seen = 0
+
+	return MultiStartBundle(ctx, id, data, m.Out)
+}
+
+func (m *TaggedFlatten) finishBundle(ctx context.Context) error {
+	m.seen++
+	if m.seen < len(m.Inputs) {
+		return nil // ok: wait for last FinishBundle.
+	}
+	m.active = false
+
+	return MultiFinishBundle(ctx, m.Out)
+}
+
+func (m *TaggedFlatten) String() string {
+	return fmt.Sprintf("TaggedFlatten[%v]. Out:%v", len(m.Inputs), m.Out)
+}
+
+// taggedFlattenInput is a single input of a TaggedFlatten.
+type taggedFlattenInput struct {
+	flatten *TaggedFlatten
+	index   int
+}
+
+func (n *taggedFlattenInput) ID() UnitID {
+	return n.flatten.UID
+}
+
+func (n *taggedFlattenInput) Up(ctx context.Context) error {
+	return nil
+}
+
+func (n *taggedFlattenInput) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return n.flatten.startBundle(ctx, id, data)
+}
+
+func (n *taggedFlattenInput) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	var v interface{} = elm.Elm
+	if elm.Elm2 != nil {
+		v = &FullValue{Elm: elm.Elm, Elm2: elm.Elm2}
+	}
+	tagged := &FullValue{
+		Elm:       n.index,
+		Elm2:      v,
+		Timestamp: elm.Timestamp,
+		Windows:   elm.Windows,
+		Pane:      elm.Pane,
+	}
+	return n.flatten.Out.ProcessElement(ctx, tagged, values...)
+}
+
+func (n *taggedFlattenInput) FinishBundle(ctx context.Context) error {
+	return n.flatten.finishBundle(ctx)
+}
+
+func (n *taggedFlattenInput) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *taggedFlattenInput) String() string {
+	return fmt.Sprintf("TaggedFlatten[%v].Input[%v]", n.flatten.UID, n.index)
+}
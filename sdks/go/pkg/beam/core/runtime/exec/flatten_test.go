@@ -18,6 +18,9 @@ package exec
 import (
 	"context"
 	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 )
 
 // TestFlatten verifies that the Flatten node works correctly.
@@ -47,3 +50,35 @@ func TestFlatten(t *testing.T) {
 		t.Errorf("flatten returned %v, want %v", extractValues(out.Elements...), extractValues(expected...))
 	}
 }
+
+// TestTaggedFlatten verifies that the TaggedFlatten node tags elements with
+// their input index, and preserves timestamps and windows.
+func TestTaggedFlatten(t *testing.T) {
+	// capture := TaggedFlatten(a, b)
+
+	out := &CaptureNode{UID: 1}
+	flatten := NewTaggedFlatten(2, out, 2)
+	a := &FixedRoot{UID: 3, Elements: makeInput(1, 2), Out: flatten.Input(0)}
+	b := &FixedRoot{UID: 4, Elements: makeKVInput("k", 3), Out: flatten.Input(1)}
+
+	p, err := NewPlan("a", []Unit{a, b, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := p.Down(context.Background()); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+
+	expected := []FullValue{
+		{Elm: 0, Elm2: 1, Timestamp: mtime.ZeroTimestamp, Windows: window.SingleGlobalWindow},
+		{Elm: 0, Elm2: 2, Timestamp: mtime.ZeroTimestamp, Windows: window.SingleGlobalWindow},
+		{Elm: 1, Elm2: &FullValue{Elm: "k", Elm2: 3}, Timestamp: mtime.ZeroTimestamp, Windows: window.SingleGlobalWindow},
+	}
+	if !equalList(out.Elements, expected) {
+		t.Errorf("taggedflatten returned %v, want %v", out.Elements, expected)
+	}
+}
@@ -64,9 +64,9 @@ type invoker struct {
 	fn   *funcx.Fn
 	args []interface{}
 	// TODO(lostluck):  2018/07/06 consider replacing with a slice of functions to run over the args slice, as an improvement.
-	ctxIdx, wndIdx, etIdx int   // specialized input indexes
-	outEtIdx, outErrIdx   int   // specialized output indexes
-	in, out               []int // general indexes
+	ctxIdx, wndIdx, etIdx, bfIdx int   // specialized input indexes
+	outEtIdx, outErrIdx          int   // specialized output indexes
+	in, out                      []int // general indexes
 
 	ret                     FullValue                     // ret is a cached allocation for passing to the next Unit. Units never modify the passed in FullValue.
 	elmConvert, elm2Convert func(interface{}) interface{} // Cached conversion functions, which assums this invoker is always used with the same parameter types.
@@ -90,6 +90,9 @@ func newInvoker(fn *funcx.Fn) *invoker {
 	if n.etIdx, ok = fn.EventTime(); !ok {
 		n.etIdx = -1
 	}
+	if n.bfIdx, ok = fn.BundleFinalizer(); !ok {
+		n.bfIdx = -1
+	}
 	if n.outEtIdx, ok = fn.OutEventTime(); !ok {
 		n.outEtIdx = -1
 	}
@@ -137,6 +140,13 @@ func (n *invoker) Invoke(ctx context.Context, ws []typex.Window, ts typex.EventT
 	if n.etIdx >= 0 {
 		args[n.etIdx] = ts
 	}
+	if n.bfIdx >= 0 {
+		bf := GetBundleFinalizer(ctx)
+		if bf == nil {
+			return nil, errors.Errorf("DoFns that finalize bundles must be invoked during a bundle: %v", fn.Fn.Name())
+		}
+		args[n.bfIdx] = bf
+	}
 
 	// (2) Main input from value, if any.
 	i := 0
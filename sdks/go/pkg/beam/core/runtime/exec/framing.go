@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/ioutilx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// FrameReader reads the frames of a data stream, one encoded windowed value
+// per frame.
+type FrameReader interface {
+	// ReadFrame returns the bytes of the next frame, or io.EOF if the stream
+	// ended cleanly.
+	ReadFrame(r io.Reader) ([]byte, error)
+}
+
+// FrameWriter writes the frames of a data stream, one encoded windowed value
+// per frame.
+type FrameWriter interface {
+	// WriteFrame writes a single frame.
+	WriteFrame(w io.Writer, frame []byte) error
+}
+
+// Framer is a framing strategy for the data streams of DataSources and
+// DataSinks.
+type Framer interface {
+	FrameReader
+	FrameWriter
+}
+
+// LengthPrefixFramer frames each encoded value with its varint encoded length.
+type LengthPrefixFramer struct{}
+
+// ReadFrame reads a length prefixed frame.
+func (LengthPrefixFramer) ReadFrame(r io.Reader) ([]byte, error) {
+	n, err := coder.DecodeVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.Errorf("invalid frame length: %v", n)
+	}
+	b, err := ioutilx.ReadN(r, int(n))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+// WriteFrame writes the frame prefixed by its length.
+func (LengthPrefixFramer) WriteFrame(w io.Writer, frame []byte) error {
+	if err := coder.EncodeVarInt(int64(len(frame)), w); err != nil {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+var (
+	framersMu sync.RWMutex
+	framers   = make(map[StreamID]Framer)
+)
+
+// RegisterFramer makes the DataSources and DataSinks of the given stream use
+// the given framer, instead of the standard FnAPI encoding in which the
+// windowed value coder delimits the elements. A nil framer restores the
+// standard encoding. Registration affects bundles started afterwards, and is
+// safe for concurrent use.
+func RegisterFramer(id StreamID, f Framer) {
+	framersMu.Lock()
+	defer framersMu.Unlock()
+	if f == nil {
+		delete(framers, id)
+		return
+	}
+	framers[id] = f
+}
+
+func lookupFramer(id StreamID) Framer {
+	framersMu.RLock()
+	defer framersMu.RUnlock()
+	return framers[id]
+}
+
+// framedReader unframes a data stream into the plain concatenation of the
+// encoded values.
+type framedReader struct {
+	io.ReadCloser
+	fr  FrameReader
+	buf bytes.Reader
+}
+
+func (r *framedReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		frame, err := r.fr.ReadFrame(r.ReadCloser)
+		if err != nil {
+			return 0, err
+		}
+		r.buf.Reset(frame)
+	}
+	return r.buf.Read(p)
+}
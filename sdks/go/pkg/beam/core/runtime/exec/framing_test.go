@@ -0,0 +1,82 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestRegisterFramer verifies that sources and sinks of streams with a
+// registered framer read and write framed values, and count the same bytes.
+func TestRegisterFramer(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	wc := MakeWindowEncoder(c.Window)
+	ec := MakeElementEncoder(coder.SkipW(c))
+	var in bytes.Buffer
+	var unframed int64
+	for _, v := range []int64{1, 2, 300} {
+		var b bytes.Buffer
+		EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &b)
+		ec.Encode(&FullValue{Elm: v}, &b)
+		unframed += int64(b.Len())
+		if err := (LengthPrefixFramer{}).WriteFrame(&in, b.Bytes()); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+	want := append([]byte(nil), in.Bytes()...)
+
+	sinkID := StreamID{PtransformID: "framedSink"}
+	sourceID := StreamID{PtransformID: "framedSource"}
+	RegisterFramer(sinkID, LengthPrefixFramer{})
+	RegisterFramer(sourceID, LengthPrefixFramer{})
+	defer RegisterFramer(sinkID, nil)
+	defer RegisterFramer(sourceID, nil)
+
+	sink := &DataSink{UID: 1, SID: sinkID, Coder: c}
+	source := &DataSource{UID: 2, SID: sourceID, Name: "framed", Coder: c, Out: sink}
+	out := &nopWriteCloser{}
+	counters := &StreamCounters{}
+	constructAndExecutePlanWithContext(t, []Unit{sink, source}, DataContext{
+		Data:     &TestDataManager{R: ioutil.NopCloser(&in), W: out},
+		Counters: counters,
+	})
+	if got := out.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("sink wrote %x, want %x", got, want)
+	}
+	for _, id := range []StreamID{sourceID, sinkID} {
+		if got := counters.For(id).Bytes(); got != unframed {
+			t.Errorf("bytes counted for %v = %v, want %v", id, got, unframed)
+		}
+	}
+}
+
+// TestLengthPrefixFramer_truncated verifies that a truncated frame is an
+// error rather than the end of the stream.
+func TestLengthPrefixFramer_truncated(t *testing.T) {
+	var b bytes.Buffer
+	(LengthPrefixFramer{}).WriteFrame(&b, []byte("abc"))
+	b.Truncate(2)
+	if _, err := (LengthPrefixFramer{}).ReadFrame(&b); err == nil {
+		t.Errorf("ReadFrame on truncated frame succeeded, want error")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
@@ -37,6 +38,97 @@ type FullValue struct {
 
 	Timestamp typex.EventTime
 	Windows   []typex.Window
+	Pane      typex.PaneInfo
+}
+
+var fullValuePool = sync.Pool{New: func() interface{} { return &FullValue{} }}
+
+// AcquireFullValue returns an empty FullValue from a pool, to reduce
+// allocations in hot paths. The caller owns the value until it is passed to
+// ReleaseFullValue.
+//
+// A node passing a pooled value to ProcessElement of another node may only
+// release it once the call returns, and only if the value is not retained
+// downstream. Nodes must cope with this: a node that keeps an element beyond
+// its ProcessElement call, such as to buffer it, must copy it. Values
+// received from other nodes must never be released.
+func AcquireFullValue() *FullValue {
+	return fullValuePool.Get().(*FullValue)
+}
+
+// ReleaseFullValue resets all fields of the given FullValue and returns it to
+// the pool. It must not be used afterwards.
+func ReleaseFullValue(v *FullValue) {
+	*v = FullValue{}
+	fullValuePool.Put(v)
+}
+
+// CopyKV sets the key and value of dst, Elm and Elm2, to those of src. A nil
+// Elm2 is copied too, so dst is only a KV if src is. The other fields of dst
+// are left unchanged. Custom nodes passing on KV elements in new FullValues,
+// such as in other windows, should use it rather than copying Elm alone.
+func CopyKV(dst, src *FullValue) {
+	dst.Elm, dst.Elm2 = src.Elm, src.Elm2
+}
+
+// CloneOption configures CloneFullValue.
+type CloneOption func(*cloner)
+
+type cloner struct {
+	value func(interface{}) interface{}
+}
+
+// CloneValuesWith makes CloneFullValue copy Elm and Elm2 with the given
+// function, such as to deep-copy values that are pointers, slices or maps.
+// It isn't called for nil values or for nested FullValues.
+func CloneValuesWith(fn func(interface{}) interface{}) CloneOption {
+	return func(c *cloner) {
+		c.value = fn
+	}
+}
+
+// CloneFullValue returns a copy of fv that shares no windows with it, for
+// nodes that buffer elements beyond their ProcessElement call. The Windows
+// slice is copied, and nested FullValues in Elm and Elm2 are cloned too. Other
+// values of Elm and Elm2 are copied shallowly, so values that are pointers,
+// slices or maps are still shared, unless a clone function is given with
+// CloneValuesWith. It returns nil if fv is nil.
+func CloneFullValue(fv *FullValue, opts ...CloneOption) *FullValue {
+	var c cloner
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c.clone(fv)
+}
+
+func (c *cloner) clone(fv *FullValue) *FullValue {
+	if fv == nil {
+		return nil
+	}
+	ret := &FullValue{
+		Elm:       c.cloneValue(fv.Elm),
+		Elm2:      c.cloneValue(fv.Elm2),
+		Timestamp: fv.Timestamp,
+		Pane:      fv.Pane,
+	}
+	if fv.Windows != nil {
+		ret.Windows = append(make([]typex.Window, 0, len(fv.Windows)), fv.Windows...)
+	}
+	return ret
+}
+
+func (c *cloner) cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *FullValue:
+		return c.clone(v)
+	default:
+		if c.value != nil {
+			return c.value(v)
+		}
+		return v
+	}
 }
 
 func (v *FullValue) String() string {
@@ -16,6 +16,7 @@
 package exec
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -228,3 +229,89 @@ func TestConvert(t *testing.T) {
 		})
 	}
 }
+
+// mapNode is a test Node that forwards the square of each int element in a
+// new FullValue, optionally taken from the pool.
+type mapNode struct {
+	Discard
+	Out    Node
+	Pooled bool
+}
+
+func (n *mapNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	i := elm.Elm.(int)
+	if !n.Pooled {
+		return n.Out.ProcessElement(ctx, &FullValue{Elm: i * i, Timestamp: elm.Timestamp, Windows: elm.Windows})
+	}
+	v := AcquireFullValue()
+	v.Elm, v.Timestamp, v.Windows = i*i, elm.Timestamp, elm.Windows
+	err := n.Out.ProcessElement(ctx, v)
+	ReleaseFullValue(v)
+	return err
+}
+
+// TestReleaseFullValue verifies that released values are reset.
+func TestReleaseFullValue(t *testing.T) {
+	v := AcquireFullValue()
+	v.Elm, v.Elm2, v.Timestamp, v.Windows = 1, 2, 3, window.SingleGlobalWindow
+	ReleaseFullValue(v)
+	if v.Elm != nil || v.Elm2 != nil || v.Timestamp != 0 || v.Windows != nil {
+		t.Errorf("released value not reset: %v", v)
+	}
+}
+
+// TestCloneFullValue verifies that clones are independent of the original.
+func TestCloneFullValue(t *testing.T) {
+	w := []typex.Window{window.IntervalWindow{Start: 0, End: 10}, window.IntervalWindow{Start: 10, End: 20}}
+	nested := &FullValue{Elm: "k", Elm2: "v", Windows: w}
+	values := []int{1, 2}
+	fv := &FullValue{Elm: nested, Elm2: values, Timestamp: 5, Windows: w, Pane: typex.PaneInfo{Index: 3}}
+
+	c := CloneFullValue(fv)
+	if !reflect.DeepEqual(c, fv) {
+		t.Fatalf("CloneFullValue(%v) = %v, want equal value", fv, c)
+	}
+	c.Windows[0] = window.GlobalWindow{}
+	if _, ok := fv.Windows[0].(window.IntervalWindow); !ok {
+		t.Errorf("clone shares windows with original: %v", fv.Windows)
+	}
+	c.Elm.(*FullValue).Elm = "other"
+	if fv.Elm.(*FullValue).Elm != "k" {
+		t.Errorf("clone shares nested FullValue with original: %v", fv.Elm)
+	}
+	// Other values are shallow copies by default.
+	c.Elm2.([]int)[0] = 7
+	if values[0] != 7 {
+		t.Errorf("clone doesn't share values with original: %v", values)
+	}
+
+	c = CloneFullValue(fv, CloneValuesWith(func(v interface{}) interface{} {
+		if vs, ok := v.([]int); ok {
+			return append([]int(nil), vs...)
+		}
+		return v
+	}))
+	c.Elm2.([]int)[1] = 8
+	if values[1] != 2 {
+		t.Errorf("clone with value function shares values with original: %v", values)
+	}
+	if got := CloneFullValue(nil); got != nil {
+		t.Errorf("CloneFullValue(nil) = %v, want nil", got)
+	}
+}
+
+func benchmarkMap(b *testing.B, pooled bool) {
+	ctx := context.Background()
+	n := &mapNode{Out: &Discard{}, Pooled: pooled}
+	elm := &FullValue{Elm: 7, Windows: window.SingleGlobalWindow}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := n.ProcessElement(ctx, elm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMap_allocated(b *testing.B) { benchmarkMap(b, false) }
+func BenchmarkMap_pooled(b *testing.B)    { benchmarkMap(b, true) }
@@ -0,0 +1,114 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+// BuildOption configures how UnmarshalPlan builds a plan.
+type BuildOption func(*builder)
+
+// WithCombineFusion makes UnmarshalPlan fuse precombines feeding a single
+// Flatten. If all inputs of a Flatten are precombines of the same commutative
+// CombineFn, they are replaced by a single precombine after the Flatten, so
+// that the accumulators of all inputs are merged in-process and fewer
+// elements are passed downstream. The fused precombine reports its metrics
+// under the transform of the first of the replaced ones. CombineFns that
+// don't embed graph.Commutative are never fused, since fusion changes the
+// order in which their inputs are added.
+func WithCombineFusion() BuildOption {
+	return func(b *builder) {
+		b.fuseCombines = true
+	}
+}
+
+// precombine returns the Combine of a LiftedCombine or PartialCombine.
+func precombine(u Unit) (*Combine, bool) {
+	switch n := u.(type) {
+	case *LiftedCombine:
+		return n.Combine, true
+	case *PartialCombine:
+		return n.Combine, true
+	default:
+		return nil, false
+	}
+}
+
+// fuseCombines moves the precombines feeding a Flatten after it, if they can
+// be fused, and returns the remaining units.
+func fuseCombines(units []Unit) []Unit {
+	removed := make(map[Unit]bool)
+	for _, u := range units {
+		f, ok := u.(*Flatten)
+		if !ok {
+			continue
+		}
+		var feeders []Unit
+		for _, v := range units {
+			for _, out := range outputs(v) {
+				if out == Node(f) {
+					feeders = append(feeders, v)
+				}
+			}
+		}
+		if !fusable(f, feeders) {
+			continue
+		}
+
+		// Feed the Flatten from the inputs of the precombines, and the
+		// first precombine from the Flatten.
+		for _, v := range feeders {
+			replaceNode(units, v.(Node), f)
+		}
+		first, _ := precombine(feeders[0])
+		first.Out, f.Out = f.Out, feeders[0].(Node)
+		for _, v := range feeders[1:] {
+			removed[v] = true
+		}
+	}
+
+	var ret []Unit
+	for _, u := range units {
+		if !removed[u] {
+			ret = append(ret, u)
+		}
+	}
+	return ret
+}
+
+// fusable returns whether the feeders of the Flatten are precombines that can
+// be fused into one.
+func fusable(f *Flatten, feeders []Unit) bool {
+	if len(feeders) < 2 || len(feeders) != f.N {
+		return false
+	}
+	first, _ := precombine(feeders[0])
+	if !first.Fn.IsCommutative() {
+		return false
+	}
+	for _, v := range feeders {
+		c, ok := precombine(v)
+		if !ok || c.Out != Node(f) || c.Fn.Name() != first.Fn.Name() || c.UsesKey != first.UsesKey {
+			return false
+		}
+		if _, ok := v.(*PartialCombine); ok != isPartialCombine(feeders[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPartialCombine(u Unit) bool {
+	_, ok := u.(*PartialCombine)
+	return ok
+}
@@ -0,0 +1,117 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+// commutativeSumFn is a CombineFn declaring that input order doesn't matter.
+type commutativeSumFn struct {
+	graph.Commutative
+}
+
+func (fn *commutativeSumFn) MergeAccumulators(a, b int) int {
+	return a + b
+}
+
+// concatFn is a CombineFn whose result depends on the order of its inputs.
+type concatFn struct{}
+
+func (fn *concatFn) MergeAccumulators(a, b string) string {
+	return a + b
+}
+
+// TestFuseCombines verifies that precombines of a commutative CombineFn
+// feeding a Flatten are fused into one, reducing the accumulators passed
+// downstream without changing the results, and that others aren't fused.
+func TestFuseCombines(t *testing.T) {
+	keyCoder := intCoder(reflectx.Int)
+	wc := coder.NewGlobalWindow()
+	newCombine := map[string]func(out Node, fn *graph.CombineFn, uid UnitID) Node{
+		"lifted": func(out Node, fn *graph.CombineFn, uid UnitID) Node {
+			return &LiftedCombine{Combine: &Combine{UID: uid, Fn: fn, Out: out}, KeyCoder: keyCoder, WindowCoder: wc}
+		},
+		"partial": func(out Node, fn *graph.CombineFn, uid UnitID) Node {
+			n := NewPartialCombine(out, fn, keyCoder, 10)
+			n.UID = uid
+			return n
+		},
+	}
+	tests := []struct {
+		fn          interface{}
+		in1, in2    []interface{}
+		want        []interface{}
+		fusedAccums int
+	}{
+		{fn: &commutativeSumFn{}, in1: []interface{}{1, 2}, in2: []interface{}{3, 4}, want: []interface{}{10, 10}, fusedAccums: 2},
+		{fn: &concatFn{}, in1: []interface{}{"a", "b"}, in2: []interface{}{"c", "d"}, want: []interface{}{"abcd", "abcd"}, fusedAccums: 4},
+	}
+	for kind, newCombine := range newCombine {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%v_%T", kind, test.fn), func(t *testing.T) {
+				edge := getCombineEdge(t, test.fn, reflectx.Int, nil)
+				run := func(fuse bool) ([]FullValue, int) {
+					out := &CaptureNode{UID: 1}
+					extract := &ExtractOutput{Combine: &Combine{UID: 2, Fn: edge.CombineFn, Out: out}}
+					merge := &MergeAccumulators{Combine: &Combine{UID: 3, Fn: edge.CombineFn, Out: extract}}
+					gbk := &simpleGBK{UID: 4, KeyCoder: keyCoder, WindowCoder: wc, Out: merge}
+					counter := &countNode{Node: gbk}
+					flatten := &Flatten{UID: 5, N: 2, Out: counter}
+					pre1 := newCombine(flatten, edge.CombineFn, 6)
+					pre2 := newCombine(flatten, edge.CombineFn, 7)
+					in := func(vs []interface{}) []MainInput {
+						return append(makeKVInput(1, vs...), makeKVInput(2, vs...)...)
+					}
+					root1 := &FixedRoot{UID: 8, Elements: in(test.in1), Out: pre1}
+					root2 := &FixedRoot{UID: 9, Elements: in(test.in2), Out: pre2}
+
+					units := []Unit{root1, root2, pre1, pre2, flatten, gbk, merge, extract, out}
+					if fuse {
+						units = fuseCombines(units)
+					}
+					constructAndExecutePlan(t, units)
+					sort.Slice(out.Elements, func(i, j int) bool {
+						return out.Elements[i].Elm.(int) < out.Elements[j].Elm.(int)
+					})
+					return out.Elements, counter.count
+				}
+
+				want := append(makeKV(1, test.want[0]), makeKV(2, test.want[1])...)
+				got, accums := run(false)
+				if !equalList(got, want) {
+					t.Errorf("unfused combine = %v, want %v", extractKeyedValues(got...), extractKeyedValues(want...))
+				}
+				if accums != 4 {
+					t.Errorf("unfused combine passed %v accumulators, want 4", accums)
+				}
+				got, accums = run(true)
+				if !equalList(got, want) {
+					t.Errorf("fused combine = %v, want %v", extractKeyedValues(got...), extractKeyedValues(want...))
+				}
+				if accums != test.fusedAccums {
+					t.Errorf("fused combine passed %v accumulators, want %v", accums, test.fusedAccums)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// gbkSpills counts the value buffers spilled to disk by GroupByKey nodes.
+var gbkSpills = metrics.NewCounter("exec", "groupByKey.spills")
+
+// GroupByKeyOption configures a GroupByKey.
+type GroupByKeyOption func(*GroupByKey)
+
+// SpillThreshold makes the node spill the values of a key and window to disk
+// once more than the given number of them are held in memory.
+func SpillThreshold(values int) GroupByKeyOption {
+	return func(n *GroupByKey) {
+		n.SpillThreshold = values
+	}
+}
+
+// SpillDir sets the directory for spill files. It defaults to the default
+// directory for temporary files.
+func SpillDir(dir string) GroupByKeyOption {
+	return func(n *GroupByKey) {
+		n.SpillDir = dir
+	}
+}
+
+// GroupByKey groups the KV elements of a bundle by key and window. On
+// FinishBundle, it passes each group to Out as its key, in the window and
+// the pane of its first element, with the end of window timestamp, and a
+// ReStream of its values, with their timestamps and panes, in order of first
+// appearance of the groups. The ReStreams are valid until Out is finished.
+//
+// If SpillThreshold is positive, the values of a group are appended to a
+// temporary file in SpillDir whenever more than SpillThreshold of them are
+// held in memory, and read back lazily as the ReStream is iterated. Spill
+// files are removed at the end of each bundle, whether it succeeds or not.
+//
+// Up fails with a NonDeterministicKeyError if the key coder is known not to
+// be deterministic, and the first keys of each bundle are checked if it isn't
+// known to be.
+type GroupByKey struct {
+	UID UnitID
+	// Coder is the windowed KV coder of the input elements.
+	Coder *coder.Coder
+	Out   Node
+
+	SpillThreshold int
+	SpillDir       string
+
+	keys   *keyCheck
+	hasher elementHasher
+	enc    ElementEncoder
+	dec    ElementDecoder
+	groups map[uint64]*gbkGroup
+	order  []*gbkGroup
+
+	checkKV bool
+}
+
+// gbkGroup holds the values of a key and window. The values in memory follow
+// those spilled to the file, if any.
+type gbkGroup struct {
+	key     FullValue
+	values  []FullValue
+	f       *os.File
+	w       *bufio.Writer
+	spilled int
+}
+
+// NewGroupByKey returns a node that groups the elements with the given
+// windowed KV coder by key and window, and passes the groups to out.
+func NewGroupByKey(uid UnitID, c *coder.Coder, out Node, opts ...GroupByKeyOption) *GroupByKey {
+	n := &GroupByKey{UID: uid, Coder: c, Out: out}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func (n *GroupByKey) ID() UnitID {
+	return n.UID
+}
+
+// Up initializes the key hasher and value coders.
+func (n *GroupByKey) Up(ctx context.Context) error {
+	if err := checkKVCoder(n.UID, "", n.Coder, nil, nil); err != nil {
+		return err
+	}
+	if !coder.IsW(n.Coder) {
+		return errors.Errorf("group by key %v has no windowed KV coder: %v", n.UID, n.Coder)
+	}
+	kv := coder.SkipW(n.Coder)
+	known, err := verifyDeterministic("", kv.Components[0])
+	if err != nil {
+		return errors.WithContextf(err, "group by key %v", n.UID)
+	}
+	n.keys = nil
+	if !known {
+		n.keys = newKeyCheck("", kv.Components[0])
+	}
+	n.hasher = makeElementHasher(kv.Components[0], n.Coder.Window)
+	n.enc = MakeElementEncoder(kv.Components[1])
+	n.dec = MakeElementDecoder(kv.Components[1])
+	return nil
+}
+
+func (n *GroupByKey) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.reset()
+	n.groups = make(map[uint64]*gbkGroup)
+	n.checkKV = isKVCheck(ctx, n.Coder)
+	if n.keys != nil {
+		n.keys.reset()
+	}
+	return MultiStartBundle(ctx, id, data, n.Out)
+}
+
+// ProcessElement adds the value of the element to its group in each of its
+// windows, spilling the values of a group if they exceed the threshold.
+func (n *GroupByKey) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("group by key %v does not support GBK/CoGBK results", n.UID)
+	}
+	if n.checkKV && elm.Elm2 == nil {
+		return errors.Errorf("KV element %v without value at group by key %v", elm, n.UID)
+	}
+	if n.keys != nil {
+		if err := n.keys.check(elm.Elm); err != nil {
+			return errors.WithContextf(err, "group by key %v", n.UID)
+		}
+	}
+	for _, w := range elm.Windows {
+		h, err := n.hasher.Hash(elm.Elm, w)
+		if err != nil {
+			return errors.WithContextf(err, "hashing key %v of group by key %v", elm.Elm, n.UID)
+		}
+		g, ok := n.groups[h]
+		if !ok {
+			g = &gbkGroup{key: FullValue{Elm: elm.Elm, Timestamp: w.MaxTimestamp(), Windows: []typex.Window{w}, Pane: elm.Pane}}
+			n.groups[h] = g
+			n.order = append(n.order, g)
+		}
+		g.values = append(g.values, FullValue{Elm: elm.Elm2, Timestamp: elm.Timestamp, Pane: elm.Pane})
+		if n.SpillThreshold > 0 && len(g.values) > n.SpillThreshold {
+			if err := n.spill(g); err != nil {
+				return errors.WithContextf(err, "spilling values of key %v of group by key %v", elm.Elm, n.UID)
+			}
+			gbkSpills.Inc(ctx, 1)
+		}
+	}
+	return nil
+}
+
+// spill appends the values of the group in memory to its spill file.
+func (n *GroupByKey) spill(g *gbkGroup) error {
+	if g.f == nil {
+		f, err := ioutil.TempFile(n.SpillDir, "beam-gbk-")
+		if err != nil {
+			return err
+		}
+		g.f = f
+		g.w = bufio.NewWriter(f)
+	}
+	for i := range g.values {
+		v := &g.values[i]
+		if err := coder.EncodeEventTime(v.Timestamp, g.w); err != nil {
+			return err
+		}
+		if err := coder.EncodePane(v.Pane, g.w); err != nil {
+			return err
+		}
+		if err := n.enc.Encode(v, g.w); err != nil {
+			return err
+		}
+	}
+	g.spilled += len(g.values)
+	g.values = g.values[:0]
+	return nil
+}
+
+// FinishBundle passes the groups to Out and finishes it. Spill files are
+// removed afterwards, even on failure.
+func (n *GroupByKey) FinishBundle(ctx context.Context) error {
+	defer n.reset()
+	for _, g := range n.order {
+		if g.w != nil {
+			if err := g.w.Flush(); err != nil {
+				return errors.WithContextf(err, "writing spill file of group by key %v", n.UID)
+			}
+		}
+		if err := n.Out.ProcessElement(ctx, &g.key, &gbkValues{g: g, dec: n.dec}); err != nil {
+			return err
+		}
+	}
+	return MultiFinishBundle(ctx, n.Out)
+}
+
+// Down removes any spill files.
+func (n *GroupByKey) Down(ctx context.Context) error {
+	n.reset()
+	return nil
+}
+
+// reset drops the groups and removes their spill files.
+func (n *GroupByKey) reset() {
+	for _, g := range n.order {
+		if g.f != nil {
+			g.f.Close()
+			os.Remove(g.f.Name())
+		}
+	}
+	n.groups = nil
+	n.order = nil
+}
+
+func (n *GroupByKey) String() string {
+	return fmt.Sprintf("GroupByKey[spill %v:%q]. Out:%v", n.SpillThreshold, n.SpillDir, n.Out.ID())
+}
+
+// gbkValues is a ReStream of the values of a group.
+type gbkValues struct {
+	g   *gbkGroup
+	dec ElementDecoder
+}
+
+func (s *gbkValues) Open() (Stream, error) {
+	ret := &gbkStream{g: s.g, dec: s.dec}
+	if s.g.f != nil {
+		f, err := os.Open(s.g.f.Name())
+		if err != nil {
+			return nil, err
+		}
+		ret.f = f
+		ret.r = bufio.NewReader(f)
+	}
+	return ret, nil
+}
+
+// gbkStream reads the spilled values of a group, followed by those in memory.
+type gbkStream struct {
+	g   *gbkGroup
+	dec ElementDecoder
+
+	f    *os.File
+	r    *bufio.Reader
+	read int
+}
+
+func (s *gbkStream) Read() (*FullValue, error) {
+	if s.read < s.g.spilled {
+		t, err := coder.DecodeEventTime(s.r)
+		if err != nil {
+			return nil, err
+		}
+		pn, err := coder.DecodePane(s.r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := s.dec.Decode(s.r)
+		if err != nil {
+			return nil, err
+		}
+		v.Timestamp, v.Pane = t, pn
+		s.read++
+		return v, nil
+	}
+	i := s.read - s.g.spilled
+	if i >= len(s.g.values) {
+		return nil, io.EOF
+	}
+	s.read++
+	return &s.g.values[i], nil
+}
+
+func (s *gbkStream) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
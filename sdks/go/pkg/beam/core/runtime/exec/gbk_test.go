@@ -0,0 +1,134 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// groupCollector is a test Node that reads the values of each group twice,
+// and fails on the key Fail, if set.
+type groupCollector struct {
+	UID    UnitID
+	Fail   interface{}
+	Groups map[interface{}][]interface{}
+}
+
+func (n *groupCollector) ID() UnitID                   { return n.UID }
+func (n *groupCollector) Up(ctx context.Context) error { return nil }
+func (n *groupCollector) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.Groups = make(map[interface{}][]interface{})
+	return nil
+}
+func (n *groupCollector) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if elm.Elm == n.Fail {
+		return errors.Errorf("failed on %v", elm.Elm)
+	}
+	var first []interface{}
+	for i := 0; i < 2; i++ {
+		vs, err := ReadAll(values[0])
+		if err != nil {
+			return err
+		}
+		got := extractValues(vs...)
+		if i == 1 && !reflect.DeepEqual(got, first) {
+			return errors.Errorf("second iteration of %v = %v, want %v", elm.Elm, got, first)
+		}
+		first = got
+	}
+	n.Groups[elm.Elm] = first
+	return nil
+}
+func (n *groupCollector) FinishBundle(ctx context.Context) error { return nil }
+func (n *groupCollector) Down(ctx context.Context) error         { return nil }
+
+// TestGroupByKey verifies that values are grouped by key, in order, both in
+// memory and when spilled, and that spill files are removed.
+func TestGroupByKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gbk")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var in []MainInput
+	for i := 0; i < 7; i++ {
+		in = append(in, MainInput{Key: FullValue{Elm: "hot", Elm2: int64(i), Windows: window.SingleGlobalWindow}})
+	}
+	in = append(in, MainInput{Key: FullValue{Elm: "cold", Elm2: int64(7), Windows: window.SingleGlobalWindow}})
+	want := map[interface{}][]interface{}{
+		"hot":  {int64(0), int64(1), int64(2), int64(3), int64(4), int64(5), int64(6)},
+		"cold": {int64(7)},
+	}
+	c := coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()}), coder.NewGlobalWindow())
+
+	tests := []struct {
+		name   string
+		opts   []GroupByKeyOption
+		fail   interface{}
+		spills int64
+	}{
+		{name: "memory"},
+		{name: "spill", opts: []GroupByKeyOption{SpillThreshold(2), SpillDir(dir)}, spills: 2},
+		{name: "failure", opts: []GroupByKeyOption{SpillThreshold(2), SpillDir(dir)}, fail: "cold", spills: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &groupCollector{UID: 1, Fail: test.fail}
+			n := NewGroupByKey(2, c, out, test.opts...)
+			root := &FixedRoot{UID: 3, Elements: in, Out: n}
+			p, err := NewPlan("a", []Unit{root, n, out})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{})
+			if test.fail == nil {
+				if err != nil {
+					t.Fatalf("execute failed: %v", err)
+				}
+				if !reflect.DeepEqual(out.Groups, want) {
+					t.Errorf("group by key = %v, want %v", out.Groups, want)
+				}
+			} else if err == nil {
+				t.Errorf("execute succeeded, want failure on %v", test.fail)
+			}
+			if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+				t.Errorf("spill files left behind: %v", len(files))
+			}
+
+			var spills int64
+			metrics.Extractor{
+				SumInt64: func(l metrics.Labels, v int64) {
+					if l.Name() == "groupByKey.spills" {
+						spills = v
+					}
+				},
+			}.ExtractFrom(p.Store())
+			if spills != test.spills {
+				t.Errorf("spills = %v, want %v", spills, test.spills)
+			}
+		})
+	}
+}
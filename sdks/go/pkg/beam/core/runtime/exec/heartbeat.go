@@ -0,0 +1,67 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+var dataSourceHeartbeats = metrics.NewCounter("exec", "dataSource.heartbeats")
+
+// WithHeartbeat makes UnmarshalPlan set up the DataSources of unbounded
+// PCollections to send heartbeats whenever no data arrives for the idle
+// duration. A heartbeat is not an element: it passes the last watermark
+// reported with ReportWatermark to Out, if it is a WatermarkObserver, so that
+// downstream windows close while the source is quiet. Watermarks otherwise
+// only reach Out along with the next element. Heartbeats never advance the
+// watermark themselves: unless the source calls ReportWatermark, nothing
+// advances, and heartbeats pass nothing downstream. Heartbeats are sent each idle
+// duration until data arrives again, and are counted in the
+// dataSource.heartbeats counter. Bounded sources never send them.
+func WithHeartbeat(idle time.Duration) BuildOption {
+	return func(b *builder) {
+		b.heartbeatIdle = idle
+	}
+}
+
+// heartbeatIdle returns the idle duration after which the source sends
+// heartbeats, or 0 if it doesn't send any.
+func (n *DataSource) heartbeatIdle() time.Duration {
+	if !n.Unbounded || n.HeartbeatIdle <= 0 {
+		return 0
+	}
+	return n.HeartbeatIdle
+}
+
+// heartbeat passes the last reported watermark to Out, if there is one.
+func (n *DataSource) heartbeat(ctx context.Context) error {
+	n.mu.Lock()
+	wm := n.watermark
+	n.wmPending = false
+	n.mu.Unlock()
+	if wm == mtime.MinTimestamp {
+		return nil
+	}
+	dataSourceHeartbeats.Inc(ctx, 1)
+	if o, ok := n.Out.(WatermarkObserver); ok {
+		return o.ProcessWatermark(ctx, wm)
+	}
+	return nil
+}
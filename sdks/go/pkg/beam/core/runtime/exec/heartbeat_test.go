@@ -0,0 +1,120 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// watermarkNode is a test Node that captures elements, and sends the
+// watermarks passed to it to a channel.
+type watermarkNode struct {
+	CaptureNode
+	wms chan mtime.Time
+}
+
+func (n *watermarkNode) ProcessWatermark(ctx context.Context, wm mtime.Time) error {
+	n.wms <- wm
+	return nil
+}
+
+// TestDataSource_heartbeat verifies that an unbounded source passes the
+// reported watermark downstream while no data arrives, and that a bounded
+// source doesn't.
+func TestDataSource_heartbeat(t *testing.T) {
+	for _, unbounded := range []bool{true, false} {
+		c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+		out := &watermarkNode{CaptureNode: CaptureNode{UID: 1}, wms: make(chan mtime.Time, 100)}
+		source := &DataSource{
+			UID:           2,
+			SID:           StreamID{PtransformID: "myPTransform"},
+			Name:          "heartbeat",
+			Coder:         c,
+			Out:           out,
+			Unbounded:     unbounded,
+			HeartbeatIdle: 10 * time.Millisecond,
+		}
+		pr, pw := io.Pipe()
+		wc := MakeWindowEncoder(c.Window)
+		ec := MakeElementEncoder(coder.SkipW(c))
+		write := func(d time.Duration) {
+			EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.FromDuration(d), typex.NoFiringPane(), pw)
+			ec.Encode(&FullValue{Elm: int64(d)}, pw)
+		}
+		idle := make(chan []mtime.Time, 1)
+		go func() {
+			write(time.Second)
+			source.ReportWatermark(time.Unix(10, 0))
+			// Collect the watermarks passed downstream while the source is
+			// idle, before sending more data.
+			var wms []mtime.Time
+			timeout := time.After(200 * time.Millisecond)
+		loop:
+			for len(wms) < 2 {
+				select {
+				case wm := <-out.wms:
+					wms = append(wms, wm)
+				case <-timeout:
+					break loop
+				}
+			}
+			idle <- wms
+			write(15 * time.Second)
+			pw.Close()
+		}()
+
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(context.Background(), "1", DataContext{Data: &TestDataManager{R: pr}}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		got := <-idle
+		if unbounded {
+			want := mtime.FromDuration(10 * time.Second)
+			if len(got) != 2 || got[0] != want || got[1] != want {
+				t.Errorf("unbounded source: watermarks while idle = %v, want heartbeats at %v", got, want)
+			}
+		} else if len(got) != 0 {
+			t.Errorf("bounded source: watermarks while idle = %v, want none", got)
+		}
+		if len(out.Elements) != 2 {
+			t.Errorf("unbounded %v: processed %v elements, want 2", unbounded, len(out.Elements))
+		}
+	}
+}
+
+// TestDataSource_heartbeatSplit verifies that a split ends the bundle of an
+// unbounded source sending heartbeats while its stream is still open.
+func TestDataSource_heartbeatSplit(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	executeSplitOpenStream(t, context.Background(), &DataSource{
+		UID:           2,
+		SID:           StreamID{PtransformID: "myPTransform"},
+		Coder:         c,
+		Unbounded:     true,
+		HeartbeatIdle: 10 * time.Millisecond,
+	})
+}
@@ -0,0 +1,55 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// InterceptNode wraps a node and rewrites each element with Transform before
+// passing it on, such as to redact fields without changing the DoFn of the
+// node. If Transform returns an error, the bundle fails. If it returns nil and
+// no error, the element is dropped.
+type InterceptNode struct {
+	Node
+	Transform func(*FullValue) (*FullValue, error)
+}
+
+// NewInterceptNode returns a node that passes the elements rewritten by
+// transform to out.
+func NewInterceptNode(out Node, transform func(*FullValue) (*FullValue, error)) *InterceptNode {
+	return &InterceptNode{Node: out, Transform: transform}
+}
+
+// ProcessElement rewrites the element and forwards the result, if any, to the
+// wrapped node.
+func (n *InterceptNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	v, err := n.Transform(elm)
+	if err != nil {
+		return errors.WithContextf(err, "intercepting element at node %v", n.ID())
+	}
+	if v == nil {
+		return nil
+	}
+	return n.Node.ProcessElement(ctx, v, values...)
+}
+
+func (n *InterceptNode) String() string {
+	return fmt.Sprintf("InterceptNode. Node:%v", n.Node)
+}
@@ -0,0 +1,81 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// TestInterceptNode verifies that elements are rewritten, dropped or fail the
+// bundle as determined by the transform, and that the wrapped node still sees
+// the bundle lifecycle.
+func TestInterceptNode(t *testing.T) {
+	transform := func(elm *FullValue) (*FullValue, error) {
+		switch v := elm.Elm.(int); {
+		case v < 0:
+			return nil, errors.Errorf("negative element %v", v)
+		case v%2 == 0:
+			return nil, nil
+		default:
+			ret := *elm
+			ret.Elm = v * 10
+			return &ret, nil
+		}
+	}
+	tests := []struct {
+		name string
+		in   []interface{}
+		want []interface{}
+		fail bool
+	}{
+		{name: "rewrite", in: []interface{}{1, 3}, want: []interface{}{10, 30}},
+		{name: "drop", in: []interface{}{1, 2, 3, 4}, want: []interface{}{10, 30}},
+		{name: "failure", in: []interface{}{1, -1, 3}, fail: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewInterceptNode(out, transform)
+			root := &FixedRoot{UID: 2, Elements: makeInput(test.in...), Out: n}
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{})
+			if test.fail {
+				if err == nil {
+					t.Fatal("execute succeeded, want transform error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if err := p.Down(context.Background()); err != nil {
+				t.Fatalf("down failed: %v", err)
+			}
+			if want := makeValues(test.want...); !equalList(out.Elements, want) {
+				t.Errorf("intercept node = %v, want %v", extractValues(out.Elements...), extractValues(want...))
+			}
+			if out.status != Down {
+				t.Errorf("wrapped node status = %v, want Down", out.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+var keySamplerSampled = metrics.NewCounter("exec", "keySampler.sampled")
+
+// defaultKeySamplerK is the default number of keys tracked by a KeySampler.
+const defaultKeySamplerK = 10
+
+// KeyCount is the approximate number of elements with a key in a bundle.
+type KeyCount struct {
+	// Key is the key of the first sampled element counted for it.
+	Key interface{}
+	// Count is the estimated number of elements with the key, scaled by the
+	// sampling rate. It overestimates the count by at most Error.
+	Count int64
+	// Error is the maximum overestimation of Count.
+	Error int64
+}
+
+// keyCounter is a counter of sampled elements in the sketch of a KeySampler.
+type keyCounter struct {
+	key   interface{}
+	count int64
+	err   int64
+}
+
+// KeySampler is a profiling tap that samples the keys of KV elements passed to
+// the wrapped node, to find skewed keys. Each element is sampled with
+// probability Rate, and its key counted in a Space-Saving sketch of K counters,
+// which finds the most frequent keys in bounded memory. At the end of each
+// bundle, the top keys are logged with their approximate counts. Elements are
+// forwarded unchanged.
+type KeySampler struct {
+	Node
+	// KeyCoder encodes the keys, which are counted by their encoding.
+	KeyCoder *coder.Coder
+	// Rate is the probability of sampling an element. If 0, nothing is
+	// sampled.
+	Rate float64
+	// K is the number of keys tracked. Defaults to 10.
+	K int
+
+	enc     ElementEncoder
+	rand    *rand.Rand
+	buf     bytes.Buffer
+	sketch  map[string]*keyCounter
+	sampled int64
+	top     []KeyCount
+}
+
+// NewKeySampler returns a node that samples the keys of the elements passed
+// to out with the given probability.
+func NewKeySampler(out Node, keyCoder *coder.Coder, rate float64) *KeySampler {
+	return &KeySampler{Node: out, KeyCoder: keyCoder, Rate: rate, K: defaultKeySamplerK}
+}
+
+// Up initializes the key encoder and brings up the wrapped node.
+func (n *KeySampler) Up(ctx context.Context) error {
+	n.enc = MakeElementEncoder(n.KeyCoder)
+	n.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	return n.Node.Up(ctx)
+}
+
+// StartBundle clears the sketch and starts the wrapped node.
+func (n *KeySampler) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.sketch = make(map[string]*keyCounter)
+	n.sampled = 0
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement samples the key of the element and forwards it to the
+// wrapped node.
+func (n *KeySampler) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.Rate > 0 && (n.Rate >= 1 || n.rand.Float64() < n.Rate) {
+		if err := n.sample(elm.Elm); err != nil {
+			return errors.Wrapf(err, "failed to sample key of %v at node %v", elm, n.ID())
+		}
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+// sample counts the key in the sketch. If the sketch is full, the key with
+// the lowest count is replaced, and its count inherited as the error.
+func (n *KeySampler) sample(key interface{}) error {
+	n.buf.Reset()
+	if err := n.enc.Encode(&FullValue{Elm: key}, &n.buf); err != nil {
+		return err
+	}
+	n.sampled++
+	if c, ok := n.sketch[string(n.buf.Bytes())]; ok {
+		c.count++
+		return nil
+	}
+	if len(n.sketch) < n.k() {
+		n.sketch[n.buf.String()] = &keyCounter{key: key, count: 1}
+		return nil
+	}
+	var minKey string
+	var min *keyCounter
+	for k, c := range n.sketch {
+		if min == nil || c.count < min.count {
+			minKey, min = k, c
+		}
+	}
+	delete(n.sketch, minKey)
+	n.sketch[n.buf.String()] = &keyCounter{key: key, count: min.count + 1, err: min.count}
+	return nil
+}
+
+func (n *KeySampler) k() int {
+	if n.K <= 0 {
+		return defaultKeySamplerK
+	}
+	return n.K
+}
+
+// FinishBundle logs the top keys of the bundle and finishes the wrapped
+// node.
+func (n *KeySampler) FinishBundle(ctx context.Context) error {
+	n.top = make([]KeyCount, 0, len(n.sketch))
+	for _, c := range n.sketch {
+		n.top = append(n.top, KeyCount{Key: c.key, Count: n.scale(c.count), Error: n.scale(c.err)})
+	}
+	sort.SliceStable(n.top, func(i, j int) bool {
+		return n.top[i].Count > n.top[j].Count
+	})
+	if n.sampled > 0 {
+		keySamplerSampled.Inc(ctx, n.sampled)
+		log.Info(ctx, n.format())
+	}
+	return n.Node.FinishBundle(ctx)
+}
+
+// scale estimates the number of elements from the number sampled.
+func (n *KeySampler) scale(c int64) int64 {
+	if n.Rate >= 1 {
+		return c
+	}
+	return int64(float64(c)/n.Rate + 0.5)
+}
+
+func (n *KeySampler) format() string {
+	var parts []string
+	for _, kc := range n.top {
+		parts = append(parts, fmt.Sprintf("%v: ~%v (±%v)", kc.Key, kc.Count, kc.Error))
+	}
+	return fmt.Sprintf("key sampler at node %v: top keys of %v sampled elements: %v", n.ID(), n.sampled, strings.Join(parts, ", "))
+}
+
+// TopKeys returns the most frequent keys of the last finished bundle with
+// their approximate counts, in decreasing order of count.
+func (n *KeySampler) TopKeys() []KeyCount {
+	return n.top
+}
+
+func (n *KeySampler) String() string {
+	return fmt.Sprintf("KeySampler[%v]. Node:%v", n.Rate, n.Node)
+}
@@ -0,0 +1,112 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+)
+
+// makeKeyInputs returns a KV<K, V> main input for each of the keys.
+func makeKeyInputs(keys ...string) []MainInput {
+	var ret []MainInput
+	for i, k := range keys {
+		ret = append(ret, MainInput{Key: FullValue{Elm: k, Elm2: i}})
+	}
+	return ret
+}
+
+// TestKeySampler verifies that the most frequent keys are found with their
+// approximate counts, and that all elements are forwarded.
+func TestKeySampler(t *testing.T) {
+	keys := []string{"c", "a", "a", "a", "a", "a", "b", "b", "b"}
+	tests := []struct {
+		name string
+		rate float64
+		want []KeyCount
+	}{
+		{name: "disabled", rate: 0, want: []KeyCount{}},
+		// c is evicted by b, which inherits its count as the error.
+		{name: "all", rate: 1, want: []KeyCount{{Key: "a", Count: 5}, {Key: "b", Count: 4, Error: 1}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewKeySampler(out, coder.NewString(), test.rate)
+			n.K = 2
+			root := &FixedRoot{UID: 2, Elements: makeKeyInputs(keys...), Out: n}
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if got := len(out.Elements); got != len(keys) {
+				t.Errorf("forwarded %v elements, want %v", got, len(keys))
+			}
+			if got := n.TopKeys(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("TopKeys() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestKeySampler_rate verifies that counts of sampled keys are scaled by the
+// sampling rate.
+func TestKeySampler_rate(t *testing.T) {
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, "hot")
+	}
+	out := &CaptureNode{UID: 1}
+	n := NewKeySampler(out, coder.NewString(), 0.5)
+	root := &FixedRoot{UID: 2, Elements: makeKeyInputs(keys...), Out: n}
+	p, err := NewPlan("a", []Unit{root, n})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	top := n.TopKeys()
+	if len(top) != 1 || top[0].Key != "hot" || top[0].Count < 800 || top[0].Count > 1200 {
+		t.Errorf("TopKeys() = %v, want about 1000 of hot", top)
+	}
+}
+
+// BenchmarkKeySampler_disabled measures the overhead of an unsampled
+// KeySampler.
+//
+// BenchmarkKeySampler_disabled 	298690614	         4.050 ns/op	       0 B/op	       0 allocs/op
+func BenchmarkKeySampler_disabled(b *testing.B) {
+	ctx := context.Background()
+	n := NewKeySampler(&Discard{UID: 1}, coder.NewString(), 0)
+	if err := n.Up(ctx); err != nil {
+		b.Fatalf("up failed: %v", err)
+	}
+	if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+		b.Fatalf("start bundle failed: %v", err)
+	}
+	elm := &FullValue{Elm: "key", Elm2: 1}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.ProcessElement(ctx, elm)
+	}
+}
@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+)
+
+// WithKVCheck returns a context in which DataSinks and GroupByKeys with a KV
+// coder fail the bundle on elements without a value in Elm2, rather than
+// failing to encode them or grouping them with nil values. It is intended for
+// debugging custom nodes that drop the values of the KV elements they pass on.
+// KVs of nil interface values also fail the check.
+func WithKVCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, kvCheckKey, true)
+}
+
+// isKVCheck returns whether elements with the given coder are checked for
+// their values.
+func isKVCheck(ctx context.Context, c *coder.Coder) bool {
+	v, _ := ctx.Value(kvCheckKey).(bool)
+	return v && c != nil && coder.IsKV(coder.SkipW(c))
+}
+
+// KVCoderError indicates that the input coder of a node feeding a GroupByKey
+// is not a KV coder of the declared key and value coders.
+type KVCoderError struct {
+	UID UnitID
+	// PID is the transform fed by the node.
+	PID    string
+	Coder  *coder.Coder
+	Reason string
+}
+
+func (e *KVCoderError) Error() string {
+	if e.PID == "" {
+		return fmt.Sprintf("invalid input coder %v of grouping node %v: %v", e.Coder, e.UID, e.Reason)
+	}
+	return fmt.Sprintf("invalid input coder %v of node %v feeding GroupByKey transform %v: %v", e.Coder, e.UID, e.PID, e.Reason)
+}
+
+// checkKVCoder returns a KVCoderError unless c is a, possibly windowed, KV
+// coder of the given key and value coders. Nil key or value coders match any
+// coder.
+func checkKVCoder(uid UnitID, pid string, c, key, value *coder.Coder) error {
+	fail := func(format string, args ...interface{}) error {
+		return &KVCoderError{UID: uid, PID: pid, Coder: c, Reason: fmt.Sprintf(format, args...)}
+	}
+	if c == nil {
+		return fail("no coder")
+	}
+	kv := coder.SkipW(c)
+	if !coder.IsKV(kv) {
+		return fail("not a KV coder")
+	}
+	if key != nil && !kv.Components[0].Equals(key) {
+		return fail("key coder %v, want %v", kv.Components[0], key)
+	}
+	if value != nil && !kv.Components[1].Equals(value) {
+		return fail("value coder %v, want %v", kv.Components[1], value)
+	}
+	return nil
+}
+
+// KVCoderCheck wraps a node feeding a GroupByKey and fails StartBundle with a
+// KVCoderError unless its input coder is a, possibly windowed, KV coder with
+// the declared key and value coders. Nil Key or Value coders match any coder.
+// The check is made once per bundle rather than per element, as the coders
+// don't change. Without a Coder, the coder of a wrapped DataSink or GroupByKey
+// is checked. It is meant for custom nodes: UnmarshalPlan doesn't insert it, as
+// GroupByKeys and the DataSinks named with WithGroupedSinks check their own
+// coders.
+type KVCoderCheck struct {
+	Node
+	PID        string
+	Coder      *coder.Coder
+	Key, Value *coder.Coder
+}
+
+// NewKVCoderCheck returns a node that checks that out, feeding the given
+// GroupByKey transform, has an input coder of KVs of the given key and value
+// coders.
+func NewKVCoderCheck(out Node, pid string, key, value *coder.Coder) *KVCoderCheck {
+	return &KVCoderCheck{Node: out, PID: pid, Key: key, Value: value}
+}
+
+// StartBundle checks the input coder and starts the wrapped node.
+func (n *KVCoderCheck) StartBundle(ctx context.Context, id string, data DataContext) error {
+	if err := n.check(); err != nil {
+		return err
+	}
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+func (n *KVCoderCheck) check() error {
+	c := n.Coder
+	if c == nil {
+		switch out := n.Node.(type) {
+		case *DataSink:
+			c = out.Coder
+		case *GroupByKey:
+			c = out.Coder
+		}
+	}
+	return checkKVCoder(n.ID(), n.PID, c, n.Key, n.Value)
+}
+
+func (n *KVCoderCheck) String() string {
+	return fmt.Sprintf("KVCoderCheck[%v]. Node:%v", n.PID, n.Node)
+}
@@ -0,0 +1,216 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// TestKVCoderCheck verifies that nodes feeding a GroupByKey fail StartBundle
+// with a KVCoderError naming the transform unless their input coder is a KV
+// coder of the declared key and value coders.
+func TestKVCoderCheck(t *testing.T) {
+	ctx := context.Background()
+	kv := coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()})
+	w := coder.NewGlobalWindow()
+
+	tests := []struct {
+		name       string
+		out        Node
+		c          *coder.Coder
+		key, value *coder.Coder
+		reason     string
+	}{
+		{name: "match", out: &Discard{UID: 1}, c: coder.NewW(kv, w), key: coder.NewString(), value: coder.NewVarInt()},
+		{name: "any value", out: &Discard{UID: 1}, c: kv, key: coder.NewString()},
+		{name: "not KV", out: &Discard{UID: 1}, c: coder.NewW(coder.NewVarInt(), w), reason: "not a KV coder"},
+		{name: "key mismatch", out: &Discard{UID: 1}, c: coder.NewW(kv, w), key: coder.NewVarInt(), value: coder.NewVarInt(), reason: "key coder"},
+		{name: "value mismatch", out: &Discard{UID: 1}, c: coder.NewW(kv, w), key: coder.NewString(), value: coder.NewString(), reason: "value coder"},
+		{name: "no coder", out: &Discard{UID: 1}, reason: "no coder"},
+		{name: "sink coder", out: &DataSink{UID: 1, Coder: coder.NewW(coder.NewVarInt(), w)}, reason: "not a KV coder"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := NewKVCoderCheck(test.out, "gbk", test.key, test.value)
+			check.Coder = test.c
+			err := check.StartBundle(ctx, "1", DataContext{})
+			if test.reason == "" {
+				if err != nil {
+					t.Errorf("StartBundle failed: %v", err)
+				}
+				return
+			}
+			var kvErr *KVCoderError
+			if !errors.As(err, &kvErr) {
+				t.Fatalf("StartBundle = %v, want KVCoderError", err)
+			}
+			if kvErr.PID != "gbk" || kvErr.UID != 1 || !strings.Contains(kvErr.Reason, test.reason) {
+				t.Errorf("StartBundle = %+v, want error for transform gbk at node 1 with reason %q", kvErr, test.reason)
+			}
+		})
+	}
+}
+
+// TestWithKVCheck verifies that, with the KV check enabled, nodes with a KV
+// coder reject elements that lost their values, and pass on complete ones.
+func TestWithKVCheck(t *testing.T) {
+	c := coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()}), coder.NewGlobalWindow())
+	kv := MainInput{Key: FullValue{Elm: "a", Elm2: int64(1), Windows: window.SingleGlobalWindow}}
+	dropped := MainInput{Key: FullValue{Elm: "a", Windows: window.SingleGlobalWindow}}
+
+	tests := []struct {
+		name string
+		node func() Node
+		data DataContext
+		err  string
+	}{
+		{
+			name: "DataSink",
+			node: func() Node { return &DataSink{UID: 1, Coder: c} },
+			data: DataContext{Data: &TestDataManager{W: &nopWriteCloser{}}},
+			err:  "without value at DataSink 1",
+		},
+		{
+			name: "GroupByKey",
+			node: func() Node { return NewGroupByKey(1, c, &Discard{UID: 3}) },
+			err:  "without value at group by key 1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, in := range []MainInput{kv, dropped} {
+				n := test.node()
+				root := &FixedRoot{UID: 2, Elements: []MainInput{in}, Out: n}
+				units := []Unit{root, n}
+				if gbk, ok := n.(*GroupByKey); ok {
+					units = append(units, gbk.Out)
+				}
+				p, err := NewPlan("a", units)
+				if err != nil {
+					t.Fatalf("failed to construct plan: %v", err)
+				}
+				err = p.Execute(WithKVCheck(context.Background()), "1", test.data)
+				if in.Key.Elm2 != nil {
+					if err != nil {
+						t.Errorf("execute(%v) failed: %v", in.Key, err)
+					}
+				} else if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("execute(%v) = %v, want error containing %q", in.Key, err, test.err)
+				}
+			}
+		})
+	}
+}
+
+// groupedSinkDescriptor describes a source feeding a sink, both with the
+// given port coder, of a PCollection of KVs of strings and varints.
+func groupedSinkDescriptor(t *testing.T, portCoder string) *fnpb.ProcessBundleDescriptor {
+	t.Helper()
+	port, err := proto.Marshal(&fnpb.RemoteGrpcPort{CoderId: portCoder})
+	if err != nil {
+		t.Fatalf("bad port: %v", err)
+	}
+	urn := func(urn string, components ...string) *pipepb.Coder {
+		return &pipepb.Coder{Spec: &pipepb.FunctionSpec{Urn: urn}, ComponentCoderIds: components}
+	}
+	return &fnpb.ProcessBundleDescriptor{
+		Id: "test",
+		Transforms: map[string]*pipepb.PTransform{
+			"source": {
+				Spec:    &pipepb.FunctionSpec{Urn: "beam:runner:source:v1", Payload: port},
+				Outputs: map[string]string{"o1": "p1"},
+			},
+			"sink": {
+				Spec:   &pipepb.FunctionSpec{Urn: "beam:runner:sink:v1", Payload: port},
+				Inputs: map[string]string{"i1": "p1"},
+			},
+		},
+		Pcollections: map[string]*pipepb.PCollection{
+			"p1": {CoderId: "kv", WindowingStrategyId: "ws"},
+		},
+		WindowingStrategies: map[string]*pipepb.WindowingStrategy{
+			"ws": {WindowCoderId: "global"},
+		},
+		Coders: map[string]*pipepb.Coder{
+			"kv":      urn("beam:coder:kv:v1", "string", "varint"),
+			"wkv":     urn("beam:coder:windowed_value:v1", "kv", "global"),
+			"wstrkv":  urn("beam:coder:windowed_value:v1", "strkv", "global"),
+			"strkv":   urn("beam:coder:kv:v1", "string", "string"),
+			"wstring": urn("beam:coder:windowed_value:v1", "string", "global"),
+			"string":  urn("beam:coder:string_utf8:v1"),
+			"varint":  urn("beam:coder:varint:v1"),
+			"global":  urn("beam:coder:global_window:v1"),
+		},
+	}
+}
+
+// TestUnmarshalPlan_groupedSinkCoder verifies that the DataSinks named with
+// WithGroupedSinks fail StartBundle with a KVCoderError unless their coder is
+// a KV coder of the coders of their input PCollection.
+func TestUnmarshalPlan_groupedSinkCoder(t *testing.T) {
+	tests := []struct {
+		portCoder string
+		reason    string
+	}{
+		{portCoder: "wkv"},
+		{portCoder: "wstrkv", reason: "value coder"},
+		{portCoder: "wstring", reason: "not a KV coder"},
+	}
+	for _, test := range tests {
+		t.Run(test.portCoder, func(t *testing.T) {
+			p, err := UnmarshalPlan(groupedSinkDescriptor(t, test.portCoder), WithGroupedSinks("sink"))
+			if err != nil {
+				t.Fatalf("UnmarshalPlan failed: %v", err)
+			}
+			data := &TestDataManager{R: ioutil.NopCloser(&bytes.Buffer{}), W: &nopWriteCloser{}}
+			err = p.Execute(context.Background(), "1", DataContext{Data: data})
+			if test.reason == "" {
+				if err != nil {
+					t.Errorf("execute failed: %v", err)
+				}
+				return
+			}
+			var kvErr *KVCoderError
+			if !errors.As(err, &kvErr) {
+				t.Fatalf("execute = %v, want KVCoderError", err)
+			}
+			if kvErr.PID != "sink" || !strings.Contains(kvErr.Reason, test.reason) {
+				t.Errorf("execute = %+v, want error for transform sink with reason %q", kvErr, test.reason)
+			}
+		})
+	}
+}
+
+// TestGroupByKey_kvCoder verifies that a GroupByKey fails Up with a
+// KVCoderError unless its coder is a KV coder.
+func TestGroupByKey_kvCoder(t *testing.T) {
+	gbk := NewGroupByKey(1, coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow()), &Discard{UID: 2})
+	var kvErr *KVCoderError
+	if err := gbk.Up(context.Background()); !errors.As(err, &kvErr) || kvErr.UID != 1 {
+		t.Errorf("Up = %v, want KVCoderError for node 1", err)
+	}
+}
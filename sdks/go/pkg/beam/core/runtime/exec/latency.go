@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// LatencyInjector wraps a node and delays each element passed to it by Delay
+// plus a pseudo-random duration of up to Jitter, to simulate a slow stage. The
+// jitter sequence is determined by Seed, so that runs are reproducible. If
+// Delay is 0, elements are not delayed at all. Elements are passed unchanged.
+// It is intended for testing the behavior of pipelines under slow stages, such
+// as autoscaling and backpressure.
+type LatencyInjector struct {
+	Node
+	Delay  time.Duration
+	Jitter time.Duration
+	Seed   int64
+
+	rand *rand.Rand
+}
+
+// NewLatencyInjector returns a node that delays each element passed to out
+// by delay plus up to jitter.
+func NewLatencyInjector(out Node, delay time.Duration, jitter time.Duration) *LatencyInjector {
+	return &LatencyInjector{Node: out, Delay: delay, Jitter: jitter}
+}
+
+// Up seeds the jitter and brings up the wrapped node.
+func (n *LatencyInjector) Up(ctx context.Context) error {
+	n.rand = rand.New(rand.NewSource(n.Seed))
+	return n.Node.Up(ctx)
+}
+
+// ProcessElement waits for the delay and forwards the element to the wrapped
+// node. If the context is done during the wait, the bundle fails.
+func (n *LatencyInjector) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.Delay > 0 {
+		d := n.Delay
+		if n.Jitter > 0 {
+			d += time.Duration(n.rand.Int63n(int64(n.Jitter) + 1))
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Wrapf(ctx.Err(), "injected latency at node %v", n.ID())
+		}
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *LatencyInjector) String() string {
+	return fmt.Sprintf("LatencyInjector[%v+%v]. Node:%v", n.Delay, n.Jitter, n.Node)
+}
@@ -0,0 +1,81 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestLatencyInjector verifies that elements are delayed by the configured
+// duration and passed unchanged.
+func TestLatencyInjector(t *testing.T) {
+	tests := []struct {
+		name          string
+		delay, jitter time.Duration
+	}{
+		{name: "none", jitter: time.Hour},
+		{name: "fixed", delay: 10 * time.Millisecond},
+		{name: "jitter", delay: 10 * time.Millisecond, jitter: 10 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewLatencyInjector(out, test.delay, test.jitter)
+			root := &FixedRoot{UID: 2, Elements: makeInput(1, 2, 3), Out: n}
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			start := time.Now()
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			elapsed := time.Since(start)
+
+			if min := 3 * test.delay; elapsed < min {
+				t.Errorf("execute took %v, want at least %v", elapsed, min)
+			}
+			if test.delay == 0 && elapsed > time.Second {
+				t.Errorf("execute took %v, want no delay", elapsed)
+			}
+			if want := makeValues(1, 2, 3); !equalList(out.Elements, want) {
+				t.Errorf("latency injector = %v, want %v", extractValues(out.Elements...), extractValues(want...))
+			}
+		})
+	}
+}
+
+// TestLatencyInjector_cancel verifies that a cancelled context interrupts the
+// delay and fails the bundle.
+func TestLatencyInjector_cancel(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	n := NewLatencyInjector(out, time.Hour, 0)
+	root := &FixedRoot{UID: 2, Elements: makeInput(1), Out: n}
+	p, err := NewPlan("a", []Unit{root, n})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Execute(ctx, "1", DataContext{}); err == nil {
+		t.Fatal("execute succeeded, want cancellation error")
+	}
+	if len(out.Elements) != 0 {
+		t.Errorf("forwarded %v, want nothing", extractValues(out.Elements...))
+	}
+}
@@ -0,0 +1,67 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+)
+
+// BundleSizeError is returned when a bundle contains more elements than
+// permitted.
+type BundleSizeError struct {
+	UID UnitID
+	Max int
+}
+
+func (e *BundleSizeError) Error() string {
+	return fmt.Sprintf("bundle exceeded maximum size of %v elements at node %v", e.Max, e.UID)
+}
+
+// BundleSizeLimiter wraps a node and fails the bundle once more than Max
+// elements have been processed.
+type BundleSizeLimiter struct {
+	Node
+	Max int
+
+	count int
+}
+
+// NewBundleSizeLimiter returns a node that limits the number of elements per
+// bundle passed to n.
+func NewBundleSizeLimiter(n Node, max int) *BundleSizeLimiter {
+	return &BundleSizeLimiter{Node: n, Max: max}
+}
+
+// StartBundle resets the element count and starts the wrapped node.
+func (n *BundleSizeLimiter) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.count = 0
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement counts the element and forwards it to the wrapped node,
+// unless the limit is exceeded.
+func (n *BundleSizeLimiter) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.count++
+	if n.count > n.Max {
+		return &BundleSizeError{UID: n.ID(), Max: n.Max}
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *BundleSizeLimiter) String() string {
+	return fmt.Sprintf("BundleSizeLimiter[%v]. Node:%v", n.Max, n.Node)
+}
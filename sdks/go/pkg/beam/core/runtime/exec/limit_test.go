@@ -0,0 +1,53 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestBundleSizeLimiter verifies that bundles over the limit fail, and that
+// the limit is reset for each bundle.
+func TestBundleSizeLimiter(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	limiter := NewBundleSizeLimiter(out, 3)
+	in := &FixedRoot{UID: 2, Elements: makeInput(1, 2, 3), Out: limiter}
+
+	p, err := NewPlan("a", []Unit{in, limiter})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+			t.Fatalf("execute failed for bundle %v: %v", i, err)
+		}
+	}
+	if got, want := len(out.Elements), 6; got != want {
+		t.Errorf("limiter passed %v elements, want %v", got, want)
+	}
+
+	in.Elements = makeInput(1, 2, 3, 4)
+	err = p.Execute(context.Background(), "2", DataContext{})
+	var sizeErr *BundleSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("execute with oversized bundle = %v, want BundleSizeError", err)
+	}
+	if sizeErr.Max != 3 || sizeErr.UID != 1 {
+		t.Errorf("execute with oversized bundle = %+v, want {UID:1, Max:3}", sizeErr)
+	}
+}
@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// Keys of the log fields set by plans on the contexts passed to user code.
+const (
+	LogBundleIDField    = "bundle_id"
+	LogPlanIDField      = "plan_id"
+	LogTransformIDField = "transform_id"
+	LogUnitIDField      = "unit_id"
+)
+
+// LogFields returns the log fields set by the plan on the context, which
+// identify the bundle, the plan, and the unit being executed, as well as the
+// transform, if known. In the harness, the plan ID is that of the process
+// bundle descriptor, and the bundle ID that of the instruction. Log messages
+// written with the context carry them.
+func LogFields(ctx context.Context) []log.Field {
+	var ret []log.Field
+	for _, f := range log.Fields(ctx) {
+		switch f.Key {
+		case LogBundleIDField, LogPlanIDField, LogTransformIDField, LogUnitIDField:
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// withBundleLogFields returns a context with the log fields of the given
+// bundle of the plan.
+func withBundleLogFields(ctx context.Context, plan, bundle string) context.Context {
+	return log.WithFields(ctx, log.Field{Key: LogPlanIDField, Value: plan}, log.Field{Key: LogBundleIDField, Value: bundle})
+}
+
+// withUnitLogFields returns a context with the log fields of the given unit
+// and transform, which replace those of any enclosing unit.
+func withUnitLogFields(ctx context.Context, uid UnitID, ptransformID string) context.Context {
+	return log.WithFields(ctx, log.Field{Key: LogUnitIDField, Value: fmt.Sprint(uid)}, log.Field{Key: LogTransformIDField, Value: ptransformID})
+}
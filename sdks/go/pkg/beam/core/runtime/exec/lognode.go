@@ -0,0 +1,120 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// LogNode is a debugging tap that logs each element before passing it unchanged
+// to the wrapped node.
+type LogNode struct {
+	Node
+	// Prefix is prepended to every log message.
+	Prefix string
+	// Severity is the log severity. Defaults to log.SevDebug.
+	Severity log.Severity
+	// Coder is the optional element coder. If present, elements are formatted
+	// by its structure, such as with quoted strings and byte slices and the
+	// key and value of KVs formatted by their own coders. Otherwise, they are
+	// formatted with %v.
+	Coder *coder.Coder
+	// MaxPerBundle caps the number of elements logged per bundle. Zero means
+	// no limit.
+	MaxPerBundle int
+
+	logged int
+}
+
+// NewLogNode returns a node that logs each element passed to out.
+func NewLogNode(out Node, prefix string) *LogNode {
+	return &LogNode{Node: out, Prefix: prefix, Severity: log.SevDebug}
+}
+
+// StartBundle resets the log cap and starts the wrapped node.
+func (n *LogNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.logged = 0
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement logs the element and forwards it to the wrapped node.
+func (n *LogNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.MaxPerBundle <= 0 || n.logged < n.MaxPerBundle {
+		n.logged++
+		log.Output(ctx, n.severity(), 1, n.format(elm, len(values)))
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *LogNode) severity() log.Severity {
+	if n.Severity == log.SevUnspecified {
+		return log.SevDebug
+	}
+	return n.Severity
+}
+
+func (n *LogNode) format(elm *FullValue, streams int) string {
+	var msg string
+	if n.Coder != nil {
+		msg = fmt.Sprintf("%v: %v", n.Prefix, formatElement(n.Coder, elm))
+	} else {
+		msg = fmt.Sprintf("%v: %v", n.Prefix, elm)
+	}
+	if streams > 0 {
+		msg += fmt.Sprintf(" with %v value stream(s)", streams)
+	}
+	return msg
+}
+
+// formatElement formats the element as FullValue.String does, but with its
+// values formatted by the components of the coder.
+func formatElement(c *coder.Coder, elm *FullValue) string {
+	c = coder.SkipW(c)
+	if c.Kind == coder.KV && len(c.Components) == 2 {
+		return fmt.Sprintf("KV<%v,%v> [@%v:%v]", formatValue(c.Components[0], elm.Elm), formatValue(c.Components[1], elm.Elm2), elm.Timestamp, elm.Windows)
+	}
+	return fmt.Sprintf("%v [@%v:%v]", formatValue(c, elm.Elm), elm.Timestamp, elm.Windows)
+}
+
+// formatValue formats a single value of the coder, falling back to %v for
+// values it doesn't know, or that don't match it.
+func formatValue(c *coder.Coder, v interface{}) string {
+	switch c.Kind {
+	case coder.LP:
+		return formatValue(c.Components[0], v)
+	case coder.Bytes:
+		if b, ok := v.([]byte); ok {
+			return fmt.Sprintf("%q", b)
+		}
+	case coder.String:
+		if s, ok := v.(string); ok {
+			return fmt.Sprintf("%q", s)
+		}
+	case coder.KV:
+		if kv, ok := v.(*FullValue); ok && len(c.Components) == 2 {
+			return fmt.Sprintf("KV<%v,%v>", formatValue(c.Components[0], kv.Elm), formatValue(c.Components[1], kv.Elm2))
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func (n *LogNode) String() string {
+	return fmt.Sprintf("LogNode[%v]. Node:%v", n.Prefix, n.Node)
+}
@@ -0,0 +1,78 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+)
+
+// TestLogNode verifies that the LogNode is an identity transform, and that
+// logging is capped per bundle.
+func TestLogNode(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	tap := NewLogNode(out, "tap")
+	tap.Coder = coder.NewVarInt()
+	tap.MaxPerBundle = 2
+	in := &FixedRoot{UID: 2, Elements: makeInput(int64(1), int64(2), int64(3)), Out: tap}
+
+	p, err := NewPlan("a", []Unit{in, tap})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := p.Down(context.Background()); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+
+	expected := makeValues(int64(1), int64(2), int64(3))
+	if !equalList(out.Elements, expected) {
+		t.Errorf("lognode returned %v, want %v", extractValues(out.Elements...), extractValues(expected...))
+	}
+	if got, want := tap.logged, 2; got != want {
+		t.Errorf("lognode logged %v elements, want %v", got, want)
+	}
+	if got, want := tap.format(&expected[0], 0), "tap: 1 "; !strings.HasPrefix(got, want) {
+		t.Errorf("lognode formatted %q, want %q...", got, want)
+	}
+}
+
+// TestLogNode_format verifies that elements are formatted by the structure of
+// the coder, if any, and with %v otherwise.
+func TestLogNode_format(t *testing.T) {
+	kv := coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewBytes()})
+	elm := &FullValue{Elm: "k", Elm2: []byte("v 1"), Timestamp: 5}
+	tests := []struct {
+		c    *coder.Coder
+		want string
+	}{
+		{nil, "tap: KV<k,[118 32 49]> [@5:[]]"},
+		{kv, `tap: KV<"k","v 1"> [@5:[]]`},
+		{coder.NewW(kv, coder.NewGlobalWindow()), `tap: KV<"k","v 1"> [@5:[]]`},
+	}
+	for _, test := range tests {
+		tap := NewLogNode(&Discard{UID: 1}, "tap")
+		tap.Coder = test.c
+		if got := tap.format(elm, 0); got != test.want {
+			t.Errorf("format(%v) with coder %v = %q, want %q", elm, test.c, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// MergeJoinPair is the value of an element emitted by a MergeJoin, holding
+// the values of the matched left and right elements.
+type MergeJoinPair struct {
+	Left, Right interface{}
+}
+
+// MergeJoin is an inner join of two KV inputs sorted by key, without a
+// GroupByKey. The inputs are fed to its Left and Right nodes, which must be
+// part of the plan. For each pair of a left and a right element with the same
+// key, it passes a KV of the key and a MergeJoinPair of their values to Out.
+// The pairs of a key are emitted as soon as both inputs have moved past it,
+// in the order of the left and then the right elements. Pairs have the later
+// timestamp of the two elements and the windows of the left one: windows are
+// not matched, so the join is meant for inputs in the global window.
+//
+// Callers must guarantee that each input is sorted by the encoding of its
+// keys with KeyCoder, in byte order, within a bundle; a key arriving after a
+// greater one fails the bundle. Elements of an input are buffered until the
+// other input catches up, so inputs processed one after the other, such as
+// from separate roots, are held in memory entirely. Out is started with the
+// first started input, and finished once both inputs are finished.
+type MergeJoin struct {
+	KeyCoder    *coder.Coder
+	Left, Right *MergeJoinInput
+	Out         Node
+
+	enc     ElementEncoder
+	buf     bytes.Buffer
+	ctx     context.Context
+	started int
+}
+
+// MergeJoinInput is an input of a MergeJoin. Its UID must be set for it to
+// take part in a plan.
+type MergeJoinInput struct {
+	UID  UnitID
+	join *MergeJoin
+	name string
+
+	pending []joinEntry
+	// lastKey and lastElm are the encoded and decoded last key of the
+	// bundle, to detect out-of-order keys.
+	lastKey []byte
+	lastElm interface{}
+	done    bool
+}
+
+// joinEntry is a buffered element of a MergeJoinInput, with its encoded key.
+type joinEntry struct {
+	key []byte
+	elm FullValue
+}
+
+// NewMergeJoin returns an inner merge join of two inputs sorted by their keys
+// as encoded by keyCoder, which passes the matched pairs to out.
+func NewMergeJoin(out Node, keyCoder *coder.Coder) *MergeJoin {
+	j := &MergeJoin{KeyCoder: keyCoder, Out: out}
+	j.Left = &MergeJoinInput{join: j, name: "left"}
+	j.Right = &MergeJoinInput{join: j, name: "right"}
+	return j
+}
+
+func (n *MergeJoinInput) ID() UnitID {
+	return n.UID
+}
+
+// Up initializes the key encoder of the join.
+func (n *MergeJoinInput) Up(ctx context.Context) error {
+	if n.join.enc == nil {
+		n.join.enc = MakeElementEncoder(n.join.KeyCoder)
+	}
+	return nil
+}
+
+// StartBundle clears the input and starts Out, if the other input hasn't yet.
+func (n *MergeJoinInput) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.pending, n.lastKey, n.lastElm, n.done = nil, nil, nil, false
+	j := n.join
+	j.started++
+	if j.started > 1 {
+		return nil
+	}
+	j.ctx = ctx
+	return MultiStartBundle(ctx, id, data, j.Out)
+}
+
+// ProcessElement buffers the element and emits any pairs that are complete.
+func (n *MergeJoinInput) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("%v input %v of merge join does not support GBK/CoGBK results", n.name, n.UID)
+	}
+	j := n.join
+	j.buf.Reset()
+	if err := j.enc.Encode(&FullValue{Elm: elm.Elm}, &j.buf); err != nil {
+		return errors.WithContextf(err, "encoding key %v at %v input %v of merge join", elm.Elm, n.name, n.UID)
+	}
+	e := joinEntry{key: append([]byte(nil), j.buf.Bytes()...), elm: *elm}
+	if n.lastKey != nil && bytes.Compare(e.key, n.lastKey) < 0 {
+		return errors.Errorf("out-of-order key %v at %v input %v of merge join: it follows key %v", elm.Elm, n.name, n.UID, n.lastElm)
+	}
+	n.pending = append(n.pending, e)
+	n.lastKey, n.lastElm = e.key, elm.Elm
+	return j.advance()
+}
+
+// FinishBundle emits the remaining pairs once both inputs are finished, and
+// then finishes Out.
+func (n *MergeJoinInput) FinishBundle(ctx context.Context) error {
+	n.done = true
+	j := n.join
+	if err := j.advance(); err != nil {
+		return err
+	}
+	if !j.Left.done || !j.Right.done {
+		return nil
+	}
+	j.started = 0
+	j.Left.pending, j.Right.pending = nil, nil
+	return MultiFinishBundle(ctx, j.Out)
+}
+
+func (n *MergeJoinInput) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *MergeJoinInput) String() string {
+	return fmt.Sprintf("MergeJoinInput[%v]. Out:%v", n.name, n.join.Out)
+}
+
+// advance emits the pairs of the keys that both inputs have moved past, and
+// drops the elements that can no longer be matched.
+func (j *MergeJoin) advance() error {
+	l, r := j.Left, j.Right
+	for len(l.pending) > 0 && len(r.pending) > 0 {
+		switch c := bytes.Compare(l.pending[0].key, r.pending[0].key); {
+		case c < 0:
+			l.pending = l.pending[1:]
+		case c > 0:
+			r.pending = r.pending[1:]
+		default:
+			key := l.pending[0].key
+			ln, rn := runLength(l.pending, key), runLength(r.pending, key)
+			if (ln == len(l.pending) && !l.done) || (rn == len(r.pending) && !r.done) {
+				return nil // ok: more elements of the key may arrive.
+			}
+			for _, le := range l.pending[:ln] {
+				for _, re := range r.pending[:rn] {
+					if err := j.emit(&le.elm, &re.elm); err != nil {
+						return err
+					}
+				}
+			}
+			l.pending, r.pending = l.pending[ln:], r.pending[rn:]
+		}
+	}
+	// Elements of an input can't be matched once the other one is done.
+	if len(l.pending) == 0 && l.done {
+		r.pending = nil
+	}
+	if len(r.pending) == 0 && r.done {
+		l.pending = nil
+	}
+	return nil
+}
+
+// runLength returns the number of leading entries with the given key.
+func runLength(entries []joinEntry, key []byte) int {
+	i := 0
+	for i < len(entries) && bytes.Equal(entries[i].key, key) {
+		i++
+	}
+	return i
+}
+
+func (j *MergeJoin) emit(left, right *FullValue) error {
+	ts := left.Timestamp
+	if right.Timestamp > ts {
+		ts = right.Timestamp
+	}
+	out := &FullValue{
+		Elm:       left.Elm,
+		Elm2:      MergeJoinPair{Left: left.Elm2, Right: right.Elm2},
+		Timestamp: ts,
+		Windows:   left.Windows,
+	}
+	return j.Out.ProcessElement(j.ctx, out)
+}
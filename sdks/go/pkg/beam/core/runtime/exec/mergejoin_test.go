@@ -0,0 +1,120 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+)
+
+// kvInput returns the given alternating keys and values as main inputs in
+// the global window, at increasing timestamps.
+func kvInput(kvs ...interface{}) []MainInput {
+	var ret []MainInput
+	for i := 0; i+1 < len(kvs); i += 2 {
+		ret = append(ret, MainInput{Key: FullValue{
+			Elm:       kvs[i],
+			Elm2:      kvs[i+1],
+			Timestamp: mtime.FromMilliseconds(int64(i)),
+			Windows:   window.SingleGlobalWindow,
+		}})
+	}
+	return ret
+}
+
+// executeMergeJoin joins the given inputs and returns the emitted pairs as
+// strings.
+func executeMergeJoin(t *testing.T, left, right []MainInput) ([]string, error) {
+	t.Helper()
+	out := &CaptureNode{UID: 1}
+	join := NewMergeJoin(out, coder.NewString())
+	join.Left.UID, join.Right.UID = 2, 3
+	l := &FixedRoot{UID: 4, Elements: left, Out: join.Left}
+	r := &FixedRoot{UID: 5, Elements: right, Out: join.Right}
+	p, err := NewPlan("a", []Unit{l, r, join.Left, join.Right, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, v := range out.Elements {
+		pair := v.Elm2.(MergeJoinPair)
+		ret = append(ret, fmt.Sprintf("%v:%v,%v", v.Elm, pair.Left, pair.Right))
+	}
+	return ret, nil
+}
+
+// TestMergeJoin verifies that matching keys of two sorted inputs are joined,
+// including one-to-many and many-to-many matches, and that keys present in
+// only one input are dropped.
+func TestMergeJoin(t *testing.T) {
+	tests := []struct {
+		name        string
+		left, right []MainInput
+		want        []string
+	}{
+		{
+			name:  "one-to-one",
+			left:  kvInput("a", 1, "b", 2, "d", 4),
+			right: kvInput("b", "x", "c", "y", "d", "z"),
+			want:  []string{"b:2,x", "d:4,z"},
+		},
+		{
+			name:  "one-to-many",
+			left:  kvInput("a", 1, "b", 2),
+			right: kvInput("a", "x", "a", "y", "b", "z"),
+			want:  []string{"a:1,x", "a:1,y", "b:2,z"},
+		},
+		{
+			name:  "many-to-many",
+			left:  kvInput("a", 1, "a", 2, "c", 3),
+			right: kvInput("a", "x", "a", "y"),
+			want:  []string{"a:1,x", "a:1,y", "a:2,x", "a:2,y"},
+		},
+		{
+			name:  "empty",
+			left:  kvInput("a", 1),
+			right: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := executeMergeJoin(t, test.left, test.right)
+			if err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("merge join = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	t.Run("out of order", func(t *testing.T) {
+		_, err := executeMergeJoin(t, kvInput("a", 1, "b", 2), kvInput("c", "x", "b", "y"))
+		if err == nil || !strings.Contains(err.Error(), "out-of-order key b at right input 3") {
+			t.Errorf("execute = %v, want out-of-order key error", err)
+		}
+	})
+}
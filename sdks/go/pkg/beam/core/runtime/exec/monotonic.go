@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// TimestampOrderError indicates that an element of a key arrived at a
+// MonotonicTimestampCheck with an earlier timestamp than a previous element
+// of the key.
+type TimestampOrderError struct {
+	UID       UnitID
+	Key       interface{}
+	Previous  typex.EventTime // The timestamp of the previous element of the key.
+	Timestamp typex.EventTime
+}
+
+func (e *TimestampOrderError) Error() string {
+	return fmt.Sprintf("element of key %v at %v arrived at node %v after an element at %v", e.Key, e.Timestamp, e.UID, e.Previous)
+}
+
+// MonotonicTimestampCheck wraps a node and fails the bundle with a
+// TimestampOrderError if the timestamps of the KV elements of a key decrease
+// within a bundle. Keys are compared by their encoding with KeyCoder, across
+// all windows.
+type MonotonicTimestampCheck struct {
+	Node
+	KeyCoder *coder.Coder
+
+	hasher elementHasher
+	last   map[uint64]typex.EventTime
+}
+
+// NewMonotonicTimestampCheck returns a node that checks that the timestamps
+// of the elements passed to out don't decrease per key, as encoded by
+// keyCoder.
+func NewMonotonicTimestampCheck(out Node, keyCoder *coder.Coder) *MonotonicTimestampCheck {
+	return &MonotonicTimestampCheck{Node: out, KeyCoder: keyCoder}
+}
+
+// Up initializes the key hasher and brings up the wrapped node.
+func (n *MonotonicTimestampCheck) Up(ctx context.Context) error {
+	n.hasher = makeElementHasher(n.KeyCoder, coder.NewGlobalWindow())
+	return n.Node.Up(ctx)
+}
+
+// StartBundle forgets the timestamps of the previous bundle.
+func (n *MonotonicTimestampCheck) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.last = make(map[uint64]typex.EventTime)
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement checks the timestamp of the element against the previous
+// element of its key, and forwards it to the wrapped node.
+func (n *MonotonicTimestampCheck) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	// Keys are hashed in the global window, so that their elements are
+	// compared regardless of their windows.
+	h, err := n.hasher.Hash(elm.Elm, window.GlobalWindow{})
+	if err != nil {
+		return errors.WithContextf(err, "hashing key %v of node %v", elm.Elm, n.ID())
+	}
+	if prev, ok := n.last[h]; ok && elm.Timestamp < prev {
+		return &TimestampOrderError{UID: n.ID(), Key: elm.Elm, Previous: prev, Timestamp: elm.Timestamp}
+	}
+	n.last[h] = elm.Timestamp
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+// FinishBundle drops the timestamps of the bundle and finishes the wrapped
+// node.
+func (n *MonotonicTimestampCheck) FinishBundle(ctx context.Context) error {
+	n.last = nil
+	return n.Node.FinishBundle(ctx)
+}
+
+func (n *MonotonicTimestampCheck) String() string {
+	return fmt.Sprintf("MonotonicTimestampCheck[%v]. Node:%v", n.KeyCoder, n.Node)
+}
@@ -0,0 +1,81 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestMonotonicTimestampCheck verifies that decreasing timestamps of a key
+// fail the bundle, in the global and other windows, and that timestamps are
+// only compared within a bundle.
+func TestMonotonicTimestampCheck(t *testing.T) {
+	kv := func(k string, ts int64, ws ...typex.Window) MainInput {
+		if len(ws) == 0 {
+			ws = window.SingleGlobalWindow
+		}
+		return MainInput{Key: FullValue{Elm: k, Elm2: ts, Timestamp: typex.EventTime(ts), Windows: ws}}
+	}
+	w1, w2 := window.IntervalWindow{Start: 0, End: 10}, window.IntervalWindow{Start: 10, End: 20}
+	tests := []struct {
+		name string
+		in   []MainInput
+		want *TimestampOrderError
+	}{
+		{name: "ordered", in: []MainInput{kv("a", 1), kv("a", 1), kv("b", 0), kv("a", 5)}},
+		{name: "reordered", in: []MainInput{kv("a", 1), kv("b", 5), kv("a", 3), kv("b", 2)}, want: &TimestampOrderError{UID: 1, Key: "b", Previous: 5, Timestamp: 2}},
+		{name: "windows", in: []MainInput{kv("a", 15, w2), kv("a", 5, w1)}, want: &TimestampOrderError{UID: 1, Key: "a", Previous: 15, Timestamp: 5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewMonotonicTimestampCheck(out, coder.NewString())
+			root := &FixedRoot{UID: 2, Elements: test.in, Out: n}
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{})
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("execute failed: %v", err)
+				}
+				if got := len(out.Elements); got != len(test.in) {
+					t.Errorf("check passed %v elements, want %v", got, len(test.in))
+				}
+				// Later elements may precede those of the previous bundle.
+				root.Elements = []MainInput{kv("a", 0)}
+				if err := p.Execute(context.Background(), "2", DataContext{}); err != nil {
+					t.Errorf("execute of second bundle failed: %v", err)
+				}
+				return
+			}
+			var got *TimestampOrderError
+			if !errors.As(err, &got) {
+				t.Fatalf("execute = %v, want TimestampOrderError", err)
+			}
+			if *got != *test.want {
+				t.Errorf("execute = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// WithPaneCheck returns a context in which DataSinks and ForwardWithPane fail
+// the bundle on elements without a pane, which are otherwise treated as in
+// the NoFiringPane. It is intended for debugging custom nodes that drop the
+// panes of the elements they pass on.
+func WithPaneCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, paneCheckKey, true)
+}
+
+func isPaneCheck(ctx context.Context) bool {
+	v, _ := ctx.Value(paneCheckKey).(bool)
+	return v
+}
+
+// ForwardWithPane passes a copy of the element in the given pane to out, for
+// nodes that produce elements in a different pane than their input, such as
+// on trigger firings.
+func ForwardWithPane(ctx context.Context, out Node, elm *FullValue, pane typex.PaneInfo) error {
+	if pane == (typex.PaneInfo{}) && isPaneCheck(ctx) {
+		return errors.Errorf("forwarding element %v without pane to node %v", elm, out.ID())
+	}
+	v := *elm
+	v.Pane = pane
+	return out.ProcessElement(ctx, &v)
+}
@@ -0,0 +1,234 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+var latePane = typex.PaneInfo{Timing: typex.PaneLate, IsLast: true, Index: 2, NonSpeculativeIndex: 1}
+
+// TestParDo_pane verifies that the outputs of a ParDo are emitted in the pane
+// of their input element.
+func TestParDo_pane(t *testing.T) {
+	fn, err := graph.NewDoFn(emitSumFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	panes := []typex.PaneInfo{typex.NoFiringPane(), latePane}
+	in := makeInput(1, 2)
+	for i := range in {
+		in[i].Key.Pane = panes[i]
+	}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}}
+	root := &FixedRoot{UID: 3, Elements: in, Out: pardo}
+	p, err := NewPlan("a", []Unit{root, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got, want := len(out.Elements), len(panes); got != want {
+		t.Fatalf("pardo emitted %v elements, want %v", got, want)
+	}
+	for i, want := range panes {
+		if got := out.Elements[i].Pane; got != want {
+			t.Errorf("pane of output %v = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+// TestForwardWithPane verifies that ForwardWithPane passes on a copy of the
+// element in the given pane, and rejects missing panes if checked.
+func TestForwardWithPane(t *testing.T) {
+	ctx := context.Background()
+	out := &CaptureNode{UID: 1}
+	if err := out.Up(ctx); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := out.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("start bundle failed: %v", err)
+	}
+	elm := &makeValues(1)[0]
+	if err := ForwardWithPane(ctx, out, elm, latePane); err != nil {
+		t.Fatalf("ForwardWithPane failed: %v", err)
+	}
+	if got := out.Elements[0].Pane; got != latePane {
+		t.Errorf("forwarded pane = %+v, want %+v", got, latePane)
+	}
+	if elm.Pane != (typex.PaneInfo{}) {
+		t.Errorf("ForwardWithPane changed the pane of its input to %+v", elm.Pane)
+	}
+
+	if err := ForwardWithPane(ctx, out, elm, typex.PaneInfo{}); err != nil {
+		t.Errorf("ForwardWithPane(no pane) failed: %v", err)
+	}
+	err := ForwardWithPane(WithPaneCheck(ctx), out, elm, typex.PaneInfo{})
+	if err == nil || !strings.Contains(err.Error(), "without pane") {
+		t.Errorf("ForwardWithPane(no pane) with pane check = %v, want missing pane error", err)
+	}
+}
+
+// TestDataSink_pane verifies that panes survive a round trip through a
+// DataSink and DataSource, and that the pane check rejects missing panes.
+func TestDataSink_pane(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	panes := []typex.PaneInfo{{}, typex.NoFiringPane(), latePane}
+	var in []MainInput
+	for i, pn := range panes {
+		v := makeValues(int64(i))[0]
+		v.Pane = pn
+		in = append(in, MainInput{Key: v})
+	}
+
+	out := &nopWriteCloser{}
+	sink := &DataSink{UID: 1, Coder: c}
+	root := &FixedRoot{UID: 2, Elements: in, Out: sink}
+	p, err := NewPlan("sink", []Unit{root, sink})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{Data: &TestDataManager{W: out}}); err != nil {
+		t.Fatalf("execute sink failed: %v", err)
+	}
+
+	capture := &CaptureNode{UID: 3}
+	source := &DataSource{UID: 4, Coder: c, Out: capture}
+	constructAndExecutePlanWithContext(t, []Unit{capture, source}, DataContext{
+		Data: &TestDataManager{R: ioutil.NopCloser(&out.Buffer)},
+	})
+	want := []typex.PaneInfo{typex.NoFiringPane(), typex.NoFiringPane(), latePane}
+	if len(capture.Elements) != len(want) {
+		t.Fatalf("source read %v elements, want %v", len(capture.Elements), len(want))
+	}
+	for i, pn := range want {
+		if got := capture.Elements[i].Pane; got != pn {
+			t.Errorf("pane of element %v = %+v, want %+v", i, got, pn)
+		}
+	}
+
+	t.Run("check", func(t *testing.T) {
+		sink := &DataSink{UID: 1, Coder: c}
+		root := &FixedRoot{UID: 2, Elements: in, Out: sink}
+		p, err := NewPlan("sink", []Unit{root, sink})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		err = p.Execute(WithPaneCheck(context.Background()), "1", DataContext{Data: &TestDataManager{W: &nopWriteCloser{}}})
+		if err == nil || !strings.Contains(err.Error(), "without pane at DataSink 1") {
+			t.Errorf("execute = %v, want missing pane error", err)
+		}
+	})
+}
+
+// TestGrouping_pane verifies that the pane of the input elements is kept
+// through grouping and the lifted combine chains, including by values
+// spilled by a GroupByKey.
+func TestGrouping_pane(t *testing.T) {
+	edge := getCombineEdge(t, mergeFn, reflectx.Int, intCoder(reflectx.Int))
+	keyCoder, wc := intCoder(reflectx.Int), coder.NewGlobalWindow()
+	kvCoder := coder.NewW(coder.NewKV([]*coder.Coder{keyCoder, intCoder(reflectx.Int)}), wc)
+	newPrecombine := map[string]func(out Node) Node{
+		"lifted": func(out Node) Node {
+			return &LiftedCombine{Combine: &Combine{UID: 5, Fn: edge.CombineFn, Out: out}, KeyCoder: keyCoder, WindowCoder: wc}
+		},
+		"partial": func(out Node) Node {
+			n := NewPartialCombine(out, edge.CombineFn, keyCoder, 10)
+			n.UID = 5
+			return n
+		},
+		"convert": func(out Node) Node {
+			return &ConvertToAccumulators{Combine: &Combine{UID: 5, Fn: edge.CombineFn, Out: out}}
+		},
+	}
+	for name, newPrecombine := range newPrecombine {
+		t.Run(name, func(t *testing.T) {
+			in := makeKVInput(42, intInput...)
+			for i := range in {
+				in[i].Key.Pane = latePane
+			}
+			out := &CaptureNode{UID: 1}
+			extract := &ExtractOutput{Combine: &Combine{UID: 2, Fn: edge.CombineFn, Out: out}}
+			merge := &MergeAccumulators{Combine: &Combine{UID: 3, Fn: edge.CombineFn, Out: extract}}
+			gbk := NewGroupByKey(4, kvCoder, merge, SpillThreshold(1))
+			precombine := newPrecombine(gbk)
+			root := &FixedRoot{UID: 6, Elements: in, Out: precombine}
+			constructAndExecutePlan(t, []Unit{root, precombine, gbk, merge, extract, out})
+			if len(out.Elements) != 1 || out.Elements[0].Elm2 != 21 {
+				t.Fatalf("combine = %v, want [42: 21]", extractKeyedValues(out.Elements...))
+			}
+			if got := out.Elements[0].Pane; got != latePane {
+				t.Errorf("pane of the combined output = %+v, want %+v", got, latePane)
+			}
+		})
+	}
+
+	t.Run("groupByKey", func(t *testing.T) {
+		in := makeKVInput(42, intInput...)
+		for i := range in {
+			in[i].Key.Pane = latePane
+		}
+		out := &paneCollector{Discard: Discard{UID: 1}}
+		gbk := NewGroupByKey(2, kvCoder, out, SpillThreshold(2))
+		root := &FixedRoot{UID: 3, Elements: in, Out: gbk}
+		constructAndExecutePlan(t, []Unit{root, gbk, out})
+		if len(out.panes) != len(intInput)+1 {
+			t.Fatalf("got %v panes, want the pane of the group and its %v values", len(out.panes), len(intInput))
+		}
+		for i, got := range out.panes {
+			if got != latePane {
+				t.Errorf("pane %v = %+v, want %+v", i, got, latePane)
+			}
+		}
+	})
+}
+
+// paneCollector is a test Node recording the pane of each group, followed by
+// those of its values.
+type paneCollector struct {
+	Discard
+	panes []typex.PaneInfo
+}
+
+func (n *paneCollector) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.panes = append(n.panes, elm.Pane)
+	vs, err := ReadAll(values[0])
+	if err != nil {
+		return err
+	}
+	for _, v := range vs {
+		n.panes = append(n.panes, v.Pane)
+	}
+	return nil
+}
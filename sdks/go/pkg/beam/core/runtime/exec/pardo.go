@@ -16,9 +16,12 @@
 package exec
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"path"
+	"reflect"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/funcx"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
@@ -41,20 +44,137 @@ type ParDo struct {
 	PID      string
 	emitters []ReusableEmitter
 	ctx      context.Context
+	fnCtx    context.Context // Passed to the DoFn, with the log fields of the ParDo.
 	inv      *invoker
 
-	side  StateReader
-	cache *cacheElm
+	// MaxSideInputWindows is the maximum number of windows for which side
+	// inputs are materialized and cached in a bundle, with least-recently-used
+	// eviction. The cache is cleared at the end of each bundle, and windows
+	// are expired as event-time timers show the watermark passing them. If
+	// less than 1, side inputs are read for each element.
+	MaxSideInputWindows int
+
+	// SideInputPrefetch is the number of pages fetched ahead of the DoFn as it
+	// iterates a side input, for adapters that support lazy reading. If
+	// positive, such side inputs are also not materialized when cached, so
+	// that side inputs larger than memory can be read.
+	SideInputPrefetch int
+
+	// Hints are the resource hints of the transform.
+	Hints ResourceHints
+
+	side       StateReader
+	cache      *cacheElm
+	sideInputs *list.List // Cached *cacheElm, most recently used first.
+
+	// skew is the allowed backwards shift of output timestamps, read from
+	// the DoFn's AllowedTimestampSkew field. If checkTs is set, the input
+	// timestamp inTs in pane inPane is currently being processed.
+	skew    time.Duration
+	checkTs bool
+	inTs    typex.EventTime
+	inPane  typex.PaneInfo
+
+	// tracer traces each element in a span, if set with WithTracer, which
+	// counts the outputs of the element.
+	tracer  Tracer
+	outputs int64
+
+	// timers holds the timers delivered in the bundle, to be fired at the
+	// end of it.
+	timers *TimerCoalescer
+
+	// timer observes the duration of DoFn invocations in the bundle, if set
+	// with WithInvocationTimer.
+	timer InvocationTimer
+
+	// timeProcess records ProcessElement durations in a distribution, if
+	// enabled with WithProcessElementTimes.
+	timeProcess bool
+
+	// gate blocks ProcessElement while the plan is paused.
+	gate *pauseGate
+	// deadline fails ProcessElement once the bundle deadline has passed.
+	deadline *bundleDeadline
+
+	// recoverable reports element errors after which the ParDo remains
+	// Active, because a wrapping node handles the failed element.
+	recoverable func(error) bool
 
 	status Status
 	err    errorx.GuardedError
 }
 
+// allowedTimestampSkewField is the name of the optional time.Duration field of
+// a DoFn that bounds how far output timestamps may precede input timestamps.
+const allowedTimestampSkewField = "AllowedTimestampSkew"
+
+// TimestampSkewError is returned when a DoFn emits an element with a
+// timestamp earlier than permitted by its allowed timestamp skew.
+type TimestampSkewError struct {
+	DoFn          string
+	Input, Output typex.EventTime
+	Allowed       time.Duration
+}
+
+// Delta returns how far the output timestamp precedes the input timestamp.
+func (e *TimestampSkewError) Delta() time.Duration {
+	return time.Duration(e.Input.Milliseconds()-e.Output.Milliseconds()) * time.Millisecond
+}
+
+func (e *TimestampSkewError) Error() string {
+	return fmt.Sprintf("DoFn %v emitted timestamp %v, which is %v before input timestamp %v and exceeds the allowed skew of %v", e.DoFn, e.Output, e.Delta(), e.Input, e.Allowed)
+}
+
+// allowedTimestampSkew returns the allowed timestamp skew declared by the
+// DoFn, or zero if none.
+func allowedTimestampSkew(fn *graph.DoFn) time.Duration {
+	if fn.Recv == nil {
+		return 0
+	}
+	v := reflect.Indirect(reflect.ValueOf(fn.Recv))
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	if f := v.FieldByName(allowedTimestampSkewField); f.IsValid() {
+		if d, ok := f.Interface().(time.Duration); ok {
+			return d
+		}
+	}
+	return 0
+}
+
+// timestampChecker validates the timestamps of elements emitted by a ParDo
+// before forwarding them downstream, in the pane of the input element.
+type timestampChecker struct {
+	Node
+	pardo *ParDo
+}
+
+func (c *timestampChecker) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n := c.pardo
+	n.outputs++
+	if n.checkTs {
+		if elm.Timestamp < n.inTs.Subtract(n.skew) {
+			return n.failElement(&TimestampSkewError{DoFn: n.Fn.Name(), Input: n.inTs, Output: elm.Timestamp, Allowed: n.skew})
+		}
+		if elm.Pane == (typex.PaneInfo{}) {
+			elm.Pane = n.inPane
+		}
+	}
+	return c.Node.ProcessElement(ctx, elm, values...)
+}
+
 // GetPID returns the PTransformID for this ParDo.
 func (n *ParDo) GetPID() string {
 	return n.PID
 }
 
+// ResourceHints returns the resource hints of the transform.
+func (n *ParDo) ResourceHints() ResourceHints {
+	return n.Hints
+}
+
 // cacheElm holds per-window cached information about side input.
 type cacheElm struct {
 	key       typex.Window
@@ -78,12 +198,17 @@ func (n *ParDo) Up(ctx context.Context) error {
 	// We can't cache the context during Setup since it runs only once per bundle.
 	// Subsequent bundles might run this same node, and the context here would be
 	// incorrectly refering to the older bundleId.
-	setupCtx := metrics.SetPTransformID(ctx, n.PID)
+	setupCtx := withUnitLogFields(metrics.SetPTransformID(ctx, n.PID), n.UID, n.PID)
 	if _, err := InvokeWithoutEventTime(setupCtx, n.Fn.SetupFn(), nil); err != nil {
 		return n.fail(err)
 	}
 
-	emitters, err := makeEmitters(n.Fn.ProcessElementFn(), n.Out)
+	n.skew = allowedTimestampSkew(n.Fn)
+	checked := make([]Node, len(n.Out))
+	for i, out := range n.Out {
+		checked[i] = &timestampChecker{Node: out, pardo: n}
+	}
+	emitters, err := makeEmitters(n.Fn.ProcessElementFn(), checked)
 	if err != nil {
 		return n.fail(err)
 	}
@@ -102,6 +227,12 @@ func (n *ParDo) StartBundle(ctx context.Context, id string, data DataContext) er
 	// and never accept modified contexts from users, so we will cache them per-bundle
 	// per-unit, to avoid the constant allocation overhead.
 	n.ctx = metrics.SetPTransformID(ctx, n.PID)
+	n.fnCtx = withUnitLogFields(n.ctx, n.UID, n.PID)
+	n.timer = getInvocationTimer(ctx)
+	n.timeProcess = isProcessElementTimes(ctx)
+	n.tracer = getTracer(ctx)
+	n.gate = getPauseGate(ctx)
+	n.deadline = getBundleDeadlineState(ctx)
 
 	if err := MultiStartBundle(n.ctx, id, data, n.Out...); err != nil {
 		return n.fail(err)
@@ -109,7 +240,7 @@ func (n *ParDo) StartBundle(ctx context.Context, id string, data DataContext) er
 
 	// TODO(BEAM-3303): what to set for StartBundle/FinishBundle window and emitter timestamp?
 
-	if _, err := n.invokeDataFn(n.ctx, window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.StartBundleFn(), nil); err != nil {
+	if _, err := n.invokeDataFn(n.fnCtx, window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.StartBundleFn(), nil); err != nil {
 		return n.fail(err)
 	}
 	return nil
@@ -120,15 +251,100 @@ func (n *ParDo) ProcessElement(_ context.Context, elm *FullValue, values ...ReSt
 	if n.status != Active {
 		return errors.Errorf("invalid status for pardo %v: %v, want Active", n.UID, n.status)
 	}
+	if err := n.gate.wait(n.ctx); err != nil {
+		return n.fail(err)
+	}
+	if err := n.deadline.check(n.UID); err != nil {
+		return n.fail(err)
+	}
 
 	return n.processMainInput(&MainInput{Key: *elm, Values: values})
 }
 
+// ProcessTimer adds a timer delivered for the DoFn. Timers are fired at the end
+// of the bundle by invoking the OnTimer method of the DoFn once for identical
+// timers, in the window and at the fire time of the timer, first in event time
+// and then in processing time, each ordered by fire time. OnTimer takes the
+// timer family and the number of coalesced timers as main inputs, followed by
+// the same side inputs and emitters as ProcessElement. The DoFn must embed
+// graph.TimerProcessing.
+func (n *ParDo) ProcessTimer(t Timer) error {
+	if n.status != Active {
+		return errors.Errorf("invalid status for pardo %v: %v, want Active", n.UID, n.status)
+	}
+	if n.Fn.OnTimerFn() == nil {
+		return errors.Errorf("timer %v delivered to pardo %v, but %v has no OnTimer method", t.Family, n.UID, n.Fn.Name())
+	}
+	if n.timers == nil {
+		n.timers = NewTimerCoalescer()
+	}
+	n.timers.Add(t)
+	return nil
+}
+
+func (n *ParDo) fireTimers() error {
+	if n.timers == nil {
+		return nil
+	}
+	fn := n.Fn.OnTimerFn()
+	for _, d := range []TimeDomain{EventTimeDomain, ProcessingTimeDomain} {
+		err := n.timers.Fire(d, mtime.MaxTimestamp, func(ct *CoalescedTimer) error {
+			if d == EventTimeDomain {
+				// The watermark has passed the fire time.
+				n.ExpireSideInputs(ct.FireTime)
+			}
+			opt := &MainInput{Key: FullValue{Elm: ct.Family, Elm2: ct.Count}}
+			val, err := n.invokeDataFn(n.fnCtx, []typex.Window{ct.Window}, ct.FireTime, fn, opt)
+			if err != nil {
+				return err
+			}
+			if val != nil {
+				return n.Out[0].ProcessElement(n.ctx, val)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	n.timers = nil
+	return nil
+}
+
 // processMainInput processes an element that has been converted into a
 // MainInput. Splitting this away from ProcessElement allows other nodes to wrap
 // a ParDo's ProcessElement functionality with their own construction of
 // MainInputs.
 func (n *ParDo) processMainInput(mainIn *MainInput) error {
+	if n.tracer == nil {
+		return n.processWindows(mainIn)
+	}
+	fnCtx := n.fnCtx
+	ctx, span := n.tracer.StartSpan(fnCtx, n.spanName())
+	n.fnCtx, n.outputs = ctx, 0
+	defer func() {
+		n.fnCtx = fnCtx
+		span.End()
+	}()
+	err := n.processWindows(mainIn)
+	span.SetInt64(SpanOutputElements, n.outputs)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// spanName returns the name of the element spans of the ParDo.
+func (n *ParDo) spanName() string {
+	if n.PID != "" {
+		return n.PID
+	}
+	return n.Fn.Name()
+}
+
+// processWindows processes an element in each of its windows, or all at once
+// if the DoFn doesn't observe them.
+func (n *ParDo) processWindows(mainIn *MainInput) error {
 	elm := &mainIn.Key
 
 	// If the function observes windows, we must invoke it for each window. The expected fast path
@@ -139,10 +355,10 @@ func (n *ParDo) processMainInput(mainIn *MainInput) error {
 	} else {
 		for _, w := range elm.Windows {
 			elm := &mainIn.Key
-			wElm := FullValue{Elm: elm.Elm, Elm2: elm.Elm2, Timestamp: elm.Timestamp, Windows: []typex.Window{w}}
+			wElm := FullValue{Elm: elm.Elm, Elm2: elm.Elm2, Timestamp: elm.Timestamp, Windows: []typex.Window{w}, Pane: elm.Pane}
 			err := n.processSingleWindow(&MainInput{Key: wElm, Values: mainIn.Values, RTracker: mainIn.RTracker})
 			if err != nil {
-				return n.fail(err)
+				return n.failElement(err)
 			}
 		}
 	}
@@ -155,9 +371,9 @@ func (n *ParDo) processMainInput(mainIn *MainInput) error {
 // each individual window by exploding the windows first.
 func (n *ParDo) processSingleWindow(mainIn *MainInput) error {
 	elm := &mainIn.Key
-	val, err := n.invokeProcessFn(n.ctx, elm.Windows, elm.Timestamp, mainIn)
+	val, err := n.invokeProcessFn(n.fnCtx, elm.Windows, elm.Timestamp, mainIn)
 	if err != nil {
-		return n.fail(err)
+		return n.failElement(err)
 	}
 	if mainIn.RTracker != nil && !mainIn.RTracker.IsDone() {
 		return rtErrHelper(mainIn.RTracker.GetError())
@@ -165,6 +381,8 @@ func (n *ParDo) processSingleWindow(mainIn *MainInput) error {
 
 	// Forward direct output, if any. It is always a main output.
 	if val != nil {
+		val.Pane = elm.Pane
+		n.outputs++
 		return n.Out[0].ProcessElement(n.ctx, val)
 	}
 	return nil
@@ -195,14 +413,18 @@ func (n *ParDo) FinishBundle(_ context.Context) error {
 	if n.status != Active {
 		return errors.Errorf("invalid status for pardo %v: %v, want Active", n.UID, n.status)
 	}
+	if err := n.fireTimers(); err != nil {
+		return n.fail(err)
+	}
 	n.status = Up
 	n.inv.Reset()
 
-	if _, err := n.invokeDataFn(n.ctx, window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.FinishBundleFn(), nil); err != nil {
+	if _, err := n.invokeDataFn(n.fnCtx, window.SingleGlobalWindow, mtime.ZeroTimestamp, n.Fn.FinishBundleFn(), nil); err != nil {
 		return n.fail(err)
 	}
 	n.side = nil
 	n.cache = nil
+	n.sideInputs = nil
 
 	if err := MultiFinishBundle(n.ctx, n.Out...); err != nil {
 		return n.fail(err)
@@ -218,6 +440,8 @@ func (n *ParDo) Down(ctx context.Context) error {
 	n.status = Down
 	n.side = nil
 	n.cache = nil
+	n.sideInputs = nil
+	n.timers = nil
 
 	if _, err := InvokeWithoutEventTime(ctx, n.Fn.TeardownFn(), nil); err != nil {
 		n.err.TrySetError(err)
@@ -226,6 +450,9 @@ func (n *ParDo) Down(ctx context.Context) error {
 }
 
 func (n *ParDo) initSideInput(ctx context.Context, w typex.Window) error {
+	if n.MaxSideInputWindows > 0 && len(n.Side) > 0 {
+		return n.initCachedSideInput(ctx, w)
+	}
 	if n.cache == nil {
 		// First time: init single-element cache. We know that side input
 		// must come before emitters in the signature.
@@ -255,7 +482,7 @@ func (n *ParDo) initSideInput(ctx context.Context, w typex.Window) error {
 
 	streams := make([]ReStream, len(n.Side), len(n.Side))
 	for i, adapter := range n.Side {
-		s, err := adapter.NewIterable(ctx, n.side, w)
+		s, err := n.newSideInput(ctx, adapter, w)
 		if err != nil {
 			return err
 		}
@@ -279,11 +506,23 @@ func (n *ParDo) initSideInput(ctx context.Context, w typex.Window) error {
 	return nil
 }
 
+// newSideInput returns the side input of the given adapter for the window,
+// read lazily with prefetching if enabled and supported by the adapter.
+func (n *ParDo) newSideInput(ctx context.Context, adapter SideInputAdapter, w typex.Window) (ReStream, error) {
+	if a, ok := adapter.(IterableSideInputAdapter); ok && n.SideInputPrefetch > 0 {
+		return a.Iterable(ctx, n.side, w, n.SideInputPrefetch)
+	}
+	return adapter.NewIterable(ctx, n.side, w)
+}
+
 // invokeDataFn handle non-per element invocations.
 func (n *ParDo) invokeDataFn(ctx context.Context, ws []typex.Window, ts typex.EventTime, fn *funcx.Fn, opt *MainInput) (*FullValue, error) {
 	if fn == nil {
 		return nil, nil
 	}
+	if n.timer != nil {
+		defer n.timeInvocation(time.Now())
+	}
 	if err := n.preInvoke(ctx, ws, ts); err != nil {
 		return nil, err
 	}
@@ -299,19 +538,43 @@ func (n *ParDo) invokeDataFn(ctx context.Context, ws []typex.Window, ts typex.Ev
 
 // invokeProcessFn handles the per element invocations
 func (n *ParDo) invokeProcessFn(ctx context.Context, ws []typex.Window, ts typex.EventTime, opt *MainInput) (*FullValue, error) {
+	if n.timer != nil {
+		defer n.timeInvocation(time.Now())
+	}
+	if n.timeProcess {
+		defer n.recordProcessTime(time.Now())
+	}
 	if err := n.preInvoke(ctx, ws, ts); err != nil {
 		return nil, err
 	}
+	n.checkTs, n.inTs, n.inPane = true, ts, opt.Key.Pane
 	val, err := n.inv.Invoke(ctx, ws, ts, opt, n.cache.extra...)
+	n.checkTs = false
 	if err != nil {
+		n.postInvoke() // ok: the invocation error takes precedence.
 		return nil, err
 	}
+	if val != nil && val.Timestamp < ts.Subtract(n.skew) {
+		return nil, &TimestampSkewError{DoFn: n.Fn.Name(), Input: ts, Output: val.Timestamp, Allowed: n.skew}
+	}
 	if err := n.postInvoke(); err != nil {
 		return nil, err
 	}
 	return val, nil
 }
 
+// timeInvocation reports the duration of a DoFn invocation started at the
+// given time, including the time spent in the nodes it emits to.
+func (n *ParDo) timeInvocation(start time.Time) {
+	n.timer(n.UID, time.Since(start))
+}
+
+// recordProcessTime records the duration of a ProcessElement invocation
+// started at the given time in the distribution of the transform.
+func (n *ParDo) recordProcessTime(start time.Time) {
+	processElementTimes.Update(n.ctx, int64(time.Since(start)/time.Microsecond))
+}
+
 func (n *ParDo) preInvoke(ctx context.Context, ws []typex.Window, ts typex.EventTime) error {
 	for _, e := range n.emitters {
 		if err := e.Init(ctx, ws, ts); err != nil {
@@ -332,18 +595,56 @@ func (n *ParDo) postInvoke() error {
 
 func (n *ParDo) fail(err error) error {
 	n.status = Broken
-	if err2, ok := err.(*doFnError); ok {
-		return err2
+	parDoError, ok := n.doFnError(err)
+	if !ok {
+		n.err.TrySetError(parDoError)
 	}
+	return parDoError
+}
 
-	parDoError := &doFnError{
+// failElement fails the processing of the current element. Unless the error
+// is recoverable, the ParDo is broken like on any other failure. Aborted
+// bundles are never recoverable.
+func (n *ParDo) failElement(err error) error {
+	if _, aborted := asBundleAborted(err); n.recoverable != nil && !aborted {
+		if parDoError, _ := n.doFnError(err); n.recoverable(parDoError) {
+			return parDoError
+		}
+	}
+	return n.fail(err)
+}
+
+// doFnError returns the error attributed to the DoFn, and whether it was
+// already a DoFn error.
+func (n *ParDo) doFnError(err error) (*doFnError, bool) {
+	if err2, ok := err.(*doFnError); ok {
+		return err2, true
+	}
+	return &doFnError{
 		doFn: n.Fn.Name(),
 		err:  err,
 		uid:  n.UID,
 		pid:  n.PID,
+	}, false
+}
+
+// recoverOn keeps the ParDo wrapped by n, if any, Active after element errors
+// matching the given predicate, in addition to those it already recovers from.
+func recoverOn(n Node, pred func(error) bool) {
+	switch n := n.(type) {
+	case *ParDo:
+		if prev := n.recoverable; prev != nil {
+			n.recoverable = func(err error) bool { return prev(err) || pred(err) }
+		} else {
+			n.recoverable = pred
+		}
+	case *RetryNode:
+		recoverOn(n.Node, pred)
+	case *DeadLetterNode:
+		recoverOn(n.Node, pred)
+	case *CircuitBreaker:
+		recoverOn(n.Node, pred)
 	}
-	n.err.TrySetError(parDoError)
-	return parDoError
 }
 
 func (n *ParDo) String() string {
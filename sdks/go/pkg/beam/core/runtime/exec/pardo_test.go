@@ -17,13 +17,17 @@ package exec
 
 import (
 	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
 )
 
 func sumFn(n int, a int, b []int, c func(*int) bool, d func() func(*int) bool, e func(int)) int {
@@ -97,6 +101,75 @@ func TestParDo(t *testing.T) {
 	}
 }
 
+// shiftFn emits each element with its timestamp shifted back by Shift.
+type shiftFn struct {
+	Shift                time.Duration
+	AllowedTimestampSkew time.Duration
+}
+
+func (f *shiftFn) ProcessElement(ts typex.EventTime, n int, emit func(typex.EventTime, int)) {
+	emit(ts.Subtract(f.Shift), n)
+}
+
+// TestParDo_timestampSkew verifies that emitting timestamps earlier than the
+// allowed skew fails the bundle with a TimestampSkewError.
+func TestParDo_timestampSkew(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      *shiftFn
+		wantErr bool
+	}{
+		{"noShift", &shiftFn{}, false},
+		{"rejected", &shiftFn{Shift: time.Second}, true},
+		{"allowed", &shiftFn{Shift: time.Second, AllowedTimestampSkew: time.Minute}, false},
+		{"exceeded", &shiftFn{Shift: time.Hour, AllowedTimestampSkew: time.Minute}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fn, err := graph.NewDoFn(test.fn)
+			if err != nil {
+				t.Fatalf("invalid function: %v", err)
+			}
+			g := graph.New()
+			nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+			edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+			if err != nil {
+				t.Fatalf("invalid pardo: %v", err)
+			}
+
+			out := &CaptureNode{UID: 1}
+			pardo := &ParDo{UID: 2, Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}}
+			n := &FixedRoot{UID: 3, Elements: makeInput(10, 20), Out: pardo}
+
+			p, err := NewPlan("a", []Unit{n, pardo, out})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{})
+			if !test.wantErr {
+				if err != nil {
+					t.Fatalf("execute failed: %v", err)
+				}
+				if got, want := len(out.Elements), 2; got != want {
+					t.Errorf("pardo(shiftFn) emitted %v elements, want %v", got, want)
+				}
+				return
+			}
+			e, ok := AsDoFnError(err)
+			if !ok {
+				t.Fatalf("execute = %v, want DoFn error", err)
+			}
+			skewErr, ok := e.err.(*TimestampSkewError)
+			if !ok {
+				t.Fatalf("execute = %v, want TimestampSkewError", e.err)
+			}
+			if got, want := skewErr.Delta(), test.fn.Shift; got != want {
+				t.Errorf("TimestampSkewError.Delta() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 func emitSumFn(n int, emit func(int)) {
 	emit(n + 1)
 }
@@ -148,3 +221,197 @@ func BenchmarkParDo_EmitSumFn(b *testing.B) {
 	}
 	close(process)
 }
+
+// TestParDo_invocationTimer verifies that the invocation timer observes each
+// DoFn invocation, keyed by the ID of its ParDo.
+func TestParDo_invocationTimer(t *testing.T) {
+	fn, err := graph.NewDoFn(emitSumFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 4}
+	pardo2 := &ParDo{UID: 3, Fn: fn, Out: []Node{out}}
+	pardo1 := &ParDo{UID: 2, Fn: fn, Out: []Node{pardo2}}
+	root := &FixedRoot{UID: 1, Elements: makeInput(1, 2, 3), Out: pardo1}
+	p, err := NewPlan("a", []Unit{root, pardo1, pardo2, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	calls := make(map[UnitID]int)
+	ctx := WithInvocationTimer(context.Background(), func(uid UnitID, d time.Duration) {
+		calls[uid]++
+	})
+	if err := p.Execute(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	// Each unit is brought up once, and each DoFn invoked once per element.
+	for uid, want := range map[UnitID]int{1: 4, 2: 4, 3: 4, 4: 1} {
+		if got := calls[uid]; got != want {
+			t.Errorf("timer called %v times for unit %v, want %v", got, uid, want)
+		}
+	}
+}
+
+// TestParDo_processElementTimes verifies that ProcessElement durations are
+// recorded per transform only if enabled.
+func TestParDo_processElementTimes(t *testing.T) {
+	fn, err := graph.NewDoFn(emitSumFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	run := func(ctx context.Context) map[string]int64 {
+		out := &CaptureNode{UID: 3}
+		pardo := &ParDo{UID: 2, PID: "emitSum", Fn: fn, Out: []Node{out}}
+		root := &FixedRoot{UID: 1, Elements: makeInput(1, 2, 3), Out: pardo}
+		p, err := NewPlan("a", []Unit{root, pardo, out})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		counts := make(map[string]int64)
+		metrics.Extractor{
+			DistributionInt64: func(l metrics.Labels, count, sum, min, max int64) {
+				if l.Name() == "processElement.usecs" {
+					counts[l.Transform()] = count
+				}
+			},
+		}.ExtractFrom(p.Store())
+		return counts
+	}
+
+	if got := run(context.Background()); len(got) != 0 {
+		t.Errorf("recorded %v when disabled, want nothing", got)
+	}
+	if got, want := run(WithProcessElementTimes(context.Background()))["emitSum"], int64(3); got != want {
+		t.Errorf("recorded %v durations for emitSum, want %v", got, want)
+	}
+}
+
+// loggedFields holds the log fields observed by logFieldsFn.
+var loggedFields [][]log.Field
+
+func logFieldsFn(ctx context.Context, n int, emit func(int)) {
+	loggedFields = append(loggedFields, LogFields(ctx))
+	emit(n)
+}
+
+// TestParDo_logFields verifies that DoFns are passed contexts with the log
+// fields of their bundle and unit.
+func TestParDo_logFields(t *testing.T) {
+	fn, err := graph.NewDoFn(logFieldsFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 4}
+	pardo2 := &ParDo{UID: 3, PID: "second", Fn: fn, Out: []Node{out}}
+	pardo1 := &ParDo{UID: 2, PID: "first", Fn: fn, Out: []Node{pardo2}}
+	root := &FixedRoot{UID: 1, Elements: makeInput(1), Out: pardo1}
+	p, err := NewPlan("stage", []Unit{root, pardo1, pardo2, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	loggedFields = nil
+	if err := p.Execute(context.Background(), "bundle", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	fields := func(uid, pid string) []log.Field {
+		return []log.Field{
+			{Key: LogPlanIDField, Value: "stage"},
+			{Key: LogBundleIDField, Value: "bundle"},
+			{Key: LogUnitIDField, Value: uid},
+			{Key: LogTransformIDField, Value: pid},
+		}
+	}
+	// The fields of the first ParDo are replaced by those of the second.
+	want := [][]log.Field{fields("2", "first"), fields("3", "second")}
+	if !reflect.DeepEqual(loggedFields, want) {
+		t.Errorf("log fields = %v, want %v", loggedFields, want)
+	}
+}
+
+func abortFn(n int) (int, error) {
+	if n == 2 {
+		return 0, AbortBundle("returned")
+	}
+	return n, nil
+}
+
+func abortPanicFn(n int) int {
+	if n == 2 {
+		panic(AbortBundle("panicked"))
+	}
+	return n
+}
+
+// TestParDo_abortBundle verifies that DoFns aborting the bundle, even when
+// downstream of or wrapped by other nodes, fail the plan with a
+// BundleAbortedError rather than a DoFn error.
+func TestParDo_abortBundle(t *testing.T) {
+	abort, err := graph.NewDoFn(abortFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	abortPanic, err := graph.NewDoFn(abortPanicFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	identity, err := graph.NewDoFn(func(n int) int { return n })
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	retryAll := RetryPolicy{MaxAttempts: 3, Retryable: func(error) bool { return true }}
+
+	tests := []struct {
+		name   string
+		fn     *graph.DoFn
+		reason string
+		pid    string
+		wrap   func(n Node) Node
+	}{
+		{name: "returned", fn: abort, reason: "returned", pid: "abort"},
+		// Panics are not attributed to the DoFn.
+		{name: "panicked", fn: abortPanic, reason: "panicked"},
+		{name: "downstream", fn: abort, reason: "returned", pid: "abort", wrap: func(n Node) Node {
+			return &ParDo{UID: 4, PID: "upstream", Fn: identity, Out: []Node{n}}
+		}},
+		{name: "retried", fn: abort, reason: "returned", pid: "abort", wrap: func(n Node) Node {
+			return NewRetryNode(n, retryAll)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			pardo := &ParDo{UID: 2, PID: "abort", Fn: test.fn, Out: []Node{out}}
+			units := []Unit{pardo, out}
+			var n Node = pardo
+			if test.wrap != nil {
+				n = test.wrap(pardo)
+				if _, ok := n.(*ParDo); ok {
+					units = append(units, n)
+				} else {
+					units[0] = n
+				}
+			}
+			root := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: n}
+			p, err := NewPlan("a", append([]Unit{root}, units...))
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			err = p.Execute(context.Background(), "1", DataContext{})
+			abort, ok := err.(*BundleAbortedError)
+			if !ok {
+				t.Fatalf("execute = %v, want BundleAbortedError", err)
+			}
+			if abort.Reason != test.reason || abort.PID != test.pid {
+				t.Errorf("execute = %+v, want abort %q of %q", abort, test.reason, test.pid)
+			}
+			if _, ok := AsDoFnError(err); ok {
+				t.Errorf("execute = %v, want no DoFn error", err)
+			}
+		})
+	}
+}
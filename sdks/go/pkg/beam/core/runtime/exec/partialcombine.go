@@ -0,0 +1,184 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// PartialCombine is an executor for combining values before grouping by keys,
+// like LiftedCombine, but with a bounded cache of at most MaxKeys
+// accumulators with least-recently-used eviction. Accumulators are kept per
+// key and window, and emitted as KV<key, accumulator> values on eviction and
+// on FinishBundle. The CombineFn's CreateAccumulator and AddInput methods are
+// invoked here, while its MergeAccumulators and ExtractOutput methods are
+// invoked downstream of the GBK, by MergeAccumulators and ExtractOutput.
+//
+// MaxKeys trades memory for the effectiveness of the pre-aggregation: each
+// cached accumulator is held until the end of the bundle or until evicted,
+// and an evicted key seen again starts a new accumulator, so the GBK receives
+// one more value for it. Results are exact either way.
+type PartialCombine struct {
+	*Combine
+	KeyCoder *coder.Coder
+	MaxKeys  int
+
+	keyHash elementHasher
+	lru     *list.List                 // Cached *partialAccum, most recently used first.
+	cache   map[uint64][]*list.Element // Cached accumulators by key hash.
+}
+
+// partialAccum is a cached accumulator for a key in a single window.
+type partialAccum struct {
+	hash uint64
+	fv   FullValue
+}
+
+// NewPartialCombine returns a PartialCombine for the given CombineFn that
+// caches accumulators for at most maxKeys keys, and emits them to out. The
+// caller sets the UID and PID of the node.
+func NewPartialCombine(out Node, fn *graph.CombineFn, keyCoder *coder.Coder, maxKeys int) *PartialCombine {
+	return &PartialCombine{Combine: &Combine{Fn: fn, Out: out}, KeyCoder: keyCoder, MaxKeys: maxKeys}
+}
+
+func (n *PartialCombine) String() string {
+	return fmt.Sprintf("PartialCombine[%v] Keyed:%v MaxKeys:%v Out:%v", path.Base(n.Fn.Name()), n.UsesKey, n.MaxKeys, n.Out.ID())
+}
+
+// Up initializes the PartialCombine.
+func (n *PartialCombine) Up(ctx context.Context) error {
+	if n.MaxKeys < 1 {
+		return errors.Errorf("invalid maximum number of keys for partial combine %v: %v, want > 0", n.UID, n.MaxKeys)
+	}
+	if err := n.Combine.Up(ctx); err != nil {
+		return err
+	}
+	// Windows are compared directly, so only the key is hashed.
+	n.keyHash = makeElementHasher(n.KeyCoder, coder.NewGlobalWindow())
+	return nil
+}
+
+// StartBundle initializes the cache of keys to accumulators.
+func (n *PartialCombine) StartBundle(ctx context.Context, id string, data DataContext) error {
+	if err := n.Combine.StartBundle(ctx, id, data); err != nil {
+		return err
+	}
+	n.lru = list.New()
+	n.cache = make(map[uint64][]*list.Element)
+	return nil
+}
+
+// ProcessElement takes a KV pair and adds the value to the accumulator of its
+// key in each of its windows, evicting the least recently used accumulator if
+// the cache is full.
+func (n *PartialCombine) ProcessElement(ctx context.Context, value *FullValue, values ...ReStream) error {
+	if n.status != Active {
+		return errors.Errorf("invalid status for partial combine %v: %v", n.UID, n.status)
+	}
+	for _, w := range value.Windows {
+		if err := n.processElementPerWindow(value, w); err != nil {
+			return n.fail(err)
+		}
+	}
+	return nil
+}
+
+func (n *PartialCombine) processElementPerWindow(value *FullValue, w typex.Window) error {
+	h, err := n.keyHash.Hash(value.Elm, nil)
+	if err != nil {
+		return err
+	}
+
+	var e *list.Element
+	for _, c := range n.cache[h] {
+		if c.Value.(*partialAccum).fv.Windows[0].Equals(w) {
+			e = c
+			break
+		}
+	}
+
+	first := e == nil
+	var a interface{}
+	if first {
+		if a, err = n.newAccum(n.Combine.ctx, value.Elm); err != nil {
+			return err
+		}
+	} else {
+		a = e.Value.(*partialAccum).fv.Elm2
+	}
+	if a, err = n.addInput(n.Combine.ctx, a, value.Elm, value.Elm2, value.Timestamp, first); err != nil {
+		return err
+	}
+
+	fv := FullValue{Windows: []typex.Window{w}, Elm: value.Elm, Elm2: a, Timestamp: value.Timestamp, Pane: value.Pane}
+	if !first {
+		e.Value.(*partialAccum).fv = fv
+		n.lru.MoveToFront(e)
+		return nil
+	}
+	n.cache[h] = append(n.cache[h], n.lru.PushFront(&partialAccum{hash: h, fv: fv}))
+	if n.lru.Len() > n.MaxKeys {
+		return n.evict(n.lru.Back())
+	}
+	return nil
+}
+
+// evict removes the accumulator from the cache and emits it.
+func (n *PartialCombine) evict(e *list.Element) error {
+	pa := n.lru.Remove(e).(*partialAccum)
+	es := n.cache[pa.hash]
+	for i, c := range es {
+		if c == e {
+			es = append(es[:i], es[i+1:]...)
+			break
+		}
+	}
+	if len(es) == 0 {
+		delete(n.cache, pa.hash)
+	} else {
+		n.cache[pa.hash] = es
+	}
+	return n.Out.ProcessElement(n.Combine.ctx, &pa.fv)
+}
+
+// FinishBundle emits all cached accumulators, least recently used first, and
+// finishes the bundle.
+func (n *PartialCombine) FinishBundle(ctx context.Context) error {
+	for n.lru.Len() > 0 {
+		if err := n.evict(n.lru.Back()); err != nil {
+			return n.fail(err)
+		}
+	}
+	n.lru, n.cache = nil, nil
+	return n.Combine.FinishBundle(n.Combine.ctx)
+}
+
+// Down tears down the cache.
+func (n *PartialCombine) Down(ctx context.Context) error {
+	if err := n.Combine.Down(ctx); err != nil {
+		return err
+	}
+	n.lru, n.cache = nil, nil
+	return nil
+}
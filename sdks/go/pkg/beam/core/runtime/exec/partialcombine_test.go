@@ -0,0 +1,87 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+// countNode is a test Node that counts the elements passed to the wrapped
+// node.
+type countNode struct {
+	Node
+	count int
+}
+
+func (n *countNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.count++
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+// TestPartialCombine verifies that a PartialCombine chain computes exact
+// results regardless of how many accumulators fit in its cache.
+func TestPartialCombine(t *testing.T) {
+	// Interleave the values of two keys, so a small cache evicts constantly.
+	makeInput := func(input []interface{}) []MainInput {
+		var ret []MainInput
+		for _, v := range input {
+			ret = append(ret, makeKVInput(1, v)...)
+			ret = append(ret, makeKVInput(2, v)...)
+		}
+		return ret
+	}
+	keyCoder := intCoder(reflectx.Int)
+	wc := coder.NewGlobalWindow()
+	for _, maxKeys := range []int{1, 2} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s_maxKeys%d", fnName(test.Fn), maxKeys), func(t *testing.T) {
+				edge := getCombineEdge(t, test.Fn, reflectx.Int, test.AccumCoder)
+
+				out := &CaptureNode{UID: 1}
+				extract := &ExtractOutput{Combine: &Combine{UID: 2, Fn: edge.CombineFn, Out: out}}
+				merge := &MergeAccumulators{Combine: &Combine{UID: 3, Fn: edge.CombineFn, Out: extract}}
+				gbk := &simpleGBK{UID: 4, KeyCoder: keyCoder, WindowCoder: wc, Out: merge}
+				counter := &countNode{Node: gbk}
+				precombine := NewPartialCombine(counter, edge.CombineFn, keyCoder, maxKeys)
+				precombine.UID = 5
+				n := &FixedRoot{UID: 6, Elements: makeInput(test.Input), Out: precombine}
+
+				constructAndExecutePlan(t, []Unit{n, precombine, gbk, merge, extract, out})
+				sort.Slice(out.Elements, func(i, j int) bool {
+					return out.Elements[i].Elm.(int) < out.Elements[j].Elm.(int)
+				})
+				expected := append(makeKV(1, test.Expected), makeKV(2, test.Expected)...)
+				if !equalList(out.Elements, expected) {
+					t.Errorf("partialCombineChain(%s) = %v, want %v", edge.CombineFn.Name(), extractKeyedValues(out.Elements...), extractKeyedValues(expected...))
+				}
+				// With room for both keys, each key is only emitted once.
+				want := 2
+				if maxKeys == 1 {
+					want = 2 * len(test.Input)
+				}
+				if counter.count != want {
+					t.Errorf("partial combine with %v keys emitted %v accumulators, want %v", maxKeys, counter.count, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+)
+
+// PartitionNode routes each element unchanged to either the wrapped node or
+// Rest, depending on whether it matches Predicate. Like any other output, Rest
+// is brought up and down as a unit of the plan.
+type PartitionNode struct {
+	Node
+	Rest      Node
+	Predicate func(*FullValue) bool
+}
+
+// NewPartitionNode returns a node that passes elements matching pred to match,
+// and all others to rest.
+func NewPartitionNode(match, rest Node, pred func(*FullValue) bool) *PartitionNode {
+	return &PartitionNode{Node: match, Rest: rest, Predicate: pred}
+}
+
+// StartBundle starts the matching and the remaining nodes.
+func (n *PartitionNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return MultiStartBundle(ctx, id, data, n.Node, n.Rest)
+}
+
+// ProcessElement forwards the element to the wrapped node if it matches, and
+// to the remaining node otherwise.
+func (n *PartitionNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.Predicate(elm) {
+		return n.Node.ProcessElement(ctx, elm, values...)
+	}
+	return n.Rest.ProcessElement(ctx, elm, values...)
+}
+
+// FinishBundle finishes the matching and the remaining nodes.
+func (n *PartitionNode) FinishBundle(ctx context.Context) error {
+	return MultiFinishBundle(ctx, n.Node, n.Rest)
+}
+
+func (n *PartitionNode) String() string {
+	return fmt.Sprintf("PartitionNode[Rest:%v]. Node:%v", n.Rest.ID(), n.Node)
+}
@@ -0,0 +1,105 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseGate blocks element processing while paused. The nil gate never
+// blocks.
+type pauseGate struct {
+	mu sync.Mutex
+	// resumed is closed on resumption. It is nil unless paused.
+	resumed chan struct{}
+}
+
+func (g *pauseGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+	}
+}
+
+func (g *pauseGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+func (g *pauseGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// wait blocks while the gate is paused. It returns the error of the context,
+// if it is done before the gate is resumed.
+func (g *pauseGate) wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func withPauseGate(ctx context.Context, g *pauseGate) context.Context {
+	return context.WithValue(ctx, pauseGateKey, g)
+}
+
+func getPauseGate(ctx context.Context) *pauseGate {
+	g, _ := ctx.Value(pauseGateKey).(*pauseGate)
+	return g
+}
+
+// Pause makes the plan block element processing at node boundaries, before
+// DataSources pass on the next element and before ParDos process an
+// element, until Resume is called. Elements already read are held rather
+// than lost. While paused, processing honors the cancellation of the context
+// of Execute, which fails the bundle. Pausing mid-bundle holds the bundle
+// open, so the runner sees no progress and may time it out, and data already
+// sent for it is buffered. Pause and Resume are safe to call concurrently with
+// Execute, such as from a control goroutine, and pausing a paused plan has no
+// effect.
+func (p *Plan) Pause() {
+	p.gate.pause()
+}
+
+// Resume unblocks element processing paused with Pause. Resuming a plan that
+// is not paused has no effect.
+func (p *Plan) Resume() {
+	p.gate.resume()
+}
+
+// Paused returns whether the plan is paused.
+func (p *Plan) Paused() bool {
+	return p.gate.paused()
+}
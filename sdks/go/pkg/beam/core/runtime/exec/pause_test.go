@@ -0,0 +1,87 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+)
+
+// TestPlan_pause verifies that a paused plan processes no elements until
+// resumed, and that cancellation of the context fails a paused bundle.
+func TestPlan_pause(t *testing.T) {
+	fn, err := graph.NewDoFn(emitSumFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	newPlan := func(t *testing.T) (*Plan, *CaptureNode) {
+		out := &CaptureNode{UID: 3}
+		pardo := &ParDo{UID: 2, Fn: fn, Out: []Node{out}}
+		root := &FixedRoot{UID: 1, Elements: makeInput(1, 2, 3), Out: pardo}
+		p, err := NewPlan("a", []Unit{root, pardo, out})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		return p, out
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		p, out := newPlan(t)
+		p.Pause()
+		if !p.Paused() {
+			t.Errorf("Paused() = false after Pause, want true")
+		}
+		done := make(chan error)
+		go func() { done <- p.Execute(context.Background(), "1", DataContext{}) }()
+
+		select {
+		case err := <-done:
+			t.Fatalf("Execute returned %v while paused, want it to block", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		if len(out.Elements) != 0 {
+			t.Fatalf("paused plan processed %v elements, want 0", len(out.Elements))
+		}
+
+		p.Resume()
+		if err := <-done; err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if p.Paused() {
+			t.Errorf("Paused() = true after Resume, want false")
+		}
+		if !equalList(out.Elements, makeValues(2, 3, 4)) {
+			t.Errorf("pardo => %#v, want %#v", extractValues(out.Elements...), extractValues(makeValues(2, 3, 4)...))
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		p, _ := newPlan(t)
+		p.Pause()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- p.Execute(ctx, "1", DataContext{}) }()
+
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Execute = %v, want %v", err, context.Canceled)
+		}
+	})
+}
@@ -22,7 +22,9 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
@@ -46,6 +48,18 @@ type Plan struct {
 
 	// TODO: there can be more than 1 DataSource in a bundle.
 	source *DataSource
+
+	// taps holds the tap interceptors installed by InstallTap, by node.
+	taps map[UnitID]*tapNode
+
+	// finalizer holds the finalization callbacks of the last bundle.
+	finalizer *bundleFinalizer
+
+	// stateCache holds state read by bundles, if enabled with WithStateCache.
+	stateCache *StateCache
+
+	// gate blocks element processing while the plan is paused.
+	gate pauseGate
 }
 
 // hasPID provides a common interface for extracting PTransformIDs
@@ -101,16 +115,31 @@ func (p *Plan) SourcePTransformID() string {
 // Execute executes the plan with the given data context and bundle id. Units
 // are brought up on the first execution. If a bundle fails, the plan cannot
 // be reused for further bundles. Does not panic. Blocking.
-func (p *Plan) Execute(ctx context.Context, id string, manager DataContext) error {
+func (p *Plan) Execute(ctx context.Context, id string, manager DataContext) (err error) {
+	// The finalizer, cleanups, log fields, pause gate and deadline must be set
+	// before the bundle ID, since metrics only recognize their own context type.
+	p.finalizer = &bundleFinalizer{}
+	ctx = context.WithValue(ctx, bundleFinalizerKey, p.finalizer)
+	cleanups := &bundleCleanups{}
+	ctx = context.WithValue(ctx, bundleCleanupKey, cleanups)
+	defer func() {
+		err = p.withCleanupErrors(ctx, err, cleanups.run())
+	}()
+	ctx = withBundleLogFields(ctx, p.id, id)
+	ctx = withPauseGate(ctx, &p.gate)
+	if d := getBundleDeadline(ctx); d > 0 {
+		dl := startBundleDeadline(d)
+		defer dl.stop()
+		ctx = withBundleDeadlineState(ctx, dl)
+	}
 	ctx = metrics.SetBundleID(ctx, p.id)
 	p.storeMu.Lock()
 	p.store = metrics.GetStore(ctx)
 	p.storeMu.Unlock()
 	if p.status == Initializing {
 		for _, u := range p.units {
-			if err := callNoPanic(ctx, u.Up); err != nil {
-				p.status = Broken
-				return errors.Wrapf(err, "while executing Up for %v", p)
+			if err := callUnitNoPanic(ctx, u.ID(), u.Up); err != nil {
+				return p.fail(err, "Up")
 			}
 		}
 		p.status = Up
@@ -126,22 +155,29 @@ func (p *Plan) Execute(ctx context.Context, id string, manager DataContext) erro
 	// Process bundle. If there are any kinds of failures, we bail and mark the plan broken.
 
 	p.status = Active
+	if size := getStateCacheSize(ctx); size > 0 && manager.State != nil {
+		if p.stateCache == nil {
+			p.stateCache = NewStateCache(size)
+		}
+		manager.State = newCachedStateReader(manager.State, p.stateCache, manager.CacheTokens)
+	}
 	for _, root := range p.roots {
-		if err := callNoPanic(ctx, func(ctx context.Context) error { return root.StartBundle(ctx, id, manager) }); err != nil {
-			p.status = Broken
-			return errors.Wrapf(err, "while executing StartBundle for %v", p)
+		if err := callUnitNoPanic(ctx, root.ID(), func(ctx context.Context) error {
+			return withNodeChain(root.StartBundle(ctx, id, manager), root.ID())
+		}); err != nil {
+			return p.fail(err, "StartBundle")
 		}
 	}
 	for _, root := range p.roots {
-		if err := callNoPanic(ctx, root.Process); err != nil {
-			p.status = Broken
-			return errors.Wrapf(err, "while executing Process for %v", p)
+		if err := callUnitNoPanic(ctx, root.ID(), root.Process); err != nil {
+			return p.fail(err, "Process")
 		}
 	}
 	for _, root := range p.roots {
-		if err := callNoPanic(ctx, root.FinishBundle); err != nil {
-			p.status = Broken
-			return errors.Wrapf(err, "while executing FinishBundle for %v", p)
+		if err := callUnitNoPanic(ctx, root.ID(), func(ctx context.Context) error {
+			return withNodeChain(root.FinishBundle(ctx), root.ID())
+		}); err != nil {
+			return p.fail(err, "FinishBundle")
 		}
 	}
 
@@ -149,6 +185,69 @@ func (p *Plan) Execute(ctx context.Context, id string, manager DataContext) erro
 	return nil
 }
 
+// fail marks the plan broken after a failure in the given phase, and returns
+// the error with the phase. Aborted bundles and exceeded deadlines are
+// reported as is.
+func (p *Plan) fail(err error, phase string) error {
+	setStage(err, p.id)
+	p.status = Broken
+	if abort, ok := err.(*BundleAbortedError); ok {
+		return abort
+	}
+	if dl, ok := asBundleDeadlineExceeded(err); ok {
+		return dl
+	}
+	return errors.Wrapf(err, "while executing %v for %v", phase, p)
+}
+
+// Finalize invokes the finalization callbacks registered during the last
+// bundle. It must only be called once the runner has durably committed the
+// bundle. Callbacks whose validity has expired are not invoked, but reported
+// as errors, and callbacks failing with a Transient error are retried. The
+// callbacks are cleared afterwards, so they are invoked at most once.
+func (p *Plan) Finalize() error {
+	if p.finalizer == nil {
+		return nil
+	}
+	errs := p.finalizer.finalize()
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errors.Wrapf(errs[0], "plan %v failed to finalize bundle", p.id)
+	default:
+		return errors.Errorf("plan %v failed to finalize bundle with multiple errors: %v", p.id, errs)
+	}
+}
+
+// FinalizationExpiry returns the time after which none of the finalization
+// callbacks of the last bundle is valid anymore. It is the zero time if there
+// are no callbacks.
+func (p *Plan) FinalizationExpiry() time.Time {
+	if p.finalizer == nil {
+		return time.Time{}
+	}
+	return p.finalizer.expiry()
+}
+
+// StateCache returns the cache of state read across bundles of the plan, or
+// nil if state caching is not enabled.
+func (p *Plan) StateCache() *StateCache {
+	return p.stateCache
+}
+
+// Checkpointers returns the units of the plan that can checkpoint their
+// progress, keyed by transform ID.
+func (p *Plan) Checkpointers() map[string]Checkpointer {
+	ret := make(map[string]Checkpointer)
+	for _, u := range p.units {
+		if c, ok := u.(*ProcessSizedElementsAndRestrictions); ok && c.CheckpointCoder != nil {
+			ret[c.TfId] = c
+		}
+	}
+	return ret
+}
+
 // Down takes the plan and associated units down. Does not panic.
 func (p *Plan) Down(ctx context.Context) error {
 	if p.status == Down {
@@ -158,7 +257,8 @@ func (p *Plan) Down(ctx context.Context) error {
 
 	var errs []error
 	for _, u := range p.units {
-		if err := callNoPanic(ctx, u.Down); err != nil {
+		if err := callUnitNoPanic(ctx, u.ID(), u.Down); err != nil {
+			setStage(err, p.id)
 			errs = append(errs, err)
 		}
 	}
@@ -189,6 +289,30 @@ func (p *Plan) Progress() (ProgressReportSnapshot, bool) {
 	return ProgressReportSnapshot{}, false
 }
 
+// Watermark returns the output watermark hold reported by the DataSource of
+// the plan in the current bundle, if any. The boolean is false if the plan has
+// no DataSource or no watermark was reported. Safe to call while the plan is
+// executing.
+func (p *Plan) Watermark() (mtime.Time, bool) {
+	if p.source == nil {
+		return mtime.MinTimestamp, false
+	}
+	wm := p.source.Watermark()
+	return wm, wm > mtime.MinTimestamp
+}
+
+// ProgressFraction returns the fraction of input processed by the plan and the
+// number of remaining input elements, as reported by its DataSource. The
+// boolean is false if the plan has no DataSource. Safe to call while the plan
+// is executing.
+func (p *Plan) ProgressFraction() (float64, int64, bool) {
+	if p.source != nil {
+		done, remaining := p.source.ProgressFraction()
+		return done, remaining, true
+	}
+	return 0, -1, false
+}
+
 // Store returns the metric store for the last use of this plan.
 func (p *Plan) Store() *metrics.Store {
 	p.storeMu.Lock()
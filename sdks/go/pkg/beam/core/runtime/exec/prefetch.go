@@ -0,0 +1,140 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// WithPrefetch returns a context in which DataSources read and decode up to
+// depth elements ahead of processing, in a separate goroutine, so that the
+// decoding of elements overlaps with their processing downstream. It pays
+// off for costly coders, or streams that are slow to arrive. The goroutine
+// stops at the end of the data, or when the bundle ends. A bundle ending early
+// on a split waits for the decoding of the current element to return, unless
+// the context is done, in which case the goroutine returns on its own once the
+// stream is closed. Values less than 1 disable prefetching, which is the
+// default, except for unbounded sources, which always decode ahead by at least
+// one element so that they can deliver watermarks while waiting for data.
+func WithPrefetch(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, prefetchKey, depth)
+}
+
+func getPrefetchDepth(ctx context.Context) int {
+	v, _ := ctx.Value(prefetchKey).(int)
+	return v
+}
+
+// prefetched is an element decoded ahead, or the error that ended decoding.
+type prefetched struct {
+	elm    *FullValue
+	values []ReStream
+	err    error
+}
+
+// prefetcher calls a decode function from a separate goroutine until it
+// fails, buffering up to depth results.
+type prefetcher struct {
+	ctx    context.Context
+	stream io.Closer // Closed by stop, to end a blocked read.
+	ch     chan prefetched
+	done   chan struct{} // Closed to stop the goroutine.
+	exit   chan struct{} // Closed once the goroutine returns.
+}
+
+// startPrefetch starts decoding elements of the given data stream ahead.
+func startPrefetch(ctx context.Context, decode func() (*FullValue, []ReStream, error), depth int, stream io.Closer) *prefetcher {
+	p := &prefetcher{
+		ctx:    ctx,
+		stream: stream,
+		ch:     make(chan prefetched, depth),
+		done:   make(chan struct{}),
+		exit:   make(chan struct{}),
+	}
+	go func() {
+		defer close(p.exit)
+		for {
+			elm, values, err := decode()
+			select {
+			case p.ch <- prefetched{elm: elm, values: values, err: err}:
+			case <-p.done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// next returns the next decoded element, blocking until it is available or
+// the context is done. It returns the error that ended decoding, which is
+// io.EOF at the end of the data, only once.
+func (p *prefetcher) next() (*FullValue, []ReStream, error) {
+	select {
+	case r := <-p.ch:
+		return r.elm, r.values, r.err
+	case <-p.ctx.Done():
+		return nil, nil, p.ctx.Err()
+	}
+}
+
+// nextOr is like next, but calls onWake each time wake receives, and onIdle
+// each time no element becomes available for the idle duration, if positive.
+// An error of either is returned instead of the next element.
+func (p *prefetcher) nextOr(wake <-chan struct{}, onWake func() error, idle time.Duration, onIdle func() error) (*FullValue, []ReStream, error) {
+	var t *time.Timer
+	var timeout <-chan time.Time
+	if idle > 0 {
+		t = time.NewTimer(idle)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for {
+		select {
+		case r := <-p.ch:
+			return r.elm, r.values, r.err
+		case <-p.ctx.Done():
+			return nil, nil, p.ctx.Err()
+		case <-wake:
+			if err := onWake(); err != nil {
+				return nil, nil, err
+			}
+		case <-timeout:
+			if err := onIdle(); err != nil {
+				return nil, nil, err
+			}
+			t.Reset(idle)
+		}
+	}
+}
+
+// stop stops the goroutine, and waits for it to return. The stream is closed
+// first, since the goroutine may be blocked reading it, such as when the
+// bundle ends early on a split while the stream is still open. Readers that
+// don't return from a blocked read when closed are only waited for until the
+// context is done.
+func (p *prefetcher) stop() {
+	close(p.done)
+	p.stream.Close()
+	select {
+	case <-p.exit:
+	case <-p.ctx.Done():
+	}
+}
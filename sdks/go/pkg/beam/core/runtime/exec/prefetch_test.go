@@ -0,0 +1,244 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// encodeElements returns the encoding of the values in the global window.
+func encodeElements(t testing.TB, c *coder.Coder, vs ...interface{}) *bytes.Buffer {
+	var in bytes.Buffer
+	wc := MakeWindowEncoder(c.Window)
+	ec := MakeElementEncoder(coder.SkipW(c))
+	for _, v := range vs {
+		if err := EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &in); err != nil {
+			t.Fatalf("encoding header failed: %v", err)
+		}
+		if err := ec.Encode(&FullValue{Elm: v}, &in); err != nil {
+			t.Fatalf("encoding %v failed: %v", v, err)
+		}
+	}
+	return &in
+}
+
+// executeSplitOpenStream executes a plan in which the source of an estimated
+// 6 elements is split in half at its second element, once the 3 elements of
+// the primary have been sent on a stream that is left open, and verifies that
+// the bundle ends without waiting for more data.
+func executeSplitOpenStream(t *testing.T, ctx context.Context, source *DataSource) {
+	t.Helper()
+	out := &splitNode{CaptureNode: CaptureNode{UID: 1}, size: 6, at: 1, fraction: 0.5}
+	source.Out, out.source = out, source
+	p, err := NewPlan("a", []Unit{out, source})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		in := encodeElements(t, source.Coder, int64(1), int64(2), int64(3))
+		pw.Write(in.Bytes())
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: pr}})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("execute didn't return after the split with the stream open")
+	}
+	if out.err != nil {
+		t.Fatalf("SplitAtFraction failed: %v", out.err)
+	}
+	if want := makeValues(int64(1), int64(2), int64(3)); !equalList(out.Elements, want) {
+		t.Errorf("primary processed %v, want %v", extractValues(out.Elements...), extractValues(want...))
+	}
+}
+
+// TestDataSource_Prefetch verifies that prefetching sources deliver the same
+// elements, and honor splits and failures.
+func TestDataSource_Prefetch(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	elements := []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6)}
+	ctx := WithPrefetch(context.Background(), 2)
+
+	t.Run("all", func(t *testing.T) {
+		out := &CaptureNode{UID: 1}
+		source := &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c, Out: out}
+		counters := &StreamCounters{}
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		in := encodeElements(t, c, elements...)
+		if err := p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: ioutil.NopCloser(in)}, Counters: counters}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if want := makeValues(elements...); !equalList(out.Elements, want) {
+			t.Errorf("source delivered %v, want %v", extractValues(out.Elements...), extractValues(want...))
+		}
+		if got, want := counters.For(source.SID).Elements(), int64(len(elements)); got != want {
+			t.Errorf("element count = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		out := &splitNode{CaptureNode: CaptureNode{UID: 1}, size: 6, at: 1, fraction: 0.5}
+		source := &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c, Out: out}
+		out.source = source
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		in := encodeElements(t, c, elements...)
+		if err := p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: ioutil.NopCloser(in)}}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if out.err != nil {
+			t.Fatalf("SplitAtFraction failed: %v", out.err)
+		}
+		if want := makeValues(elements[:3]...); !equalList(out.Elements, want) {
+			t.Errorf("primary processed %v, want %v", extractValues(out.Elements...), extractValues(want...))
+		}
+	})
+
+	t.Run("splitOpenStream", func(t *testing.T) {
+		executeSplitOpenStream(t, ctx, &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c})
+	})
+
+	t.Run("decodeError", func(t *testing.T) {
+		out := &CaptureNode{UID: 1}
+		source := &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c, Out: out}
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		in := encodeElements(t, c, elements...)
+		// Truncate the last element.
+		in.Truncate(in.Len() - 1)
+		err = p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: ioutil.NopCloser(in)}})
+		if err == nil || !strings.Contains(err.Error(), "source decode failed") {
+			t.Fatalf("execute = %v, want decode failure", err)
+		}
+		if got, want := len(out.Elements), len(elements)-1; got != want {
+			t.Errorf("source delivered %v elements, want %v", got, want)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		out := &cancelNode{CaptureNode: CaptureNode{UID: 1}, after: 2, cancel: cancel}
+		source := &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c, Out: out}
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		// The stream isn't closed before the bundle ends, so the prefetching
+		// goroutine may be blocked reading it.
+		pr, pw := io.Pipe()
+		go func() {
+			io.Copy(pw, encodeElements(t, c, elements...))
+		}()
+		defer pw.Close()
+		err = p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: pr}})
+		if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Fatalf("execute with cancelled context = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+// slowString is a string whose coder hashes it at decoding, to make decoding
+// costly.
+type slowString string
+
+var slowStringType = reflect.TypeOf(slowString(""))
+
+func slowStringEncoder(s slowString) []byte {
+	return []byte(s)
+}
+
+func slowStringDecoder(b []byte) slowString {
+	burn(b)
+	return slowString(b)
+}
+
+// burn hashes b repeatedly to spend CPU time.
+func burn(b []byte) {
+	for i := 0; i < 200; i++ {
+		h := sha256.Sum256(b)
+		b = h[:]
+	}
+}
+
+// burnNode spends as much CPU time per element as decoding a slowString.
+type burnNode struct {
+	CaptureNode
+}
+
+func (n *burnNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	burn([]byte(elm.Elm.(slowString)))
+	return nil
+}
+
+func benchmarkDataSourcePrefetch(b *testing.B, depth int) {
+	cc, err := coder.NewCustomCoder("slow", slowStringType, slowStringEncoder, slowStringDecoder)
+	if err != nil {
+		b.Fatalf("NewCustomCoder failed: %v", err)
+	}
+	c := coder.NewW(&coder.Coder{Kind: coder.Custom, T: typex.New(slowStringType), Custom: cc}, coder.NewGlobalWindow())
+	var elms []interface{}
+	for i := 0; i < 1000; i++ {
+		elms = append(elms, slowString("element"))
+	}
+	data := encodeElements(b, c, elms...).Bytes()
+
+	out := &burnNode{CaptureNode: CaptureNode{UID: 1}}
+	source := &DataSource{UID: 2, SID: StreamID{PtransformID: "myPTransform"}, Coder: c, Out: out}
+	p, err := NewPlan("a", []Unit{out, source})
+	if err != nil {
+		b.Fatalf("failed to construct plan: %v", err)
+	}
+	ctx := WithPrefetch(context.Background(), depth)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: ioutil.NopCloser(bytes.NewReader(data))}}); err != nil {
+			b.Fatalf("execute failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkDataSource_noPrefetch(b *testing.B) { benchmarkDataSourcePrefetch(b, 0) }
+func BenchmarkDataSource_prefetch(b *testing.B)   { benchmarkDataSourcePrefetch(b, 16) }
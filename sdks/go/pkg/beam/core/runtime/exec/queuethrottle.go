@@ -0,0 +1,122 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// queueDepthBlocked is the time, in milliseconds, elements were blocked on a
+// full downstream queue.
+var queueDepthBlocked = metrics.NewCounter("exec", "queueDepthThrottle.blockedMsecs")
+
+// defaultQueueDepthPoll is the default interval at which a blocked
+// QueueDepthThrottle polls the queue depth.
+const defaultQueueDepthPoll = 10 * time.Millisecond
+
+// QueueDepthThrottle wraps a node and blocks elements while a user supplied
+// queue fed by the node is too deep. Once the depth reported by DepthFn exceeds
+// High, elements are blocked until it drops below Low, so that the queue drains
+// somewhat before upstream resumes. The time spent blocked is reported in the
+// queueDepthThrottle.blockedMsecs counter of the transform of the wrapped node,
+// if known.
+type QueueDepthThrottle struct {
+	Node
+	DepthFn   func() int
+	High, Low int
+	// PollInterval is the interval at which the depth is polled while
+	// blocked. If zero, it is 10ms.
+	PollInterval time.Duration
+
+	throttled bool
+	ctx       context.Context
+}
+
+// NewQueueDepthThrottle returns a node that blocks elements passed to out while
+// depthFn reports a depth above high, until it drops below low.
+func NewQueueDepthThrottle(out Node, depthFn func() int, high, low int) *QueueDepthThrottle {
+	return &QueueDepthThrottle{Node: out, DepthFn: depthFn, High: high, Low: low}
+}
+
+// Up validates the thresholds and brings up the wrapped node.
+func (n *QueueDepthThrottle) Up(ctx context.Context) error {
+	if n.DepthFn == nil {
+		return errors.Errorf("missing queue depth function for node %v", n.ID())
+	}
+	if n.Low < 1 || n.High < n.Low {
+		return errors.Errorf("invalid queue depth thresholds for node %v: high %v, low %v, want high >= low > 0", n.ID(), n.High, n.Low)
+	}
+	if n.PollInterval < 0 {
+		return errors.Errorf("invalid poll interval for node %v: %v, want >= 0", n.ID(), n.PollInterval)
+	}
+	return n.Node.Up(ctx)
+}
+
+// StartBundle attributes the blocked time to the transform of the wrapped
+// node, if known, and starts it.
+func (n *QueueDepthThrottle) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.ctx = ctx
+	if p, ok := n.Node.(hasPID); ok {
+		n.ctx = metrics.SetPTransformID(ctx, p.GetPID())
+	}
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement waits until the queue is shallow enough and forwards the
+// element to the wrapped node. It fails if the context is done while blocked.
+func (n *QueueDepthThrottle) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if err := n.wait(ctx); err != nil {
+		return err
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+// wait blocks while the node is throttled.
+func (n *QueueDepthThrottle) wait(ctx context.Context) error {
+	if !n.throttled && n.DepthFn() <= n.High {
+		return nil
+	}
+	n.throttled = true
+	poll := n.PollInterval
+	if poll == 0 {
+		poll = defaultQueueDepthPoll
+	}
+	start := time.Now()
+	defer func() {
+		queueDepthBlocked.Inc(n.ctx, int64(time.Since(start)/time.Millisecond))
+	}()
+
+	t := time.NewTicker(poll)
+	defer t.Stop()
+	for n.DepthFn() >= n.Low {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "blocked on queue depth at node %v", n.ID())
+		}
+	}
+	n.throttled = false
+	return nil
+}
+
+func (n *QueueDepthThrottle) String() string {
+	return fmt.Sprintf("QueueDepthThrottle[high %v, low %v]. Node:%v", n.High, n.Low, n.Node)
+}
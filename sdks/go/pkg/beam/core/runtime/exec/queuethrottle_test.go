@@ -0,0 +1,114 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// TestQueueDepthThrottle verifies that elements are blocked once the depth
+// exceeds the high threshold until it drops below the low one, and that the
+// blocked time is attributed to the transform of the wrapped node.
+func TestQueueDepthThrottle(t *testing.T) {
+	fn, err := graph.NewDoFn(func(n int) int { return n })
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	var depth int64
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 3, PID: "throttled", Fn: fn, Out: []Node{out}}
+	n := NewQueueDepthThrottle(pardo, func() int { return int(atomic.LoadInt64(&depth)) }, 3, 2)
+	n.PollInterval = time.Millisecond
+	in := &FixedRoot{UID: 2, Elements: makeInput(1, 2, 3), Out: n}
+
+	p, err := NewPlan("a", []Unit{in, n, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	atomic.StoreInt64(&depth, 4)
+	go func() {
+		// Draining to the high threshold doesn't resume; only below low does.
+		time.Sleep(10 * time.Millisecond)
+		atomic.StoreInt64(&depth, 2)
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt64(&depth, 1)
+	}()
+
+	start := time.Now()
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got, want := time.Since(start), 30*time.Millisecond; got < want {
+		t.Errorf("execute took %v, want at least %v", got, want)
+	}
+	if got, want := len(out.Elements), 3; got != want {
+		t.Errorf("throttle passed %v elements, want %v", got, want)
+	}
+
+	var blocked int64 = -1
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			if l.Transform() == "throttled" && l.Name() == "queueDepthThrottle.blockedMsecs" {
+				blocked = v
+			}
+		},
+	}.ExtractFrom(p.Store())
+	if blocked < 20 {
+		t.Errorf("blocked time counter = %v, want at least 20", blocked)
+	}
+}
+
+func TestQueueDepthThrottle_cancel(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	n := NewQueueDepthThrottle(out, func() int { return 10 }, 5, 1)
+	if err := n.Up(context.Background()); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := n.StartBundle(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := n.ProcessElement(ctx, &FullValue{Elm: 1})
+	if err == nil || !strings.Contains(err.Error(), "blocked on queue depth") {
+		t.Fatalf("ProcessElement error = %v, want blocked on queue depth", err)
+	}
+	if len(out.Elements) != 0 {
+		t.Errorf("throttle passed %v elements, want none", len(out.Elements))
+	}
+}
+
+func TestQueueDepthThrottle_invalid(t *testing.T) {
+	tests := []struct {
+		high, low int
+	}{
+		{high: 5, low: 0},
+		{high: 1, low: 2},
+	}
+	for _, test := range tests {
+		n := NewQueueDepthThrottle(&CaptureNode{UID: 1}, func() int { return 0 }, test.high, test.low)
+		if err := n.Up(context.Background()); err == nil {
+			t.Errorf("Up with high %v, low %v succeeded, want error", test.high, test.low)
+		}
+	}
+}
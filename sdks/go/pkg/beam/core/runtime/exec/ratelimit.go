@@ -0,0 +1,150 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// rateLimitWait is the time, in milliseconds, the last element waited for a
+// token.
+var rateLimitWait = metrics.NewGauge("exec", "rateLimit.waitMsecs")
+
+// RateLimiter is a token bucket that admits QPS operations per second on
+// average, and bursts of up to Burst operations. Tokens accumulate while the
+// limiter is idle, but never beyond Burst, so a limiter left idle between
+// bundles admits at most a burst before throttling again. It is safe for
+// concurrent use, and waiting callers are admitted in order of arrival.
+type RateLimiter struct {
+	qps   float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a full token bucket with the given rate and burst
+// size.
+func NewRateLimiter(qps float64, burst int) *RateLimiter {
+	return &RateLimiter{qps: qps, burst: burst, tokens: float64(burst)}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it. The token may be returned with cancel.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.qps
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.qps * float64(time.Second))
+}
+
+// cancel returns a reserved but unused token.
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// Wait blocks until a token is available or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	d := l.reserve()
+	if d == 0 {
+		return 0, nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return d, nil
+	case <-ctx.Done():
+		l.cancel()
+		return 0, ctx.Err()
+	}
+}
+
+// RateLimitOption configures a RateLimitNode.
+type RateLimitOption func(*RateLimitNode)
+
+// SharedRateLimiter makes the node draw tokens from the given limiter instead
+// of its own, so that parallel instances of a node share a single rate. The
+// rate and burst given to NewRateLimitNode are then ignored.
+func SharedRateLimiter(l *RateLimiter) RateLimitOption {
+	return func(n *RateLimitNode) {
+		n.Limiter = l
+	}
+}
+
+// RateLimitNode wraps a node and throttles the elements passed to it. Each
+// element blocks until a token is available from Limiter.
+type RateLimitNode struct {
+	Node
+	Limiter *RateLimiter
+}
+
+// NewRateLimitNode returns a node that passes at most qps elements per second
+// to out on average, with bursts of up to burst elements.
+func NewRateLimitNode(out Node, qps float64, burst int, opts ...RateLimitOption) *RateLimitNode {
+	n := &RateLimitNode{Node: out}
+	for _, opt := range opts {
+		opt(n)
+	}
+	if n.Limiter == nil {
+		n.Limiter = NewRateLimiter(qps, burst)
+	}
+	return n
+}
+
+// Up validates the limiter and brings up the wrapped node.
+func (n *RateLimitNode) Up(ctx context.Context) error {
+	if n.Limiter.qps <= 0 || n.Limiter.burst < 1 {
+		return errors.Errorf("invalid rate limit for node %v: %v qps, burst %v, want > 0", n.ID(), n.Limiter.qps, n.Limiter.burst)
+	}
+	return n.Node.Up(ctx)
+}
+
+// ProcessElement waits for a token and forwards the element to the wrapped
+// node. It fails if the context is done before a token is available.
+func (n *RateLimitNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	d, err := n.Limiter.Wait(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "waiting for rate limit at node %v", n.ID())
+	}
+	rateLimitWait.Set(ctx, int64(d/time.Millisecond))
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *RateLimitNode) String() string {
+	return fmt.Sprintf("RateLimitNode[%v qps, burst %v]. Node:%v", n.Limiter.qps, n.Limiter.burst, n.Node)
+}
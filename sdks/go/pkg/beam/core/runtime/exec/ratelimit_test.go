@@ -0,0 +1,92 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// TestRateLimitNode verifies that elements beyond the burst are throttled,
+// and that the wait time is recorded.
+func TestRateLimitNode(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	n := NewRateLimitNode(out, 100, 2)
+	in := &FixedRoot{UID: 2, Elements: makeInput(1, 2, 3, 4, 5), Out: n}
+
+	p, err := NewPlan("a", []Unit{in, n})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	ctx := metrics.SetPTransformID(context.Background(), "limited")
+	start := time.Now()
+	if err := p.Execute(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	// 2 elements pass immediately, 3 more take 10ms each.
+	if got, want := time.Since(start), 25*time.Millisecond; got < want {
+		t.Errorf("execute took %v, want at least %v", got, want)
+	}
+	if got, want := len(out.Elements), 5; got != want {
+		t.Errorf("rate limiter passed %v elements, want %v", got, want)
+	}
+
+	var wait int64 = -1
+	metrics.Extractor{
+		GaugeInt64: func(l metrics.Labels, v int64, _ time.Time) {
+			if l.Transform() == "limited" && l.Name() == "rateLimit.waitMsecs" {
+				wait = v
+			}
+		},
+	}.ExtractFrom(p.Store())
+	if wait < 0 {
+		t.Error("wait time gauge not set")
+	}
+}
+
+// TestRateLimitNode_shared verifies that nodes built with a shared limiter
+// draw from the same bucket.
+func TestRateLimitNode_shared(t *testing.T) {
+	l := NewRateLimiter(1, 2)
+	a := NewRateLimitNode(&CaptureNode{UID: 1}, 1000, 1000, SharedRateLimiter(l))
+	b := NewRateLimitNode(&CaptureNode{UID: 2}, 1000, 1000, SharedRateLimiter(l))
+	if a.Limiter != l || b.Limiter != l {
+		t.Fatal("nodes don't use the shared limiter")
+	}
+	ctx := context.Background()
+	for _, n := range []*RateLimitNode{a, b} {
+		if _, err := n.Limiter.Wait(ctx); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+
+	// The burst is used up, so the next element waits about a second.
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := a.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := a.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	if err := a.ProcessElement(ctx, &FullValue{Elm: 1}); err == nil || !strings.Contains(err.Error(), "rate limit") {
+		t.Errorf("ProcessElement with cancelled context = %v, want rate limit error", err)
+	}
+}
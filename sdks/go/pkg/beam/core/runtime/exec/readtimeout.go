@@ -0,0 +1,133 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WithReadTimeout returns a context in which DataSources fail the bundle with
+// a ReadTimeoutError if a single read from their data stream blocks for longer
+// than d. The deadline is reset for every read, so a slow but progressing
+// stream is not interrupted. Values less than or equal to 0 disable the
+// deadline, which is the default, so reads block until data arrives.
+func WithReadTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, readTimeoutKey, d)
+}
+
+func getReadTimeout(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(readTimeoutKey).(time.Duration)
+	return d
+}
+
+// ReadTimeoutError indicates that a read from a data stream exceeded the
+// deadline set with WithReadTimeout.
+type ReadTimeoutError struct {
+	SID      StreamID
+	Deadline time.Duration // The per-read deadline that was exceeded.
+}
+
+func (e *ReadTimeoutError) Error() string {
+	return fmt.Sprintf("read from %v timed out after %v", e.SID, e.Deadline)
+}
+
+// Timeout reports that the error is a timeout, as for net.Error.
+func (e *ReadTimeoutError) Timeout() bool {
+	return true
+}
+
+// readResult is the outcome of a read by a deadlineReader.
+type readResult struct {
+	n   int
+	err error
+}
+
+// deadlineReader bounds the duration of each read from the wrapped reader.
+// Reads are performed by a separate goroutine into a buffer owned by it for
+// the duration of the read, so a timed out read doesn't write to the
+// caller's buffer. Once a read times out, the reader remains failed, and the
+// blocked read is abandoned until the wrapped reader is closed.
+type deadlineReader struct {
+	io.ReadCloser
+	sid     StreamID
+	timeout time.Duration
+
+	timer *time.Timer
+	buf   []byte
+	reqs  chan []byte
+	res   chan readResult
+	err   error
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.reqs == nil {
+		r.reqs = make(chan []byte)
+		r.res = make(chan readResult, 1)
+		go r.readLoop()
+	}
+	if len(r.buf) < len(p) {
+		r.buf = make([]byte, len(p))
+	}
+	r.reqs <- r.buf[:len(p)]
+
+	if r.timer == nil {
+		r.timer = time.NewTimer(r.timeout)
+	} else {
+		r.timer.Reset(r.timeout)
+	}
+	select {
+	case res := <-r.res:
+		if !r.timer.Stop() {
+			// Drain a fired timer without blocking, so it can be reset.
+			select {
+			case <-r.timer.C:
+			default:
+			}
+		}
+		return copy(p, r.buf[:res.n]), res.err
+	case <-r.timer.C:
+		r.err = &ReadTimeoutError{SID: r.sid, Deadline: r.timeout}
+		return 0, r.err
+	}
+}
+
+// readLoop performs the requested reads until the reader is closed.
+func (r *deadlineReader) readLoop() {
+	for buf := range r.reqs {
+		n, err := r.ReadCloser.Read(buf)
+		r.res <- readResult{n: n, err: err}
+	}
+}
+
+// Close closes the wrapped reader, which unblocks any abandoned read, and
+// stops the read goroutine.
+func (r *deadlineReader) Close() error {
+	err := r.ReadCloser.Close()
+	if r.reqs != nil {
+		close(r.reqs)
+		r.reqs = nil
+	}
+	return err
+}
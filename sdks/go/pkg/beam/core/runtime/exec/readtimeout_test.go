@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// slowReader delays every read by a fixed duration.
+type slowReader struct {
+	io.Reader
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	return r.Reader.Read(p)
+}
+
+func (r *slowReader) Close() error {
+	return nil
+}
+
+// TestDataSource_ReadTimeout verifies that reads blocking longer than the
+// deadline fail the bundle with a ReadTimeoutError, while slow reads within
+// the deadline succeed.
+func TestDataSource_ReadTimeout(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	wc := MakeWindowEncoder(c.Window)
+	ec := MakeElementEncoder(coder.SkipW(c))
+	encode := func(w io.Writer, elms ...interface{}) {
+		for _, v := range elms {
+			EncodeWindowedValueHeader(wc, window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), w)
+			ec.Encode(&FullValue{Elm: v}, w)
+		}
+	}
+	sid := StreamID{PtransformID: "mySource"}
+	run := func(ctx context.Context, r io.ReadCloser) ([]FullValue, error) {
+		out := &CaptureNode{UID: 1}
+		source := &DataSource{UID: 2, SID: sid, Name: "timeout", Coder: c, Out: out}
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		err = p.Execute(ctx, "1", DataContext{Data: &TestDataManager{R: r}})
+		p.Down(ctx)
+		return out.Elements, err
+	}
+
+	t.Run("stalled", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		go encode(pw, int64(1))
+
+		ctx := WithReadTimeout(context.Background(), 50*time.Millisecond)
+		_, err := run(ctx, pr)
+		var timeout *ReadTimeoutError
+		if !errors.As(err, &timeout) {
+			t.Fatalf("execute = %v, want ReadTimeoutError", err)
+		}
+		if timeout.SID != sid || timeout.Deadline != 50*time.Millisecond {
+			t.Errorf("execute = %+v, want timeout for %v after 50ms", timeout, sid)
+		}
+	})
+	t.Run("slow", func(t *testing.T) {
+		var in bytes.Buffer
+		encode(&in, int64(1), int64(2), int64(3))
+		// The total read time exceeds the deadline, but individual reads don't.
+		r := &slowReader{Reader: &in, delay: 20 * time.Millisecond}
+
+		ctx := WithReadTimeout(context.Background(), 200*time.Millisecond)
+		got, err := run(ctx, r)
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if len(got) != 3 {
+			t.Errorf("got %v elements, want 3", len(got))
+		}
+	})
+}
@@ -0,0 +1,270 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/graphx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// The replay format starts with the magic string and the format version,
+// followed by the length prefixed pipepb.Components holding the windowed
+// value coder of the elements, and its ID. Each bundle is a begin record,
+// followed by a record of each element, encoded as a windowed value, and a
+// finish record. A recording of a failed bundle may lack the finish record.
+const (
+	replayMagic   = "beam:replay"
+	replayVersion = 1
+
+	replayBegin   byte = 'B'
+	replayElement byte = 'E'
+	replayFinish  byte = 'F'
+)
+
+// Recorder wraps a node and records the elements passed to it, by bundle, to W,
+// so that they can be replayed by a ReplaySource to reproduce a failure. The
+// recording is self-describing, so it can be replayed without the pipeline, but
+// custom coders must be registered as in the original binary. Elements are
+// recorded before they are processed, and GBK and CoGBK results are not
+// supported.
+type Recorder struct {
+	Node
+	// Coder is the windowed value coder of the elements. It must be set
+	// before the recorder is brought up.
+	Coder *coder.Coder
+	W     io.Writer
+
+	enc  ElementEncoder
+	wEnc WindowEncoder
+}
+
+// NewRecorder returns a node that records the elements passed to out to w,
+// once its Coder is set.
+func NewRecorder(out Node, w io.Writer) *Recorder {
+	return &Recorder{Node: out, W: w}
+}
+
+// Up writes the header of the recording and brings up the wrapped node.
+func (n *Recorder) Up(ctx context.Context) error {
+	if err := checkWindowedValueCoder(n.Coder); err != nil {
+		return errors.WithContextf(err, "recorder for node %v", n.ID())
+	}
+	n.enc = MakeElementEncoder(coder.SkipW(n.Coder))
+	n.wEnc = MakeWindowEncoder(n.Coder.Window)
+	if err := n.writeHeader(); err != nil {
+		return errors.WithContextf(err, "writing recording header of node %v", n.ID())
+	}
+	return n.Node.Up(ctx)
+}
+
+func (n *Recorder) writeHeader() error {
+	ids, coders, err := graphx.MarshalCoders([]*coder.Coder{n.Coder})
+	if err != nil {
+		return err
+	}
+	b, err := proto.Marshal(&pipepb.Components{Coders: coders})
+	if err != nil {
+		return err
+	}
+	if err := coder.EncodeStringUTF8(replayMagic, n.W); err != nil {
+		return err
+	}
+	if err := coder.EncodeVarInt(replayVersion, n.W); err != nil {
+		return err
+	}
+	if err := coder.EncodeBytes(b, n.W); err != nil {
+		return err
+	}
+	return coder.EncodeStringUTF8(ids[0], n.W)
+}
+
+// StartBundle records the start of a bundle and starts the wrapped node.
+func (n *Recorder) StartBundle(ctx context.Context, id string, data DataContext) error {
+	if err := coder.EncodeByte(replayBegin, n.W); err != nil {
+		return errors.WithContextf(err, "recording bundle of node %v", n.ID())
+	}
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement records the element and forwards it to the wrapped node.
+func (n *Recorder) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("recorder for node %v does not support GBK/CoGBK results", n.ID())
+	}
+	if err := n.record(elm); err != nil {
+		return errors.WithContextf(err, "recording element %v of node %v", elm, n.ID())
+	}
+	return n.Node.ProcessElement(ctx, elm)
+}
+
+func (n *Recorder) record(elm *FullValue) error {
+	if err := coder.EncodeByte(replayElement, n.W); err != nil {
+		return err
+	}
+	if err := EncodeWindowedValueHeader(n.wEnc, elm.Windows, elm.Timestamp, elm.Pane, n.W); err != nil {
+		return err
+	}
+	return n.enc.Encode(elm, n.W)
+}
+
+// FinishBundle records the end of the bundle and finishes the wrapped node.
+func (n *Recorder) FinishBundle(ctx context.Context) error {
+	if err := coder.EncodeByte(replayFinish, n.W); err != nil {
+		return errors.WithContextf(err, "recording bundle of node %v", n.ID())
+	}
+	return n.Node.FinishBundle(ctx)
+}
+
+func (n *Recorder) String() string {
+	return fmt.Sprintf("Recorder[%v]. Node:%v", n.Coder, n.Node)
+}
+
+// ReplaySource is a Root that replays a recording of a Recorder to Out,
+// one recorded bundle per bundle, with the elements in the recorded order.
+// Executing a bundle after the last recorded one fails.
+type ReplaySource struct {
+	UID UnitID
+	R   io.Reader
+	Out Node
+
+	r    *bufio.Reader
+	dec  ElementDecoder
+	wDec WindowDecoder
+}
+
+// NewReplaySource returns a root that replays the recording read from r to
+// out.
+func NewReplaySource(r io.Reader, out Node) *ReplaySource {
+	return &ReplaySource{R: r, Out: out}
+}
+
+func (n *ReplaySource) ID() UnitID {
+	return n.UID
+}
+
+// Up reads the header of the recording.
+func (n *ReplaySource) Up(ctx context.Context) error {
+	n.r = bufio.NewReader(n.R)
+	c, err := n.readHeader()
+	if err != nil {
+		return errors.WithContextf(err, "reading recording header of replay source %v", n.UID)
+	}
+	n.dec = MakeElementDecoder(coder.SkipW(c))
+	n.wDec = MakeWindowDecoder(c.Window)
+	return nil
+}
+
+func (n *ReplaySource) readHeader() (*coder.Coder, error) {
+	magic, err := coder.DecodeStringUTF8(n.r)
+	if err != nil {
+		return nil, err
+	}
+	if magic != replayMagic {
+		return nil, errors.Errorf("not a recording: %q", magic)
+	}
+	version, err := coder.DecodeVarInt(n.r)
+	if err != nil {
+		return nil, err
+	}
+	if version != replayVersion {
+		return nil, errors.Errorf("unsupported recording version %v, want %v", version, replayVersion)
+	}
+	b, err := coder.DecodeBytes(n.r)
+	if err != nil {
+		return nil, err
+	}
+	var comps pipepb.Components
+	if err := proto.Unmarshal(b, &comps); err != nil {
+		return nil, err
+	}
+	id, err := coder.DecodeStringUTF8(n.r)
+	if err != nil {
+		return nil, err
+	}
+	return graphx.NewCoderUnmarshaller(comps.GetCoders()).Coder(id)
+}
+
+func (n *ReplaySource) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return MultiStartBundle(ctx, id, data, n.Out)
+}
+
+// Process replays the elements of the next recorded bundle.
+func (n *ReplaySource) Process(ctx context.Context) error {
+	tag, err := coder.DecodeByte(n.r)
+	if err == io.EOF {
+		return errors.Errorf("replay source %v has no recorded bundle left", n.UID)
+	}
+	if err != nil {
+		return errors.WithContextf(err, "replay source %v", n.UID)
+	}
+	if tag != replayBegin {
+		return errors.Errorf("replay source %v read record %q, want bundle start", n.UID, tag)
+	}
+	for {
+		tag, err := coder.DecodeByte(n.r)
+		switch {
+		case err == io.EOF:
+			// The recorded bundle failed.
+			return nil
+		case err != nil:
+			return errors.WithContextf(err, "replay source %v", n.UID)
+		case tag == replayFinish:
+			return nil
+		case tag != replayElement:
+			return errors.Errorf("replay source %v read invalid record %q", n.UID, tag)
+		}
+		elm, err := n.readElement()
+		if err != nil {
+			return errors.WithContextf(err, "reading recorded element of replay source %v", n.UID)
+		}
+		if err := n.Out.ProcessElement(ctx, elm); err != nil {
+			return err
+		}
+	}
+}
+
+func (n *ReplaySource) readElement() (*FullValue, error) {
+	ws, t, pn, err := DecodeWindowedValueHeader(n.wDec, n.r)
+	if err != nil {
+		return nil, err
+	}
+	elm, err := n.dec.Decode(n.r)
+	if err != nil {
+		return nil, err
+	}
+	elm.Windows, elm.Timestamp, elm.Pane = ws, t, pn
+	return elm, nil
+}
+
+func (n *ReplaySource) FinishBundle(ctx context.Context) error {
+	return MultiFinishBundle(ctx, n.Out)
+}
+
+func (n *ReplaySource) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *ReplaySource) String() string {
+	return fmt.Sprintf("ReplaySource. Out:%v", n.Out.ID())
+}
@@ -0,0 +1,105 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestReplay verifies that a ReplaySource replays the bundles recorded by a
+// Recorder, with their timestamps, windows and panes, reading the coder from
+// the recording.
+func TestReplay(t *testing.T) {
+	c := coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()}), coder.NewIntervalWindow())
+	w := []typex.Window{window.IntervalWindow{Start: 0, End: 10}}
+	bundles := [][]FullValue{
+		{{Elm: "a", Elm2: int64(1), Timestamp: 1, Windows: w, Pane: latePane}, {Elm: "b", Elm2: int64(2), Timestamp: 2, Windows: w}},
+		{{Elm: "c", Elm2: int64(3), Timestamp: 3, Windows: w}},
+	}
+
+	var rec bytes.Buffer
+	recorded := &CaptureNode{UID: 1}
+	n := NewRecorder(recorded, &rec)
+	n.Coder = c
+	root := &FixedRoot{UID: 2, Out: n}
+	p, err := NewPlan("record", []Unit{root, n})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	for i, b := range bundles {
+		root.Elements = nil
+		for _, v := range b {
+			root.Elements = append(root.Elements, MainInput{Key: v})
+		}
+		if err := p.Execute(context.Background(), "record", DataContext{}); err != nil {
+			t.Fatalf("execute of bundle %v failed: %v", i, err)
+		}
+	}
+
+	// Elements without a pane are replayed in the NoFiringPane.
+	for _, b := range bundles {
+		for j := range b {
+			if b[j].Pane == (typex.PaneInfo{}) {
+				b[j].Pane = typex.NoFiringPane()
+			}
+		}
+	}
+	out := &CaptureNode{UID: 3}
+	source := NewReplaySource(&rec, out)
+	source.UID = 4
+	p, err = NewPlan("replay", []Unit{source, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	for i, want := range bundles {
+		out.Elements = nil
+		if err := p.Execute(context.Background(), "replay", DataContext{}); err != nil {
+			t.Fatalf("replay of bundle %v failed: %v", i, err)
+		}
+		if !equalList(out.Elements, want) {
+			t.Errorf("replay of bundle %v = %v, want %v", i, out.Elements, want)
+		}
+		for j, v := range out.Elements {
+			if v.Pane != want[j].Pane {
+				t.Errorf("pane of replayed element %v of bundle %v = %+v, want %+v", j, i, v.Pane, want[j].Pane)
+			}
+		}
+	}
+	err = p.Execute(context.Background(), "replay", DataContext{})
+	if err == nil || !strings.Contains(err.Error(), "no recorded bundle left") {
+		t.Errorf("replay after last bundle = %v, want error", err)
+	}
+}
+
+// TestRecorder_noCoder verifies that a recorder without a windowed value
+// coder fails to come up.
+func TestRecorder_noCoder(t *testing.T) {
+	var rec bytes.Buffer
+	n := NewRecorder(&CaptureNode{UID: 1}, &rec)
+	if err := n.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "not a windowed value coder") {
+		t.Errorf("Up without a coder = %v, want error", err)
+	}
+	if rec.Len() != 0 {
+		t.Errorf("Up without a coder wrote %v bytes, want none", rec.Len())
+	}
+}
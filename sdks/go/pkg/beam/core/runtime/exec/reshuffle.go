@@ -62,7 +62,7 @@ func (n *ReshuffleInput) StartBundle(ctx context.Context, id string, data DataCo
 
 func (n *ReshuffleInput) ProcessElement(ctx context.Context, value *FullValue, values ...ReStream) error {
 	n.b.Reset()
-	if err := EncodeWindowedValueHeader(n.wEnc, value.Windows, value.Timestamp, &n.b); err != nil {
+	if err := EncodeWindowedValueHeader(n.wEnc, value.Windows, value.Timestamp, value.Pane, &n.b); err != nil {
 		return err
 	}
 	if err := n.enc.Encode(value, &n.b); err != nil {
@@ -135,7 +135,7 @@ func (n *ReshuffleOutput) ProcessElement(ctx context.Context, value *FullValue,
 			return errors.WithContextf(err, "reading values for %v", n)
 		}
 		n.b = *bytes.NewBuffer(v.Elm.([]byte))
-		ws, ts, err := DecodeWindowedValueHeader(n.wDec, &n.b)
+		ws, ts, pn, err := DecodeWindowedValueHeader(n.wDec, &n.b)
 		if err != nil {
 			return errors.WithContextf(err, "decoding windows for %v", n)
 		}
@@ -144,6 +144,7 @@ func (n *ReshuffleOutput) ProcessElement(ctx context.Context, value *FullValue,
 		}
 		n.ret.Windows = ws
 		n.ret.Timestamp = ts
+		n.ret.Pane = pn
 		if err := n.Out.ProcessElement(ctx, &n.ret); err != nil {
 			return err
 		}
@@ -165,3 +166,123 @@ func (n *ReshuffleOutput) Down(ctx context.Context) error {
 func (n *ReshuffleOutput) String() string {
 	return fmt.Sprintf("ReshuffleOutput[%v] Coder:%v", n.SID, n.Coder)
 }
+
+// Reshuffle is a terminal Node that breaks fusion by buffering elements and
+// writing them to the data plane in chunks of ChunkSize elements. Each chunk
+// is written to the stream of the bundle as soon as it is complete, so that
+// the runner can redistribute chunks independently. The stream is opened
+// through the DataContext once per bundle, and closed at the end of it.
+//
+// Elements are written as windowed KV<int, V> values, where the key is a
+// random shard key shared by all elements of a chunk and V is the original
+// element; see ReshuffleChunkCoder. Timestamps and windows are preserved per
+// element, so the elements of a window may span several chunks. Downstream
+// consumers must therefore not assume that a chunk holds a whole window.
+type Reshuffle struct {
+	UID       UnitID
+	SID       StreamID
+	Coder     *coder.Coder // Coder for the input PCollection.
+	Seed      int64
+	ChunkSize int // Target number of elements per chunk.
+
+	r     *rand.Rand
+	enc   ElementEncoder
+	wEnc  WindowEncoder
+	w     io.WriteCloser
+	b     bytes.Buffer // Encoded elements of the pending chunk.
+	n     int          // Number of elements in the pending chunk.
+	shard int64
+}
+
+// NewReshuffle returns a Reshuffle node that writes chunks of chunkSize
+// elements encoded with the given windowed coder to the given stream.
+func NewReshuffle(uid UnitID, sid StreamID, c *coder.Coder, chunkSize int) *Reshuffle {
+	return &Reshuffle{UID: uid, SID: sid, Coder: c, Seed: rand.Int63(), ChunkSize: chunkSize}
+}
+
+// ReshuffleChunkCoder returns the windowed coder of the chunks written by a
+// Reshuffle node with the given windowed input coder.
+func ReshuffleChunkCoder(c *coder.Coder) *coder.Coder {
+	return coder.NewW(coder.NewKV([]*coder.Coder{coder.NewVarInt(), coder.SkipW(c)}), c.Window)
+}
+
+// ID returns the unit debug id.
+func (n *Reshuffle) ID() UnitID {
+	return n.UID
+}
+
+// Up initializes the chunk encoders, and the random source.
+func (n *Reshuffle) Up(ctx context.Context) error {
+	if n.ChunkSize < 1 {
+		return errors.Errorf("invalid chunk size for reshuffle %v: %v, want > 0", n.UID, n.ChunkSize)
+	}
+	n.enc = MakeElementEncoder(coder.SkipW(ReshuffleChunkCoder(n.Coder)))
+	n.wEnc = MakeWindowEncoder(n.Coder.Window)
+	n.r = rand.New(rand.NewSource(n.Seed))
+	return nil
+}
+
+// StartBundle opens the stream of the bundle for writing chunks.
+func (n *Reshuffle) StartBundle(ctx context.Context, id string, data DataContext) error {
+	w, err := data.Data.OpenWrite(ctx, n.SID)
+	if err != nil {
+		return err
+	}
+	n.w = w
+	n.b.Reset()
+	n.n = 0
+	return nil
+}
+
+// ProcessElement adds the element to the pending chunk, and writes the chunk
+// once it is full.
+func (n *Reshuffle) ProcessElement(ctx context.Context, value *FullValue, values ...ReStream) error {
+	if n.n == 0 {
+		n.shard = n.r.Int63()
+	}
+	if err := EncodeWindowedValueHeader(n.wEnc, value.Windows, value.Timestamp, value.Pane, &n.b); err != nil {
+		return err
+	}
+	kv := &FullValue{Elm: n.shard, Elm2: value}
+	if err := n.enc.Encode(kv, &n.b); err != nil {
+		return errors.WithContextf(err, "encoding element %v with coder %v", value, n.Coder)
+	}
+	n.n++
+	if n.n >= n.ChunkSize {
+		return n.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the pending chunk to the stream.
+func (n *Reshuffle) flush(ctx context.Context) error {
+	if n.n == 0 {
+		return nil
+	}
+	if _, err := n.w.Write(n.b.Bytes()); err != nil {
+		return errors.WithContextf(err, "writing chunk of %v elements for %v", n.n, n)
+	}
+	n.b.Reset()
+	n.n = 0
+	return nil
+}
+
+// FinishBundle writes any pending partial chunk, and closes the stream.
+func (n *Reshuffle) FinishBundle(ctx context.Context) error {
+	err := n.flush(ctx)
+	if err2 := n.w.Close(); err == nil {
+		err = err2
+	}
+	n.b = bytes.Buffer{}
+	n.w = nil
+	return err
+}
+
+// Down is a no-op.
+func (n *Reshuffle) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *Reshuffle) String() string {
+	return fmt.Sprintf("Reshuffle[%v, chunk:%v] Coder:%v", n.SID, n.ChunkSize, n.Coder)
+}
@@ -0,0 +1,131 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// chunkDataManager is a DataManager that records each write to its streams
+// separately.
+type chunkDataManager struct {
+	chunks        [][]byte
+	opens, closes int
+}
+
+func (dm *chunkDataManager) OpenRead(ctx context.Context, id StreamID) (io.ReadCloser, error) {
+	return nil, io.EOF
+}
+
+func (dm *chunkDataManager) OpenWrite(ctx context.Context, id StreamID) (io.WriteCloser, error) {
+	dm.opens++
+	return dm, nil
+}
+
+func (dm *chunkDataManager) Write(p []byte) (int, error) {
+	dm.chunks = append(dm.chunks, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (dm *chunkDataManager) Close() error {
+	dm.closes++
+	return nil
+}
+
+// TestReshuffle verifies that elements are written in chunks of the target
+// size, with one shard key per chunk, preserving timestamps and windows, to a
+// single stream per bundle.
+func TestReshuffle(t *testing.T) {
+	wc := coder.NewW(coder.NewVarInt(), coder.NewIntervalWindow())
+	w1 := window.IntervalWindow{Start: 0, End: 10}
+	w2 := window.IntervalWindow{Start: 10, End: 20}
+
+	var in []FullValue
+	for i := 0; i < 5; i++ {
+		w := w1
+		if i == 4 {
+			w = w2
+		}
+		in = append(in, FullValue{Elm: int64(i), Timestamp: mtime.Time(i), Windows: []typex.Window{w}})
+	}
+
+	n := NewReshuffle(1, StreamID{PtransformID: "reshuffle"}, wc, 2)
+	dm := &chunkDataManager{}
+	ctx := context.Background()
+	if err := n.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := n.StartBundle(ctx, "1", DataContext{Data: dm}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	for i := range in {
+		if err := n.ProcessElement(ctx, &in[i]); err != nil {
+			t.Fatalf("ProcessElement failed: %v", err)
+		}
+	}
+	if got, want := len(dm.chunks), 2; got != want {
+		t.Errorf("chunks written before FinishBundle = %v, want %v", got, want)
+	}
+	if dm.closes != 0 {
+		t.Errorf("stream closed %v times before FinishBundle, want 0", dm.closes)
+	}
+	if err := n.FinishBundle(ctx); err != nil {
+		t.Fatalf("FinishBundle failed: %v", err)
+	}
+	if got, want := len(dm.chunks), 3; got != want {
+		t.Fatalf("chunks written = %v, want %v", got, want)
+	}
+	if dm.opens != 1 || dm.closes != 1 {
+		t.Errorf("stream opened %v and closed %v times, want once each", dm.opens, dm.closes)
+	}
+
+	cc := ReshuffleChunkCoder(wc)
+	dec := MakeElementDecoder(coder.SkipW(cc))
+	wDec := MakeWindowDecoder(cc.Window)
+	var got []FullValue
+	for i, chunk := range dm.chunks {
+		r := bytes.NewReader(chunk)
+		var shards []interface{}
+		for r.Len() > 0 {
+			ws, ts, _, err := DecodeWindowedValueHeader(wDec, r)
+			if err != nil {
+				t.Fatalf("chunk %v: decoding header failed: %v", i, err)
+			}
+			kv, err := dec.Decode(r)
+			if err != nil {
+				t.Fatalf("chunk %v: decoding element failed: %v", i, err)
+			}
+			shards = append(shards, kv.Elm)
+			got = append(got, FullValue{Elm: kv.Elm2, Timestamp: ts, Windows: ws})
+		}
+		for _, s := range shards {
+			if s != shards[0] {
+				t.Errorf("chunk %v has shard keys %v, want a single key", i, shards)
+			}
+		}
+	}
+	if !equalList(got, in) {
+		t.Errorf("Reshuffle wrote %v, want %v", got, in)
+	}
+}
@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// URNs of the standard resource hints, as in StandardResourceHints in
+// beam_runner_api.proto.
+const (
+	URNAcceleratorHint = "beam:resources:accelerator:v1"
+	URNMinRAMBytesHint = "beam:resources:min_ram_bytes:v1"
+)
+
+// ResourceHints are the resource hints of a transform, read from its
+// environment in the plan, as encoded values keyed by URN.
+type ResourceHints map[string][]byte
+
+// Accelerator returns the accelerator hint, if any.
+func (h ResourceHints) Accelerator() (string, bool) {
+	v, ok := h[URNAcceleratorHint]
+	return string(v), ok
+}
+
+// MinRAMBytes returns the minimum RAM hint in bytes, if any. The hint is
+// encoded as a decimal string.
+func (h ResourceHints) MinRAMBytes() (int64, bool) {
+	v, ok := h[URNMinRAMBytesHint]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// ResourceHinted is implemented by nodes that carry the resource hints of
+// their transform.
+type ResourceHinted interface {
+	ResourceHints() ResourceHints
+}
+
+// ResourceHints returns the combined resource hints of the units of the plan,
+// for the harness to request resources for the bundles. The largest minimum
+// RAM hint applies; for other hints, transforms are assumed to agree.
+func (p *Plan) ResourceHints() ResourceHints {
+	ret := ResourceHints{}
+	for _, u := range p.units {
+		h, ok := resourceHintsOf(u)
+		if !ok {
+			continue
+		}
+		for urn, v := range h {
+			if urn == URNMinRAMBytesHint {
+				n, _ := h.MinRAMBytes()
+				if cur, ok := ret.MinRAMBytes(); ok && cur >= n {
+					continue
+				}
+			}
+			ret[urn] = v
+		}
+	}
+	return ret
+}
+
+// resourceHintsOf returns the resource hints of the unit, looking through
+// wrappers that embed the node they stand in for, such as a RetryNode.
+func resourceHintsOf(u Unit) (ResourceHints, bool) {
+	if r, ok := u.(ResourceHinted); ok {
+		return r.ResourceHints(), true
+	}
+	if n := embeddedNode(u); n != nil {
+		return resourceHintsOf(n)
+	}
+	return nil, false
+}
+
+// embeddedNode returns the node embedded in the given wrapper, if any.
+func embeddedNode(u Unit) Node {
+	v := reflect.ValueOf(u)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Type().Field(i); f.Anonymous && f.Type == nodeType {
+			n, _ := v.Field(i).Interface().(Node)
+			return n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+
+	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+// TestResourceHints verifies that the resource hints of the environment of a
+// transform are read from the plan, and combined for the plan.
+func TestResourceHints(t *testing.T) {
+	b := &builder{desc: &fnpb.ProcessBundleDescriptor{
+		Environments: map[string]*pipepb.Environment{
+			"big": {ResourceHints: map[string][]byte{
+				URNMinRAMBytesHint: []byte("4000000000"),
+				URNAcceleratorHint: []byte("type:nvidia-tesla-k80;count:1"),
+			}},
+			"small": {ResourceHints: map[string][]byte{URNMinRAMBytesHint: []byte("1000")}},
+			"none":  {},
+		},
+	}}
+	tests := []struct {
+		env         string
+		ram         int64
+		accelerator string
+	}{
+		{env: "big", ram: 4000000000, accelerator: "type:nvidia-tesla-k80;count:1"},
+		{env: "small", ram: 1000},
+		{env: "none"},
+		{env: "unknown"},
+	}
+	for _, test := range tests {
+		h := b.resourceHints(&pipepb.PTransform{EnvironmentId: test.env})
+		ram, ok := h.MinRAMBytes()
+		if ram != test.ram || ok != (test.ram != 0) {
+			t.Errorf("MinRAMBytes() for %v = %v, %v, want %v", test.env, ram, ok, test.ram)
+		}
+		accelerator, ok := h.Accelerator()
+		if accelerator != test.accelerator || ok != (test.accelerator != "") {
+			t.Errorf("Accelerator() for %v = %q, %v, want %q", test.env, accelerator, ok, test.accelerator)
+		}
+	}
+
+	small := &ParDo{UID: 1, Hints: b.resourceHints(&pipepb.PTransform{EnvironmentId: "small"})}
+	big := &Combine{UID: 2, Hints: b.resourceHints(&pipepb.PTransform{EnvironmentId: "big"})}
+	// Wrappers stand in for the nodes they wrap in the plan.
+	retry := NewRetryNode(NewDeadLetterNode(big, &Discard{UID: 4}, func(error) bool { return true }), RetryPolicy{MaxAttempts: 2})
+	root := &FixedRoot{UID: 3, Out: small}
+	p, err := NewPlan("a", []Unit{root, small, retry})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if got, want := p.ResourceHints(), big.Hints; !reflect.DeepEqual(got, want) {
+		t.Errorf("plan ResourceHints() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// RetryPolicy describes how failed elements are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per element, including the
+	// first one. Values less than 1 are treated as 1.
+	MaxAttempts int
+	// Backoff returns the delay before the given retry attempt, starting at 1.
+	// If nil, retries are immediate.
+	Backoff func(attempt int) time.Duration
+	// Retryable decides whether an error is worth retrying. If nil, only
+	// errors categorized as Transient are retried. Aborted bundles are never
+	// retried per element.
+	Retryable func(err error) bool
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if _, aborted := asBundleAborted(err); aborted {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return Category(err) == Transient
+}
+
+// RetryNode wraps a node and replays elements that fail with a retryable error.
+// The wrapped node must tolerate an element being processed more than once.
+type RetryNode struct {
+	Node
+	Policy RetryPolicy
+}
+
+// NewRetryNode returns a node that retries failed elements passed to out
+// according to the given policy.
+func NewRetryNode(out Node, policy RetryPolicy) *RetryNode {
+	return &RetryNode{Node: out, Policy: policy}
+}
+
+// Up brings up the wrapped node. A wrapped ParDo remains usable after elements
+// failing with a retryable error.
+func (n *RetryNode) Up(ctx context.Context) error {
+	recoverOn(n.Node, n.Policy.retryable)
+	return n.Node.Up(ctx)
+}
+
+// ProcessElement forwards the element to the wrapped node, retrying it on
+// retryable errors.
+func (n *RetryNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	attempt := 1
+	for {
+		err := n.Node.ProcessElement(ctx, elm, values...)
+		if err == nil {
+			return nil
+		}
+		if attempt >= n.Policy.MaxAttempts || !n.Policy.retryable(err) {
+			return errors.Wrapf(err, "element failed after %v attempt(s) at node %v", attempt, n.ID())
+		}
+		if n.Policy.Backoff != nil {
+			select {
+			case <-time.After(n.Policy.Backoff(attempt)):
+			case <-ctx.Done():
+				return errors.Wrapf(err, "retry of element cancelled after %v attempt(s) at node %v", attempt, n.ID())
+			}
+		}
+		attempt++
+	}
+}
+
+func (n *RetryNode) String() string {
+	return fmt.Sprintf("RetryNode[%v]. Node:%v", n.Policy.MaxAttempts, n.Node)
+}
@@ -0,0 +1,144 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// flakyNode is a test Node that fails the first failures calls.
+type flakyNode struct {
+	ErrorNode
+	failures int
+}
+
+func (n *flakyNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if err := n.ErrorNode.ProcessElement(ctx, elm, values...); n.Calls <= n.failures {
+		return err
+	}
+	return nil
+}
+
+// TestRetryNode verifies that retryable errors are retried up to the maximum
+// number of attempts, and that others fail immediately.
+func TestRetryNode(t *testing.T) {
+	ctx := context.Background()
+	transient := TransientError(errors.New("flaky"))
+	tests := []struct {
+		name      string
+		err       error
+		failures  int
+		policy    RetryPolicy
+		wantCalls int
+		wantErr   string
+	}{
+		{
+			name:      "recovers",
+			err:       transient,
+			failures:  2,
+			policy:    RetryPolicy{MaxAttempts: 3, Backoff: func(int) time.Duration { return time.Millisecond }},
+			wantCalls: 3,
+		},
+		{
+			name:      "exhausted",
+			err:       transient,
+			failures:  5,
+			policy:    RetryPolicy{MaxAttempts: 3},
+			wantCalls: 3,
+			wantErr:   "after 3 attempt(s)",
+		},
+		{
+			name:      "notRetryable",
+			err:       errors.New("bad input"),
+			failures:  5,
+			policy:    RetryPolicy{MaxAttempts: 3},
+			wantCalls: 1,
+			wantErr:   "after 1 attempt(s)",
+		},
+		{
+			name:     "predicate",
+			err:      errors.New("bad input"),
+			failures: 1,
+			policy: RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool {
+				return strings.Contains(err.Error(), "bad input")
+			}},
+			wantCalls: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &flakyNode{ErrorNode: ErrorNode{UID: 1, Err: test.err}, failures: test.failures}
+			n := NewRetryNode(out, test.policy)
+			err := n.ProcessElement(ctx, &FullValue{Elm: 1})
+			if test.wantErr == "" && err != nil {
+				t.Errorf("ProcessElement failed: %v", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Errorf("ProcessElement = %v, want error containing %q", err, test.wantErr)
+			}
+			if out.Calls != test.wantCalls {
+				t.Errorf("wrapped node called %v times, want %v", out.Calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+// flakyFn is a DoFn that fails with a transient error on every other call.
+type flakyFn struct {
+	calls int
+}
+
+func (fn *flakyFn) ProcessElement(v int, emit func(int)) error {
+	fn.calls++
+	if fn.calls%2 == 1 {
+		return TransientError(errors.New("flaky"))
+	}
+	emit(v)
+	return nil
+}
+
+// TestRetryNode_parDo verifies that a ParDo failing with a retryable error
+// remains usable for retrying the element.
+func TestRetryNode_parDo(t *testing.T) {
+	fn := &flakyFn{}
+	dfn, err := graph.NewDoFn(fn, graph.NumMainInputs(graph.MainSingle))
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	n := NewRetryNode(&ParDo{UID: 2, Fn: dfn, Out: []Node{out}}, RetryPolicy{MaxAttempts: 2})
+	root := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: n}
+
+	p, err := NewPlan("a", []Unit{root, n, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(1, 2, 3); !equalList(out.Elements, want) {
+		t.Errorf("ParDo emitted %v, want %v", extractValues(out.Elements...), extractValues(want...))
+	}
+	if got, want := fn.calls, 6; got != want {
+		t.Errorf("DoFn called %v times, want %v", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// WithCoderRoundTripCheck returns a context in which DataSources and
+// DataSinks verify that every element survives a decode/encode round trip
+// through its coder unchanged, failing the bundle otherwise. It is intended
+// for debugging asymmetric custom coders and adds significant overhead.
+func WithCoderRoundTripCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, roundTripCheckKey, true)
+}
+
+func isCoderRoundTripCheck(ctx context.Context) bool {
+	v, _ := ctx.Value(roundTripCheckKey).(bool)
+	return v
+}
+
+// roundTripper re-encodes windowed values for comparison with their original
+// encoding.
+type roundTripper struct {
+	enc  ElementEncoder
+	wEnc WindowEncoder
+	dec  ElementDecoder
+	wDec WindowDecoder
+}
+
+// encode encodes the given windowed value.
+func (rt *roundTripper) encode(v *FullValue) ([]byte, error) {
+	var b bytes.Buffer
+	if err := EncodeWindowedValueHeader(rt.wEnc, v.Windows, v.Timestamp, v.Pane, &b); err != nil {
+		return nil, err
+	}
+	if err := rt.enc.Encode(v, &b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// check verifies that the decoded value v re-encodes to its original bytes.
+func (rt *roundTripper) check(v *FullValue, original []byte) error {
+	got, err := rt.encode(v)
+	if err != nil {
+		return errors.Wrapf(err, "coder round trip failed to re-encode %v", v)
+	}
+	return compareEncodings(v.Windows, v, original, got)
+}
+
+// checkEncoded verifies that the encoded value decodes and re-encodes to the
+// same bytes.
+func (rt *roundTripper) checkEncoded(original []byte) error {
+	r := bytes.NewReader(original)
+	ws, t, pn, err := DecodeWindowedValueHeader(rt.wDec, r)
+	if err != nil {
+		return errors.Wrap(err, "coder round trip failed to decode windowed value header")
+	}
+	v, err := rt.dec.Decode(r)
+	if err != nil {
+		return errors.Wrap(err, "coder round trip failed to decode element")
+	}
+	v.Windows = ws
+	v.Timestamp = t
+	v.Pane = pn
+	return rt.check(v, original)
+}
+
+// checkingDecoder verifies that each value decoded through the recording
+// reader re-encodes to its original bytes. It is used for the unwindowed
+// values of GBKs and CoGBKs. Values read from other readers, such as state
+// backed iterables, are not checked.
+type checkingDecoder struct {
+	ElementDecoder
+	enc ElementEncoder
+	rec *recordingReader
+}
+
+func (d *checkingDecoder) Decode(r io.Reader) (*FullValue, error) {
+	v := &FullValue{}
+	if err := d.DecodeTo(r, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (d *checkingDecoder) DecodeTo(r io.Reader, v *FullValue) error {
+	if r != io.Reader(d.rec) {
+		return d.ElementDecoder.DecodeTo(r, v)
+	}
+	d.rec.reset()
+	if err := d.ElementDecoder.DecodeTo(r, v); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := d.enc.Encode(v, &b); err != nil {
+		return errors.Wrapf(err, "coder round trip failed to re-encode value %v", v)
+	}
+	return compareEncodings(nil, v, d.rec.recorded(), b.Bytes())
+}
+
+// compareEncodings returns a descriptive error if the two encodings of v
+// differ.
+func compareEncodings(ws []typex.Window, v *FullValue, want, got []byte) error {
+	if bytes.Equal(want, got) {
+		return nil
+	}
+	i := 0
+	for i < len(want) && i < len(got) && want[i] == got[i] {
+		i++
+	}
+	return errors.Errorf("coder round trip mismatch for element %v in windows %v at byte %v:\noriginal:\n%vre-encoded:\n%v",
+		v, ws, i, hex.Dump(want[i:]), hex.Dump(got[i:]))
+}
+
+// recordingReader retains all bytes read through it since the last reset.
+type recordingReader struct {
+	io.ReadCloser
+	buf bytes.Buffer
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+func (r *recordingReader) reset() {
+	r.buf.Reset()
+}
+
+func (r *recordingReader) recorded() []byte {
+	return r.buf.Bytes()
+}
@@ -0,0 +1,166 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// RoutingStrategy decides which outputs of a Router receive each element.
+// Strategies are used by a single Router, and not concurrently.
+type RoutingStrategy interface {
+	// Route appends the indices of the outputs, of n, that receive the element
+	// to dst and returns the result. Indices must be distinct and in [0, n).
+	Route(elm *FullValue, n int, dst []int) ([]int, error)
+}
+
+// Router is a fan-out node that passes each element to the outputs chosen by
+// Strategy. Unlike elements, StartBundle and FinishBundle are passed to all
+// outputs.
+type Router struct {
+	// UID is the unit identifier.
+	UID      UnitID
+	Strategy RoutingStrategy
+	// Out is a list of output nodes.
+	Out []Node
+
+	route  []int
+	chosen []Node
+}
+
+// NewRouter returns a node that passes elements to the outputs chosen by the
+// given strategy. Its UID must be set for it to take part in a plan.
+func NewRouter(strategy RoutingStrategy, outputs ...Node) *Router {
+	return &Router{Strategy: strategy, Out: outputs}
+}
+
+func (r *Router) ID() UnitID {
+	return r.UID
+}
+
+func (r *Router) Up(ctx context.Context) error {
+	return nil
+}
+
+func (r *Router) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return MultiStartBundle(ctx, id, data, r.Out...)
+}
+
+// ProcessElement passes the element to the outputs chosen by the strategy.
+func (r *Router) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	route, err := r.Strategy.Route(elm, len(r.Out), r.route[:0])
+	if err != nil {
+		return errors.WithContextf(err, "routing element %v at router %v", elm, r.UID)
+	}
+	r.route = route
+	r.chosen = r.chosen[:0]
+	for _, i := range route {
+		if i < 0 || i >= len(r.Out) {
+			return errors.Errorf("invalid output %v for element %v at router %v with %v outputs", i, elm, r.UID, len(r.Out))
+		}
+		r.chosen = append(r.chosen, r.Out[i])
+	}
+	return MultiProcessElementValues(ctx, elm, values, r.chosen...)
+}
+
+func (r *Router) FinishBundle(ctx context.Context) error {
+	return MultiFinishBundle(ctx, r.Out...)
+}
+
+func (r *Router) Down(ctx context.Context) error {
+	return nil
+}
+
+func (r *Router) String() string {
+	return fmt.Sprintf("Router[%v]. Out:%v", r.Strategy, IDs(r.Out...))
+}
+
+// Broadcast returns a strategy that routes each element to all outputs.
+func Broadcast() RoutingStrategy {
+	return broadcast{}
+}
+
+type broadcast struct{}
+
+func (broadcast) Route(elm *FullValue, n int, dst []int) ([]int, error) {
+	for i := 0; i < n; i++ {
+		dst = append(dst, i)
+	}
+	return dst, nil
+}
+
+func (broadcast) String() string {
+	return "Broadcast"
+}
+
+// RoundRobin returns a strategy that routes each element to a single output,
+// cycling through the outputs in order. The cycle continues across bundles.
+func RoundRobin() RoutingStrategy {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next int
+}
+
+func (s *roundRobin) Route(elm *FullValue, n int, dst []int) ([]int, error) {
+	if n == 0 {
+		return dst, nil
+	}
+	i := s.next % n
+	s.next = i + 1
+	return append(dst, i), nil
+}
+
+func (s *roundRobin) String() string {
+	return "RoundRobin"
+}
+
+// HashByKey returns a strategy that routes each KV element to a single output
+// chosen by the hash of its key, encoded with the given coder, so that all
+// elements of a key reach the same output. Keys are hashed regardless of
+// their windows.
+func HashByKey(keyCoder *coder.Coder) RoutingStrategy {
+	return &hashByKey{keyCoder: keyCoder}
+}
+
+type hashByKey struct {
+	keyCoder *coder.Coder
+	hasher   elementHasher
+}
+
+func (s *hashByKey) Route(elm *FullValue, n int, dst []int) ([]int, error) {
+	if n == 0 {
+		return dst, nil
+	}
+	if s.hasher == nil {
+		s.hasher = makeElementHasher(s.keyCoder, coder.NewGlobalWindow())
+	}
+	h, err := s.hasher.Hash(elm.Elm, window.GlobalWindow{})
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, int(h%uint64(n))), nil
+}
+
+func (s *hashByKey) String() string {
+	return fmt.Sprintf("HashByKey[%v]", s.keyCoder)
+}
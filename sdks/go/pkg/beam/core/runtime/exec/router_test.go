@@ -0,0 +1,125 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+)
+
+// TestRouter verifies that the built-in strategies route elements to the
+// expected outputs, and that all outputs are started and finished.
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy RoutingStrategy
+		in       []MainInput
+		check    func(t *testing.T, outs []*CaptureNode)
+	}{
+		{
+			name:     "broadcast",
+			strategy: Broadcast(),
+			in:       makeInput(1, 2, 3),
+			check: func(t *testing.T, outs []*CaptureNode) {
+				for i, out := range outs {
+					if !equalList(out.Elements, makeValues(1, 2, 3)) {
+						t.Errorf("output %v got %v, want all elements", i, extractValues(out.Elements...))
+					}
+				}
+			},
+		},
+		{
+			name:     "round robin",
+			strategy: RoundRobin(),
+			in:       makeInput(1, 2, 3, 4, 5, 6, 7),
+			check: func(t *testing.T, outs []*CaptureNode) {
+				want := [][]interface{}{{1, 4, 7}, {2, 5}, {3, 6}}
+				for i, out := range outs {
+					if !equalList(out.Elements, makeValues(want[i]...)) {
+						t.Errorf("output %v got %v, want %v", i, extractValues(out.Elements...), want[i])
+					}
+				}
+			},
+		},
+		{
+			name:     "hash by key",
+			strategy: HashByKey(coder.NewString()),
+			in:       kvInput("a", 1, "b", 2, "a", 3, "c", 4, "b", 5, "a", 6),
+			check: func(t *testing.T, outs []*CaptureNode) {
+				seen := make(map[interface{}]int)
+				total := 0
+				for i, out := range outs {
+					for _, v := range out.Elements {
+						if j, ok := seen[v.Elm]; ok && j != i {
+							t.Errorf("key %v routed to outputs %v and %v, want one", v.Elm, j, i)
+						}
+						seen[v.Elm] = i
+						total++
+					}
+				}
+				if total != 6 {
+					t.Errorf("routed %v elements, want 6", total)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outs := []*CaptureNode{{UID: 1}, {UID: 2}, {UID: 3}}
+			router := NewRouter(test.strategy, outs[0], outs[1], outs[2])
+			router.UID = 4
+			root := &FixedRoot{UID: 5, Elements: test.in, Out: router}
+			p, err := NewPlan("a", []Unit{root, router, outs[0], outs[1], outs[2]})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			test.check(t, outs)
+			if err := p.Down(context.Background()); err != nil {
+				t.Fatalf("down failed: %v", err)
+			}
+		})
+	}
+}
+
+// badStrategy routes each element to an output out of range.
+type badStrategy struct{}
+
+func (badStrategy) Route(elm *FullValue, n int, dst []int) ([]int, error) {
+	return append(dst, n), nil
+}
+
+// TestRouter_invalidOutput verifies that routing to a nonexistent output
+// fails the bundle.
+func TestRouter_invalidOutput(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	router := NewRouter(badStrategy{}, out)
+	router.UID = 2
+	root := &FixedRoot{UID: 3, Elements: makeInput(1), Out: router}
+	p, err := NewPlan("a", []Unit{root, router, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	err = p.Execute(context.Background(), "1", DataContext{})
+	if err == nil || !strings.Contains(err.Error(), "invalid output 1") {
+		t.Errorf("execute = %v, want invalid output error", err)
+	}
+}
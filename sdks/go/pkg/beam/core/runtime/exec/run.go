@@ -0,0 +1,114 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// RunElement executes a bundle of the plan with the single element as its
+// input, and returns the elements written to its output, with their
+// timestamps, windows and panes. It is intended for testing plan fragments,
+// such as custom nodes, without a harness. The plan must read from a single
+// DataSource and write to a single DataSink, which are used with in-memory
+// data streams. An element without windows is in the global window. The
+// plan is brought up if needed, but not taken down, so it can be used for
+// further elements.
+func RunElement(ctx context.Context, plan *Plan, elm *FullValue) ([]*FullValue, error) {
+	source := plan.source
+	if source == nil || len(plan.roots) != 1 {
+		return nil, errors.Errorf("plan %v does not have a single DataSource root", plan.id)
+	}
+	var sink *DataSink
+	for _, u := range plan.units {
+		if s, ok := u.(*DataSink); ok {
+			if sink != nil {
+				return nil, errors.Errorf("plan %v has multiple DataSinks: %v and %v", plan.id, sink.UID, s.UID)
+			}
+			sink = s
+		}
+	}
+	if sink == nil {
+		return nil, errors.Errorf("plan %v has no DataSink", plan.id)
+	}
+
+	var in bytes.Buffer
+	ws := elm.Windows
+	if len(ws) == 0 {
+		ws = window.SingleGlobalWindow
+	}
+	if err := EncodeWindowedValueHeader(MakeWindowEncoder(source.Coder.Window), ws, elm.Timestamp, elm.Pane, &in); err != nil {
+		return nil, errors.WithContextf(err, "encoding element %v for plan %v", elm, plan.id)
+	}
+	if err := MakeElementEncoder(coder.SkipW(source.Coder)).Encode(elm, &in); err != nil {
+		return nil, errors.WithContextf(err, "encoding element %v for plan %v", elm, plan.id)
+	}
+
+	data := &elementDataManager{in: &in}
+	if err := plan.Execute(ctx, "RunElement", DataContext{Data: data}); err != nil {
+		return nil, err
+	}
+
+	wd := MakeWindowDecoder(sink.Coder.Window)
+	ed := MakeElementDecoder(coder.SkipW(sink.Coder))
+	var ret []*FullValue
+	for {
+		ws, t, pn, err := DecodeWindowedValueHeader(wd, &data.out)
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return nil, errors.WithContextf(err, "decoding output of plan %v", plan.id)
+		}
+		v, err := ed.Decode(&data.out)
+		if err != nil {
+			return nil, errors.WithContextf(err, "decoding output of plan %v", plan.id)
+		}
+		v.Windows, v.Timestamp, v.Pane = ws, t, pn
+		ret = append(ret, v)
+	}
+}
+
+// elementDataManager is a DataManager serving the input of RunElement from
+// memory, and collecting its output.
+type elementDataManager struct {
+	in  *bytes.Buffer
+	out bytes.Buffer
+}
+
+func (m *elementDataManager) OpenRead(ctx context.Context, id StreamID) (io.ReadCloser, error) {
+	return ioutil.NopCloser(m.in), nil
+}
+
+func (m *elementDataManager) OpenWrite(ctx context.Context, id StreamID) (io.WriteCloser, error) {
+	return bufferCloser{&m.out}, nil
+}
+
+// bufferCloser is a buffer with a no-op Close.
+type bufferCloser struct {
+	*bytes.Buffer
+}
+
+func (bufferCloser) Close() error {
+	return nil
+}
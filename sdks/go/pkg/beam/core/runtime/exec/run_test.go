@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestRunElement verifies that RunElement passes single elements through a
+// plan and returns its output with timestamps, windows and panes, and that
+// it rejects plans without a single source and sink.
+func TestRunElement(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewIntervalWindow())
+	sink := &DataSink{UID: 1, Coder: c}
+	n := NewInterceptNode(sink, func(elm *FullValue) (*FullValue, error) {
+		ret := *elm
+		ret.Elm = elm.Elm.(int64) * 10
+		ret.Timestamp = elm.Timestamp.Add(time.Second)
+		return &ret, nil
+	})
+	source := &DataSource{UID: 2, Coder: c, Out: n}
+	p, err := NewPlan("a", []Unit{sink, n, source})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	defer p.Down(context.Background())
+
+	ws := []typex.Window{window.IntervalWindow{Start: 0, End: mtime.FromDuration(time.Minute)}}
+	for i := int64(1); i <= 2; i++ {
+		elm := &FullValue{Elm: i, Timestamp: mtime.FromMilliseconds(i), Windows: ws, Pane: latePane}
+		got, err := RunElement(context.Background(), p, elm)
+		if err != nil {
+			t.Fatalf("RunElement(%v) failed: %v", i, err)
+		}
+		want := []FullValue{{Elm: i * 10, Timestamp: elm.Timestamp.Add(time.Second), Windows: ws}}
+		if len(got) != 1 || !equal(*got[0], want[0]) {
+			t.Errorf("RunElement(%v) = %v, want %v", i, got, want)
+		} else if got[0].Pane != latePane {
+			t.Errorf("RunElement(%v) pane = %+v, want %+v", i, got[0].Pane, latePane)
+		}
+	}
+
+	t.Run("noSink", func(t *testing.T) {
+		out := &CaptureNode{UID: 1}
+		source := &DataSource{UID: 2, Coder: c, Out: out}
+		p, err := NewPlan("a", []Unit{out, source})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		_, err = RunElement(context.Background(), p, &FullValue{Elm: int64(1), Windows: ws})
+		if err == nil || !strings.Contains(err.Error(), "no DataSink") {
+			t.Errorf("RunElement = %v, want missing DataSink error", err)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// CompatPolicy is the compatibility required of the schema of an element with
+// the schema it is read with.
+type CompatPolicy int
+
+const (
+	// CompatBackward requires that the reader schema can read elements of the
+	// writer schema: fields the reader adds must be nullable.
+	CompatBackward CompatPolicy = iota
+	// CompatForward requires that the writer schema can read elements of the
+	// reader schema: fields the reader drops must be nullable.
+	CompatForward
+	// CompatFull requires compatibility in both directions.
+	CompatFull
+)
+
+func (p CompatPolicy) String() string {
+	switch p {
+	case CompatBackward:
+		return "BACKWARD"
+	case CompatForward:
+		return "FORWARD"
+	case CompatFull:
+		return "FULL"
+	default:
+		return fmt.Sprintf("CompatPolicy(%d)", int(p))
+	}
+}
+
+// VersionedRow is a row tagged with the ID of the schema it was written
+// with, with its values keyed by field name.
+type VersionedRow struct {
+	SchemaID string
+	Fields   map[string]interface{}
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]*pipepb.Schema)
+)
+
+// RegisterSchema registers a schema of VersionedRows by its ID, so that
+// SchemaCompatChecks can read rows written with it. It is safe for concurrent
+// use.
+func RegisterSchema(s *pipepb.Schema) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	schemas[s.GetId()] = s
+}
+
+func lookupSchema(id string) *pipepb.Schema {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	return schemas[id]
+}
+
+// SchemaCompatError indicates that an element was written with a schema that
+// isn't compatible with the reader schema of a SchemaCompatCheck.
+type SchemaCompatError struct {
+	UID      UnitID
+	ReaderID string
+	WriterID string
+	Policy   CompatPolicy
+	// Field is the offending field, if any.
+	Field  string
+	Reason string
+}
+
+func (e *SchemaCompatError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("schema %v is not %v compatible with reader schema %v at node %v: %v", e.WriterID, e.Policy, e.ReaderID, e.UID, e.Reason)
+	}
+	return fmt.Sprintf("schema %v is not %v compatible with reader schema %v at node %v: field %v %v", e.WriterID, e.Policy, e.ReaderID, e.UID, e.Field, e.Reason)
+}
+
+// SchemaCompatCheck wraps a node and adapts the VersionedRows passed to it to
+// Schema, if the schema they were written with is compatible with it under
+// Policy. Writer schemas must be registered with RegisterSchema. Fields that
+// Schema doesn't have are dropped, and nullable fields that the writer schema
+// doesn't have are added with nil values. Apart from nullability, common fields
+// must have identical types. Rows of an unknown or incompatible schema fail the
+// bundle with a SchemaCompatError.
+type SchemaCompatCheck struct {
+	Node
+	Schema *pipepb.Schema
+	Policy CompatPolicy
+
+	// checked holds the result of the compatibility check of each writer
+	// schema.
+	checked map[string]error
+}
+
+// NewSchemaCompatCheck returns a node that adapts rows to readerSchema under
+// the given policy before passing them to out.
+func NewSchemaCompatCheck(out Node, readerSchema *pipepb.Schema, policy CompatPolicy) *SchemaCompatCheck {
+	return &SchemaCompatCheck{Node: out, Schema: readerSchema, Policy: policy}
+}
+
+// Up validates the policy and brings up the wrapped node.
+func (n *SchemaCompatCheck) Up(ctx context.Context) error {
+	if n.Policy < CompatBackward || n.Policy > CompatFull {
+		return errors.Errorf("invalid compatibility policy for node %v: %v", n.ID(), n.Policy)
+	}
+	if n.Schema.GetId() == "" {
+		return errors.Errorf("missing reader schema ID for node %v", n.ID())
+	}
+	n.checked = make(map[string]error)
+	return n.Node.Up(ctx)
+}
+
+// ProcessElement adapts the row to the reader schema and forwards it to the
+// wrapped node.
+func (n *SchemaCompatCheck) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	row, ok := elm.Elm.(*VersionedRow)
+	if !ok {
+		return errors.Errorf("invalid element %v at node %v: got %T, want *exec.VersionedRow", elm, n.ID(), elm.Elm)
+	}
+	if row.SchemaID == n.Schema.GetId() {
+		return n.Node.ProcessElement(ctx, elm, values...)
+	}
+	err, ok := n.checked[row.SchemaID]
+	if !ok {
+		err = n.check(row.SchemaID)
+		n.checked[row.SchemaID] = err
+	}
+	if err != nil {
+		return err
+	}
+
+	adapted := &VersionedRow{SchemaID: n.Schema.GetId(), Fields: make(map[string]interface{}, len(n.Schema.GetFields()))}
+	for _, f := range n.Schema.GetFields() {
+		v, ok := row.Fields[f.GetName()]
+		if !ok && !f.GetType().GetNullable() {
+			return n.error(row.SchemaID, f.GetName(), "has no value and is not nullable")
+		}
+		adapted.Fields[f.GetName()] = v
+	}
+	out := *elm
+	out.Elm = adapted
+	return n.Node.ProcessElement(ctx, &out, values...)
+}
+
+// check verifies that the writer schema with the given ID is compatible with
+// the reader schema under the policy.
+func (n *SchemaCompatCheck) check(writerID string) error {
+	w := lookupSchema(writerID)
+	if w == nil {
+		return n.error(writerID, "", "unknown writer schema")
+	}
+	if n.Policy == CompatBackward || n.Policy == CompatFull {
+		if field, reason := canRead(n.Schema, w); reason != "" {
+			return n.error(writerID, field, reason)
+		}
+	}
+	if n.Policy == CompatForward || n.Policy == CompatFull {
+		if field, reason := canRead(w, n.Schema); reason != "" {
+			return n.error(writerID, field, reason+", when reading rows of the reader schema with the writer schema")
+		}
+	}
+	return nil
+}
+
+func (n *SchemaCompatCheck) error(writerID, field, reason string) error {
+	return &SchemaCompatError{UID: n.ID(), ReaderID: n.Schema.GetId(), WriterID: writerID, Policy: n.Policy, Field: field, Reason: reason}
+}
+
+func (n *SchemaCompatCheck) String() string {
+	return fmt.Sprintf("SchemaCompatCheck[%v, %v]. Node:%v", n.Schema.GetId(), n.Policy, n.Node)
+}
+
+// canRead returns the offending field and the reason why rows written with
+// schema w can't be read with schema r, if they can't.
+func canRead(r, w *pipepb.Schema) (string, string) {
+	written := make(map[string]*pipepb.Field)
+	for _, f := range w.GetFields() {
+		written[f.GetName()] = f
+	}
+	for _, f := range r.GetFields() {
+		wf, ok := written[f.GetName()]
+		switch {
+		case !ok:
+			if !f.GetType().GetNullable() {
+				return f.GetName(), "is missing and not nullable"
+			}
+		case wf.GetType().GetNullable() && !f.GetType().GetNullable():
+			return f.GetName(), "is nullable, but not when read"
+		case !equalIgnoringNullable(f.GetType(), wf.GetType()):
+			return f.GetName(), fmt.Sprintf("has type %v, but is read as %v", wf.GetType(), f.GetType())
+		}
+	}
+	return "", ""
+}
+
+// equalIgnoringNullable returns whether the field types are equal, apart
+// from their nullability.
+func equalIgnoringNullable(a, b *pipepb.FieldType) bool {
+	a, b = proto.Clone(a).(*pipepb.FieldType), proto.Clone(b).(*pipepb.FieldType)
+	a.Nullable, b.Nullable = false, false
+	return proto.Equal(a, b)
+}
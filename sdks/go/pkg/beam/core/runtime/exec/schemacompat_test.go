@@ -0,0 +1,146 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+func init() {
+	for _, s := range []*pipepb.Schema{
+		{Id: "v1", Fields: []*pipepb.Field{
+			atomicField("name", pipepb.AtomicType_STRING, false),
+			atomicField("age", pipepb.AtomicType_INT64, false),
+		}},
+		// v3 drops age.
+		{Id: "v3", Fields: []*pipepb.Field{
+			atomicField("name", pipepb.AtomicType_STRING, false),
+			atomicField("email", pipepb.AtomicType_STRING, true),
+		}},
+		// v4 changes the type of name.
+		{Id: "v4", Fields: []*pipepb.Field{
+			atomicField("name", pipepb.AtomicType_INT64, false),
+			atomicField("age", pipepb.AtomicType_INT64, false),
+		}},
+		// v5 adds a non-nullable city.
+		{Id: "v5", Fields: []*pipepb.Field{
+			atomicField("name", pipepb.AtomicType_STRING, false),
+			atomicField("age", pipepb.AtomicType_INT64, false),
+			atomicField("email", pipepb.AtomicType_STRING, true),
+			atomicField("city", pipepb.AtomicType_STRING, false),
+		}},
+	} {
+		RegisterSchema(s)
+	}
+}
+
+// readerV2 adds a nullable email to v1.
+var readerV2 = &pipepb.Schema{Id: "v2", Fields: []*pipepb.Field{
+	atomicField("name", pipepb.AtomicType_STRING, false),
+	atomicField("age", pipepb.AtomicType_INT64, false),
+	atomicField("email", pipepb.AtomicType_STRING, true),
+}}
+
+// TestSchemaCompatCheck verifies that rows of compatible schemas are adapted
+// to the reader schema, and that others fail with the offending field.
+func TestSchemaCompatCheck(t *testing.T) {
+	v1 := &VersionedRow{SchemaID: "v1", Fields: map[string]interface{}{"name": "ann", "age": int64(3)}}
+	v2 := &VersionedRow{SchemaID: "v2", Fields: map[string]interface{}{"name": "bob", "age": int64(4), "email": "b@x"}}
+	v3 := &VersionedRow{SchemaID: "v3", Fields: map[string]interface{}{"name": "cy"}}
+	v4 := &VersionedRow{SchemaID: "v4", Fields: map[string]interface{}{"name": int64(1), "age": int64(5)}}
+	v5 := &VersionedRow{SchemaID: "v5", Fields: map[string]interface{}{"name": "dee", "age": int64(6), "email": "d@x", "city": "ams"}}
+	unknown := &VersionedRow{SchemaID: "v9"}
+
+	tests := []struct {
+		name      string
+		policy    CompatPolicy
+		row       *VersionedRow
+		want      map[string]interface{}
+		wantField string
+	}{
+		{name: "same", policy: CompatFull, row: v2, want: v2.Fields},
+		{name: "backwardAdded", policy: CompatBackward, row: v1, want: map[string]interface{}{"name": "ann", "age": int64(3), "email": nil}},
+		{name: "backwardDropped", policy: CompatBackward, row: v5, want: map[string]interface{}{"name": "dee", "age": int64(6), "email": "d@x"}},
+		{name: "backwardMissing", policy: CompatBackward, row: v3, wantField: "age"},
+		{name: "backwardType", policy: CompatBackward, row: v4, wantField: "name"},
+		{name: "forwardAdded", policy: CompatForward, row: v1, want: map[string]interface{}{"name": "ann", "age": int64(3), "email": nil}},
+		{name: "forwardDropped", policy: CompatForward, row: v5, wantField: "city"},
+		{name: "forwardMissing", policy: CompatForward, row: v3, wantField: "age"},
+		{name: "fullAdded", policy: CompatFull, row: v1, want: map[string]interface{}{"name": "ann", "age": int64(3), "email": nil}},
+		{name: "fullDropped", policy: CompatFull, row: v5, wantField: "city"},
+		{name: "unknown", policy: CompatFull, row: unknown, wantField: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			out := &CaptureNode{UID: 1}
+			n := NewSchemaCompatCheck(out, readerV2, test.policy)
+			if err := n.Up(ctx); err != nil {
+				t.Fatalf("Up failed: %v", err)
+			}
+			if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+				t.Fatalf("StartBundle failed: %v", err)
+			}
+			err := n.ProcessElement(ctx, &FullValue{Elm: test.row})
+			if test.want == nil {
+				var ce *SchemaCompatError
+				if !errors.As(err, &ce) {
+					t.Fatalf("ProcessElement(%v) = %v, want SchemaCompatError", test.row, err)
+				}
+				if ce.Field != test.wantField || ce.WriterID != test.row.SchemaID || ce.ReaderID != "v2" || ce.Policy != test.policy {
+					t.Errorf("ProcessElement(%v) = %+v, want error on field %q", test.row, ce, test.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessElement(%v) failed: %v", test.row, err)
+			}
+			got := out.Elements[0].Elm.(*VersionedRow)
+			if got.SchemaID != "v2" || !reflect.DeepEqual(got.Fields, test.want) {
+				t.Errorf("ProcessElement(%v) emitted %v, want %v", test.row, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSchemaCompatCheck_invalid(t *testing.T) {
+	ctx := context.Background()
+	n := NewSchemaCompatCheck(&CaptureNode{UID: 1}, readerV2, CompatPolicy(7))
+	if err := n.Up(ctx); err == nil {
+		t.Error("Up with invalid policy succeeded, want error")
+	}
+	n = NewSchemaCompatCheck(&CaptureNode{UID: 1}, &pipepb.Schema{}, CompatFull)
+	if err := n.Up(ctx); err == nil {
+		t.Error("Up without reader schema ID succeeded, want error")
+	}
+
+	out := &CaptureNode{UID: 1}
+	n = NewSchemaCompatCheck(out, readerV2, CompatFull)
+	if err := n.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	if err := n.ProcessElement(ctx, &FullValue{Elm: "not a row"}); err == nil {
+		t.Error("ProcessElement with non-row element succeeded, want error")
+	}
+}
@@ -0,0 +1,227 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+// WithSchemaValidation returns a context in which SchemaValidators check the
+// elements passed through them. Without it, they forward elements unchecked.
+func WithSchemaValidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, schemaValidationKey, true)
+}
+
+func isSchemaValidation(ctx context.Context) bool {
+	v, _ := ctx.Value(schemaValidationKey).(bool)
+	return v
+}
+
+// SchemaValidator wraps a node and verifies that the elements passed to it are
+// rows conforming to Schema, if enabled with WithSchemaValidation. Rows are Go
+// structs, or pointers to them, with fields matched to the schema by name,
+// honoring beam field tags. A mismatching element fails the bundle with the
+// path of the offending field.
+type SchemaValidator struct {
+	Node
+	Schema *pipepb.Schema
+
+	enabled bool
+}
+
+// NewSchemaValidator returns a node that validates elements against the given
+// schema before passing them to out.
+func NewSchemaValidator(out Node, schema *pipepb.Schema) *SchemaValidator {
+	return &SchemaValidator{Node: out, Schema: schema}
+}
+
+// StartBundle records whether validation is enabled for the bundle and starts
+// the wrapped node.
+func (n *SchemaValidator) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.enabled = isSchemaValidation(ctx)
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement validates the element and forwards it to the wrapped node.
+func (n *SchemaValidator) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.enabled {
+		if err := validateRow("", reflect.ValueOf(elm.Elm), n.Schema); err != nil {
+			return errors.Wrapf(err, "invalid element %v at node %v", elm, n.ID())
+		}
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *SchemaValidator) String() string {
+	return fmt.Sprintf("SchemaValidator[%v fields]. Node:%v", len(n.Schema.GetFields()), n.Node)
+}
+
+// atomicKinds holds the Go kinds accepted for each atomic schema type. BYTES
+// is handled separately, as it is a slice.
+var atomicKinds = map[pipepb.AtomicType][]reflect.Kind{
+	pipepb.AtomicType_BYTE:    {reflect.Uint8},
+	pipepb.AtomicType_INT16:   {reflect.Int16},
+	pipepb.AtomicType_INT32:   {reflect.Int32},
+	pipepb.AtomicType_INT64:   {reflect.Int64, reflect.Int},
+	pipepb.AtomicType_FLOAT:   {reflect.Float32},
+	pipepb.AtomicType_DOUBLE:  {reflect.Float64},
+	pipepb.AtomicType_STRING:  {reflect.String},
+	pipepb.AtomicType_BOOLEAN: {reflect.Bool},
+}
+
+// fieldPath returns the path of the named field of the row at path.
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// validateRow verifies that v is a non-nil row with all fields of s.
+func validateRow(path string, v reflect.Value, s *pipepb.Schema) error {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return errors.Errorf("nil row at %v", rowPath(path))
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return errors.Errorf("row at %v has type %v, want struct", rowPath(path), typeOf(v))
+	}
+	fields := make(map[string]reflect.Value)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue // Unexported fields aren't part of the schema.
+		}
+		name := sf.Name
+		if tag := sf.Tag.Get("beam"); tag != "" {
+			name = beamTagName(tag)
+		}
+		fields[name] = v.Field(i)
+	}
+	for _, f := range s.GetFields() {
+		p := fieldPath(path, f.GetName())
+		fv, ok := fields[f.GetName()]
+		if !ok {
+			return errors.Errorf("missing field %v of type %v", p, typeOf(v))
+		}
+		if err := validateField(p, fv, f.GetType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateField verifies that v is a valid value of the field type ft.
+func validateField(path string, v reflect.Value, ft *pipepb.FieldType) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if ft.GetNullable() {
+				return nil
+			}
+			return errors.Errorf("nil value for non-nullable field %v", path)
+		}
+		v = v.Elem()
+	}
+
+	switch ti := ft.GetTypeInfo().(type) {
+	case *pipepb.FieldType_AtomicType:
+		if ti.AtomicType == pipepb.AtomicType_BYTES {
+			if v.Type() != reflectx.ByteSlice {
+				return errors.Errorf("field %v has type %v, want []byte", path, v.Type())
+			}
+			return nil
+		}
+		for _, k := range atomicKinds[ti.AtomicType] {
+			if v.Kind() == k {
+				return nil
+			}
+		}
+		return errors.Errorf("field %v has type %v, want %v", path, v.Type(), ti.AtomicType)
+	case *pipepb.FieldType_ArrayType:
+		return validateElements(path, v, ti.ArrayType.GetElementType())
+	case *pipepb.FieldType_IterableType:
+		return validateElements(path, v, ti.IterableType.GetElementType())
+	case *pipepb.FieldType_MapType:
+		if v.Kind() != reflect.Map {
+			return errors.Errorf("field %v has type %v, want map", path, v.Type())
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			p := fmt.Sprintf("%v[%v]", path, iter.Key())
+			if err := validateField(p+" (key)", iter.Key(), ti.MapType.GetKeyType()); err != nil {
+				return err
+			}
+			if err := validateField(p, iter.Value(), ti.MapType.GetValueType()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *pipepb.FieldType_RowType:
+		return validateRow(path, v, ti.RowType.GetSchema())
+	case *pipepb.FieldType_LogicalType:
+		// Logical types are represented by arbitrary Go types, which are
+		// converted by their providers at encoding.
+		return nil
+	default:
+		return errors.Errorf("field %v has unsupported schema type %v", path, ft)
+	}
+}
+
+// validateElements verifies that v is a slice or array of values of the
+// field type et.
+func validateElements(path string, v reflect.Value, et *pipepb.FieldType) error {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return errors.Errorf("field %v has type %v, want slice", path, v.Type())
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := validateField(fmt.Sprintf("%v[%d]", path, i), v.Index(i), et); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beamTagName returns the field name of a beam struct tag.
+func beamTagName(tag string) string {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+func rowPath(path string) string {
+	if path == "" {
+		return "top level"
+	}
+	return path
+}
+
+func typeOf(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return "<nil>"
+	}
+	return v.Type()
+}
@@ -0,0 +1,146 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pipepb "github.com/apache/beam/sdks/go/pkg/beam/model/pipeline_v1"
+)
+
+type address struct {
+	City string
+	Zip  *int32 `beam:"zip_code"`
+}
+
+type person struct {
+	Name    string
+	Age     int64
+	Home    *address
+	Work    address
+	Tags    []string
+	Scores  map[string]float64
+	private int
+}
+
+func atomicField(name string, t pipepb.AtomicType, nullable bool) *pipepb.Field {
+	return &pipepb.Field{Name: name, Type: &pipepb.FieldType{
+		Nullable: nullable,
+		TypeInfo: &pipepb.FieldType_AtomicType{AtomicType: t},
+	}}
+}
+
+func rowField(name string, s *pipepb.Schema, nullable bool) *pipepb.Field {
+	return &pipepb.Field{Name: name, Type: &pipepb.FieldType{
+		Nullable: nullable,
+		TypeInfo: &pipepb.FieldType_RowType{RowType: &pipepb.RowType{Schema: s}},
+	}}
+}
+
+var (
+	stringType = &pipepb.FieldType{TypeInfo: &pipepb.FieldType_AtomicType{AtomicType: pipepb.AtomicType_STRING}}
+	doubleType = &pipepb.FieldType{TypeInfo: &pipepb.FieldType_AtomicType{AtomicType: pipepb.AtomicType_DOUBLE}}
+
+	addressSchema = &pipepb.Schema{Fields: []*pipepb.Field{
+		atomicField("City", pipepb.AtomicType_STRING, false),
+		atomicField("zip_code", pipepb.AtomicType_INT32, true),
+	}}
+	personSchema = &pipepb.Schema{Fields: []*pipepb.Field{
+		atomicField("Name", pipepb.AtomicType_STRING, false),
+		atomicField("Age", pipepb.AtomicType_INT64, false),
+		rowField("Home", addressSchema, true),
+		rowField("Work", addressSchema, false),
+		{Name: "Tags", Type: &pipepb.FieldType{TypeInfo: &pipepb.FieldType_ArrayType{
+			ArrayType: &pipepb.ArrayType{ElementType: stringType},
+		}}},
+		{Name: "Scores", Type: &pipepb.FieldType{TypeInfo: &pipepb.FieldType_MapType{
+			MapType: &pipepb.MapType{KeyType: stringType, ValueType: doubleType},
+		}}},
+	}}
+)
+
+// TestSchemaValidator verifies that elements are checked against the schema
+// with precise field paths, and only if validation is enabled.
+func TestSchemaValidator(t *testing.T) {
+	zip := int32(12345)
+	tests := []struct {
+		name string
+		elm  interface{}
+		err  string
+	}{
+		{name: "valid", elm: person{Name: "a", Home: &address{City: "b", Zip: &zip}, Tags: []string{"x"}}},
+		{name: "pointer", elm: &person{Name: "a", Work: address{City: "c"}}},
+		{name: "null row", elm: person{Home: nil}},
+		{name: "not a row", elm: 5, err: "row at top level has type int, want struct"},
+		{name: "nil row", elm: (*person)(nil), err: "nil row at top level"},
+		{name: "missing field", elm: address{}, err: "missing field Name"},
+		{name: "wrong type", elm: struct {
+			Name int
+		}{}, err: "field Name has type int, want STRING"},
+		{name: "nested", elm: struct {
+			Name, Work string
+			Age        int64
+			Home       *struct{ City int }
+		}{Home: &struct{ City int }{}}, err: "field Home.City has type int"},
+		{name: "non-nullable nested", elm: struct {
+			Name string
+			Age  int64
+			Home *address
+			Work *address
+		}{}, err: "nil value for non-nullable field Work"},
+		{name: "array element", elm: struct {
+			Name string
+			Age  int64
+			Home *address
+			Work address
+			Tags []interface{}
+		}{Tags: []interface{}{"x", 2}}, err: "field Tags[1] has type int"},
+		{name: "map value", elm: person{Scores: map[string]float64{"k": 1}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewSchemaValidator(out, personSchema)
+			ctx := WithSchemaValidation(context.Background())
+			if err := n.Up(ctx); err != nil {
+				t.Fatalf("Up failed: %v", err)
+			}
+			if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+				t.Fatalf("StartBundle failed: %v", err)
+			}
+			err := n.ProcessElement(ctx, &FullValue{Elm: test.elm})
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("ProcessElement(%v) failed: %v", test.elm, err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("ProcessElement(%v) = %v, want error containing %q", test.elm, err, test.err)
+			}
+			if err := n.FinishBundle(ctx); err != nil {
+				t.Fatalf("FinishBundle failed: %v", err)
+			}
+
+			// Without validation, all elements are forwarded unchecked.
+			if err := n.StartBundle(context.Background(), "2", DataContext{}); err != nil {
+				t.Fatalf("StartBundle failed: %v", err)
+			}
+			if err := n.ProcessElement(context.Background(), &FullValue{Elm: test.elm}); err != nil {
+				t.Errorf("ProcessElement(%v) without validation failed: %v", test.elm, err)
+			}
+		})
+	}
+}
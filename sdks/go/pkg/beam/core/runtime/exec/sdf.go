@@ -22,6 +22,7 @@ import (
 	"path"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/sdf"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
@@ -56,7 +57,7 @@ func (n *PairWithRestriction) Up(_ context.Context) error {
 
 // StartBundle currently does nothing.
 func (n *PairWithRestriction) StartBundle(ctx context.Context, id string, data DataContext) error {
-	return n.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, n.Out)
 }
 
 // ProcessElement expects elm to be the main input to the ParDo. See
@@ -78,7 +79,7 @@ func (n *PairWithRestriction) StartBundle(ctx context.Context, id string, data D
 //   }
 func (n *PairWithRestriction) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
 	rest := n.inv.Invoke(elm)
-	output := FullValue{Elm: elm, Elm2: rest, Timestamp: elm.Timestamp, Windows: elm.Windows}
+	output := FullValue{Elm: elm, Elm2: rest, Timestamp: elm.Timestamp, Windows: elm.Windows, Pane: elm.Pane}
 
 	return n.Out.ProcessElement(ctx, &output, values...)
 }
@@ -86,7 +87,7 @@ func (n *PairWithRestriction) ProcessElement(ctx context.Context, elm *FullValue
 // FinishBundle resets the invokers.
 func (n *PairWithRestriction) FinishBundle(ctx context.Context) error {
 	n.inv.Reset()
-	return n.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, n.Out)
 }
 
 // Down currently does nothing.
@@ -136,7 +137,7 @@ func (n *SplitAndSizeRestrictions) Up(_ context.Context) error {
 
 // StartBundle currently does nothing.
 func (n *SplitAndSizeRestrictions) StartBundle(ctx context.Context, id string, data DataContext) error {
-	return n.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, n.Out)
 }
 
 // ProcessElement expects elm.Elm to hold the original input while elm.Elm2
@@ -183,6 +184,7 @@ func (n *SplitAndSizeRestrictions) ProcessElement(ctx context.Context, elm *Full
 
 		output.Timestamp = elm.Timestamp
 		output.Windows = elm.Windows
+		output.Pane = elm.Pane
 		output.Elm = &FullValue{Elm: mainElm, Elm2: splitRest}
 		output.Elm2 = size
 
@@ -198,7 +200,7 @@ func (n *SplitAndSizeRestrictions) ProcessElement(ctx context.Context, elm *Full
 func (n *SplitAndSizeRestrictions) FinishBundle(ctx context.Context) error {
 	n.splitInv.Reset()
 	n.sizeInv.Reset()
-	return n.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, n.Out)
 }
 
 // Down currently does nothing.
@@ -222,6 +224,10 @@ type ProcessSizedElementsAndRestrictions struct {
 	ctInv   *ctInvoker
 	sizeInv *rsInvoker
 
+	// CheckpointCoder is the windowed coder of the input elements, used to
+	// encode checkpoints. Checkpoint and Restore fail if it is nil.
+	CheckpointCoder *coder.Coder
+
 	// SU is a buffered channel for indicating when this unit is splittable.
 	// When this unit is processing an element, it sends a SplittableUnit
 	// interface through the channel. That interface can be received on other
@@ -245,6 +251,10 @@ type ProcessSizedElementsAndRestrictions struct {
 	// This can change during processing due to splits, but it should always be
 	// set greater than currW.
 	numW int
+
+	// resume is the residual restriction of a checkpoint, to process once the
+	// current restriction is done.
+	resume interface{}
 }
 
 // ID calls the ParDo's ID method.
@@ -252,6 +262,11 @@ func (n *ProcessSizedElementsAndRestrictions) ID() UnitID {
 	return n.PDo.ID()
 }
 
+// ResourceHints calls the ParDo's ResourceHints method.
+func (n *ProcessSizedElementsAndRestrictions) ResourceHints() ResourceHints {
+	return n.PDo.ResourceHints()
+}
+
 // Up performs some one-time setup and then calls the ParDo's Up method.
 func (n *ProcessSizedElementsAndRestrictions) Up(ctx context.Context) error {
 	fn := (*graph.SplittableDoFn)(n.PDo.Fn).CreateTrackerFn()
@@ -318,12 +333,14 @@ func (n *ProcessSizedElementsAndRestrictions) ProcessElement(_ context.Context,
 			Elm2:      userElm.Elm2,
 			Timestamp: elm.Timestamp,
 			Windows:   elm.Windows,
+			Pane:      elm.Pane,
 		}
 	} else {
 		mainIn.Key = FullValue{
 			Elm:       elm.Elm.(*FullValue).Elm,
 			Timestamp: elm.Timestamp,
 			Windows:   elm.Windows,
+			Pane:      elm.Pane,
 		}
 	}
 
@@ -332,18 +349,9 @@ func (n *ProcessSizedElementsAndRestrictions) ProcessElement(_ context.Context,
 	if !mustExplodeWindows(n.PDo.inv.fn, elm, len(n.PDo.Side) > 0) {
 		// If windows don't need to be exploded (i.e. aren't observed), treat
 		// all windows as one as an optimization.
-		rest := elm.Elm.(*FullValue).Elm2
-		rt := n.ctInv.Invoke(rest)
-		mainIn.RTracker = rt
-
 		n.numW = 1 // Even if there's more than one window, treat them as one.
-		n.rt = rt
 		n.elm = elm
-		n.SU <- n
-		defer func() {
-			<-n.SU
-		}()
-		return n.PDo.processSingleWindow(mainIn)
+		return n.processRestriction(mainIn, elm.Elm.(*FullValue).Elm2)
 	} else {
 		// If we need to process the element in multiple windows, each one needs
 		// its own RTracker and progress must be tracked among all windows by
@@ -351,27 +359,46 @@ func (n *ProcessSizedElementsAndRestrictions) ProcessElement(_ context.Context,
 		n.numW = len(elm.Windows)
 
 		for i := 0; i < n.numW; i++ {
-			rest := elm.Elm.(*FullValue).Elm2
-			rt := n.ctInv.Invoke(rest)
 			key := &mainIn.Key
 			w := elm.Windows[i]
-			wElm := FullValue{Elm: key.Elm, Elm2: key.Elm2, Timestamp: key.Timestamp, Windows: []typex.Window{w}}
+			wElm := FullValue{Elm: key.Elm, Elm2: key.Elm2, Timestamp: key.Timestamp, Windows: []typex.Window{w}, Pane: key.Pane}
 
 			n.currW = i
-			n.rt = rt
 			n.elm = elm
-			n.SU <- n
-			err := n.PDo.processSingleWindow(&MainInput{Key: wElm, Values: mainIn.Values, RTracker: rt})
-			if err != nil {
-				<-n.SU
+			if err := n.processRestriction(&MainInput{Key: wElm, Values: mainIn.Values}, elm.Elm.(*FullValue).Elm2); err != nil {
 				return n.PDo.fail(err)
 			}
-			<-n.SU
 		}
 	}
 	return nil
 }
 
+// processRestriction processes the main input with a new restriction tracker
+// for the given restriction, and then with the residual restriction of each
+// checkpoint taken meanwhile.
+func (n *ProcessSizedElementsAndRestrictions) processRestriction(mainIn *MainInput, rest interface{}) error {
+	for rest != nil {
+		rt := n.ctInv.Invoke(rest)
+		mainIn.RTracker = rt
+		n.rt = rt
+		if err := n.processTracked(mainIn); err != nil {
+			return err
+		}
+		rest, n.resume = n.resume, nil
+	}
+	return nil
+}
+
+// processTracked processes the main input while making this unit available
+// for splitting.
+func (n *ProcessSizedElementsAndRestrictions) processTracked(mainIn *MainInput) error {
+	n.SU <- n
+	defer func() {
+		<-n.SU
+	}()
+	return n.PDo.processSingleWindow(mainIn)
+}
+
 // FinishBundle resets the invokers and then calls the ParDo's FinishBundle method.
 func (n *ProcessSizedElementsAndRestrictions) FinishBundle(ctx context.Context) error {
 	n.ctInv.Reset()
@@ -680,6 +707,11 @@ func (n *SdfFallback) ID() UnitID {
 	return n.PDo.UID
 }
 
+// ResourceHints calls the ParDo's ResourceHints method.
+func (n *SdfFallback) ResourceHints() ResourceHints {
+	return n.PDo.ResourceHints()
+}
+
 // Up performs some one-time setup and then calls the ParDo's Up method.
 func (n *SdfFallback) Up(ctx context.Context) error {
 	dfn := (*graph.SplittableDoFn)(n.PDo.Fn)
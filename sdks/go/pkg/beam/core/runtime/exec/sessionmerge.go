@@ -0,0 +1,163 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// SessionMerge assigns KV elements to session windows per key, and emits
+// each element in the merged session it ends up in. As with session
+// windowing, each element starts in a session from its timestamp until Gap
+// later, and overlapping sessions of the same key are merged into one
+// spanning them. Since an element may bridge two sessions that were separate
+// so far, merging them and the elements assigned to both, elements are
+// buffered and only emitted at FinishBundle: per key, in order of the first
+// element of the key, per session in order of their start, and within a
+// session in order of the sessions merged into it. The windows of
+// incoming elements are replaced. Keys are compared by their encoding with
+// KeyCoder.
+type SessionMerge struct {
+	UID      UnitID
+	Gap      time.Duration
+	KeyCoder *coder.Coder
+	Out      Node
+
+	enc  ElementEncoder
+	buf  bytes.Buffer
+	keys map[string]int
+	// sessions holds the sessions of each key, sorted by start and disjoint.
+	sessions [][]*session
+}
+
+// session is a merged session window of a key, with the elements in it.
+type session struct {
+	window window.IntervalWindow
+	elms   []*FullValue
+}
+
+// NewSessionMerge returns a SessionMerge with the given gap, which emits to
+// out. The caller sets the UID of the node.
+func NewSessionMerge(out Node, gap time.Duration, keyCoder *coder.Coder) *SessionMerge {
+	return &SessionMerge{Gap: gap, KeyCoder: keyCoder, Out: out}
+}
+
+func (n *SessionMerge) ID() UnitID {
+	return n.UID
+}
+
+// Up validates the gap and initializes the key encoder.
+func (n *SessionMerge) Up(ctx context.Context) error {
+	if n.Gap <= 0 {
+		return errors.Errorf("invalid gap for session merge %v: %v, want > 0", n.UID, n.Gap)
+	}
+	n.enc = MakeElementEncoder(n.KeyCoder)
+	return nil
+}
+
+func (n *SessionMerge) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.keys, n.sessions = make(map[string]int), nil
+	return MultiStartBundle(ctx, id, data, n.Out)
+}
+
+// ProcessElement adds the element to the sessions of its key, merging any
+// sessions that its own session overlaps.
+func (n *SessionMerge) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("session merge %v does not support GBK/CoGBK results", n.UID)
+	}
+	if elm.Elm2 == nil {
+		return errors.Errorf("non-KV element %v at session merge %v", elm, n.UID)
+	}
+	if elm.Timestamp < mtime.MinTimestamp || elm.Timestamp > mtime.EndOfGlobalWindowTime {
+		return errors.Errorf("invalid timestamp for session merge %v: %v is outside the windowable range [%v, %v]", n.UID, elm.Timestamp, mtime.MinTimestamp, mtime.EndOfGlobalWindowTime)
+	}
+	n.buf.Reset()
+	if err := n.enc.Encode(&FullValue{Elm: elm.Elm}, &n.buf); err != nil {
+		return errors.WithContextf(err, "encoding key %v at session merge %v", elm.Elm, n.UID)
+	}
+	i, ok := n.keys[n.buf.String()]
+	if !ok {
+		i = len(n.sessions)
+		n.keys[n.buf.String()] = i
+		n.sessions = append(n.sessions, nil)
+	}
+
+	s := &session{
+		window: window.IntervalWindow{Start: elm.Timestamp, End: elm.Timestamp.Add(n.Gap)},
+		elms:   []*FullValue{CloneFullValue(elm)},
+	}
+	n.sessions[i] = mergeSession(n.sessions[i], s)
+	return nil
+}
+
+// mergeSession inserts s into the sorted, disjoint sessions, merging it with
+// all sessions it overlaps. The elements of merged sessions precede those of
+// s, in order of the sessions.
+func mergeSession(sessions []*session, s *session) []*session {
+	var ret []*session
+	var merged []*FullValue
+	for i, o := range sessions {
+		switch {
+		case o.window.End <= s.window.Start:
+			ret = append(ret, o)
+		case s.window.End <= o.window.Start:
+			s.elms = append(merged, s.elms...)
+			ret = append(ret, s)
+			return append(ret, sessions[i:]...)
+		default:
+			s.window.Start = mtime.Min(s.window.Start, o.window.Start)
+			s.window.End = mtime.Max(s.window.End, o.window.End)
+			merged = append(merged, o.elms...)
+		}
+	}
+	s.elms = append(merged, s.elms...)
+	return append(ret, s)
+}
+
+// FinishBundle emits the buffered elements in their merged sessions and
+// finishes the bundle downstream.
+func (n *SessionMerge) FinishBundle(ctx context.Context) error {
+	for _, sessions := range n.sessions {
+		for _, s := range sessions {
+			for _, elm := range s.elms {
+				elm.Windows = []typex.Window{s.window}
+				if err := n.Out.ProcessElement(ctx, elm); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	n.keys, n.sessions = nil, nil
+	return MultiFinishBundle(ctx, n.Out)
+}
+
+func (n *SessionMerge) Down(ctx context.Context) error {
+	return nil
+}
+
+func (n *SessionMerge) String() string {
+	return fmt.Sprintf("SessionMerge[%v]. Out:%v", n.Gap, n.Out.ID())
+}
@@ -0,0 +1,132 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// sessionInput returns KV elements of the given key, value and timestamp in
+// milliseconds, in the global window.
+func sessionInput(ktvs ...interface{}) []MainInput {
+	var ret []MainInput
+	for i := 0; i+2 < len(ktvs); i += 3 {
+		ret = append(ret, MainInput{Key: FullValue{
+			Elm:       ktvs[i],
+			Elm2:      ktvs[i+1],
+			Timestamp: mtime.FromMilliseconds(int64(ktvs[i+2].(int))),
+			Windows:   window.SingleGlobalWindow,
+		}})
+	}
+	return ret
+}
+
+func TestSessionMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []MainInput
+		want []string
+	}{
+		{
+			name: "separate",
+			in:   sessionInput("a", 1, 0, "a", 2, 20, "b", 3, 5),
+			want: []string{"a:1@[0:10)", "a:2@[20:30)", "b:3@[5:15)"},
+		},
+		{
+			name: "overlapping",
+			in:   sessionInput("a", 1, 0, "a", 2, 5, "a", 3, 14),
+			want: []string{"a:1@[0:24)", "a:2@[0:24)", "a:3@[0:24)"},
+		},
+		{
+			name: "adjacent",
+			in:   sessionInput("a", 1, 0, "a", 2, 10),
+			want: []string{"a:1@[0:10)", "a:2@[10:20)"},
+		},
+		{
+			// The late element at 8 bridges the sessions of 0 and 15, but not
+			// that of key b.
+			name: "bridging",
+			in:   sessionInput("a", 1, 0, "a", 2, 15, "b", 3, 8, "a", 4, 8),
+			want: []string{"a:1@[0:25)", "a:2@[0:25)", "a:4@[0:25)", "b:3@[8:18)"},
+		},
+		{
+			name: "out of order",
+			in:   sessionInput("a", 1, 30, "a", 2, 0, "a", 3, 38, "a", 4, 8),
+			want: []string{"a:2@[0:18)", "a:4@[0:18)", "a:1@[30:48)", "a:3@[30:48)"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewSessionMerge(out, 10*time.Millisecond, coder.NewString())
+			n.UID = 2
+			in := &FixedRoot{UID: 3, Elements: test.in, Out: n}
+
+			p, err := NewPlan("a", []Unit{in, n, out})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			var got []string
+			for _, elm := range out.Elements {
+				if len(elm.Windows) != 1 {
+					t.Fatalf("element %v has %v windows, want 1", elm, len(elm.Windows))
+				}
+				w := elm.Windows[0].(window.IntervalWindow)
+				got = append(got, fmt.Sprintf("%v:%v@[%v:%v)", elm.Elm, elm.Elm2, int64(w.Start), int64(w.End)))
+			}
+			if !equalStrings(got, test.want) {
+				t.Errorf("session merge emitted %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSessionMerge_errors(t *testing.T) {
+	ctx := context.Background()
+	n := NewSessionMerge(&CaptureNode{UID: 1}, 0, coder.NewString())
+	if err := n.Up(ctx); err == nil {
+		t.Error("Up with zero gap succeeded, want error")
+	}
+
+	out := &CaptureNode{UID: 1}
+	n = NewSessionMerge(out, time.Second, coder.NewString())
+	if err := out.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := n.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	if err := n.ProcessElement(ctx, &FullValue{Elm: "a", Windows: []typex.Window{window.GlobalWindow{}}}); err == nil {
+		t.Error("ProcessElement with non-KV element succeeded, want error")
+	}
+	if err := n.ProcessElement(ctx, &FullValue{Elm: "a", Elm2: 1, Timestamp: mtime.MaxTimestamp}); err == nil {
+		t.Error("ProcessElement with timestamp past the global window succeeded, want error")
+	}
+}
@@ -0,0 +1,81 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// ShuffleNode wraps a node and reorders the elements of each bundle
+// pseudo-randomly. Elements are buffered and passed to the wrapped node on
+// FinishBundle, in an order determined by Seed and the number of elements in
+// the bundle only, so that a bundle is reordered the same way in every run.
+// Elements are passed unchanged, along with their GBK/CoGBK values. It is
+// intended for testing that transforms are independent of the order of their
+// input.
+type ShuffleNode struct {
+	Node
+	Seed int64
+
+	buf []shuffleEntry
+}
+
+// shuffleEntry is a buffered element with its values.
+type shuffleEntry struct {
+	v      FullValue
+	values []ReStream
+}
+
+// NewShuffleNode returns a node that passes the elements of each bundle to
+// out in a pseudo-random order determined by seed.
+func NewShuffleNode(out Node, seed int64) *ShuffleNode {
+	return &ShuffleNode{Node: out, Seed: seed}
+}
+
+// StartBundle clears the buffer and starts the wrapped node.
+func (n *ShuffleNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.buf = n.buf[:0]
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement buffers the element.
+func (n *ShuffleNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.buf = append(n.buf, shuffleEntry{v: *elm, values: values})
+	return nil
+}
+
+// FinishBundle passes the buffered elements to the wrapped node in shuffled
+// order and finishes it.
+func (n *ShuffleNode) FinishBundle(ctx context.Context) error {
+	r := rand.New(rand.NewSource(n.Seed))
+	r.Shuffle(len(n.buf), func(i, j int) {
+		n.buf[i], n.buf[j] = n.buf[j], n.buf[i]
+	})
+	for i := range n.buf {
+		e := &n.buf[i]
+		if err := n.Node.ProcessElement(ctx, &e.v, e.values...); err != nil {
+			return err
+		}
+	}
+	n.buf = n.buf[:0]
+	return n.Node.FinishBundle(ctx)
+}
+
+func (n *ShuffleNode) String() string {
+	return fmt.Sprintf("ShuffleNode[seed %v]. Node:%v", n.Seed, n.Node)
+}
@@ -0,0 +1,83 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestShuffleNode verifies that the elements of each bundle are passed on
+// unchanged, in an order that only depends on the seed.
+func TestShuffleNode(t *testing.T) {
+	var in []MainInput
+	for i := 0; i < 20; i++ {
+		in = append(in, MainInput{Key: FullValue{
+			Elm:       i,
+			Elm2:      "v",
+			Timestamp: mtime.Time(i * 10),
+			Windows:   []typex.Window{window.IntervalWindow{Start: mtime.Time(i), End: 100}},
+		}})
+	}
+	run := func(seed int64) [][]FullValue {
+		out := &CaptureNode{UID: 1}
+		n := NewShuffleNode(out, seed)
+		root := &FixedRoot{UID: 2, Elements: in, Out: n}
+		p, err := NewPlan("a", []Unit{root, n})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		var bundles [][]FullValue
+		for _, id := range []string{"1", "2"} {
+			out.Elements = nil
+			if err := p.Execute(context.Background(), id, DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			bundles = append(bundles, out.Elements)
+		}
+		return bundles
+	}
+
+	got := run(42)
+	if !reflect.DeepEqual(got[0], got[1]) {
+		t.Errorf("bundles were shuffled differently: %v and %v", extractValues(got[0]...), extractValues(got[1]...))
+	}
+	if again := run(42); !reflect.DeepEqual(got, again) {
+		t.Errorf("seed 42 shuffled differently in another run: %v and %v", extractValues(got[0]...), extractValues(again[0]...))
+	}
+	if other := run(7); reflect.DeepEqual(got[0], other[0]) {
+		t.Errorf("seeds 42 and 7 gave the same order: %v", extractValues(got[0]...))
+	}
+
+	var want []FullValue
+	for _, e := range in {
+		want = append(want, e.Key)
+	}
+	if reflect.DeepEqual(got[0], want) {
+		t.Errorf("elements were not shuffled: %v", extractValues(got[0]...))
+	}
+	sorted := append([]FullValue(nil), got[0]...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Elm.(int) < sorted[j].Elm.(int) })
+	if !reflect.DeepEqual(sorted, want) {
+		t.Errorf("shuffled elements = %v, want a permutation of %v", sorted, want)
+	}
+}
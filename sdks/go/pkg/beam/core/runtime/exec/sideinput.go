@@ -35,6 +35,17 @@ type SideInputAdapter interface {
 	NewIterable(ctx context.Context, reader StateReader, w typex.Window) (ReStream, error)
 }
 
+// IterableSideInputAdapter is a SideInputAdapter that can read side input
+// lazily, page by page, without materializing it in memory.
+type IterableSideInputAdapter interface {
+	SideInputAdapter
+
+	// Iterable returns a ReStream over the side input for the given window,
+	// which fetches pages from the reader as it is iterated, up to prefetch
+	// pages ahead. Each Open restarts the iteration from the first page.
+	Iterable(ctx context.Context, reader StateReader, w typex.Window, prefetch int) (ReStream, error)
+}
+
 type sideInputAdapter struct {
 	sid         StreamID
 	sideInputID string
@@ -57,6 +68,10 @@ func NewSideInputAdapter(sid StreamID, sideInputID string, c *coder.Coder) SideI
 }
 
 func (s *sideInputAdapter) NewIterable(ctx context.Context, reader StateReader, w typex.Window) (ReStream, error) {
+	return s.Iterable(ctx, reader, w, 0)
+}
+
+func (s *sideInputAdapter) Iterable(ctx context.Context, reader StateReader, w typex.Window, prefetch int) (ReStream, error) {
 	key, err := EncodeElement(s.kc, []byte(iterableSideInputKey))
 	if err != nil {
 		return nil, err
@@ -71,6 +86,9 @@ func (s *sideInputAdapter) NewIterable(ctx context.Context, reader StateReader,
 			if err != nil {
 				return nil, err
 			}
+			if prefetch > 0 {
+				r = newPrefetchReader(r, prefetch)
+			}
 			return &elementStream{r: r, ec: s.ec}, nil
 		},
 	}, nil
@@ -104,6 +122,88 @@ func (s *elementStream) Read() (*FullValue, error) {
 	return s.ec.Decode(s.r)
 }
 
+// sideInputPageSize is the size of the buffers side input pages are
+// prefetched into. State readers return at most one page per read, so larger
+// pages span several buffers.
+const sideInputPageSize = 64 << 10
+
+// page is a prefetched chunk of a byte stream.
+type page struct {
+	b   []byte
+	err error
+}
+
+// prefetchReader reads pages from the wrapped reader in a separate goroutine,
+// up to a given number of pages ahead of the reads from it.
+type prefetchReader struct {
+	r     io.ReadCloser
+	pages chan page
+	done  chan struct{}
+
+	cur    []byte
+	err    error
+	closed bool
+}
+
+func newPrefetchReader(r io.ReadCloser, depth int) *prefetchReader {
+	p := &prefetchReader{r: r, pages: make(chan page, depth), done: make(chan struct{})}
+	go p.fetch()
+	return p
+}
+
+// fetch reads pages until the wrapped reader fails, or the prefetchReader
+// is closed.
+func (p *prefetchReader) fetch() {
+	defer close(p.pages)
+	for {
+		buf := make([]byte, sideInputPageSize)
+		n, err := p.r.Read(buf)
+		if n == 0 && err == nil {
+			continue
+		}
+		select {
+		case p.pages <- page{b: buf[:n], err: err}:
+		case <-p.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *prefetchReader) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	for len(p.cur) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		pg, ok := <-p.pages
+		if !ok {
+			return 0, io.EOF
+		}
+		p.cur, p.err = pg.b, pg.err
+	}
+	n := copy(b, p.cur)
+	p.cur = p.cur[n:]
+	return n, nil
+}
+
+// Close stops the prefetching, waiting for any pending read to complete, and
+// closes the wrapped reader.
+func (p *prefetchReader) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	for range p.pages {
+	}
+	return p.r.Close()
+}
+
 // FixedKey transform any value into KV<K, V> for a fixed K.
 type FixedKey struct {
 	// UID is the unit identifier.
@@ -123,7 +223,7 @@ func (n *FixedKey) Up(ctx context.Context) error {
 }
 
 func (n *FixedKey) StartBundle(ctx context.Context, id string, data DataContext) error {
-	return n.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, n.Out)
 }
 
 func (n *FixedKey) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
@@ -139,7 +239,7 @@ func (n *FixedKey) ProcessElement(ctx context.Context, elm *FullValue, values ..
 }
 
 func (n *FixedKey) FinishBundle(ctx context.Context) error {
-	return n.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, n.Out)
 }
 
 func (n *FixedKey) Down(ctx context.Context) error {
@@ -0,0 +1,176 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+// pagedStateReader serves side inputs as a fixed sequence of pages, one per
+// read, and counts the pages fetched.
+type pagedStateReader struct {
+	pages   [][]byte
+	fetched int32
+}
+
+func (s *pagedStateReader) OpenSideInput(ctx context.Context, id StreamID, sideInputID string, key, w []byte) (io.ReadCloser, error) {
+	return &pageReader{s: s}, nil
+}
+
+func (s *pagedStateReader) OpenIterable(ctx context.Context, id StreamID, key []byte) (io.ReadCloser, error) {
+	return &pageReader{s: s}, nil
+}
+
+type pageReader struct {
+	s *pagedStateReader
+	i int
+}
+
+func (r *pageReader) Read(b []byte) (int, error) {
+	if r.i == len(r.s.pages) {
+		return 0, io.EOF
+	}
+	atomic.AddInt32(&r.s.fetched, 1)
+	n := copy(b, r.s.pages[r.i])
+	r.i++
+	return n, nil
+}
+
+func (r *pageReader) Close() error {
+	return nil
+}
+
+func newPagedStateReader(t *testing.T, vs ...int64) *pagedStateReader {
+	ec := MakeElementEncoder(coder.NewVarInt())
+	state := &pagedStateReader{}
+	for _, v := range vs {
+		var buf bytes.Buffer
+		if err := ec.Encode(&FullValue{Elm: v}, &buf); err != nil {
+			t.Fatalf("failed to encode %v: %v", v, err)
+		}
+		state.pages = append(state.pages, buf.Bytes())
+	}
+	return state
+}
+
+var pagedSideInputCoder = coder.NewW(coder.NewKV([]*coder.Coder{coder.NewBytes(), coder.NewVarInt()}), coder.NewGlobalWindow())
+
+// TestSideInputAdapter_Iterable verifies that lazily read side inputs fetch
+// pages only as far ahead as configured, and can be iterated repeatedly.
+func TestSideInputAdapter_Iterable(t *testing.T) {
+	var vs []int64
+	var want []interface{}
+	for i := 0; i < 10; i++ {
+		vs = append(vs, int64(i))
+		want = append(want, int64(i))
+	}
+	state := newPagedStateReader(t, vs...)
+
+	adapter := NewSideInputAdapter(StreamID{PtransformID: "pardo"}, "side", pagedSideInputCoder).(IterableSideInputAdapter)
+	rs, err := adapter.Iterable(context.Background(), state, window.GlobalWindow{}, 2)
+	if err != nil {
+		t.Fatalf("Iterable failed: %v", err)
+	}
+
+	s, err := rs.Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := s.Read(); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	// The page read, 2 buffered pages, and one pending the buffer.
+	if got := atomic.LoadInt32(&state.fetched); got > 4 {
+		t.Errorf("fetched %v pages after reading one value, want at most 4", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Each iteration starts over from the first page.
+	for i := 0; i < 2; i++ {
+		got, err := ReadAll(rs)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if !equalList(got, makeValuesNoWindowOrTime(want...)) {
+			t.Errorf("iteration %v = %v, want %v", i, extractValues(got...), want)
+		}
+	}
+}
+
+func addSideInt64Fn(n int, side func(*int64) bool, emit func(int)) {
+	var i int64
+	for side(&i) {
+		n += int(i)
+	}
+	emit(n)
+}
+
+// TestParDo_sideInputPrefetch verifies that lazily read side inputs are read
+// again for each element, rather than materialized by the side input cache.
+func TestParDo_sideInputPrefetch(t *testing.T) {
+	fn, err := graph.NewDoFn(addSideInt64Fn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	sN := g.NewNode(typex.New(reflectx.Int64), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN, sN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	for _, prefetch := range []int{0, 1} {
+		state := newPagedStateReader(t, 1, 2, 3)
+		side := NewSideInputAdapter(StreamID{PtransformID: "pardo"}, "side", pagedSideInputCoder)
+		out := &CaptureNode{UID: 1}
+		pardo := &ParDo{UID: 2, PID: "pardo", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}, Side: []SideInputAdapter{side}, MaxSideInputWindows: 2, SideInputPrefetch: prefetch}
+		n := &FixedRoot{UID: 3, Elements: makeInput(10, 20, 30), Out: pardo}
+		p, err := NewPlan("a", []Unit{n, pardo, out})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(context.Background(), "1", DataContext{State: state}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+
+		if want := makeValues(16, 26, 36); !equalList(out.Elements, want) {
+			t.Errorf("pardo(addSideInt64Fn) with prefetch %v = %v, want %v", prefetch, extractValues(out.Elements...), extractValues(want...))
+		}
+		// Materialized side inputs are read once, lazy ones for each element.
+		want := int32(3)
+		if prefetch > 0 {
+			want = 9
+		}
+		if got := atomic.LoadInt32(&state.fetched); got != want {
+			t.Errorf("fetched %v pages with prefetch %v, want %v", got, prefetch, want)
+		}
+	}
+}
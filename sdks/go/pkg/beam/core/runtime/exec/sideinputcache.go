@@ -0,0 +1,127 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"container/list"
+	"context"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+var (
+	sideInputCacheHits   = metrics.NewCounter("exec", "sideInputCache.hits")
+	sideInputCacheMisses = metrics.NewCounter("exec", "sideInputCache.misses")
+)
+
+// initCachedSideInput initializes the side inputs for the given window from
+// the cache, materializing them on a miss.
+func (n *ParDo) initCachedSideInput(ctx context.Context, w typex.Window) error {
+	if n.cache == nil || !w.Equals(n.cache.key) {
+		if n.sideInputs == nil {
+			n.sideInputs = list.New()
+		}
+		var hit *list.Element
+		for e := n.sideInputs.Front(); e != nil; e = e.Next() {
+			if e.Value.(*cacheElm).key.Equals(w) {
+				hit = e
+				break
+			}
+		}
+		if hit == nil {
+			sideInputCacheMisses.Inc(n.ctx, 1)
+			c, err := n.materializeSideInput(ctx, w)
+			if err != nil {
+				return err
+			}
+			hit = n.sideInputs.PushFront(c)
+			for n.sideInputs.Len() > n.MaxSideInputWindows {
+				n.sideInputs.Remove(n.sideInputs.Back())
+			}
+		} else {
+			sideInputCacheHits.Inc(n.ctx, 1)
+			n.sideInputs.MoveToFront(hit)
+		}
+		n.cache = hit.Value.(*cacheElm)
+	} else {
+		sideInputCacheHits.Inc(n.ctx, 1)
+	}
+
+	for _, s := range n.cache.sideinput {
+		if err := s.Init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materializeSideInput reads the side inputs for the given window into
+// memory, except those read lazily with SideInputPrefetch.
+func (n *ParDo) materializeSideInput(ctx context.Context, w typex.Window) (*cacheElm, error) {
+	streams := make([]ReStream, len(n.Side))
+	for i, adapter := range n.Side {
+		if a, ok := adapter.(IterableSideInputAdapter); ok && n.SideInputPrefetch > 0 {
+			s, err := a.Iterable(ctx, n.side, w, n.SideInputPrefetch)
+			if err != nil {
+				return nil, err
+			}
+			streams[i] = s // Read lazily on each iteration.
+			continue
+		}
+		s, err := adapter.NewIterable(ctx, n.side, w)
+		if err != nil {
+			return nil, err
+		}
+		buf, err := ReadAll(s)
+		if err != nil {
+			return nil, err
+		}
+		streams[i] = &FixedReStream{Buf: buf}
+	}
+	sideinput, err := makeSideInputs(n.Fn.ProcessElementFn(), n.Inbound, streams)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cacheElm{key: w, sideinput: sideinput, extra: make([]interface{}, len(n.Side)+len(n.emitters))}
+	for i, s := range sideinput {
+		c.extra[i] = s.Value()
+	}
+	for i, emit := range n.emitters {
+		c.extra[i+len(n.Side)] = emit.Value()
+	}
+	return c, nil
+}
+
+// ExpireSideInputs evicts the cached side inputs of all windows that closed
+// before the given time, such as when the input watermark passes them. It is
+// called before firing event-time timers.
+func (n *ParDo) ExpireSideInputs(t typex.EventTime) {
+	if n.sideInputs == nil {
+		return
+	}
+	for e := n.sideInputs.Front(); e != nil; {
+		next := e.Next()
+		if c := e.Value.(*cacheElm); c.key.MaxTimestamp() < t {
+			n.sideInputs.Remove(e)
+			if c == n.cache {
+				n.cache = nil
+			}
+		}
+		e = next
+	}
+}
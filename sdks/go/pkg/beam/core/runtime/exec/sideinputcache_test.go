@@ -0,0 +1,220 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+// countingSideInputAdapter serves the start of each window as its side input
+// and counts how often the side input is read.
+type countingSideInputAdapter struct {
+	opens int
+}
+
+func (a *countingSideInputAdapter) NewIterable(ctx context.Context, reader StateReader, w typex.Window) (ReStream, error) {
+	return &proxyReStream{open: func() (Stream, error) {
+		a.opens++
+		return &FixedStream{Buf: makeValues(int(w.(window.IntervalWindow).Start))}, nil
+	}}, nil
+}
+
+func addSideFn(n int, side func(*int) bool, emit func(int)) {
+	var i int
+	for side(&i) {
+		n += i
+	}
+	emit(n)
+}
+
+// TestParDo_sideInputCache verifies that side inputs are read once per cached
+// window, are read again after eviction, and are not cached across bundles.
+func TestParDo_sideInputCache(t *testing.T) {
+	fn, err := graph.NewDoFn(addSideFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	sN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN, sN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	w := func(start int) []typex.Window {
+		return []typex.Window{window.IntervalWindow{Start: typex.EventTime(start), End: typex.EventTime(start + 10)}}
+	}
+	var in []MainInput
+	for _, s := range []int{0, 10, 0, 0, 20, 0, 10} {
+		in = append(in, MainInput{Key: FullValue{Elm: 1, Windows: w(s), Timestamp: typex.EventTime(s)}})
+	}
+
+	side := &countingSideInputAdapter{}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "pardo", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}, Side: []SideInputAdapter{side}, MaxSideInputWindows: 2}
+	n := &FixedRoot{UID: 3, Elements: in, Out: pardo}
+	p, err := NewPlan("a", []Unit{n, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	// Windows 0 and 10 are read, 20 evicts 10, which is then read again.
+	if side.opens != 4 {
+		t.Errorf("side input read %v times, want 4", side.opens)
+	}
+	var got []interface{}
+	for _, e := range out.Elements {
+		got = append(got, e.Elm)
+	}
+	if want := []interface{}{1, 11, 1, 1, 21, 1, 11}; len(got) != len(want) || got[1] != 11 || got[4] != 21 || got[6] != 11 {
+		t.Errorf("pardo(addSideFn) = %v, want %v", got, want)
+	}
+
+	counters := make(map[string]int64)
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			counters[l.Name()] = v
+		},
+	}.ExtractFrom(p.Store())
+	if counters["sideInputCache.hits"] != 3 || counters["sideInputCache.misses"] != 4 {
+		t.Errorf("cache counters = %v, want 3 hits and 4 misses", counters)
+	}
+
+	if err := p.Execute(context.Background(), "2", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if side.opens != 8 {
+		t.Errorf("side input read %v times after second bundle, want 8", side.opens)
+	}
+}
+
+// TestParDo_ExpireSideInputs verifies that side inputs of closed windows are
+// evicted.
+func TestParDo_ExpireSideInputs(t *testing.T) {
+	fn, err := graph.NewDoFn(addSideFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	sN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN, sN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	ctx := context.Background()
+	side := &countingSideInputAdapter{}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "pardo", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}, Side: []SideInputAdapter{side}, MaxSideInputWindows: 10}
+	for _, u := range []Unit{out, pardo} {
+		if err := u.Up(ctx); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+	}
+	if err := pardo.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("start bundle failed: %v", err)
+	}
+	for _, s := range []typex.EventTime{0, 10, 0, 10} {
+		if s == 0 && side.opens == 2 {
+			pardo.ExpireSideInputs(10)
+		}
+		elm := &FullValue{Elm: 1, Windows: []typex.Window{window.IntervalWindow{Start: s, End: s + 10}}, Timestamp: s}
+		if err := pardo.ProcessElement(ctx, elm); err != nil {
+			t.Fatalf("process element failed: %v", err)
+		}
+	}
+	// Window 0 is read again after expiry, window 10 is still cached.
+	if side.opens != 3 {
+		t.Errorf("side input read %v times, want 3", side.opens)
+	}
+}
+
+// sideTimerFn is a DoFn that adds the side input to its elements, and to the
+// count of its timers.
+type sideTimerFn struct {
+	graph.TimerProcessing
+}
+
+func (fn *sideTimerFn) ProcessElement(n int, side func(*int) bool, emit func(int)) {
+	addSideFn(n, side, emit)
+}
+
+func (fn *sideTimerFn) OnTimer(family string, count int, side func(*int) bool, emit func(int)) {
+	addSideFn(count, side, emit)
+}
+
+// TestParDo_sideInputCacheTimers verifies that firing event-time timers
+// expires the side inputs of the windows the watermark passed.
+func TestParDo_sideInputCacheTimers(t *testing.T) {
+	fn, err := graph.NewDoFn(&sideTimerFn{})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	sN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN, sN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	ctx := context.Background()
+	side := &countingSideInputAdapter{}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "pardo", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}, Side: []SideInputAdapter{side}, MaxSideInputWindows: 10}
+	for _, u := range []Unit{out, pardo} {
+		if err := u.Up(ctx); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+	}
+	if err := pardo.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("start bundle failed: %v", err)
+	}
+	w0 := window.IntervalWindow{Start: 0, End: 10}
+	w10 := window.IntervalWindow{Start: 10, End: 20}
+	if err := pardo.ProcessElement(ctx, &FullValue{Elm: 1, Windows: []typex.Window{w0}}); err != nil {
+		t.Fatalf("process element failed: %v", err)
+	}
+	for _, tm := range []Timer{{Family: "f", Window: w10, FireTime: 15}, {Family: "f", Window: w0, FireTime: 16}} {
+		if err := pardo.ProcessTimer(tm); err != nil {
+			t.Fatalf("process timer failed: %v", err)
+		}
+	}
+	if err := pardo.FinishBundle(ctx); err != nil {
+		t.Fatalf("finish bundle failed: %v", err)
+	}
+	// Window 0 is read again for the second timer, since the first one
+	// expired it.
+	if side.opens != 3 {
+		t.Errorf("side input read %v times, want 3", side.opens)
+	}
+	if got, want := extractValues(out.Elements...), []interface{}{1, 11, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParDo emitted %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// elementSizes is the distribution of the encoded sizes of elements measured
+// by SizeMeters, in bytes. It is reported per transform of the wrapped node,
+// if known.
+var elementSizes = metrics.NewDistribution("exec", "elementSize.bytes")
+
+// SizeMeter wraps a node and records the encoded size of each element passed to
+// it in a distribution metric, for cost estimation. If Coder is a windowed
+// value coder, the size includes the windowed value header, as on the data
+// channel. Elements are forwarded as is, so measuring an element doubles its
+// encoding cost where it is encoded again downstream. If Disabled, elements are
+// forwarded without being measured.
+type SizeMeter struct {
+	Node
+	Coder    *coder.Coder
+	Disabled bool
+
+	enc  ElementEncoder
+	wEnc WindowEncoder
+	buf  bytes.Buffer
+	ctx  context.Context
+}
+
+// NewSizeMeter returns a node that measures the size of each element passed
+// to out, encoded with the given coder.
+func NewSizeMeter(out Node, c *coder.Coder) *SizeMeter {
+	return &SizeMeter{Node: out, Coder: c}
+}
+
+// Up initializes the encoders and brings up the wrapped node.
+func (n *SizeMeter) Up(ctx context.Context) error {
+	if coder.IsW(n.Coder) {
+		n.wEnc = MakeWindowEncoder(n.Coder.Window)
+	}
+	n.enc = MakeElementEncoder(coder.SkipW(n.Coder))
+	return n.Node.Up(ctx)
+}
+
+// StartBundle attributes the sizes to the transform of the wrapped node, if
+// known, and starts it.
+func (n *SizeMeter) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.ctx = ctx
+	if p, ok := n.Node.(hasPID); ok {
+		n.ctx = metrics.SetPTransformID(ctx, p.GetPID())
+	}
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement records the encoded size of the element and forwards it to
+// the wrapped node.
+func (n *SizeMeter) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if !n.Disabled {
+		n.buf.Reset()
+		if n.wEnc != nil {
+			if err := EncodeWindowedValueHeader(n.wEnc, elm.Windows, elm.Timestamp, elm.Pane, &n.buf); err != nil {
+				return errors.WithContextf(err, "measuring element %v at node %v", elm, n.ID())
+			}
+		}
+		if err := n.enc.Encode(elm, &n.buf); err != nil {
+			return errors.WithContextf(err, "measuring element %v at node %v", elm, n.ID())
+		}
+		elementSizes.Update(n.ctx, int64(n.buf.Len()))
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *SizeMeter) String() string {
+	return fmt.Sprintf("SizeMeter[%v, disabled %v]. Node:%v", n.Coder, n.Disabled, n.Node)
+}
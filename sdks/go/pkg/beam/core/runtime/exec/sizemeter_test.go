@@ -0,0 +1,78 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// TestSizeMeter verifies that the encoded sizes of elements are recorded for
+// the transform of the wrapped node, unless disabled, and that elements are
+// forwarded unchanged.
+func TestSizeMeter(t *testing.T) {
+	fn, err := graph.NewDoFn(func(n int64) int64 { return n })
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	// The windowed value header of the global window has 13 bytes.
+	tests := []struct {
+		name     string
+		coder    *coder.Coder
+		disabled bool
+		want     []int64 // count, sum, min, max
+	}{
+		{name: "element", coder: coder.NewVarInt(), want: []int64{2, 3, 1, 2}},
+		{name: "windowed", coder: coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow()), want: []int64{2, 29, 14, 15}},
+		{name: "disabled", coder: coder.NewVarInt(), disabled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			pardo := &ParDo{UID: 2, PID: "measured", Fn: fn, Out: []Node{out}}
+			n := NewSizeMeter(pardo, test.coder)
+			n.Disabled = test.disabled
+			root := &FixedRoot{UID: 3, Elements: makeInput(int64(1), int64(300)), Out: n}
+			p, err := NewPlan("a", []Unit{root, n, out})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if want := makeValues(int64(1), int64(300)); !equalList(out.Elements, want) {
+				t.Errorf("size meter = %v, want %v", extractValues(out.Elements...), extractValues(want...))
+			}
+
+			var got []int64
+			metrics.Extractor{
+				DistributionInt64: func(l metrics.Labels, count, sum, min, max int64) {
+					if l.Name() == "elementSize.bytes" && l.Transform() == "measured" {
+						got = []int64{count, sum, min, max}
+					}
+				},
+			}.ExtractFrom(p.Store())
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("element sizes = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// SortByTimestamp wraps a node and reorders the elements of each bundle by
+// event timestamp within each window. Elements are buffered and passed to the
+// wrapped node on FinishBundle, grouped by window in order of first appearance
+// and sorted by timestamp within a window. Elements with equal timestamps keep
+// their arrival order. An element in multiple windows is passed once per
+// window.
+//
+// If ByKey, elements are grouped by key and window instead, in order of first
+// appearance, so that the elements of each key are passed contiguously and
+// sorted by timestamp, as stateful DoFns may expect. It requires Coder to be a
+// windowed KV coder.
+//
+// At most MaxBuffer elements are held in memory. If more are buffered, the
+// buffer is sorted and spilled to a temporary file in SpillDir, and spilled
+// runs are merged on FinishBundle. If SpillDir is empty, exceeding MaxBuffer
+// fails the bundle.
+type SortByTimestamp struct {
+	Node
+	MaxBuffer int
+
+	// SpillDir is the directory for spill files. If empty, spilling is
+	// disabled.
+	SpillDir string
+	// Coder is the windowed coder used to spill elements. It is required if
+	// SpillDir or ByKey is set.
+	Coder *coder.Coder
+	ByKey bool
+
+	windows []typex.Window
+	hasher  elementHasher
+	keys    map[uint64]int // Group index by hash of key and window, if ByKey.
+	buf     []sortEntry
+	runs    []*sortRun
+
+	enc  ElementEncoder
+	dec  ElementDecoder
+	wEnc WindowEncoder
+	wDec WindowDecoder
+}
+
+// sortEntry is a buffered element in a single window, identified by the
+// index of its window, or key and window if grouped by key, in the bundle.
+type sortEntry struct {
+	w int
+	v FullValue
+}
+
+func (e *sortEntry) less(o *sortEntry) bool {
+	if e.w != o.w {
+		return e.w < o.w
+	}
+	return e.v.Timestamp < o.v.Timestamp
+}
+
+// sortRun is a sorted set of elements spilled to a file.
+type sortRun struct {
+	f *os.File
+	r *bufio.Reader
+	n int // Number of unread entries.
+}
+
+// NewSortByTimestamp returns a node that passes the elements of each bundle
+// to out sorted by timestamp per window, buffering at most maxBuffer elements
+// in memory. Spilling is disabled until SpillDir and Coder are set.
+func NewSortByTimestamp(out Node, maxBuffer int) *SortByTimestamp {
+	return &SortByTimestamp{Node: out, MaxBuffer: maxBuffer}
+}
+
+// NewSortByKey returns a node that passes the elements of each bundle to out
+// grouped by key and window and sorted by timestamp, for elements with the
+// given windowed KV coder, buffering at most maxBuffer elements in memory.
+// Spilling is disabled until SpillDir is set.
+func NewSortByKey(out Node, c *coder.Coder, maxBuffer int) *SortByTimestamp {
+	return &SortByTimestamp{Node: out, MaxBuffer: maxBuffer, Coder: c, ByKey: true}
+}
+
+// Up initializes the key hasher and spill encoders, if needed, and brings up
+// the wrapped node.
+func (n *SortByTimestamp) Up(ctx context.Context) error {
+	if n.MaxBuffer < 1 {
+		return errors.Errorf("invalid buffer size for sort node %v: %v, want > 0", n.ID(), n.MaxBuffer)
+	}
+	if n.ByKey {
+		if n.Coder == nil || !coder.IsW(n.Coder) || !coder.IsKV(coder.SkipW(n.Coder)) {
+			return errors.Errorf("sort node %v sorts by key but has no windowed KV coder: %v", n.ID(), n.Coder)
+		}
+		n.hasher = makeElementHasher(coder.SkipW(n.Coder).Components[0], n.Coder.Window)
+	}
+	if n.SpillDir != "" {
+		if n.Coder == nil || !coder.IsW(n.Coder) {
+			return errors.Errorf("sort node %v spills to %v but has no windowed value coder: %v", n.ID(), n.SpillDir, n.Coder)
+		}
+		n.enc = MakeElementEncoder(coder.SkipW(n.Coder))
+		n.dec = MakeElementDecoder(coder.SkipW(n.Coder))
+		n.wEnc = MakeWindowEncoder(n.Coder.Window)
+		n.wDec = MakeWindowDecoder(n.Coder.Window)
+	}
+	return n.Node.Up(ctx)
+}
+
+// StartBundle clears the buffer and starts the wrapped node.
+func (n *SortByTimestamp) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.reset()
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement buffers the element in each of its windows, spilling the
+// buffer if it is full.
+func (n *SortByTimestamp) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("sort node %v does not support GBK/CoGBK results", n.ID())
+	}
+	for _, w := range elm.Windows {
+		if len(n.buf) >= n.MaxBuffer {
+			if err := n.spill(); err != nil {
+				return err
+			}
+		}
+		i, err := n.groupIndex(elm, w)
+		if err != nil {
+			return err
+		}
+		n.buf = append(n.buf, sortEntry{
+			w: i,
+			v: FullValue{Elm: elm.Elm, Elm2: elm.Elm2, Timestamp: elm.Timestamp, Windows: []typex.Window{w}, Pane: elm.Pane},
+		})
+	}
+	return nil
+}
+
+// groupIndex returns the index of the group of the element in the window.
+func (n *SortByTimestamp) groupIndex(elm *FullValue, w typex.Window) (int, error) {
+	if !n.ByKey {
+		return n.windowIndex(w), nil
+	}
+	h, err := n.hasher.Hash(elm.Elm, w)
+	if err != nil {
+		return 0, errors.WithContextf(err, "hashing key %v of sort node %v", elm.Elm, n.ID())
+	}
+	if n.keys == nil {
+		n.keys = make(map[uint64]int)
+	}
+	i, ok := n.keys[h]
+	if !ok {
+		i = len(n.keys)
+		n.keys[h] = i
+	}
+	return i, nil
+}
+
+func (n *SortByTimestamp) windowIndex(w typex.Window) int {
+	for i, v := range n.windows {
+		if v.Equals(w) {
+			return i
+		}
+	}
+	n.windows = append(n.windows, w)
+	return len(n.windows) - 1
+}
+
+func (n *SortByTimestamp) sortBuffer() {
+	sort.SliceStable(n.buf, func(i, j int) bool {
+		return n.buf[i].less(&n.buf[j])
+	})
+}
+
+// spill writes the sorted buffer to a new run file.
+func (n *SortByTimestamp) spill() error {
+	if n.SpillDir == "" {
+		return errors.Errorf("sort node %v exceeded its buffer of %v elements and has no spill directory configured", n.ID(), n.MaxBuffer)
+	}
+	n.sortBuffer()
+
+	f, err := ioutil.TempFile(n.SpillDir, "beam-sort-")
+	if err != nil {
+		return errors.WithContextf(err, "creating spill file for sort node %v", n.ID())
+	}
+	run := &sortRun{f: f, n: len(n.buf)}
+	n.runs = append(n.runs, run)
+
+	w := bufio.NewWriter(f)
+	for i := range n.buf {
+		e := &n.buf[i]
+		if err := coder.EncodeVarInt(int64(e.w), w); err != nil {
+			return err
+		}
+		if err := EncodeWindowedValueHeader(n.wEnc, e.v.Windows, e.v.Timestamp, e.v.Pane, w); err != nil {
+			return err
+		}
+		if err := n.enc.Encode(&e.v, w); err != nil {
+			return errors.WithContextf(err, "encoding element %v with coder %v", e.v, n.Coder)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithContextf(err, "writing spill file for sort node %v", n.ID())
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	run.r = bufio.NewReader(f)
+	n.buf = n.buf[:0]
+	return nil
+}
+
+// next reads the next entry of the run.
+func (n *SortByTimestamp) next(run *sortRun) (*sortEntry, error) {
+	w, err := coder.DecodeVarInt(run.r)
+	if err != nil {
+		return nil, err
+	}
+	ws, t, pn, err := DecodeWindowedValueHeader(n.wDec, run.r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := n.dec.Decode(run.r)
+	if err != nil {
+		return nil, err
+	}
+	v.Windows = ws
+	v.Timestamp = t
+	v.Pane = pn
+	run.n--
+	return &sortEntry{w: int(w), v: *v}, nil
+}
+
+// FinishBundle passes all buffered elements in order to the wrapped node,
+// merging any spilled runs, and finishes the wrapped node.
+func (n *SortByTimestamp) FinishBundle(ctx context.Context) error {
+	defer n.reset()
+	n.sortBuffer()
+
+	// Merge the spilled runs and the in-memory buffer. Runs hold earlier
+	// elements than the buffer, and earlier runs earlier elements than later
+	// ones, so preferring the first head on ties keeps arrival order.
+	heads := make([]*sortEntry, len(n.runs)+1)
+	for i, run := range n.runs {
+		e, err := n.next(run)
+		if err != nil {
+			return errors.WithContextf(err, "reading spill file for sort node %v", n.ID())
+		}
+		heads[i] = e
+	}
+	bi := 0
+	if len(n.buf) > 0 {
+		heads[len(n.runs)] = &n.buf[0]
+	}
+	for {
+		min := -1
+		for i, h := range heads {
+			if h != nil && (min < 0 || h.less(heads[min])) {
+				min = i
+			}
+		}
+		if min < 0 {
+			break
+		}
+		if err := n.Node.ProcessElement(ctx, &heads[min].v); err != nil {
+			return err
+		}
+		if min == len(n.runs) {
+			bi++
+			heads[min] = nil
+			if bi < len(n.buf) {
+				heads[min] = &n.buf[bi]
+			}
+			continue
+		}
+		heads[min] = nil
+		if run := n.runs[min]; run.n > 0 {
+			e, err := n.next(run)
+			if err != nil {
+				return errors.WithContextf(err, "reading spill file for sort node %v", n.ID())
+			}
+			heads[min] = e
+		}
+	}
+	return n.Node.FinishBundle(ctx)
+}
+
+// Down removes any spill files and brings down the wrapped node.
+func (n *SortByTimestamp) Down(ctx context.Context) error {
+	n.reset()
+	return n.Node.Down(ctx)
+}
+
+// reset clears the buffer and removes any spill files.
+func (n *SortByTimestamp) reset() {
+	for _, run := range n.runs {
+		run.f.Close()
+		os.Remove(run.f.Name())
+	}
+	n.runs = nil
+	n.windows = nil
+	n.keys = nil
+	n.buf = nil
+}
+
+func (n *SortByTimestamp) String() string {
+	return fmt.Sprintf("SortByTimestamp[%v, spill:%q, by key %v]. Node:%v", n.MaxBuffer, n.SpillDir, n.ByKey, n.Node)
+}
+
+// WithKeyOrdering makes UnmarshalPlan pass the elements of each bundle to the
+// ParDos of the given transforms, by unique name, grouped by key and sorted
+// by timestamp, for stateful DoFns that expect the elements of a key to be
+// contiguous. Since the bundle is buffered, at most maxBuffer elements are
+// held in memory per transform, and the rest are spilled to spillDir, or the
+// default directory for temporary files if empty.
+func WithKeyOrdering(maxBuffer int, spillDir string, transforms ...string) BuildOption {
+	return func(b *builder) {
+		if b.keyOrdering == nil {
+			b.keyOrdering = make(map[string]bool)
+		}
+		for _, t := range transforms {
+			b.keyOrdering[t] = true
+		}
+		b.keyOrderingBuffer = maxBuffer
+		b.keyOrderingDir = spillDir
+		if spillDir == "" {
+			b.keyOrderingDir = os.TempDir()
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TestSortByTimestamp verifies that elements are sorted by timestamp within
+// each window, both in memory and when spilling.
+func TestSortByTimestamp(t *testing.T) {
+	w1 := window.IntervalWindow{Start: 0, End: 100}
+	w2 := window.IntervalWindow{Start: 100, End: 200}
+	elm := func(k string, v int64, ts mtime.Time, ws ...typex.Window) MainInput {
+		return MainInput{Key: FullValue{Elm: k, Elm2: v, Timestamp: ts, Windows: ws}}
+	}
+	in := []MainInput{
+		elm("a", 1, 50, w1),
+		elm("b", 2, 150, w2),
+		elm("c", 3, 10, w1),
+		elm("d", 4, 120, w2),
+		elm("e", 5, 10, w1),
+		elm("f", 6, 30, w1, w2),
+	}
+	want := []FullValue{
+		{Elm: "c", Elm2: int64(3), Timestamp: 10, Windows: []typex.Window{w1}},
+		{Elm: "e", Elm2: int64(5), Timestamp: 10, Windows: []typex.Window{w1}},
+		{Elm: "f", Elm2: int64(6), Timestamp: 30, Windows: []typex.Window{w1}},
+		{Elm: "a", Elm2: int64(1), Timestamp: 50, Windows: []typex.Window{w1}},
+		{Elm: "f", Elm2: int64(6), Timestamp: 30, Windows: []typex.Window{w2}},
+		{Elm: "d", Elm2: int64(4), Timestamp: 120, Windows: []typex.Window{w2}},
+		{Elm: "b", Elm2: int64(2), Timestamp: 150, Windows: []typex.Window{w2}},
+	}
+
+	dir, err := ioutil.TempDir("", "sort")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		maxBuffer int
+		spillDir  string
+	}{
+		{name: "memory", maxBuffer: 10},
+		{name: "spill", maxBuffer: 2, spillDir: dir},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewSortByTimestamp(out, test.maxBuffer)
+			n.SpillDir = test.spillDir
+			n.Coder = coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()}), coder.NewIntervalWindow())
+			root := &FixedRoot{UID: 2, Elements: in, Out: n}
+
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if !equalList(out.Elements, want) {
+				t.Errorf("sort node passed %v, want %v", out.Elements, want)
+			}
+			if err := p.Down(context.Background()); err != nil {
+				t.Fatalf("down failed: %v", err)
+			}
+			if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+				t.Errorf("spill files left behind: %v", len(files))
+			}
+		})
+	}
+
+	t.Run("overflow", func(t *testing.T) {
+		n := NewSortByTimestamp(&CaptureNode{UID: 1}, 2)
+		root := &FixedRoot{UID: 2, Elements: in, Out: n}
+		p, err := NewPlan("a", []Unit{root, n})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		err = p.Execute(context.Background(), "1", DataContext{})
+		if err == nil || !strings.Contains(err.Error(), "no spill directory") {
+			t.Errorf("execute = %v, want buffer overflow error", err)
+		}
+	})
+}
+
+// TestSortByKey verifies that elements are grouped by key and window, and
+// sorted by timestamp within each group, both in memory and when spilling.
+func TestSortByKey(t *testing.T) {
+	w1 := window.IntervalWindow{Start: 0, End: 100}
+	w2 := window.IntervalWindow{Start: 100, End: 200}
+	elm := func(k string, v int64, ts mtime.Time, ws ...typex.Window) MainInput {
+		return MainInput{Key: FullValue{Elm: k, Elm2: v, Timestamp: ts, Windows: ws}}
+	}
+	in := []MainInput{
+		elm("a", 1, 50, w1),
+		elm("b", 2, 40, w1),
+		elm("a", 3, 10, w1),
+		elm("b", 4, 20, w1),
+		elm("a", 5, 30, w1, w2),
+	}
+	want := []FullValue{
+		{Elm: "a", Elm2: int64(3), Timestamp: 10, Windows: []typex.Window{w1}},
+		{Elm: "a", Elm2: int64(5), Timestamp: 30, Windows: []typex.Window{w1}},
+		{Elm: "a", Elm2: int64(1), Timestamp: 50, Windows: []typex.Window{w1}},
+		{Elm: "b", Elm2: int64(4), Timestamp: 20, Windows: []typex.Window{w1}},
+		{Elm: "b", Elm2: int64(2), Timestamp: 40, Windows: []typex.Window{w1}},
+		{Elm: "a", Elm2: int64(5), Timestamp: 30, Windows: []typex.Window{w2}},
+	}
+	c := coder.NewW(coder.NewKV([]*coder.Coder{coder.NewString(), coder.NewVarInt()}), coder.NewIntervalWindow())
+
+	dir, err := ioutil.TempDir("", "sort")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		maxBuffer int
+		spillDir  string
+	}{
+		{name: "memory", maxBuffer: 10},
+		{name: "spill", maxBuffer: 2, spillDir: dir},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &CaptureNode{UID: 1}
+			n := NewSortByKey(out, c, test.maxBuffer)
+			n.SpillDir = test.spillDir
+			root := &FixedRoot{UID: 2, Elements: in, Out: n}
+
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+			if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if !equalList(out.Elements, want) {
+				t.Errorf("sort node passed %v, want %v", out.Elements, want)
+			}
+			if err := p.Down(context.Background()); err != nil {
+				t.Fatalf("down failed: %v", err)
+			}
+			if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+				t.Errorf("spill files left behind: %v", len(files))
+			}
+		})
+	}
+
+	t.Run("unkeyed", func(t *testing.T) {
+		n := NewSortByKey(&CaptureNode{UID: 1}, coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow()), 2)
+		root := &FixedRoot{UID: 2, Elements: makeInput(int64(1)), Out: n}
+		p, err := NewPlan("a", []Unit{root, n})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		err = p.Execute(context.Background(), "1", DataContext{})
+		if err == nil || !strings.Contains(err.Error(), "no windowed KV coder") {
+			t.Errorf("execute = %v, want coder error", err)
+		}
+	})
+}
@@ -0,0 +1,213 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+var (
+	stateCacheHits   = metrics.NewCounter("exec", "stateCache.hits")
+	stateCacheMisses = metrics.NewCounter("exec", "stateCache.misses")
+)
+
+// WithStateCache returns a context in which plans cache state read from the
+// runner across bundles, up to maxBytes of encoded values. Only state covered
+// by a cache token of the bundle is cached, and cached values are used only in
+// bundles with the same token. Side inputs are thus cached; iterables are
+// specific to a bundle and never are.
+//
+// TODO: cache user state, once the SDK reads and writes it.
+func WithStateCache(ctx context.Context, maxBytes int64) context.Context {
+	return context.WithValue(ctx, stateCacheKey, maxBytes)
+}
+
+func getStateCacheSize(ctx context.Context) int64 {
+	v, _ := ctx.Value(stateCacheKey).(int64)
+	return v
+}
+
+// CacheToken is a token given by the runner for a bundle, under which state
+// may be cached. Cached state is valid for as long as the runner gives the
+// same token.
+type CacheToken struct {
+	// TransformID and SideInputID identify the side input the token applies
+	// to. They are empty for a user state token.
+	TransformID, SideInputID string
+	Token                    []byte
+}
+
+// StateKey identifies a cached state value.
+type StateKey struct {
+	Stream StreamID
+	// ID is the side input ID, for side inputs.
+	ID                 string
+	Token, Window, Key string
+}
+
+// StateCache is a least-recently-used cache of encoded state values. Its
+// size is bounded by the total length of the cached values. It is safe for
+// concurrent use.
+type StateCache struct {
+	MaxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // Cached *stateCacheEntry, most recently used first.
+	entries map[StateKey]*list.Element
+}
+
+type stateCacheEntry struct {
+	key  StateKey
+	data []byte
+}
+
+// NewStateCache returns an empty state cache that holds at most maxBytes of
+// encoded values.
+func NewStateCache(maxBytes int64) *StateCache {
+	return &StateCache{MaxBytes: maxBytes, lru: list.New(), entries: make(map[StateKey]*list.Element)}
+}
+
+// Get returns the cached value of the given state, if present.
+func (c *StateCache) Get(k StateKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*stateCacheEntry).data, true
+}
+
+// Put caches the value of the given state, evicting the least recently used
+// values as needed. Values larger than MaxBytes are not cached.
+func (c *StateCache) Put(k StateKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(k)
+	if int64(len(data)) > c.MaxBytes {
+		return
+	}
+	c.entries[k] = c.lru.PushFront(&stateCacheEntry{key: k, data: data})
+	c.size += int64(len(data))
+	for c.size > c.MaxBytes {
+		c.remove(c.lru.Back().Value.(*stateCacheEntry).key)
+	}
+}
+
+// Invalidate removes the cached value of the given state. It must be called
+// whenever the state is written.
+func (c *StateCache) Invalidate(k StateKey) {
+	c.mu.Lock()
+	c.remove(k)
+	c.mu.Unlock()
+}
+
+// Size returns the total length of the cached values.
+func (c *StateCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *StateCache) remove(k StateKey) {
+	if e, ok := c.entries[k]; ok {
+		c.size -= int64(len(c.lru.Remove(e).(*stateCacheEntry).data))
+		delete(c.entries, k)
+	}
+}
+
+// sideInputToken identifies the side input of a cache token.
+type sideInputToken struct {
+	transformID, sideInputID string
+}
+
+// cachedStateReader is a StateReader that serves side input reads covered by
+// a cache token from a StateCache, and caches the values it reads from the
+// runner.
+type cachedStateReader struct {
+	StateReader
+	cache  *StateCache
+	tokens map[sideInputToken]string
+}
+
+func newCachedStateReader(r StateReader, cache *StateCache, tokens []CacheToken) *cachedStateReader {
+	ret := &cachedStateReader{StateReader: r, cache: cache, tokens: make(map[sideInputToken]string)}
+	for _, t := range tokens {
+		if t.SideInputID != "" {
+			ret.tokens[sideInputToken{t.TransformID, t.SideInputID}] = string(t.Token)
+		}
+	}
+	return ret
+}
+
+// OpenSideInput returns the cached side input value, or reads it from the
+// runner.
+func (s *cachedStateReader) OpenSideInput(ctx context.Context, id StreamID, sideInputID string, key, w []byte) (io.ReadCloser, error) {
+	read := func() (io.ReadCloser, error) {
+		return s.StateReader.OpenSideInput(ctx, id, sideInputID, key, w)
+	}
+	token, ok := s.tokens[sideInputToken{id.PtransformID, sideInputID}]
+	if !ok {
+		return read()
+	}
+	k := StateKey{Stream: id, ID: sideInputID, Token: token, Window: string(w), Key: string(key)}
+	if data, ok := s.cache.Get(k); ok {
+		stateCacheHits.Inc(ctx, 1)
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	stateCacheMisses.Inc(ctx, 1)
+	r, err := read()
+	if err != nil {
+		return nil, err
+	}
+	return &cachingReadCloser{ReadCloser: r, put: func(data []byte) { s.cache.Put(k, data) }, max: s.cache.MaxBytes}, nil
+}
+
+// cachingReadCloser records the bytes read, and caches them once the stream
+// has been read completely. Streams longer than max are not recorded.
+type cachingReadCloser struct {
+	io.ReadCloser
+	put  func(data []byte)
+	max  int64
+	buf  bytes.Buffer
+	skip bool
+}
+
+func (r *cachingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if !r.skip {
+		if int64(r.buf.Len()+n) > r.max {
+			r.skip = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !r.skip {
+		r.put(r.buf.Bytes())
+		r.skip = true
+	}
+	return n, err
+}
@@ -0,0 +1,191 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// countingStateReader serves the transform ID and key of each side input as
+// its value, and counts the reads.
+type countingStateReader struct {
+	reads int
+}
+
+func (s *countingStateReader) OpenSideInput(ctx context.Context, id StreamID, sideInputID string, key, w []byte) (io.ReadCloser, error) {
+	s.reads++
+	return ioutil.NopCloser(strings.NewReader(id.PtransformID + string(key))), nil
+}
+
+func (s *countingStateReader) OpenIterable(ctx context.Context, id StreamID, key []byte) (io.ReadCloser, error) {
+	s.reads++
+	return ioutil.NopCloser(strings.NewReader(string(key))), nil
+}
+
+// stateReadRoot is a test Root that reads side input "side" of each key.
+type stateReadRoot struct {
+	UID    UnitID
+	Stream StreamID
+	Keys   []string
+	Got    []string
+
+	state StateReader
+}
+
+func (n *stateReadRoot) ID() UnitID                   { return n.UID }
+func (n *stateReadRoot) Up(ctx context.Context) error { return nil }
+func (n *stateReadRoot) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.state = data.State
+	n.Got = nil
+	return nil
+}
+func (n *stateReadRoot) Process(ctx context.Context) error {
+	for _, k := range n.Keys {
+		r, err := n.state.OpenSideInput(ctx, n.Stream, "side", []byte(k), nil)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		n.Got = append(n.Got, string(b))
+	}
+	return nil
+}
+func (n *stateReadRoot) FinishBundle(ctx context.Context) error { return nil }
+func (n *stateReadRoot) Down(ctx context.Context) error         { return nil }
+
+func sideInputTokens(token string, transformIDs ...string) []CacheToken {
+	var ret []CacheToken
+	for _, id := range transformIDs {
+		ret = append(ret, CacheToken{TransformID: id, SideInputID: "side", Token: []byte(token)})
+	}
+	return ret
+}
+
+// TestPlanStateCache verifies that state read in one bundle is served from
+// the cache in the next with the same cache token, and that hits and misses
+// are counted.
+func TestPlanStateCache(t *testing.T) {
+	state := &countingStateReader{}
+	root := &stateReadRoot{UID: 1, Stream: StreamID{PtransformID: "p"}, Keys: []string{"a", "b", "a"}}
+	p, err := NewPlan("a", []Unit{root})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	execute := func(tokens []CacheToken) {
+		t.Helper()
+		ctx := WithStateCache(context.Background(), 1<<20)
+		if err := p.Execute(ctx, "1", DataContext{State: state, CacheTokens: tokens}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if got, want := strings.Join(root.Got, ","), "pa,pb,pa"; got != want {
+			t.Errorf("read values %v, want %v", got, want)
+		}
+	}
+
+	for i, want := range []map[string]int64{
+		{"exec.stateCache.hits": 1, "exec.stateCache.misses": 2},
+		{"exec.stateCache.hits": 3},
+	} {
+		execute(sideInputTokens("t1", "p"))
+		got := make(map[string]int64)
+		metrics.Extractor{
+			SumInt64: func(l metrics.Labels, v int64) {
+				got[l.Namespace()+"."+l.Name()] = v
+			},
+		}.ExtractFrom(p.Store())
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("bundle %v: %v = %v, want %v", i, k, got[k], v)
+			}
+		}
+	}
+	if state.reads != 2 {
+		t.Errorf("runner reads = %v, want 2", state.reads)
+	}
+
+	p.StateCache().Invalidate(StateKey{Stream: root.Stream, ID: "side", Token: "t1", Key: "a"})
+	execute(sideInputTokens("t1", "p"))
+	if state.reads != 3 {
+		t.Errorf("runner reads after invalidation = %v, want 3", state.reads)
+	}
+
+	execute(sideInputTokens("t2", "p"))
+	if state.reads != 5 {
+		t.Errorf("runner reads with a new cache token = %v, want 5", state.reads)
+	}
+
+	execute(nil)
+	if state.reads != 8 {
+		t.Errorf("runner reads without a cache token = %v, want 8", state.reads)
+	}
+}
+
+// TestPlanStateCache_transforms verifies that side inputs with the same ID
+// in different transforms are cached separately.
+func TestPlanStateCache_transforms(t *testing.T) {
+	state := &countingStateReader{}
+	root1 := &stateReadRoot{UID: 1, Stream: StreamID{PtransformID: "p1"}, Keys: []string{"a"}}
+	root2 := &stateReadRoot{UID: 2, Stream: StreamID{PtransformID: "p2"}, Keys: []string{"a"}}
+	p, err := NewPlan("a", []Unit{root1, root2})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		ctx := WithStateCache(context.Background(), 1<<20)
+		if err := p.Execute(ctx, "1", DataContext{State: state, CacheTokens: sideInputTokens("t", "p1", "p2")}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if got, want := strings.Join(append(root1.Got, root2.Got...), ","), "p1a,p2a"; got != want {
+			t.Errorf("bundle %v: read values %v, want %v", i, got, want)
+		}
+	}
+	if state.reads != 2 {
+		t.Errorf("runner reads = %v, want 2", state.reads)
+	}
+}
+
+// TestStateCache_eviction verifies that the cache is bounded by the length
+// of its values, evicting the least recently used first.
+func TestStateCache_eviction(t *testing.T) {
+	key := func(k string) StateKey { return StateKey{ID: "s", Key: k} }
+	c := NewStateCache(6)
+	c.Put(key("a"), []byte("aaa"))
+	c.Put(key("b"), []byte("bbb"))
+	c.Get(key("a"))
+	c.Put(key("c"), []byte("ccc"))
+	if _, ok := c.Get(key("b")); ok {
+		t.Errorf("least recently used value not evicted")
+	}
+	if _, ok := c.Get(key("a")); !ok {
+		t.Errorf("recently used value evicted")
+	}
+	c.Put(key("d"), []byte("toolarge"))
+	if _, ok := c.Get(key("d")); ok {
+		t.Errorf("value larger than the cache was cached")
+	}
+	if got, want := c.Size(), int64(6); got != want {
+		t.Errorf("Size() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"sort"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// TaggedEmit routes elements to output nodes by tag, so that multi-output
+// nodes can address their outputs by name instead of position. It only
+// routes elements: the outputs are started and finished by the node using it.
+type TaggedEmit struct {
+	outputs map[string]Node
+}
+
+// NewTaggedEmit returns a router to the given outputs, keyed by tag.
+func NewTaggedEmit(outputs map[string]Node) *TaggedEmit {
+	return &TaggedEmit{outputs: outputs}
+}
+
+// TaggedOutputs returns the given outputs of a transform keyed by the local
+// output IDs assigned by graph translation, "i0" for the first output, "i1"
+// for the second, and so on.
+func TaggedOutputs(out []Node) map[string]Node {
+	ret := make(map[string]Node, len(out))
+	for i, n := range out {
+		ret[indexToInputId(i)] = n
+	}
+	return ret
+}
+
+// Tags returns the known tags in sorted order.
+func (e *TaggedEmit) Tags() []string {
+	var tags []string
+	for tag := range e.outputs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Output returns the node for the given tag. Resolving the node once and
+// emitting to it directly avoids the lookup per element.
+func (e *TaggedEmit) Output(tag string) (Node, error) {
+	n, ok := e.outputs[tag]
+	if !ok {
+		return nil, errors.Errorf("unknown output tag %q, available tags: %v", tag, e.Tags())
+	}
+	return n, nil
+}
+
+// Emit passes the element to the output with the given tag.
+func (e *TaggedEmit) Emit(ctx context.Context, tag string, elm *FullValue, values ...ReStream) error {
+	n, err := e.Output(tag)
+	if err != nil {
+		return err
+	}
+	return n.ProcessElement(ctx, elm, values...)
+}
@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestTaggedEmit verifies that elements are routed by tag, and that unknown
+// tags fail with the available tags.
+func TestTaggedEmit(t *testing.T) {
+	ctx := context.Background()
+	main := &CaptureNode{UID: 1}
+	errs := &CaptureNode{UID: 2}
+	e := NewTaggedEmit(map[string]Node{"main": main, "errors": errs})
+	for _, n := range []Node{main, errs} {
+		if err := n.Up(ctx); err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("StartBundle failed: %v", err)
+		}
+	}
+
+	for _, elm := range []struct {
+		tag string
+		v   int
+	}{{"main", 1}, {"errors", 2}, {"main", 3}} {
+		if err := e.Emit(ctx, elm.tag, &FullValue{Elm: elm.v}); err != nil {
+			t.Fatalf("Emit(%v, %v) failed: %v", elm.tag, elm.v, err)
+		}
+	}
+	if want := makeValuesNoWindowOrTime(1, 3); !equalList(main.Elements, want) {
+		t.Errorf("main got %v, want %v", extractValues(main.Elements...), extractValues(want...))
+	}
+	if want := makeValuesNoWindowOrTime(2); !equalList(errs.Elements, want) {
+		t.Errorf("errors got %v, want %v", extractValues(errs.Elements...), extractValues(want...))
+	}
+
+	err := e.Emit(ctx, "other", &FullValue{Elm: 4})
+	if err == nil || !strings.Contains(err.Error(), `unknown output tag "other", available tags: [errors main]`) {
+		t.Errorf("Emit(other) = %v, want unknown tag error", err)
+	}
+}
+
+// TestTaggedOutputs verifies that outputs are tagged like in graph
+// translation.
+func TestTaggedOutputs(t *testing.T) {
+	out := []Node{&CaptureNode{UID: 1}, &CaptureNode{UID: 2}}
+	e := NewTaggedEmit(TaggedOutputs(out))
+	for i, tag := range []string{"i0", "i1"} {
+		n, err := e.Output(tag)
+		if err != nil {
+			t.Fatalf("Output(%v) failed: %v", tag, err)
+		}
+		if n != out[i] {
+			t.Errorf("Output(%v) = %v, want %v", tag, n.ID(), out[i].ID())
+		}
+	}
+}
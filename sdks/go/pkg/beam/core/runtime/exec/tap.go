@@ -0,0 +1,175 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// Tap observes the elements passed to a node of a plan, for example to
+// track data lineage. Observe is called on the element path before the
+// element is passed on, so it should return quickly. It may be called
+// concurrently, if the tap is installed on several nodes or plans that
+// execute concurrently, and must not modify or retain the element.
+type Tap interface {
+	Observe(uid UnitID, elm *FullValue)
+}
+
+// tapNode is an identity node that calls the installed taps before passing
+// elements to the wrapped node.
+type tapNode struct {
+	Node
+
+	mu   sync.RWMutex
+	taps []Tap
+}
+
+func (n *tapNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.mu.RLock()
+	for _, t := range n.taps {
+		t.Observe(n.ID(), elm)
+	}
+	n.mu.RUnlock()
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *tapNode) add(t Tap) {
+	n.mu.Lock()
+	n.taps = append(n.taps, t)
+	n.mu.Unlock()
+}
+
+func (n *tapNode) remove(t Tap) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, v := range n.taps {
+		if v == t {
+			n.taps = append(n.taps[:i:i], n.taps[i+1:]...)
+			return
+		}
+	}
+}
+
+func (n *tapNode) String() string {
+	return fmt.Sprintf("Tap. Node:%v", n.Node)
+}
+
+// InstallTap installs the tap on the node with the given ID, so that it
+// observes every element passed to the node. It returns a function that
+// removes the tap again.
+//
+// The first tap on a node inserts an interceptor between the node and the
+// nodes feeding it, which are found in the exported Node and []Node fields of
+// the units of the plan. It must therefore be installed before the plan is
+// first executed, while further taps on the same node may be installed at
+// any time. Plans without taps are unaffected, and a node whose taps have all
+// been removed only incurs the cost of the interceptor.
+func InstallTap(p *Plan, uid UnitID, tap Tap) (func(), error) {
+	if n, ok := p.taps[uid]; ok {
+		n.add(tap)
+		return func() { n.remove(tap) }, nil
+	}
+	if p.status != Initializing {
+		return nil, errors.Errorf("cannot install first tap on node %v of plan %v: plan already executed", uid, p.id)
+	}
+
+	var target Node
+	for _, u := range p.units {
+		if u.ID() == uid {
+			n, ok := u.(Node)
+			if !ok {
+				return nil, errors.Errorf("cannot install tap on unit %v of plan %v: not a Node", uid, p.id)
+			}
+			target = n
+			break
+		}
+	}
+	if target == nil {
+		return nil, errors.Errorf("cannot install tap on node %v of plan %v: no such node", uid, p.id)
+	}
+
+	n := &tapNode{Node: target, taps: []Tap{tap}}
+	if replaced := replaceNode(p.units, target, n); replaced == 0 {
+		return nil, errors.Errorf("cannot install tap on node %v of plan %v: no node feeds it", uid, p.id)
+	}
+	if p.taps == nil {
+		p.taps = make(map[UnitID]*tapNode)
+	}
+	p.taps[uid] = n
+	return func() { n.remove(tap) }, nil
+}
+
+var (
+	nodeType      = reflect.TypeOf((*Node)(nil)).Elem()
+	nodeSliceType = reflect.SliceOf(nodeType)
+)
+
+// replaceNode replaces all references to old in the exported Node and []Node
+// fields of the given units with new. It returns the number of references
+// replaced.
+func replaceNode(units []Unit, old, new Node) int {
+	count := 0
+	for _, u := range units {
+		for _, f := range nodeFields(reflect.ValueOf(u)) {
+			switch f.Type() {
+			case nodeType:
+				if !f.IsNil() && f.Interface() == old {
+					f.Set(reflect.ValueOf(new))
+					count++
+				}
+			case nodeSliceType:
+				for j := 0; j < f.Len(); j++ {
+					if e := f.Index(j); !e.IsNil() && e.Interface() == old {
+						e.Set(reflect.ValueOf(new))
+						count++
+					}
+				}
+			}
+		}
+	}
+	return count
+}
+
+// nodeFields returns the exported Node and []Node fields of the struct v
+// points to, including those of embedded structs, such as the Combine of a
+// LiftedCombine.
+func nodeFields(v reflect.Value) []reflect.Value {
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || !v.CanAddr() {
+		return nil
+	}
+	var ret []reflect.Value
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch {
+		case f.Type() == nodeType || f.Type() == nodeSliceType:
+			ret = append(ret, f)
+		case v.Type().Field(i).Anonymous:
+			ret = append(ret, nodeFields(f)...)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,86 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+)
+
+// recordTap is a test Tap that records observed elements.
+type recordTap struct {
+	uids []UnitID
+	elms []FullValue
+}
+
+func (t *recordTap) Observe(uid UnitID, elm *FullValue) {
+	t.uids = append(t.uids, uid)
+	t.elms = append(t.elms, *elm)
+}
+
+// TestInstallTap verifies that taps observe elements passed to a node until
+// removed, and that the elements still reach the node.
+func TestInstallTap(t *testing.T) {
+	out := &CaptureNode{UID: 1}
+	in := &FixedRoot{UID: 2, Elements: makeInput(1, 2), Out: out}
+	p, err := NewPlan("a", []Unit{out, in})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+
+	if _, err := InstallTap(p, 2, &recordTap{}); err == nil {
+		t.Error("InstallTap on root succeeded, want error")
+	}
+	if _, err := InstallTap(p, 3, &recordTap{}); err == nil {
+		t.Error("InstallTap on missing node succeeded, want error")
+	}
+
+	first := &recordTap{}
+	removeFirst, err := InstallTap(p, 1, first)
+	if err != nil {
+		t.Fatalf("InstallTap failed: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(1, 2); !equalList(first.elms, want) {
+		t.Errorf("tap observed %v, want %v", extractValues(first.elms...), extractValues(want...))
+	}
+	for _, uid := range first.uids {
+		if uid != 1 {
+			t.Errorf("tap observed element at node %v, want 1", uid)
+		}
+	}
+
+	// Further taps may be added after execution.
+	second := &recordTap{}
+	if _, err := InstallTap(p, 1, second); err != nil {
+		t.Fatalf("InstallTap of second tap failed: %v", err)
+	}
+	removeFirst()
+	if err := p.Execute(context.Background(), "2", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got, want := len(first.elms), 2; got != want {
+		t.Errorf("removed tap observed %v elements, want %v", got, want)
+	}
+	if got, want := len(second.elms), 2; got != want {
+		t.Errorf("second tap observed %v elements, want %v", got, want)
+	}
+	if got, want := len(out.Elements), 4; got != want {
+		t.Errorf("node received %v elements, want %v", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// ElementTimeoutError indicates that processing an element took longer than
+// allowed by a TimeoutNode.
+type ElementTimeoutError struct {
+	// DoFn is the name of the DoFn processing the element, or the ID of the
+	// node if it isn't a ParDo.
+	DoFn      string
+	Timestamp typex.EventTime
+	Timeout   time.Duration
+}
+
+func (e *ElementTimeoutError) Error() string {
+	return fmt.Sprintf("processing element at %v in %v timed out after %v", e.Timestamp, e.DoFn, e.Timeout)
+}
+
+// TimeoutNode wraps a node and fails the bundle with an ElementTimeoutError if
+// processing a single element takes longer than PerElement. Each element is
+// processed in a separate goroutine, which the node stops waiting for on
+// timeout. The goroutine exits once the element is eventually processed, but it
+// may then still run concurrently with the rest of the failed bundle, including
+// FinishBundle and Down of the wrapped node. The wrapped node and its DoFn must
+// therefore be safe for concurrent use. Other calls are made on the wrapped
+// node directly.
+type TimeoutNode struct {
+	Node
+	PerElement time.Duration
+}
+
+// NewTimeoutNode returns a node that passes elements to out, failing if out
+// takes longer than perElement to process one.
+func NewTimeoutNode(out Node, perElement time.Duration) *TimeoutNode {
+	return &TimeoutNode{Node: out, PerElement: perElement}
+}
+
+// ProcessElement passes the element to the wrapped node and waits for at most
+// PerElement for it to be processed.
+func (n *TimeoutNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	// The element is owned by the caller, so the goroutine needs its own copy
+	// in case it outlives the call.
+	v := *elm
+	done := make(chan error, 1) // Buffered, so an abandoned goroutine can exit.
+	go func() {
+		done <- callNoPanic(ctx, func(ctx context.Context) error {
+			return n.Node.ProcessElement(ctx, &v, values...)
+		})
+	}()
+
+	t := time.NewTimer(n.PerElement)
+	defer t.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-t.C:
+		return &ElementTimeoutError{DoFn: n.name(), Timestamp: elm.Timestamp, Timeout: n.PerElement}
+	}
+}
+
+// name returns the name of the DoFn of the wrapped node, if any.
+func (n *TimeoutNode) name() string {
+	if p, ok := n.Node.(*ParDo); ok {
+		return p.Fn.Name()
+	}
+	return fmt.Sprintf("node %v", n.ID())
+}
+
+func (n *TimeoutNode) String() string {
+	return fmt.Sprintf("TimeoutNode[%v]. Node:%v", n.PerElement, n.Node)
+}
@@ -0,0 +1,79 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+)
+
+// hangingFn is a DoFn that blocks on the element 2 until released.
+type hangingFn struct {
+	release  chan struct{}
+	returned chan struct{}
+}
+
+func (fn *hangingFn) ProcessElement(v int, emit func(int)) {
+	if v == 2 {
+		<-fn.release
+		close(fn.returned)
+		return
+	}
+	emit(v)
+}
+
+// TestTimeoutNode verifies that an element exceeding the timeout fails the
+// bundle with the DoFn and timestamp, and that the abandoned invocation exits
+// once the DoFn returns.
+func TestTimeoutNode(t *testing.T) {
+	fn := &hangingFn{release: make(chan struct{}), returned: make(chan struct{})}
+	dfn, err := graph.NewDoFn(fn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	n := NewTimeoutNode(&ParDo{UID: 2, Fn: dfn, Out: []Node{out}}, 20*time.Millisecond)
+	root := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: n}
+
+	p, err := NewPlan("a", []Unit{root, n, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	err = p.Execute(context.Background(), "1", DataContext{})
+	var timeout *ElementTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("execute = %v, want ElementTimeoutError", err)
+	}
+	want := ElementTimeoutError{DoFn: dfn.Name(), Timestamp: mtime.ZeroTimestamp, Timeout: 20 * time.Millisecond}
+	if *timeout != want {
+		t.Errorf("execute = %+v, want %+v", *timeout, want)
+	}
+	if want := makeValues(1); !equalList(out.Elements, want) {
+		t.Errorf("ParDo emitted %v, want %v", extractValues(out.Elements...), extractValues(want...))
+	}
+
+	close(fn.release)
+	select {
+	case <-fn.returned:
+	case <-time.After(time.Second):
+		t.Error("abandoned invocation didn't return")
+	}
+}
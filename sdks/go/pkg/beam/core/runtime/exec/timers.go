@@ -0,0 +1,156 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"sort"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+)
+
+// TimeDomain is the time domain of a timer.
+type TimeDomain int
+
+const (
+	// EventTimeDomain timers fire when the watermark passes their fire time.
+	EventTimeDomain TimeDomain = iota
+	// ProcessingTimeDomain timers fire when the wall clock passes their fire time.
+	ProcessingTimeDomain
+)
+
+// Timer is a timer set by a DoFn for a key and window.
+type Timer struct {
+	Family   string
+	Domain   TimeDomain
+	Key      []byte // Encoded key.
+	Window   typex.Window
+	FireTime mtime.Time
+	Tag      string
+}
+
+// CoalescedTimer is a set of identical timers, to be delivered in a single
+// OnTimer invocation.
+type CoalescedTimer struct {
+	Timer
+	// Count is the number of timers set.
+	Count int
+	// Tags holds the distinct tags of the timers, in the order they were set.
+	Tags []string
+
+	seq int
+}
+
+// timerID identifies identical timers, except for the window.
+type timerID struct {
+	family, key string
+	fire        mtime.Time
+}
+
+// TimerCoalescer collects timers and delivers timers with identical family,
+// key, window and fire time only once. Each time domain is kept separately.
+// It is not safe for concurrent use.
+type TimerCoalescer struct {
+	pending map[TimeDomain]map[timerID][]*CoalescedTimer
+	seq     int
+}
+
+// NewTimerCoalescer returns a TimerCoalescer without pending timers.
+func NewTimerCoalescer() *TimerCoalescer {
+	return &TimerCoalescer{pending: make(map[TimeDomain]map[timerID][]*CoalescedTimer)}
+}
+
+// Add adds a timer, coalescing it with an identical pending timer, if any.
+func (c *TimerCoalescer) Add(t Timer) {
+	timers, ok := c.pending[t.Domain]
+	if !ok {
+		timers = make(map[timerID][]*CoalescedTimer)
+		c.pending[t.Domain] = timers
+	}
+	id := timerID{family: t.Family, key: string(t.Key), fire: t.FireTime}
+	for _, ct := range timers[id] {
+		if ct.Window.Equals(t.Window) {
+			ct.Count++
+			ct.addTag(t.Tag)
+			return
+		}
+	}
+	ct := &CoalescedTimer{Timer: t, Count: 1, seq: c.seq}
+	ct.addTag(t.Tag)
+	c.seq++
+	timers[id] = append(timers[id], ct)
+}
+
+func (ct *CoalescedTimer) addTag(tag string) {
+	for _, t := range ct.Tags {
+		if t == tag {
+			return
+		}
+	}
+	ct.Tags = append(ct.Tags, tag)
+}
+
+// Len returns the number of pending coalesced timers in the given domain.
+func (c *TimerCoalescer) Len(d TimeDomain) int {
+	n := 0
+	for _, cts := range c.pending[d] {
+		n += len(cts)
+	}
+	return n
+}
+
+// Fire delivers the pending timers of the given domain with a fire time of at
+// most upTo to fn, ordered by fire time and then by the order in which they
+// were first set. Delivered timers are removed. On the first error, the
+// remaining timers stay pending and the error is returned.
+func (c *TimerCoalescer) Fire(d TimeDomain, upTo mtime.Time, fn func(ct *CoalescedTimer) error) error {
+	timers := c.pending[d]
+	var due []*CoalescedTimer
+	for id, cts := range timers {
+		if id.fire <= upTo {
+			due = append(due, cts...)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].FireTime != due[j].FireTime {
+			return due[i].FireTime < due[j].FireTime
+		}
+		return due[i].seq < due[j].seq
+	})
+	for _, ct := range due {
+		if err := fn(ct); err != nil {
+			return err
+		}
+		c.remove(timers, ct)
+	}
+	return nil
+}
+
+func (c *TimerCoalescer) remove(timers map[timerID][]*CoalescedTimer, ct *CoalescedTimer) {
+	id := timerID{family: ct.Family, key: string(ct.Key), fire: ct.FireTime}
+	cts := timers[id]
+	for i, t := range cts {
+		if t == ct {
+			cts = append(cts[:i], cts[i+1:]...)
+			break
+		}
+	}
+	if len(cts) == 0 {
+		delete(timers, id)
+	} else {
+		timers[id] = cts
+	}
+}
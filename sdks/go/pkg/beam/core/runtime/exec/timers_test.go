@@ -0,0 +1,156 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+)
+
+// TestTimerCoalescer_duplicates verifies that identical timers fire once.
+func TestTimerCoalescer_duplicates(t *testing.T) {
+	c := NewTimerCoalescer()
+	for i := 0; i < 1000; i++ {
+		c.Add(Timer{Family: "f", Key: []byte("k"), Window: window.GlobalWindow{}, FireTime: 10, Tag: fmt.Sprintf("t%v", i%3)})
+	}
+	var fired []*CoalescedTimer
+	if err := c.Fire(EventTimeDomain, 10, func(ct *CoalescedTimer) error {
+		fired = append(fired, ct)
+		return nil
+	}); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if len(fired) != 1 {
+		t.Fatalf("fired %v timers, want 1", len(fired))
+	}
+	if fired[0].Count != 1000 {
+		t.Errorf("Count = %v, want 1000", fired[0].Count)
+	}
+	if got := fmt.Sprint(fired[0].Tags); got != "[t0 t1 t2]" {
+		t.Errorf("Tags = %v, want [t0 t1 t2]", got)
+	}
+	if c.Len(EventTimeDomain) != 0 {
+		t.Errorf("Len() = %v after firing, want 0", c.Len(EventTimeDomain))
+	}
+}
+
+// TestTimerCoalescer_ordering verifies that timers are kept apart by domain,
+// family, key, window and fire time, and fire in fire time order.
+func TestTimerCoalescer_ordering(t *testing.T) {
+	c := NewTimerCoalescer()
+	w1 := window.IntervalWindow{Start: 0, End: 10}
+	w2 := window.IntervalWindow{Start: 10, End: 20}
+	c.Add(Timer{Family: "f", Key: []byte("k"), Window: w1, FireTime: 30})
+	c.Add(Timer{Family: "f", Key: []byte("k"), Window: w2, FireTime: 20})
+	c.Add(Timer{Family: "g", Key: []byte("k"), Window: w1, FireTime: 20})
+	c.Add(Timer{Family: "f", Key: []byte("j"), Window: w1, FireTime: 10})
+	c.Add(Timer{Family: "f", Key: []byte("k"), Window: w1, FireTime: 30})
+	c.Add(Timer{Family: "f", Domain: ProcessingTimeDomain, Key: []byte("k"), Window: w1, FireTime: 5})
+
+	var got []string
+	fire := func(ct *CoalescedTimer) error {
+		got = append(got, fmt.Sprintf("%v/%s/%v/%v/%v", ct.Family, ct.Key, ct.Window, ct.FireTime, ct.Count))
+		return nil
+	}
+	if err := c.Fire(EventTimeDomain, 20, fire); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if err := c.Fire(EventTimeDomain, mtime.MaxTimestamp, fire); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	want := fmt.Sprint([]string{
+		fmt.Sprintf("f/j/%v/10/1", w1),
+		fmt.Sprintf("f/k/%v/20/1", w2),
+		fmt.Sprintf("g/k/%v/20/1", w1),
+		fmt.Sprintf("f/k/%v/30/2", w1),
+	})
+	if fmt.Sprint(got) != want {
+		t.Errorf("fired %v, want %v", got, want)
+	}
+	if c.Len(ProcessingTimeDomain) != 1 {
+		t.Errorf("processing time timers = %v, want 1", c.Len(ProcessingTimeDomain))
+	}
+}
+
+// timerFn is a DoFn that emits its elements, and the number of coalesced
+// timers of each firing.
+type timerFn struct {
+	graph.TimerProcessing
+	firings int
+}
+
+func (fn *timerFn) ProcessElement(v int, emit func(int)) {
+	emit(v)
+}
+
+func (fn *timerFn) OnTimer(family string, count int, emit func(int)) {
+	fn.firings++
+	emit(count)
+}
+
+// TestParDo_ProcessTimer verifies that timers delivered to a ParDo are fired
+// once per set of identical timers at the end of the bundle, ordered by time
+// domain and fire time.
+func TestParDo_ProcessTimer(t *testing.T) {
+	fn := &timerFn{}
+	dfn, err := graph.NewDoFn(fn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, Fn: dfn, Out: []Node{out}}
+	ctx := context.Background()
+	for _, u := range []Unit{out, pardo} {
+		if err := u.Up(ctx); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+	}
+	if err := pardo.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("start bundle failed: %v", err)
+	}
+	if err := pardo.ProcessElement(ctx, &FullValue{Elm: 7, Windows: window.SingleGlobalWindow}); err != nil {
+		t.Fatalf("process element failed: %v", err)
+	}
+	timers := []Timer{{Family: "f", Domain: ProcessingTimeDomain, Window: window.GlobalWindow{}, FireTime: 1}}
+	for i := 0; i < 1000; i++ {
+		timers = append(timers, Timer{Family: "f", Key: []byte("k"), Window: window.GlobalWindow{}, FireTime: 10})
+	}
+	timers = append(timers, Timer{Family: "f", Key: []byte("k"), Window: window.GlobalWindow{}, FireTime: 5})
+	for _, tm := range timers {
+		if err := pardo.ProcessTimer(tm); err != nil {
+			t.Fatalf("process timer failed: %v", err)
+		}
+	}
+	if err := pardo.FinishBundle(ctx); err != nil {
+		t.Fatalf("finish bundle failed: %v", err)
+	}
+
+	if got, want := extractValues(out.Elements...), []interface{}{7, 1, 1000, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParDo emitted %v, want %v", got, want)
+	}
+	if got, want := fn.firings, 3; got != want {
+		t.Errorf("OnTimer invoked %v times, want %v", got, want)
+	}
+	if got, want := out.Elements[2].Timestamp, mtime.Time(10); got != want {
+		t.Errorf("timer output timestamp = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+)
+
+// Tracer starts the spans of distributed traces, such as with OpenTelemetry,
+// for which it is implemented by a small adapter of a trace.Tracer. Tracers
+// must be safe for concurrent use.
+type Tracer interface {
+	// StartSpan starts a span with the given name, as a child of the span
+	// in ctx, if any, and returns it with a derived context containing it.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetInt64 records an integer attribute of the span.
+	SetInt64(key string, value int64)
+	// RecordError records an error as an event of the span.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+// SpanOutputElements is the attribute of element spans with the number of
+// elements the DoFn emitted for the element.
+const SpanOutputElements = "beam.output_elements"
+
+// WithTracer returns a context in which ParDos trace each element they
+// process in a span named after their transform, as a child of the span in
+// the bundle context, if any. The span is passed to the DoFn in its context,
+// so that spans started by the DoFn nest beneath it. Without a tracer,
+// elements are not traced at no cost.
+func WithTracer(ctx context.Context, t Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, t)
+}
+
+func getTracer(ctx context.Context) Tracer {
+	t, _ := ctx.Value(tracerKey).(Tracer)
+	return t
+}
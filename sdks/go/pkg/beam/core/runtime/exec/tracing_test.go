@@ -0,0 +1,116 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+type spanKey struct{}
+
+// fakeSpan is a span of a fakeTracer.
+type fakeSpan struct {
+	name   string
+	parent *fakeSpan
+	attrs  map[string]int64
+	err    error
+	ended  bool
+}
+
+func (s *fakeSpan) SetInt64(key string, value int64) { s.attrs[key] = value }
+func (s *fakeSpan) RecordError(err error)            { s.err = err }
+func (s *fakeSpan) End()                             { s.ended = true }
+
+// fakeTracer is a Tracer recording the spans it starts.
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*fakeSpan)
+	s := &fakeSpan{name: name, parent: parent, attrs: make(map[string]int64)}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+var tracer = &fakeTracer{}
+
+// tracedFn emits its input n times in a span of its own, and fails on 3.
+func tracedFn(ctx context.Context, n int, emit func(int)) error {
+	_, span := tracer.StartSpan(ctx, "user")
+	defer span.End()
+	if n == 3 {
+		return errors.New("three")
+	}
+	for i := 0; i < n; i++ {
+		emit(n)
+	}
+	return nil
+}
+
+// TestParDo_tracing verifies that a ParDo traces each element in a span of
+// its transform with the number of outputs and any error, and that the DoFn
+// is passed the span.
+func TestParDo_tracing(t *testing.T) {
+	fn, err := graph.NewDoFn(tracedFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	pardo := &ParDo{UID: 2, PID: "traced", Fn: fn, Out: []Node{out}}
+	root := &FixedRoot{UID: 3, Elements: makeInput(1, 2, 3), Out: pardo}
+	p, err := NewPlan("a", []Unit{root, pardo, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	tracer.spans = nil
+	if err := p.Execute(WithTracer(context.Background(), tracer), "1", DataContext{}); err == nil {
+		t.Fatalf("execute succeeded, want failure on 3")
+	}
+
+	var elements []*fakeSpan
+	for _, s := range tracer.spans {
+		if !s.ended {
+			t.Errorf("span %v was not ended", s.name)
+		}
+		switch s.name {
+		case "traced":
+			if s.parent != nil {
+				t.Errorf("element span has parent %v, want none", s.parent.name)
+			}
+			elements = append(elements, s)
+		case "user":
+			if s.parent == nil || s.parent.name != "traced" {
+				t.Errorf("user span has parent %v, want element span", s.parent)
+			}
+		}
+	}
+	if len(elements) != 3 {
+		t.Fatalf("traced %v elements, want 3", len(elements))
+	}
+	for i, s := range elements[:2] {
+		if got, want := s.attrs[SpanOutputElements], int64(i+1); got != want || s.err != nil {
+			t.Errorf("span of element %v has %v outputs and error %v, want %v and none", i+1, got, s.err, want)
+		}
+	}
+	if elements[2].err == nil {
+		t.Errorf("span of element 3 has no error, want failure")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
@@ -50,11 +51,14 @@ const (
 )
 
 // UnmarshalPlan converts a model bundle descriptor into an execution Plan.
-func UnmarshalPlan(desc *fnpb.ProcessBundleDescriptor) (*Plan, error) {
+func UnmarshalPlan(desc *fnpb.ProcessBundleDescriptor, opts ...BuildOption) (*Plan, error) {
 	b, err := newBuilder(desc)
 	if err != nil {
 		return nil, err
 	}
+	for _, opt := range opts {
+		opt(b)
+	}
 	for id, transform := range desc.GetTransforms() {
 		if transform.GetSpec().GetUrn() != urnDataSource {
 			continue
@@ -68,8 +72,8 @@ func UnmarshalPlan(desc *fnpb.ProcessBundleDescriptor) (*Plan, error) {
 			return nil, err
 		}
 
-		u := &DataSource{UID: b.idgen.New()}
-		u.Coder, err = b.coders.Coder(cid) // Expected to be windowed coder
+		u := &DataSource{UID: b.idgen.New(), Codec: b.compression[id]}
+		u.Coder, err = b.coder(cid) // Expected to be windowed coder
 		if err != nil {
 			return nil, err
 		}
@@ -83,6 +87,8 @@ func UnmarshalPlan(desc *fnpb.ProcessBundleDescriptor) (*Plan, error) {
 			u.SID = StreamID{PtransformID: id, Port: port}
 			u.Name = key
 			u.outputPID = pid
+			u.Unbounded = desc.GetPcollections()[pid].GetIsBounded() == pipepb.IsBounded_UNBOUNDED
+			u.HeartbeatIdle = b.heartbeatIdle
 
 			u.Out, err = b.makePCollection(pid)
 			if err != nil {
@@ -108,6 +114,29 @@ type builder struct {
 
 	units []Unit // result
 	idgen *GenID
+
+	fuseCombines bool // set by WithCombineFusion
+
+	// Set by WithKeyOrdering.
+	keyOrdering       map[string]bool
+	keyOrderingBuffer int
+	keyOrderingDir    string
+
+	compression map[string]StreamCodec // set by WithCompression
+
+	heartbeatIdle time.Duration // set by WithHeartbeat
+
+	groupedSinks map[string]bool // set by WithGroupedSinks
+}
+
+// coder unmarshals the coder with the given id, using registered coders
+// instead of JSON coders where possible.
+func (b *builder) coder(id string) (*coder.Coder, error) {
+	c, err := b.coders.Coder(id)
+	if err != nil {
+		return nil, err
+	}
+	return withRegisteredCoders(c), nil
 }
 
 // linkID represents an incoming data link to an Node.
@@ -155,6 +184,9 @@ func newBuilder(desc *fnpb.ProcessBundleDescriptor) (*builder, error) {
 }
 
 func (b *builder) build() (*Plan, error) {
+	if b.fuseCombines {
+		b.units = fuseCombines(b.units)
+	}
 	return NewPlan(b.desc.GetId(), b.units)
 }
 
@@ -240,7 +272,7 @@ func (b *builder) makeCoderForPCollection(id string) (*coder.Coder, *coder.Windo
 	if !ok {
 		return nil, nil, errors.Errorf("pcollection %v not found", id)
 	}
-	c, err := b.coders.Coder(col.CoderId)
+	c, err := b.coder(col.CoderId)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -314,6 +346,16 @@ func (b *builder) makeLinks(from string, ids []linkID) ([]Node, error) {
 	return ret, nil
 }
 
+// resourceHints returns the resource hints of the environment of the
+// transform, if any.
+func (b *builder) resourceHints(transform *pipepb.PTransform) ResourceHints {
+	env := b.desc.GetEnvironments()[transform.GetEnvironmentId()]
+	if len(env.GetResourceHints()) == 0 {
+		return nil
+	}
+	return ResourceHints(env.GetResourceHints())
+}
+
 func (b *builder) makeLink(from string, id linkID) (Node, error) {
 	if n, ok := b.links[id]; ok {
 		return n, nil
@@ -397,6 +439,7 @@ func (b *builder) makeLink(from string, id linkID) (Node, error) {
 				default:
 					n := &ParDo{UID: b.idgen.New(), Fn: dofn, Inbound: in, Out: out}
 					n.PID = transform.GetUniqueName()
+					n.Hints = b.resourceHints(transform)
 
 					input := unmarshalKeyedValues(transform.GetInputs())
 					for i := 1; i < len(input); i++ {
@@ -418,9 +461,21 @@ func (b *builder) makeLink(from string, id linkID) (Node, error) {
 					}
 					u = n
 					if urn == urnProcessSizedElementsAndRestrictions {
-						u = &ProcessSizedElementsAndRestrictions{PDo: n, TfId: id.to}
+						ec, wc, err := b.makeCoderForPCollection(input[0])
+						if err != nil {
+							return nil, err
+						}
+						u = &ProcessSizedElementsAndRestrictions{PDo: n, TfId: id.to, CheckpointCoder: coder.NewW(ec, wc)}
 					} else if dofn.IsSplittable() {
 						u = &SdfFallback{PDo: n}
+					} else if b.keyOrdering[n.PID] {
+						ec, wc, err := b.makeCoderForPCollection(input[0])
+						if err != nil {
+							return nil, err
+						}
+						s := NewSortByKey(n, coder.NewW(ec, wc), b.keyOrderingBuffer)
+						s.SpillDir = b.keyOrderingDir
+						u = s
 					}
 				}
 
@@ -433,6 +488,7 @@ func (b *builder) makeLink(from string, id linkID) (Node, error) {
 				cn.UsesKey = typex.IsKV(in[0].Type)
 
 				cn.PID = transform.GetUniqueName()
+				cn.Hints = b.resourceHints(transform)
 
 				switch urn {
 				case urnPerKeyCombinePre:
@@ -447,7 +503,11 @@ func (b *builder) makeLink(from string, id linkID) (Node, error) {
 					if !coder.IsKV(ec) {
 						return nil, errors.Errorf("unexpected non-KV coder PCollection input to combine: %v", ec)
 					}
-					u = &LiftedCombine{Combine: cn, KeyCoder: ec.Components[0], WindowCoder: wc}
+					known, err := verifyDeterministic(cn.PID, ec.Components[0])
+					if err != nil {
+						return nil, err
+					}
+					u = &LiftedCombine{Combine: cn, KeyCoder: ec.Components[0], WindowCoder: wc, CheckKeys: !known}
 				case urnPerKeyCombineMerge:
 					u = &MergeAccumulators{Combine: cn}
 				case urnPerKeyCombineExtract:
@@ -549,15 +609,34 @@ func (b *builder) makeLink(from string, id linkID) (Node, error) {
 			return nil, err
 		}
 
-		sink := &DataSink{UID: b.idgen.New()}
+		sink := &DataSink{UID: b.idgen.New(), Codec: b.compression[id.to]}
 		sink.SID = StreamID{PtransformID: id.to, Port: port}
-		sink.Coder, err = b.coders.Coder(cid) // Expected to be windowed coder
+		sink.Coder, err = b.coder(cid) // Expected to be windowed coder
 		if err != nil {
 			return nil, err
 		}
 		if !coder.IsW(sink.Coder) {
 			return nil, errors.Errorf("unwindowed coder %v on DataSink %v: %v", cid, id, sink.Coder)
 		}
+		if b.groupedSinks[id.to] {
+			sink.Grouped = true
+			if inputs := unmarshalKeyedValues(transform.GetInputs()); len(inputs) == 1 {
+				ec, _, err := b.makeCoderForPCollection(inputs[0])
+				if err != nil {
+					return nil, err
+				}
+				if coder.IsKV(ec) {
+					sink.KeyCoder, sink.ValueCoder = ec.Components[0], ec.Components[1]
+				}
+			}
+			if kv := coder.SkipW(sink.Coder); coder.IsKV(kv) {
+				known, err := verifyDeterministic(id.to, kv.Components[0])
+				if err != nil {
+					return nil, err
+				}
+				sink.CheckKeys = !known
+			}
+		}
 		u = sink
 
 	default:
@@ -0,0 +1,96 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// WithTypeAsserts returns a context in which TypeAssert nodes check the types
+// of elements. It is intended for debugging coder and type mismatches, so the
+// checks are skipped otherwise.
+func WithTypeAsserts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, typeAssertKey, true)
+}
+
+func isTypeAsserts(ctx context.Context) bool {
+	v, _ := ctx.Value(typeAssertKey).(bool)
+	return v
+}
+
+// TypeAssertError indicates that an element passed to a TypeAssert node has
+// an unexpected type.
+type TypeAssertError struct {
+	UID  UnitID
+	Elm  *FullValue
+	Got  reflect.Type // nil for a nil element.
+	Want reflect.Type
+}
+
+func (e *TypeAssertError) Error() string {
+	return fmt.Sprintf("invalid element %v at node %v: got type %v, want %v", e.Elm, e.UID, e.Got, e.Want)
+}
+
+// TypeAssert wraps a node and verifies that the concrete type of the Elm of
+// each element passed to it is assignable to Want, such as before a DoFn with a
+// parameter of that type. Elements of other types fail the bundle with a
+// TypeAssertError, rather than a panic in the reflective invocation of the
+// DoFn. The check is only done in contexts set up with WithTypeAsserts.
+type TypeAssert struct {
+	Node
+	Want reflect.Type
+
+	enabled bool
+}
+
+// NewTypeAssert returns a node that passes elements to out, after verifying
+// that their types are assignable to want.
+func NewTypeAssert(out Node, want reflect.Type) *TypeAssert {
+	return &TypeAssert{Node: out, Want: want}
+}
+
+// Up validates the type and brings up the wrapped node.
+func (n *TypeAssert) Up(ctx context.Context) error {
+	if n.Want == nil {
+		return errors.Errorf("missing type for type assert node %v", n.ID())
+	}
+	return n.Node.Up(ctx)
+}
+
+// StartBundle enables the check if requested, and starts the wrapped node.
+func (n *TypeAssert) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.enabled = isTypeAsserts(ctx)
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement verifies the type of the element, if enabled, and forwards
+// it to the wrapped node.
+func (n *TypeAssert) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if n.enabled {
+		if got := reflect.TypeOf(elm.Elm); got == nil || !got.AssignableTo(n.Want) {
+			return &TypeAssertError{UID: n.ID(), Elm: elm, Got: got, Want: n.Want}
+		}
+	}
+	return n.Node.ProcessElement(ctx, elm, values...)
+}
+
+func (n *TypeAssert) String() string {
+	return fmt.Sprintf("TypeAssert[%v]. Node:%v", n.Want, n.Node)
+}
@@ -0,0 +1,72 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestTypeAssert verifies that elements of unexpected types fail with a
+// TypeAssertError when type asserts are enabled, and pass otherwise.
+func TestTypeAssert(t *testing.T) {
+	stringer := reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	tests := []struct {
+		name    string
+		want    reflect.Type
+		in      []MainInput
+		enabled bool
+		wantErr reflect.Type
+	}{
+		{name: "match", want: reflect.TypeOf(0), in: makeInput(1, 2), enabled: true},
+		{name: "interface", want: stringer, in: makeInput(&FullValue{}), enabled: true},
+		{name: "mismatch", want: reflect.TypeOf(0), in: makeInput(1, "a"), enabled: true, wantErr: reflect.TypeOf("")},
+		{name: "disabled", want: reflect.TypeOf(0), in: makeInput(1, "a")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.enabled {
+				ctx = WithTypeAsserts(ctx)
+			}
+			out := &CaptureNode{UID: 1}
+			n := NewTypeAssert(out, test.want)
+			root := &FixedRoot{UID: 2, Elements: test.in, Out: n}
+			p, err := NewPlan("a", []Unit{root, n})
+			if err != nil {
+				t.Fatalf("failed to construct plan: %v", err)
+			}
+
+			err = p.Execute(ctx, "1", DataContext{})
+			if test.wantErr == nil {
+				if err != nil {
+					t.Fatalf("execute failed: %v", err)
+				}
+				if len(out.Elements) != len(test.in) {
+					t.Errorf("forwarded %v elements, want %v", len(out.Elements), len(test.in))
+				}
+				return
+			}
+			var te *TypeAssertError
+			if !errors.As(err, &te) || te.Got != test.wantErr || te.Want != test.want || te.UID != 1 {
+				t.Errorf("execute = %v, want TypeAssertError of %v at node 1", err, test.wantErr)
+			}
+		})
+	}
+}
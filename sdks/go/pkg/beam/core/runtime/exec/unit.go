@@ -17,6 +17,8 @@ package exec
 
 import (
 	"context"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 )
 
 // UnitID is a unit identifier. Used for debugging.
@@ -70,7 +72,53 @@ type ElementProcessor interface {
 
 // Node represents an single-bundle processing unit. Each node contains
 // its processing continuation, notably other nodes.
+//
+// A node may wrap another by embedding it, such as a RetryNode or a
+// DeadLetterNode. The embedded node supplies the methods the wrapper doesn't
+// override, notably its ID, so the wrapper stands in for it in a plan and
+// is placed where the wrapped node was.
 type Node interface {
 	Unit
 	ElementProcessor
 }
+
+// Drainable is an optional interface for nodes that buffer data and need to
+// flush it when a bundle is drained, rather than finished abruptly. Draining
+// is called before the final FinishBundle. As with other data processing
+// calls, each node is responsible for propagating the call downstream.
+type Drainable interface {
+	// Draining signals that the current bundle is being drained. Any buffered
+	// data should be emitted downstream.
+	Draining(ctx context.Context) error
+}
+
+// WatermarkObserver is an optional interface for nodes that act on the output
+// watermark of their input, such as to close windows, even if no elements
+// arrive. As with other data processing calls, each node is responsible for
+// propagating the call downstream.
+type WatermarkObserver interface {
+	// ProcessWatermark signals that no further elements with timestamps
+	// before wm are expected in the bundle.
+	ProcessWatermark(ctx context.Context, wm mtime.Time) error
+}
+
+// FinishEmitter is an optional interface for nodes that emit elements at the
+// end of a bundle, after their last input element, such as accumulated
+// results. Such nodes finish the bundle with FinishBundleWithEmit, which
+// passes the emitted elements downstream before finishing the downstream
+// nodes. Since no input element is being processed, each emitted element
+// must carry its own windows and timestamp: it must be in at least one
+// window, at a timestamp no later than the maximum timestamp of any of them.
+type FinishEmitter interface {
+	// EmitOnFinish passes the remaining output of the bundle to emit, which
+	// forwards each element downstream before returning.
+	EmitOnFinish(ctx context.Context, emit func(elm *FullValue) error) error
+}
+
+// Resettable is an optional interface for nodes that accumulate state across
+// bundles and need to clear it when the plan is reused. Reset is called
+// between bundles, separately from StartBundle.
+type Resettable interface {
+	// Reset clears any inter-bundle caches held by the node.
+	Reset(ctx context.Context) error
+}
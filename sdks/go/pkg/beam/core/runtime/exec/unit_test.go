@@ -23,57 +23,36 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
 
-// CaptureNode is a test Node that captures all elements for verification. It also
-// validates that it is invoked correctly.
-type CaptureNode struct {
-	UID      UnitID
-	Elements []FullValue
-
-	status Status
+// ErrorNode is a test Node that returns Err from every ProcessElement call. It
+// records the number of elements it has seen.
+type ErrorNode struct {
+	UID   UnitID
+	Err   error
+	Calls int
 }
 
-func (n *CaptureNode) ID() UnitID {
+func (n *ErrorNode) ID() UnitID {
 	return n.UID
 }
 
-func (n *CaptureNode) Up(ctx context.Context) error {
-	if n.status != Initializing {
-		return errors.Errorf("invalid status for %v: %v, want Initializing", n.UID, n.status)
-	}
-	n.status = Up
+func (n *ErrorNode) Up(ctx context.Context) error {
 	return nil
 }
 
-func (n *CaptureNode) StartBundle(ctx context.Context, id string, data DataContext) error {
-	if n.status != Up {
-		return errors.Errorf("invalid status for %v: %v, want Up", n.UID, n.status)
-	}
-	n.status = Active
+func (n *ErrorNode) StartBundle(ctx context.Context, id string, data DataContext) error {
 	return nil
 }
 
-func (n *CaptureNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
-	if n.status != Active {
-		return errors.Errorf("invalid status for pardo %v: %v, want Active", n.UID, n.status)
-	}
-
-	n.Elements = append(n.Elements, *elm)
-	return nil
+func (n *ErrorNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.Calls++
+	return n.Err
 }
 
-func (n *CaptureNode) FinishBundle(ctx context.Context) error {
-	if n.status != Active {
-		return errors.Errorf("invalid status for %v: %v, want Active", n.UID, n.status)
-	}
-	n.status = Up
+func (n *ErrorNode) FinishBundle(ctx context.Context) error {
 	return nil
 }
 
-func (n *CaptureNode) Down(ctx context.Context) error {
-	if n.status != Up {
-		return errors.Errorf("invalid status for %v: %v, want Up", n.UID, n.status)
-	}
-	n.status = Down
+func (n *ErrorNode) Down(ctx context.Context) error {
 	return nil
 }
 
@@ -134,7 +113,7 @@ func (n *FixedRoot) Up(ctx context.Context) error {
 }
 
 func (n *FixedRoot) StartBundle(ctx context.Context, id string, data DataContext) error {
-	return n.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, n.Out)
 }
 
 func (n *FixedRoot) Process(ctx context.Context) error {
@@ -147,7 +126,7 @@ func (n *FixedRoot) Process(ctx context.Context) error {
 }
 
 func (n *FixedRoot) FinishBundle(ctx context.Context) error {
-	return n.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, n.Out)
 }
 
 func (n *FixedRoot) Down(ctx context.Context) error {
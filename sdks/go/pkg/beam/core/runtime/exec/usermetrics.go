@@ -0,0 +1,36 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+)
+
+// SharedMetricsID is the transform ID that metrics updated with a context
+// returned by SharedMetrics are reported under.
+const SharedMetricsID = "beam:shared"
+
+// SharedMetrics returns a context in which metrics are shared by all
+// transforms of the bundle. By default, the context passed to DoFns scopes
+// metrics to their transform, so that identically named metrics of different
+// transforms are distinct. Updating a metric with the returned context instead
+// adds to a single metric per bundle, reported under SharedMetricsID. The
+// context must be derived from one passed to a DoFn.
+func SharedMetrics(ctx context.Context) context.Context {
+	return metrics.SetPTransformID(ctx, SharedMetricsID)
+}
@@ -0,0 +1,109 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/util/reflectx"
+)
+
+var (
+	userElements = metrics.NewCounter("user", "elements")
+	userShared   = metrics.NewCounter("user", "shared")
+)
+
+// countFn counts its elements in a per transform and a shared counter.
+func countFn(ctx context.Context, n int, emit func(int)) {
+	userElements.Inc(ctx, 1)
+	userShared.Inc(SharedMetrics(ctx), 1)
+	emit(n)
+}
+
+// TestUserMetrics verifies that identically named metrics of two transforms
+// are distinct, unless shared.
+func TestUserMetrics(t *testing.T) {
+	fn, err := graph.NewDoFn(countFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	g := graph.New()
+	nN := g.NewNode(typex.New(reflectx.Int), window.DefaultWindowingStrategy(), true)
+	edge, err := graph.NewParDo(g, g.Root(), fn, []*graph.Node{nN}, nil, nil)
+	if err != nil {
+		t.Fatalf("invalid pardo: %v", err)
+	}
+
+	out := &CaptureNode{UID: 1}
+	second := &ParDo{UID: 2, PID: "second", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{out}}
+	first := &ParDo{UID: 3, PID: "first", Fn: edge.DoFn, Inbound: edge.Input, Out: []Node{second}}
+	in := &FixedRoot{UID: 4, Elements: makeInput(1, 2, 3), Out: first}
+	p, err := NewPlan("a", []Unit{in, first, second, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	// Run two bundles, to check that metrics are per bundle.
+	for _, id := range []string{"1", "2"} {
+		if err := p.Execute(context.Background(), id, DataContext{}); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+	}
+
+	got := make(map[metrics.Labels]int64)
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			got[l] = v
+		},
+	}.ExtractFrom(p.Store())
+	want := map[metrics.Labels]int64{
+		metrics.UserLabels("first", "user", "elements"):       3,
+		metrics.UserLabels("second", "user", "elements"):      3,
+		metrics.UserLabels(SharedMetricsID, "user", "shared"): 6,
+	}
+	for l, v := range want {
+		if got[l] != v {
+			t.Errorf("metric %v = %v, want %v", l, got[l], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got metrics %v, want %v", got, want)
+	}
+}
+
+// TestUserMetrics_sameTransform verifies that contexts scoped separately to
+// the same transform update the same metrics.
+func TestUserMetrics_sameTransform(t *testing.T) {
+	ctx := metrics.SetBundleID(context.Background(), "1")
+	userElements.Inc(metrics.SetPTransformID(ctx, "a"), 1)
+	userElements.Inc(metrics.SetPTransformID(ctx, "a"), 2)
+
+	var got int64
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			if l == metrics.UserLabels("a", "user", "elements") {
+				got = v
+			}
+		},
+	}.ExtractFrom(metrics.GetStore(ctx))
+	if got != 3 {
+		t.Errorf("metric = %v, want 3", got)
+	}
+}
@@ -19,19 +19,45 @@ import (
 	"context"
 	"fmt"
 	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
 
-// GenID is a simple UnitID generator.
+// GenID is a simple UnitID generator. It is safe for concurrent use and the
+// zero value is ready to use.
 type GenID struct {
-	last int
+	last int64
 }
 
 // New returns a fresh ID.
 func (g *GenID) New() UnitID {
-	g.last++
-	return UnitID(g.last)
+	return UnitID(atomic.AddInt64(&g.last, 1))
+}
+
+// Reserve allocates a contiguous block of n IDs. It returns the first ID of
+// the block and a generator that hands out the IDs of the block in order.
+// Calling the generator more than n times panics.
+func (g *GenID) Reserve(n int) (first UnitID, next func() UnitID) {
+	if n < 0 {
+		panic(fmt.Sprintf("invalid number of IDs to reserve: %v", n))
+	}
+	end := atomic.AddInt64(&g.last, int64(n))
+	start := end - int64(n) + 1
+
+	var used int64
+	return UnitID(start), func() UnitID {
+		i := atomic.AddInt64(&used, 1)
+		if i > int64(n) {
+			panic(fmt.Sprintf("reserved block of %v IDs starting at %v exhausted", n, start))
+		}
+		return UnitID(start + i - 1)
+	}
 }
 
 type doFnError struct {
@@ -39,50 +65,639 @@ type doFnError struct {
 	err  error
 	uid  UnitID
 	pid  string
+	// stage is the ID of the plan that executed the DoFn, if known.
+	stage string
+
+	// Stack is the stack trace captured when the error was recovered from a
+	// panic. It is nil if the error was returned normally.
+	Stack []byte
+	// Category classifies the failure for retry purposes.
+	Category ErrorCategory
+
+	// chain holds the nodes through which a StartBundle or FinishBundle
+	// failure propagated, from the failing node up to the root. It is only
+	// exposed by NodeChain, so that the message of the error is unchanged.
+	chain []UnitID
 }
 
 func (e *doFnError) Error() string {
-	return fmt.Sprintf("DoFn[UID:%v, PID:%v, Name: %v] failed:\n%v", e.uid, e.pid, e.doFn, e.err)
+	if e.stage == "" {
+		return fmt.Sprintf("DoFn[UID:%v, PID:%v, Name: %v] failed:\n%v", e.uid, e.pid, e.doFn, e.err)
+	}
+	return fmt.Sprintf("DoFn[UID:%v, PID:%v, Name: %v, Stage: %v] failed:\n%v", e.uid, e.pid, e.doFn, e.stage, e.err)
+}
+
+// Unwrap returns the underlying error of the failed DoFn.
+func (e *doFnError) Unwrap() error {
+	return e.err
+}
+
+// StackTrace returns the stack trace captured at panic recovery, if any.
+func (e *doFnError) StackTrace() []byte {
+	return e.Stack
+}
+
+// AsDoFnError returns the first DoFn error in the chain of wrapped errors, if
+// present.
+func AsDoFnError(err error) (*doFnError, bool) {
+	for err != nil {
+		if e, ok := err.(*doFnError); ok {
+			return e, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
+// StageID returns the ID of the stage in which the first DoFn error in the
+// chain of wrapped errors occurred, or "" if there is none or the stage is
+// unknown.
+func StageID(err error) string {
+	if e, ok := AsDoFnError(err); ok {
+		return e.stage
+	}
+	return ""
+}
+
+// setStage populates the stage of a DoFn error, unless already set.
+func setStage(err error, stage string) {
+	if e, ok := AsDoFnError(err); ok && e.stage == "" {
+		e.stage = stage
+	}
+}
+
+// nodeChainError annotates a StartBundle or FinishBundle failure of a node
+// that is not a DoFn with the nodes through which it propagated.
+type nodeChainError struct {
+	chain []UnitID
+	err   error
+}
+
+func (e *nodeChainError) Error() string {
+	return fmt.Sprintf("%v%v", e.err, formatNodeChain(e.chain))
+}
+
+func (e *nodeChainError) Unwrap() error {
+	return e.err
+}
+
+// formatNodeChain returns a concise suffix describing the given chain, or ""
+// if it is empty.
+func formatNodeChain(chain []UnitID) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(" [node chain: ")
+	for i, id := range chain {
+		if i > 0 {
+			sb.WriteString(" -> ")
+		}
+		fmt.Fprintf(&sb, "%v", id)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// withNodeChain records that the given StartBundle or FinishBundle error
+// propagated through the node with the given ID. The first node recorded is
+// the failing one. DoFn errors carry the chain themselves, so that ParDos
+// upstream still recognize them, while other errors are wrapped. Aborted
+// bundles are returned as is.
+func withNodeChain(err error, id UnitID) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := asBundleAborted(err); ok {
+		return err
+	}
+	for e := err; e != nil; {
+		switch c := e.(type) {
+		case *nodeChainError:
+			c.chain = append(c.chain, id)
+			return err
+		case *doFnError:
+			if len(c.chain) > 0 {
+				c.chain = append(c.chain, id)
+				return err
+			}
+		}
+		u, ok := e.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		e = u.Unwrap()
+	}
+	if e, ok := err.(*doFnError); ok {
+		e.chain = []UnitID{id}
+		return e
+	}
+	return &nodeChainError{chain: []UnitID{id}, err: err}
+}
+
+// NodeChain returns the IDs of the nodes through which a StartBundle or
+// FinishBundle error propagated, from the failing node up to the root of the
+// plan, or nil if the error carries no chain.
+func NodeChain(err error) []UnitID {
+	for err != nil {
+		switch e := err.(type) {
+		case *nodeChainError:
+			return append([]UnitID(nil), e.chain...)
+		case *doFnError:
+			if len(e.chain) > 0 {
+				return append([]UnitID(nil), e.chain...)
+			}
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil
+		}
+		err = u.Unwrap()
+	}
+	return nil
+}
+
+// ErrorCategory classifies a failure as worth retrying or not.
+type ErrorCategory int
+
+const (
+	// Unknown is the category of unclassified errors.
+	Unknown ErrorCategory = iota
+	// Transient errors may succeed if the bundle is retried.
+	Transient
+	// Permanent errors will fail again if the bundle is retried.
+	Permanent
+)
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case Unknown:
+		return "Unknown"
+	case Transient:
+		return "Transient"
+	case Permanent:
+		return "Permanent"
+	default:
+		return fmt.Sprintf("UnknownErrorCategory(%d)", int(c))
+	}
+}
+
+// categorizedError is an error explicitly classified by user code.
+type categorizedError struct {
+	err      error
+	category ErrorCategory
+}
+
+func (e *categorizedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categorizedError) Unwrap() error {
+	return e.err
+}
+
+// TransientError marks the given error as transient. DoFns may return it to
+// signal that retrying the bundle may succeed.
+func TransientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categorizedError{err: err, category: Transient}
+}
+
+// PermanentError marks the given error as permanent. DoFns may return it to
+// signal that retrying the bundle will not succeed.
+func PermanentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categorizedError{err: err, category: Permanent}
 }
 
-// callNoPanic calls the given function and catches any panic.
+// Category returns the category of the given error. Errors that were not
+// classified are Unknown.
+func Category(err error) ErrorCategory {
+	for err != nil {
+		switch e := err.(type) {
+		case *categorizedError:
+			return e.category
+		case *doFnError:
+			if e.Category != Unknown {
+				return e.Category
+			}
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+	return Unknown
+}
+
+// classify populates the category of a DoFn error from its cause.
+func classify(err error) {
+	if e, ok := AsDoFnError(err); ok && e.Category == Unknown {
+		e.Category = Category(e.err)
+	}
+}
+
+// BundleAbortedError indicates that user code abandoned the bundle with
+// AbortBundle. Unlike other failures, which are attributed to a DoFn and
+// returned as DoFn errors, it signals that the bundle as a whole should be
+// discarded, including any output, and retried, rather than failed.
+type BundleAbortedError struct {
+	Reason string
+	// UID and PID identify the DoFn that aborted the bundle, if known.
+	UID UnitID
+	PID string
+}
+
+func (e *BundleAbortedError) Error() string {
+	if e.PID == "" {
+		return fmt.Sprintf("bundle aborted: %v", e.Reason)
+	}
+	return fmt.Sprintf("bundle aborted by DoFn[UID:%v, PID:%v]: %v", e.UID, e.PID, e.Reason)
+}
+
+// AbortBundle returns an error that DoFns may return, or panic with, to
+// abandon the current bundle. The plan then fails with a BundleAbortedError
+// regardless of how the DoFn is wrapped, for the harness to discard the bundle
+// and retry it. It is not recovered or retried per element, unlike normal
+// errors returned by DoFns, which fail the bundle with a DoFn error.
+func AbortBundle(reason string) error {
+	return &BundleAbortedError{Reason: reason}
+}
+
+// asBundleAborted returns the first BundleAbortedError in the chain of wrapped
+// errors, with the DoFn of any enclosing DoFn error, if present.
+func asBundleAborted(err error) (*BundleAbortedError, bool) {
+	var fn *doFnError
+	for err != nil {
+		switch e := err.(type) {
+		case *BundleAbortedError:
+			if fn == nil || e.PID != "" {
+				return e, true
+			}
+			ret := *e
+			ret.UID, ret.PID = fn.uid, fn.pid
+			return &ret, true
+		case *doFnError:
+			if fn == nil {
+				fn = e
+			}
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
+// Counters for panics recovered by callNoPanic, split by whether the panic
+// value was a structured DoFn error or a raw panic.
+var (
+	doFnPanicsRecovered = metrics.NewCounter("exec", "panicsRecovered.doFnError")
+	rawPanicsRecovered  = metrics.NewCounter("exec", "panicsRecovered.raw")
+)
+
+// callNoPanic calls the given function and catches any panic. Bundles aborted
+// with AbortBundle, by panic or error, result in the BundleAbortedError.
 func callNoPanic(ctx context.Context, fn func(context.Context) error) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				if abort, ok := asBundleAborted(e); ok {
+					err = abort
+					return
+				}
+			}
 			// Check if the panic value is from a failed DoFn, and return it without a panic trace.
 			if e, ok := r.(*doFnError); ok {
+				doFnPanicsRecovered.Inc(metrics.SetPTransformID(ctx, e.pid), 1)
+				if e.Stack == nil {
+					e.Stack = debug.Stack()
+				}
+				classify(e)
 				err = e
 			} else {
+				if filter := getPanicFilter(ctx); filter != nil && !filter(r) {
+					panic(r)
+				}
+				rawPanicsRecovered.Inc(ctx, 1)
 				// Top level error is the panic itself, but also include the stack trace as the original error.
 				// Higher levels can then add appropriate context without getting pushed down by the stack trace.
 				err = errors.SetTopLevelMsgf(errors.Errorf("panic: %v %s", r, debug.Stack()), "panic: %v", r)
 			}
 		}
 	}()
-	return fn(ctx)
+	err = fn(ctx)
+	if abort, ok := asBundleAborted(err); ok {
+		return abort
+	}
+	classify(err)
+	return err
+}
+
+type ctxKey string
+
+const (
+	invocationTimerKey  ctxKey = "beam:invocationtimer"
+	panicFilterKey      ctxKey = "beam:panicfilter"
+	cancelCheckKey      ctxKey = "beam:cancelcheck"
+	roundTripCheckKey   ctxKey = "beam:roundtripcheck"
+	bundleFinalizerKey  ctxKey = "beam:bundlefinalizer"
+	stateCacheKey       ctxKey = "beam:statecache"
+	processTimesKey     ctxKey = "beam:processtimes"
+	schemaValidationKey ctxKey = "beam:schemavalidation"
+	readTimeoutKey      ctxKey = "beam:readtimeout"
+	sinkBufferKey       ctxKey = "beam:sinkbuffer"
+	paneCheckKey        ctxKey = "beam:panecheck"
+	tracerKey           ctxKey = "beam:tracer"
+	sinkBatchKey        ctxKey = "beam:sinkbatch"
+	pauseGateKey        ctxKey = "beam:pausegate"
+	bundleDeadlineKey   ctxKey = "beam:bundledeadline"
+	deadlineStateKey    ctxKey = "beam:deadlinestate"
+	kvCheckKey          ctxKey = "beam:kvcheck"
+	bundleCleanupKey    ctxKey = "beam:bundlecleanup"
+	prefetchKey         ctxKey = "beam:prefetch"
+	dedupStoreKey       ctxKey = "beam:dedupstore"
+	typeAssertKey       ctxKey = "beam:typeassert"
+)
+
+// InvocationTimer observes the wall-clock duration of a unit invocation.
+type InvocationTimer func(uid UnitID, d time.Duration)
+
+// WithInvocationTimer returns a context that reports the duration of each
+// unit invocation made by the plan, and of each DoFn invocation made by a
+// ParDo, to the given callback, keyed by the ID of the invoked unit. The
+// callback is invoked for both successful and panicking invocations.
+func WithInvocationTimer(ctx context.Context, cb InvocationTimer) context.Context {
+	return context.WithValue(ctx, invocationTimerKey, cb)
+}
+
+func getInvocationTimer(ctx context.Context) InvocationTimer {
+	if cb, ok := ctx.Value(invocationTimerKey).(InvocationTimer); ok {
+		return cb
+	}
+	return nil
+}
+
+// processElementTimes is the distribution of ProcessElement durations of each
+// DoFn, in microseconds, recorded if enabled with WithProcessElementTimes. It
+// is reported per transform by its PTransform label.
+var processElementTimes = metrics.NewDistribution("exec", "processElement.usecs")
+
+// WithProcessElementTimes returns a context in which ParDos record the
+// duration of each ProcessElement invocation in a distribution metric for
+// their transform. Durations are recorded in microseconds, which resolves
+// sub-millisecond invocations while leaving ample range for multi-second ones.
+// As with WithInvocationTimer, the duration includes the time spent in the
+// nodes the DoFn emits to.
+func WithProcessElementTimes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, processTimesKey, true)
+}
+
+func isProcessElementTimes(ctx context.Context) bool {
+	v, _ := ctx.Value(processTimesKey).(bool)
+	return v
 }
 
-// MultiStartBundle calls StartBundle on multiple nodes. Convenience function.
+// PanicFilter decides whether a panic value is recovered and converted into an
+// error. Returning false lets the panic propagate.
+type PanicFilter func(r interface{}) bool
+
+// WithPanicFilter returns a context in which callNoPanic consults the given
+// filter before recovering a panic. Panics carrying DoFn errors are always
+// recovered, since they propagate failures of downstream DoFns. Without a
+// filter, all panics are recovered.
+func WithPanicFilter(ctx context.Context, filter PanicFilter) context.Context {
+	return context.WithValue(ctx, panicFilterKey, filter)
+}
+
+func getPanicFilter(ctx context.Context) PanicFilter {
+	if f, ok := ctx.Value(panicFilterKey).(PanicFilter); ok {
+		return f
+	}
+	return nil
+}
+
+// callUnitNoPanic calls the given function of the given unit and catches any
+// panic. If an invocation timer is present in the context, it is informed of
+// the duration of the call.
+func callUnitNoPanic(ctx context.Context, uid UnitID, fn func(context.Context) error) error {
+	cb := getInvocationTimer(ctx)
+	if cb == nil {
+		return callNoPanic(ctx, fn)
+	}
+	start := time.Now()
+	err := callNoPanic(ctx, fn)
+	cb(uid, time.Since(start))
+	return err
+}
+
+// MultiStartBundle calls StartBundle on multiple nodes. Errors record the
+// failing node in their node chain. Convenience function.
 func MultiStartBundle(ctx context.Context, id string, data DataContext, list ...Node) error {
 	for _, n := range list {
 		if err := n.StartBundle(ctx, id, data); err != nil {
-			return err
+			return withNodeChain(err, n.ID())
 		}
 	}
 	return nil
 }
 
-// MultiFinishBundle calls StartBundle on multiple nodes. Convenience function.
+// MultiStartBundleParallel calls StartBundle on multiple nodes, using at
+// most concurrency goroutines. The nodes must be safe to start concurrently.
+// Each node is started with a child of the given context, which is only
+// canceled on error, as nodes may keep it for processing the bundle. On the
+// first error, the context is canceled, so that in-flight calls can return
+// early, no further nodes are started, and the error is returned once all
+// in-flight calls have returned. Convenience function.
+func MultiStartBundleParallel(ctx context.Context, id string, data DataContext, concurrency int, list ...Node) error {
+	if concurrency < 1 {
+		return errors.Errorf("invalid concurrency for StartBundle: %v, want > 0", concurrency)
+	}
+	if concurrency == 1 || len(list) < 2 {
+		return MultiStartBundle(ctx, id, data, list...)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	var once sync.Once
+	var first error
+	fail := func(err error) {
+		once.Do(func() {
+			first = err
+			cancel()
+			close(stop)
+		})
+	}
+
+	work := make(chan Node)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(list); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range work {
+				if err := n.StartBundle(ctx, id, data); err != nil {
+					fail(withNodeChain(err, n.ID()))
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, n := range list {
+		select {
+		case work <- n:
+		case <-stop:
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	return first
+}
+
+// MultiFinishBundle calls FinishBundle on multiple nodes. Errors record the
+// failing node in their node chain. Convenience function.
 func MultiFinishBundle(ctx context.Context, list ...Node) error {
 	for _, n := range list {
 		if err := n.FinishBundle(ctx); err != nil {
-			return err
+			return withNodeChain(err, n.ID())
+		}
+	}
+	return nil
+}
+
+// FinishBundleWithEmit finishes the bundle of the given node, feeding the
+// given nodes. If the node is a FinishEmitter, the elements it emits are
+// passed to each of the nodes first, in order, and then FinishBundle is
+// called on the nodes, so downstream nodes see all emitted elements before
+// their own FinishBundle. Emitted elements without a window, or with a
+// timestamp after the maximum timestamp of one of their windows, fail the
+// bundle. Convenience function.
+func FinishBundleWithEmit(ctx context.Context, n Node, list ...Node) error {
+	if e, ok := n.(FinishEmitter); ok {
+		emit := func(elm *FullValue) error {
+			if len(elm.Windows) == 0 {
+				return errors.Errorf("element %v emitted at FinishBundle of node %v has no window", elm, n.ID())
+			}
+			for _, w := range elm.Windows {
+				if elm.Timestamp > w.MaxTimestamp() {
+					return errors.Errorf("element %v emitted at FinishBundle of node %v has timestamp %v after the end of its window %v", elm, n.ID(), elm.Timestamp, w)
+				}
+			}
+			return MultiProcessElement(ctx, elm, list...)
+		}
+		if err := e.EmitOnFinish(ctx, emit); err != nil {
+			return errors.Wrapf(err, "while emitting at FinishBundle of node %v", n.ID())
+		}
+	}
+	return MultiFinishBundle(ctx, list...)
+}
+
+// MultiFinishBundleAll calls FinishBundle on multiple nodes. Unlike
+// MultiFinishBundle, it calls FinishBundle on every node even if some fail,
+// so that all nodes get to release their resources. It returns an error
+// listing the IDs of all failing nodes and their errors, if any, which wraps
+// the error of the first failing node, with that node in its node chain.
+// Convenience function.
+func MultiFinishBundleAll(ctx context.Context, list ...Node) error {
+	var ids []UnitID
+	var errs []error
+	for _, n := range list {
+		if err := n.FinishBundle(ctx); err != nil {
+			ids = append(ids, n.ID())
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		errs[0] = withNodeChain(errs[0], ids[0])
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errors.Wrapf(errs[0], "while executing FinishBundle for node %v", ids[0])
+	default:
+		return errors.Wrapf(errs[0], "while executing FinishBundle for nodes %v: multiple errors, further ones: %v", ids, errs[1:])
+	}
+}
+
+// MultiDrain calls Draining on multiple nodes. Nodes that are not Drainable
+// are skipped. Convenience function.
+func MultiDrain(ctx context.Context, list ...Node) error {
+	for _, n := range list {
+		if d, ok := n.(Drainable); ok {
+			if err := d.Draining(ctx); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-// IDs returns the unit IDs of the given nodes.
+// MultiProcessWatermark calls ProcessWatermark on multiple nodes. Nodes that
+// are not WatermarkObservers are skipped. Convenience function.
+func MultiProcessWatermark(ctx context.Context, wm mtime.Time, list ...Node) error {
+	for _, n := range list {
+		if o, ok := n.(WatermarkObserver); ok {
+			if err := o.ProcessWatermark(ctx, wm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MultiReset calls Reset on multiple nodes. Nodes that are not Resettable
+// are skipped. Convenience function.
+func MultiReset(ctx context.Context, list ...Node) error {
+	for _, n := range list {
+		if r, ok := n.(Resettable); ok {
+			if err := r.Reset(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MultiProcessElement calls ProcessElement on multiple nodes with the given
+// element. It returns the first error, annotated with the ID of the failing
+// node. Convenience function.
+func MultiProcessElement(ctx context.Context, elm *FullValue, list ...Node) error {
+	return MultiProcessElementValues(ctx, elm, nil, list...)
+}
+
+// MultiProcessElementValues calls ProcessElement on multiple nodes with the
+// given element and values. It returns the first error, annotated with the ID
+// of the failing node. Each node is passed the element with its original key,
+// value and pane, even if a previous node changed them. Convenience function.
+func MultiProcessElementValues(ctx context.Context, elm *FullValue, values []ReStream, list ...Node) error {
+	orig := FullValue{Pane: elm.Pane}
+	CopyKV(&orig, elm)
+	for _, n := range list {
+		CopyKV(elm, &orig)
+		elm.Pane = orig.Pane
+		if err := n.ProcessElement(ctx, elm, values...); err != nil {
+			return errors.Wrapf(err, "while executing ProcessElement for node %v", n.ID())
+		}
+	}
+	return nil
+}
+
+// IDs returns the unit IDs of the given nodes, in argument order.
 func IDs(list ...Node) []UnitID {
 	var ret []UnitID
 	for _, n := range list {
@@ -90,3 +705,17 @@ func IDs(list ...Node) []UnitID {
 	}
 	return ret
 }
+
+// NodesByID returns the given nodes keyed by their unit IDs. It panics if
+// two nodes share an ID, as that indicates a malformed plan.
+func NodesByID(list ...Node) map[UnitID]Node {
+	ret := make(map[UnitID]Node, len(list))
+	for _, n := range list {
+		id := n.ID()
+		if prev, ok := ret[id]; ok {
+			panic(fmt.Sprintf("duplicate unit ID %v: %v and %v", id, prev, n))
+		}
+		ret[id] = n
+	}
+	return ret
+}
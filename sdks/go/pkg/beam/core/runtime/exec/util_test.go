@@ -17,9 +17,18 @@ package exec
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/util/errorx"
 )
@@ -65,3 +74,790 @@ func TestCallNoPanic_wrappedPanic(t *testing.T) {
 		t.Errorf("callNoPanic(<func that panics with a wrapped known error>) did not filter panic, want %v, got %v", want, got)
 	}
 }
+
+// TestMultiProcessElement verifies that elements are forwarded to every node
+// and that the first error short-circuits the remaining nodes.
+func TestMultiProcessElement(t *testing.T) {
+	ctx := context.Background()
+	elm := &FullValue{Elm: 1}
+
+	t.Run("empty", func(t *testing.T) {
+		if err := MultiProcessElement(ctx, elm); err != nil {
+			t.Errorf("MultiProcessElement(<no nodes>) = %v, want nil", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		a := &CaptureNode{UID: 1}
+		b := &ErrorNode{UID: 2, Err: errors.New("middle error")}
+		c := &CaptureNode{UID: 3}
+		for _, n := range []Node{a, c} {
+			if err := n.Up(ctx); err != nil {
+				t.Fatalf("Up failed: %v", err)
+			}
+			if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+				t.Fatalf("StartBundle failed: %v", err)
+			}
+		}
+
+		err := MultiProcessElement(ctx, elm, a, b, c)
+		if err == nil {
+			t.Fatalf("MultiProcessElement(<failing middle node>) = nil, want error")
+		}
+		if !strings.Contains(err.Error(), "middle error") || !strings.Contains(err.Error(), "node 2") {
+			t.Errorf("MultiProcessElement(<failing middle node>) = %v, want error from node 2", err)
+		}
+		if got, want := len(a.Elements), 1; got != want {
+			t.Errorf("len(a.Elements) = %v, want %v", got, want)
+		}
+		if got, want := b.Calls, 1; got != want {
+			t.Errorf("b.Calls = %v, want %v", got, want)
+		}
+		if got, want := len(c.Elements), 0; got != want {
+			t.Errorf("len(c.Elements) = %v, want %v", got, want)
+		}
+	})
+}
+
+// kvChangingNode is a test Node that drops the values of the elements passed
+// to it.
+type kvChangingNode struct {
+	Discard
+}
+
+func (n *kvChangingNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	elm.Elm, elm.Elm2 = "changed", nil
+	return nil
+}
+
+// TestMultiProcessElement_kv verifies that each node is passed the original
+// key and value of the element, even if a previous node changed them.
+func TestMultiProcessElement_kv(t *testing.T) {
+	ctx := context.Background()
+	a := &kvChangingNode{Discard: Discard{UID: 1}}
+	b := &CaptureNode{UID: 2}
+	if err := b.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := b.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("StartBundle failed: %v", err)
+	}
+	if err := MultiProcessElement(ctx, &FullValue{Elm: "a", Elm2: 1}, a, b); err != nil {
+		t.Fatalf("MultiProcessElement failed: %v", err)
+	}
+	if got := b.Elements; len(got) != 1 || got[0].Elm != "a" || got[0].Elm2 != 1 {
+		t.Errorf("second node got %v, want KV<a,1>", got)
+	}
+
+	var dst FullValue
+	CopyKV(&dst, &FullValue{Elm: "k", Elm2: "v"})
+	CopyKV(&dst, &FullValue{Elm: "k2"})
+	if dst.Elm != "k2" || dst.Elm2 != nil {
+		t.Errorf("CopyKV of non-KV = %v, want k2 without value", dst)
+	}
+}
+
+// TestCallNoPanic_stackTrace verifies that a DoFn error recovered from a panic
+// carries the stack trace, without altering the error message.
+func TestCallNoPanic_stackTrace(t *testing.T) {
+	ctx := context.Background()
+	parDoError := &doFnError{
+		doFn: "sumFn",
+		err:  errors.New("SumFn error"),
+		uid:  1,
+		pid:  "Plan ID",
+	}
+	want := parDoError.Error()
+
+	got := callNoPanic(ctx, func(c context.Context) error { panic(parDoError) })
+
+	e, ok := AsDoFnError(errors.Wrap(got, "while executing bundle"))
+	if !ok {
+		t.Fatalf("AsDoFnError(%v) = false, want true", got)
+	}
+	if !strings.Contains(string(e.StackTrace()), "callNoPanic") {
+		t.Errorf("StackTrace() = %s, want stack through callNoPanic", e.StackTrace())
+	}
+	if got.Error() != want {
+		t.Errorf("callNoPanic(<func that panics with a DoFn error>) = %v, want %v", got, want)
+	}
+	if _, ok := AsDoFnError(errors.New("plain error")); ok {
+		t.Errorf("AsDoFnError(<plain error>) = true, want false")
+	}
+}
+
+// TestCallUnitNoPanic_timer verifies that the invocation timer fires once per
+// call, for both returning and panicking functions.
+func TestCallUnitNoPanic_timer(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(context.Context) error
+	}{
+		{"success", func(context.Context) error { time.Sleep(time.Millisecond); return nil }},
+		{"panic", func(context.Context) error { time.Sleep(time.Millisecond); panic("Panic error") }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			var got time.Duration
+			ctx := WithInvocationTimer(context.Background(), func(uid UnitID, d time.Duration) {
+				if uid != 42 {
+					t.Errorf("timer called with UnitID %v, want 42", uid)
+				}
+				calls++
+				got = d
+			})
+			callUnitNoPanic(ctx, 42, test.fn)
+			if calls != 1 {
+				t.Errorf("timer called %v times, want 1", calls)
+			}
+			if got <= 0 {
+				t.Errorf("timer duration = %v, want > 0", got)
+			}
+		})
+	}
+}
+
+// TestGenID_Reserve verifies that reserved blocks are contiguous, don't
+// overlap with subsequent IDs and can't be overdrawn.
+func TestGenID_Reserve(t *testing.T) {
+	var g GenID
+	if got, want := g.New(), UnitID(1); got != want {
+		t.Fatalf("New() = %v, want %v", got, want)
+	}
+	first, next := g.Reserve(3)
+	if got, want := first, UnitID(2); got != want {
+		t.Errorf("Reserve(3) first = %v, want %v", got, want)
+	}
+	if got, want := g.New(), UnitID(5); got != want {
+		t.Errorf("New() after Reserve(3) = %v, want %v", got, want)
+	}
+	for i := 0; i < 3; i++ {
+		if got, want := next(), first+UnitID(i); got != want {
+			t.Errorf("next() call %v = %v, want %v", i, got, want)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("next() on exhausted block didn't panic")
+		}
+	}()
+	next()
+}
+
+// TestGenID_concurrent verifies that concurrent New calls return distinct IDs.
+func TestGenID_concurrent(t *testing.T) {
+	const goroutines, calls = 100, 1000
+
+	var g GenID
+	ids := make([][]UnitID, goroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < calls; j++ {
+				ids[i] = append(ids[i], g.New())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[UnitID]bool)
+	for _, list := range ids {
+		for _, id := range list {
+			if seen[id] {
+				t.Fatalf("New() returned duplicate ID %v", id)
+			}
+			seen[id] = true
+		}
+	}
+	if got, want := len(seen), goroutines*calls; got != want {
+		t.Errorf("got %v unique IDs, want %v", got, want)
+	}
+}
+
+// drainNode is a test Node that counts Draining calls.
+type drainNode struct {
+	Discard
+	drained int
+	err     error
+}
+
+func (n *drainNode) Draining(ctx context.Context) error {
+	n.drained++
+	return n.err
+}
+
+// finishNode is a test Node that counts FinishBundle calls.
+type finishNode struct {
+	Discard
+	finished int
+	err      error
+}
+
+func (n *finishNode) FinishBundle(ctx context.Context) error {
+	n.finished++
+	return n.err
+}
+
+// resetNode is a test Node that counts Reset calls.
+type resetNode struct {
+	Discard
+	reset int
+	err   error
+}
+
+func (n *resetNode) Reset(ctx context.Context) error {
+	n.reset++
+	return n.err
+}
+
+// TestMultiReset verifies that only Resettable nodes are reset, and that the
+// first error is returned.
+func TestMultiReset(t *testing.T) {
+	ctx := context.Background()
+	a := &resetNode{Discard: Discard{UID: 1}}
+	b := &Discard{UID: 2}
+	c := &resetNode{Discard: Discard{UID: 3}}
+	if err := MultiReset(ctx, a, b, c); err != nil {
+		t.Fatalf("MultiReset failed: %v", err)
+	}
+	if a.reset != 1 || c.reset != 1 {
+		t.Errorf("MultiReset reset (%v, %v) times, want (1, 1)", a.reset, c.reset)
+	}
+
+	a.err = errors.New("reset error")
+	if err := MultiReset(ctx, a, b, c); err == nil || !strings.Contains(err.Error(), "reset error") {
+		t.Errorf("MultiReset(<failing node>) = %v, want reset error", err)
+	}
+	if c.reset != 1 {
+		t.Errorf("MultiReset reset c %v times after failure, want 1", c.reset)
+	}
+}
+
+// TestMultiFinishBundleAll verifies that all nodes are finished despite
+// failures, and that the error reports all failing nodes and wraps the first
+// error.
+func TestMultiFinishBundleAll(t *testing.T) {
+	ctx := context.Background()
+	a := &finishNode{Discard: Discard{UID: 1}}
+	b := &finishNode{Discard: Discard{UID: 2}}
+	c := &finishNode{Discard: Discard{UID: 3}}
+	if err := MultiFinishBundleAll(ctx, a, b, c); err != nil {
+		t.Fatalf("MultiFinishBundleAll failed: %v", err)
+	}
+
+	a.err = errors.New("finish error a")
+	err := MultiFinishBundleAll(ctx, a, b, c)
+	if err == nil || !strings.Contains(err.Error(), "finish error a") || !strings.Contains(err.Error(), "node 1") {
+		t.Errorf("MultiFinishBundleAll(<failing node>) = %v, want error for node 1", err)
+	}
+
+	c.err = errors.New("finish error c")
+	err = MultiFinishBundleAll(ctx, a, b, c)
+	for _, want := range []string{"nodes [1 3]", "finish error a", "finish error c"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("MultiFinishBundleAll(<failing nodes>) = %v, want it to contain %q", err, want)
+		}
+	}
+	if u, ok := err.(interface{ Unwrap() error }); !ok {
+		t.Errorf("MultiFinishBundleAll(<failing nodes>) = %v, want it to wrap %v", err, a.err)
+	} else if c, ok := u.Unwrap().(*nodeChainError); !ok || c.err != a.err {
+		t.Errorf("MultiFinishBundleAll(<failing nodes>) = %v, want it to wrap %v", err, a.err)
+	}
+	if got, want := NodeChain(err), []UnitID{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeChain(%v) = %v, want %v", err, got, want)
+	}
+	for _, n := range []*finishNode{a, b, c} {
+		if n.finished != 3 {
+			t.Errorf("MultiFinishBundleAll finished node %v %v times, want 3", n.UID, n.finished)
+		}
+	}
+}
+
+// TestMultiDrain verifies that only Drainable nodes are drained, and that the
+// first error is returned.
+func TestMultiDrain(t *testing.T) {
+	ctx := context.Background()
+	a := &drainNode{Discard: Discard{UID: 1}}
+	b := &Discard{UID: 2}
+	c := &drainNode{Discard: Discard{UID: 3}}
+	if err := MultiDrain(ctx, a, b, c); err != nil {
+		t.Fatalf("MultiDrain failed: %v", err)
+	}
+	if a.drained != 1 || c.drained != 1 {
+		t.Errorf("MultiDrain drained (%v, %v) times, want (1, 1)", a.drained, c.drained)
+	}
+
+	a.err = errors.New("drain error")
+	if err := MultiDrain(ctx, a, b, c); err == nil || !strings.Contains(err.Error(), "drain error") {
+		t.Errorf("MultiDrain(<failing node>) = %v, want drain error", err)
+	}
+	if c.drained != 1 {
+		t.Errorf("MultiDrain drained c %v times after failure, want 1", c.drained)
+	}
+}
+
+// TestCallNoPanic_category verifies that user-classified errors are
+// recognized through DoFn errors and further wrapping.
+func TestCallNoPanic_category(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		err  error
+		want ErrorCategory
+	}{
+		{errors.New("unclassified"), Unknown},
+		{TransientError(errors.New("flaky")), Transient},
+		{PermanentError(errors.New("bad input")), Permanent},
+		{errors.Wrap(TransientError(errors.New("flaky")), "wrapped"), Transient},
+	}
+	for _, test := range tests {
+		dfErr := &doFnError{doFn: "fn", err: test.err, uid: 1, pid: "Plan ID"}
+		want := dfErr.Error()
+		got := callNoPanic(ctx, func(c context.Context) error {
+			return errors.Wrap(dfErr, "while processing")
+		})
+		if c := Category(got); c != test.want {
+			t.Errorf("Category(%v) = %v, want %v", got, c, test.want)
+		}
+		if dfErr.Category != test.want {
+			t.Errorf("doFnError.Category = %v, want %v", dfErr.Category, test.want)
+		}
+		if dfErr.Error() != want {
+			t.Errorf("doFnError.Error() = %v, want %v", dfErr.Error(), want)
+		}
+	}
+	if got := Category(nil); got != Unknown {
+		t.Errorf("Category(nil) = %v, want %v", got, Unknown)
+	}
+}
+
+// TestNodesByID verifies that NodesByID round-trips with IDs and rejects
+// duplicate IDs.
+func TestNodesByID(t *testing.T) {
+	list := []Node{&Discard{UID: 3}, &Discard{UID: 1}, &Discard{UID: 2}}
+	m := NodesByID(list...)
+	ids := IDs(list...)
+	if got, want := len(m), len(ids); got != want {
+		t.Fatalf("len(NodesByID) = %v, want %v", got, want)
+	}
+	for i, id := range ids {
+		if m[id] != list[i] {
+			t.Errorf("NodesByID[%v] = %v, want %v", id, m[id], list[i])
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("NodesByID(<duplicate IDs>) didn't panic")
+		}
+	}()
+	NodesByID(&Discard{UID: 1}, &Discard{UID: 1})
+}
+
+// TestCallNoPanic_metrics verifies that recovered panics are counted in the
+// bundle's metric store.
+func TestCallNoPanic_metrics(t *testing.T) {
+	ctx := metrics.SetBundleID(context.Background(), "bundle")
+	parDoError := &doFnError{doFn: "sumFn", err: errors.New("SumFn error"), uid: 1, pid: "pardo"}
+
+	callNoPanic(ctx, func(c context.Context) error { panic("Panic error") })
+	callNoPanic(ctx, func(c context.Context) error { panic("Panic error") })
+	callNoPanic(ctx, func(c context.Context) error { panic(parDoError) })
+	callNoPanic(ctx, func(c context.Context) error { return errors.New("Simple error.") })
+
+	got := make(map[string]int64)
+	metrics.Extractor{
+		SumInt64: func(l metrics.Labels, v int64) {
+			got[l.Transform()+"/"+l.Namespace()+"."+l.Name()] = v
+		},
+	}.ExtractFrom(metrics.GetStore(ctx))
+
+	want := map[string]int64{
+		"/exec.panicsRecovered.raw":            2,
+		"pardo/exec.panicsRecovered.doFnError": 1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got counters %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("counter %v = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+// startNode is a test Node that tracks concurrent StartBundle calls.
+type startNode struct {
+	Discard
+	err error
+
+	mu      *sync.Mutex
+	active  *int
+	maxSeen *int
+	started bool
+	ctx     context.Context
+}
+
+func (n *startNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	n.mu.Lock()
+	*n.active++
+	if *n.active > *n.maxSeen {
+		*n.maxSeen = *n.active
+	}
+	n.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	n.mu.Lock()
+	*n.active--
+	n.started = true
+	n.ctx = ctx
+	n.mu.Unlock()
+	return n.err
+}
+
+// blockingStartNode is a test Node whose StartBundle blocks until its context
+// is canceled.
+type blockingStartNode struct {
+	Discard
+}
+
+func (n *blockingStartNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestMultiStartBundleParallel verifies that StartBundle is called on all
+// nodes within the concurrency bound, and that errors are returned after all
+// in-flight calls complete. The context of the nodes must stay live after
+// success, as they may keep it for processing the bundle, and be canceled on
+// error, including by the last node.
+func TestMultiStartBundleParallel(t *testing.T) {
+	ctx := context.Background()
+	makeNodes := func(n int, fail int) ([]Node, *int, *int, *sync.Mutex) {
+		var mu sync.Mutex
+		var active, maxSeen int
+		var list []Node
+		for i := 0; i < n; i++ {
+			sn := &startNode{Discard: Discard{UID: UnitID(i)}, mu: &mu, active: &active, maxSeen: &maxSeen}
+			if i == fail {
+				sn.err = errors.New("start error")
+			}
+			list = append(list, sn)
+		}
+		return list, &active, &maxSeen, &mu
+	}
+
+	t.Run("success", func(t *testing.T) {
+		list, _, maxSeen, _ := makeNodes(20, -1)
+		if err := MultiStartBundleParallel(ctx, "1", DataContext{}, 4, list...); err != nil {
+			t.Fatalf("MultiStartBundleParallel failed: %v", err)
+		}
+		for _, n := range list {
+			if !n.(*startNode).started {
+				t.Errorf("node %v was not started", n.ID())
+			}
+			if err := n.(*startNode).ctx.Err(); err != nil {
+				t.Errorf("node %v context after success = %v, want live context", n.ID(), err)
+			}
+		}
+		if *maxSeen > 4 {
+			t.Errorf("saw %v concurrent StartBundle calls, want <= 4", *maxSeen)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		list, active, _, mu := makeNodes(20, 0)
+		err := MultiStartBundleParallel(ctx, "1", DataContext{}, 4, list...)
+		if err == nil || !strings.Contains(err.Error(), "start error") {
+			t.Errorf("MultiStartBundleParallel(<failing node>) = %v, want start error", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if *active != 0 {
+			t.Errorf("%v StartBundle calls still in flight after return, want 0", *active)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		// All but the last node block until their context is canceled.
+		var list []Node
+		for i := 0; i < 3; i++ {
+			list = append(list, &blockingStartNode{Discard: Discard{UID: UnitID(i)}})
+		}
+		list = append(list, &startNode{Discard: Discard{UID: 3}, err: errors.New("start error"), mu: &sync.Mutex{}, active: new(int), maxSeen: new(int)})
+		err := MultiStartBundleParallel(ctx, "1", DataContext{}, 4, list...)
+		if err == nil || !strings.Contains(err.Error(), "start error") {
+			t.Errorf("MultiStartBundleParallel(<failing last node>) = %v, want start error", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := MultiStartBundleParallel(ctx, "1", DataContext{}, 0); err == nil {
+			t.Errorf("MultiStartBundleParallel(<concurrency 0>) = nil, want error")
+		}
+	})
+}
+
+// TestCallNoPanic_filter verifies that panics rejected by the filter
+// propagate, while accepted ones and DoFn errors are recovered.
+func TestCallNoPanic_filter(t *testing.T) {
+	type userPanic struct{}
+	ctx := WithPanicFilter(context.Background(), func(r interface{}) bool {
+		_, user := r.(userPanic)
+		return !user
+	})
+
+	if err := callNoPanic(ctx, func(c context.Context) error { panic("Panic error") }); err == nil {
+		t.Errorf("callNoPanic(<func that panics with a string>) = nil, want error")
+	}
+	parDoError := &doFnError{doFn: "sumFn", err: errors.New("SumFn error"), uid: 1, pid: "Plan ID"}
+	if err := callNoPanic(ctx, func(c context.Context) error { panic(parDoError) }); err != parDoError {
+		t.Errorf("callNoPanic(<func that panics with a DoFn error>) = %v, want %v", err, parDoError)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("callNoPanic(<func that panics with a filtered value>) didn't panic")
+		} else if _, ok := r.(userPanic); !ok {
+			t.Errorf("callNoPanic(<func that panics with a filtered value>) panicked with %v, want userPanic", r)
+		}
+	}()
+	callNoPanic(ctx, func(c context.Context) error { panic(userPanic{}) })
+}
+
+// TestStageID verifies that DoFn errors failing a plan are annotated with the
+// ID of the plan, which StageID extracts, and that other errors have none.
+func TestStageID(t *testing.T) {
+	dfe := &doFnError{doFn: "fn", err: errors.New("boom"), uid: 2, pid: "p"}
+	out := &ErrorNode{UID: 2, Err: dfe}
+	root := &FixedRoot{UID: 1, Elements: makeInput(1), Out: out}
+	p, err := NewPlan("stage1", []Unit{out, root})
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+	err = p.Execute(context.Background(), "bundle", DataContext{})
+	if err == nil {
+		t.Fatal("Execute succeeded, want error")
+	}
+	if got, want := StageID(err), "stage1"; got != want {
+		t.Errorf("StageID(%v) = %q, want %q", err, got, want)
+	}
+	if got, want := dfe.Error(), "Stage: stage1"; !strings.Contains(got, want) {
+		t.Errorf("Error() = %q, want it to contain %q", got, want)
+	}
+	if got := StageID(errors.New("other")); got != "" {
+		t.Errorf("StageID(non-DoFn error) = %q, want \"\"", got)
+	}
+}
+
+// TestCallNoPanic_abort verifies that aborted bundles result in the
+// BundleAbortedError, whether returned or panicked, and attributed to the
+// DoFn error wrapping it, if any.
+func TestCallNoPanic_abort(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &doFnError{doFn: "abortFn", err: errors.Wrap(AbortBundle("stale"), "context"), uid: 1, pid: "abort"}
+	tests := []struct {
+		name string
+		fn   func(context.Context) error
+		pid  string
+	}{
+		{name: "returned", fn: func(context.Context) error { return AbortBundle("stale") }},
+		{name: "panicked", fn: func(context.Context) error { panic(AbortBundle("stale")) }},
+		{name: "returned DoFn error", fn: func(context.Context) error { return wrapped }, pid: "abort"},
+		{name: "panicked DoFn error", fn: func(context.Context) error { panic(wrapped) }, pid: "abort"},
+	}
+	for _, test := range tests {
+		got := callNoPanic(ctx, test.fn)
+		abort, ok := got.(*BundleAbortedError)
+		if !ok {
+			t.Errorf("callNoPanic(<%v>) = %v, want BundleAbortedError", test.name, got)
+			continue
+		}
+		if abort.Reason != "stale" || abort.PID != test.pid {
+			t.Errorf("callNoPanic(<%v>) = %+v, want abort \"stale\" of %q", test.name, abort, test.pid)
+		}
+	}
+}
+
+// finishFailFn is a DoFn that fails in FinishBundle.
+type finishFailFn struct{}
+
+func (fn *finishFailFn) ProcessElement(n int) int {
+	return n
+}
+
+func (fn *finishFailFn) FinishBundle() error {
+	return errors.New("finish failed")
+}
+
+// startFailNode is a test Node that fails in StartBundle.
+type startFailNode struct {
+	Discard
+	err error
+}
+
+func (n *startFailNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return n.err
+}
+
+// TestNodeChain verifies that StartBundle and FinishBundle errors record the
+// nodes from the failing one up to the root, in the error and its message.
+func TestNodeChain(t *testing.T) {
+	ctx := context.Background()
+	emitSum, err := graph.NewDoFn(emitSumFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	failFinish, err := graph.NewDoFn(&finishFailFn{})
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+
+	t.Run("node", func(t *testing.T) {
+		sink := &finishNode{Discard: Discard{UID: 4}, err: errors.New("sink failed")}
+		flatten := &Flatten{UID: 3, N: 1, Out: sink}
+		pardo := &ParDo{UID: 2, PID: "upstream", Fn: emitSum, Out: []Node{flatten}}
+		root := &FixedRoot{UID: 1, Elements: makeInput(1), Out: pardo}
+		p, err := NewPlan("a", []Unit{root, pardo, flatten, sink})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		if err := p.Execute(ctx, "1", DataContext{}); err == nil {
+			t.Fatal("Execute(<failing FinishBundle>) succeeded, want error")
+		} else {
+			if got, want := NodeChain(err), []UnitID{4, 3, 2, 1}; !reflect.DeepEqual(got, want) {
+				t.Errorf("NodeChain(%v) = %v, want %v", err, got, want)
+			}
+			if !strings.Contains(err.Error(), "sink failed [node chain: 4 -> 3 -> 2 -> 1]") {
+				t.Errorf("Execute(<failing FinishBundle>) = %v, want it to contain the node chain", err)
+			}
+		}
+	})
+
+	t.Run("DoFn", func(t *testing.T) {
+		out := &CaptureNode{UID: 4}
+		pardo2 := &ParDo{UID: 3, PID: "failing", Fn: failFinish, Out: []Node{out}}
+		pardo1 := &ParDo{UID: 2, PID: "upstream", Fn: emitSum, Out: []Node{pardo2}}
+		root := &FixedRoot{UID: 1, Elements: makeInput(1), Out: pardo1}
+		p, err := NewPlan("a", []Unit{root, pardo1, pardo2, out})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		err = p.Execute(ctx, "1", DataContext{})
+		if err == nil {
+			t.Fatal("Execute(<failing FinishBundle>) succeeded, want error")
+		}
+		if got, want := NodeChain(err), []UnitID{3, 2, 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("NodeChain(%v) = %v, want %v", err, got, want)
+		}
+		if e, ok := AsDoFnError(err); !ok || e.pid != "failing" {
+			t.Errorf("AsDoFnError(%v) = %v, want error of DoFn failing", err, e)
+		}
+		if strings.Contains(err.Error(), "node chain") {
+			t.Errorf("Execute(<failing FinishBundle>) = %v, want the DoFn error message without the node chain", err)
+		}
+	})
+
+	t.Run("StartBundle", func(t *testing.T) {
+		sink := &startFailNode{Discard: Discard{UID: 3}, err: errors.New("start failed")}
+		flatten := &Flatten{UID: 2, N: 1, Out: sink}
+		err := MultiStartBundle(ctx, "1", DataContext{}, flatten)
+		if got, want := NodeChain(err), []UnitID{3, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("NodeChain(%v) = %v, want %v", err, got, want)
+		}
+	})
+
+	if got := NodeChain(errors.New("plain error")); got != nil {
+		t.Errorf("NodeChain(<plain error>) = %v, want nil", got)
+	}
+}
+
+// sumNode is a test Node that emits the sum of its elements at FinishBundle.
+type sumNode struct {
+	Discard
+	Out Node
+	sum int
+}
+
+func (n *sumNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.sum += elm.Elm.(int)
+	return nil
+}
+
+func (n *sumNode) FinishBundle(ctx context.Context) error {
+	return FinishBundleWithEmit(ctx, n, n.Out)
+}
+
+func (n *sumNode) EmitOnFinish(ctx context.Context, emit func(*FullValue) error) error {
+	return emit(&FullValue{Elm: n.sum, Timestamp: mtime.ZeroTimestamp, Windows: window.SingleGlobalWindow})
+}
+
+// eventNode is a test Node that records the elements and FinishBundle calls
+// it sees.
+type eventNode struct {
+	Discard
+	events []string
+}
+
+func (n *eventNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.events = append(n.events, fmt.Sprintf("element %v", elm.Elm))
+	return nil
+}
+
+func (n *eventNode) FinishBundle(ctx context.Context) error {
+	n.events = append(n.events, "finish")
+	return nil
+}
+
+// TestFinishBundleWithEmit verifies that elements emitted at FinishBundle
+// are passed downstream before the downstream FinishBundle, and that they
+// must have a window and a timestamp within it.
+func TestFinishBundleWithEmit(t *testing.T) {
+	ctx := context.Background()
+	out := &eventNode{Discard: Discard{UID: 3}}
+	sum := &sumNode{Discard: Discard{UID: 2}, Out: out}
+	root := &FixedRoot{UID: 1, Elements: makeInput(1, 2, 3), Out: sum}
+	p, err := NewPlan("a", []Unit{root, sum, out})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	if err := p.Execute(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got, want := out.events, []string{"element 6", "finish"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	w := window.IntervalWindow{Start: 0, End: 1000}
+	tests := []struct {
+		name string
+		elm  *FullValue
+	}{
+		{name: "no window", elm: &FullValue{Elm: 1}},
+		{name: "after window", elm: &FullValue{Elm: 1, Timestamp: w.End, Windows: []typex.Window{w}}},
+	}
+	for _, test := range tests {
+		out := &eventNode{Discard: Discard{UID: 2}}
+		emitter := &emitFinishNode{Discard: Discard{UID: 1}, elm: test.elm}
+		if err := FinishBundleWithEmit(ctx, emitter, out); err == nil {
+			t.Errorf("FinishBundleWithEmit(<%v>) succeeded, want error", test.name)
+		}
+		if len(out.events) != 0 {
+			t.Errorf("FinishBundleWithEmit(<%v>) passed on %v, want nothing", test.name, out.events)
+		}
+	}
+}
+
+// emitFinishNode is a test Node that emits a fixed element at FinishBundle.
+type emitFinishNode struct {
+	Discard
+	elm *FullValue
+}
+
+func (n *emitFinishNode) EmitOnFinish(ctx context.Context, emit func(*FullValue) error) error {
+	return emit(n.elm)
+}
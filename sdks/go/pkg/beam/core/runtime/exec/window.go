@@ -23,6 +23,7 @@ import (
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 )
 
 // WindowInto places each element in one or more windows.
@@ -41,7 +42,7 @@ func (w *WindowInto) Up(ctx context.Context) error {
 }
 
 func (w *WindowInto) StartBundle(ctx context.Context, id string, data DataContext) error {
-	return w.Out.StartBundle(ctx, id, data)
+	return MultiStartBundle(ctx, id, data, w.Out)
 }
 
 func (w *WindowInto) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
@@ -85,7 +86,7 @@ func assignWindows(wfn *window.Fn, ts typex.EventTime) []typex.Window {
 }
 
 func (w *WindowInto) FinishBundle(ctx context.Context) error {
-	return w.Out.FinishBundle(ctx)
+	return MultiFinishBundle(ctx, w.Out)
 }
 
 func (w *WindowInto) Down(ctx context.Context) error {
@@ -95,3 +96,87 @@ func (w *WindowInto) Down(ctx context.Context) error {
 func (w *WindowInto) String() string {
 	return fmt.Sprintf("WindowInto[%v]. Out:%v", w.Fn, w.Out.ID())
 }
+
+// ReWindow reassigns each element to the windows of a new WindowFn, based on
+// its timestamp, and emits it once per assigned window. Unlike WindowInto, it
+// explodes multiple windows, such as from sliding windows, into separate
+// elements.
+type ReWindow struct {
+	UID UnitID
+	Fn  *window.Fn
+	Out Node
+}
+
+// NewReWindow returns a ReWindow that applies the given WindowFn and emits to
+// out. The caller sets the UID of the node.
+func NewReWindow(out Node, wfn *window.Fn) *ReWindow {
+	return &ReWindow{Fn: wfn, Out: out}
+}
+
+func (w *ReWindow) ID() UnitID {
+	return w.UID
+}
+
+// Up validates the WindowFn.
+func (w *ReWindow) Up(ctx context.Context) error {
+	if w.Fn == nil {
+		return errors.Errorf("missing window fn for rewindow %v", w.UID)
+	}
+	switch w.Fn.Kind {
+	case window.GlobalWindows:
+		return nil
+	case window.FixedWindows:
+		if w.Fn.Size <= 0 {
+			return errors.Errorf("invalid window fn for rewindow %v: %v, size must be positive", w.UID, w.Fn)
+		}
+	case window.SlidingWindows:
+		if w.Fn.Size <= 0 || w.Fn.Period <= 0 {
+			return errors.Errorf("invalid window fn for rewindow %v: %v, size and period must be positive", w.UID, w.Fn)
+		}
+	case window.Sessions:
+		if w.Fn.Gap <= 0 {
+			return errors.Errorf("invalid window fn for rewindow %v: %v, gap must be positive", w.UID, w.Fn)
+		}
+	default:
+		return errors.Errorf("unsupported window fn for rewindow %v: %v", w.UID, w.Fn)
+	}
+	return nil
+}
+
+func (w *ReWindow) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return MultiStartBundle(ctx, id, data, w.Out)
+}
+
+// ProcessElement emits the element in each window it is assigned to. Except
+// for global windows, the timestamp must not be after the end of the global
+// window.
+func (w *ReWindow) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if elm.Timestamp < mtime.MinTimestamp || elm.Timestamp > mtime.MaxTimestamp ||
+		(w.Fn.Kind != window.GlobalWindows && elm.Timestamp > mtime.EndOfGlobalWindowTime) {
+		return errors.Errorf("invalid timestamp for %v in rewindow %v: %v is outside the windowable range [%v, %v]", w.Fn, w.UID, elm.Timestamp, mtime.MinTimestamp, mtime.EndOfGlobalWindowTime)
+	}
+	for _, win := range assignWindows(w.Fn, elm.Timestamp) {
+		windowed := &FullValue{
+			Windows:   []typex.Window{win},
+			Timestamp: elm.Timestamp,
+			Elm:       elm.Elm,
+			Elm2:      elm.Elm2,
+		}
+		if err := w.Out.ProcessElement(ctx, windowed, values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ReWindow) FinishBundle(ctx context.Context) error {
+	return MultiFinishBundle(ctx, w.Out)
+}
+
+func (w *ReWindow) Down(ctx context.Context) error {
+	return nil
+}
+
+func (w *ReWindow) String() string {
+	return fmt.Sprintf("ReWindow[%v]. Out:%v", w.Fn, w.Out.ID())
+}
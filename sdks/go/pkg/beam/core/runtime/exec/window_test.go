@@ -16,6 +16,8 @@
 package exec
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -113,3 +115,45 @@ func TestAssignWindow(t *testing.T) {
 		}
 	}
 }
+
+// TestReWindow verifies that elements are emitted once per assigned window,
+// and that timestamps outside of the windowable range are rejected.
+func TestReWindow(t *testing.T) {
+	ctx := context.Background()
+	out := &CaptureNode{UID: 1}
+	n := NewReWindow(out, window.NewSlidingWindows(time.Minute, 3*time.Minute))
+	n.UID = 2
+	if err := n.Up(ctx); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := out.Up(ctx); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+		t.Fatalf("start bundle failed: %v", err)
+	}
+	if err := n.ProcessElement(ctx, &FullValue{Elm: 1, Timestamp: 60000, Windows: window.SingleGlobalWindow}); err != nil {
+		t.Fatalf("process element failed: %v", err)
+	}
+	want := []typex.Window{
+		window.IntervalWindow{Start: 60000, End: 240000},
+		window.IntervalWindow{Start: 0, End: 180000},
+		window.IntervalWindow{Start: -60000, End: 120000},
+	}
+	if len(out.Elements) != len(want) {
+		t.Fatalf("rewindow emitted %v elements, want %v", len(out.Elements), len(want))
+	}
+	for i, e := range out.Elements {
+		if !window.IsEqualList(e.Windows, want[i:i+1]) || e.Elm != 1 || e.Timestamp != 60000 {
+			t.Errorf("element %v = %v, want 1 [@60000:%v]", i, e, want[i])
+		}
+	}
+
+	err := n.ProcessElement(ctx, &FullValue{Elm: 2, Timestamp: mtime.MaxTimestamp})
+	if err == nil || !strings.Contains(err.Error(), "invalid timestamp") {
+		t.Errorf("ProcessElement with timestamp %v = %v, want invalid timestamp error", mtime.MaxTimestamp, err)
+	}
+	if bad := NewReWindow(out, window.NewFixedWindows(0)); bad.Up(ctx) == nil {
+		t.Errorf("Up with zero size fixed windows succeeded, want error")
+	}
+}
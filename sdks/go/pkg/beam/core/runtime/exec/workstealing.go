@@ -0,0 +1,388 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+var workStolen = metrics.NewCounter("exec", "workStealing.stolen")
+
+// defaultStealBuffer is the number of elements a WorkStealingNode buffers
+// unless set otherwise.
+const defaultStealBuffer = 100
+
+// WorkStealingPool is shared by the instances of a stage processed in
+// parallel in the same process, such as by plans built from the same bundle
+// descriptor, for them to steal buffered elements from each other. It is
+// safe for concurrent use.
+type WorkStealingPool struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// active holds the instances with a bundle in progress.
+	active []*WorkStealingNode
+	// inFlight holds the instance processing each key, or holding stolen
+	// elements of it.
+	inFlight map[string]*WorkStealingNode
+}
+
+// NewWorkStealingPool returns an empty pool.
+func NewWorkStealingPool() *WorkStealingPool {
+	p := &WorkStealingPool{inFlight: make(map[string]*WorkStealingNode)}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// WorkStealingNode wraps an instance of a stage and buffers up to Buffer
+// elements passed to it, so that instances sharing its Pool can steal them once
+// they are done with their own bundles. An instance that finishes its bundle
+// first processes its own buffered elements, then steals from the instance with
+// the most buffered elements until none are left, and then finishes the wrapped
+// node. Stolen elements are processed by the wrapped node of the stealing
+// instance, in its bundle, with their windows and timestamps unchanged, and are
+// counted in the workStealing.stolen counter. The bundle of an instance only
+// finishes once the elements stolen from it are processed, and fails if any of
+// them failed.
+//
+// If KeyCoder is set, elements must be KVs, and keys keep affinity to an
+// instance: the buffered elements of a key are stolen together, and never
+// while the key is being processed, so that the elements of a key are
+// processed by one instance at a time, in order. Stateful DoFns require a
+// KeyCoder, and additionally that the runner doesn't confine the state and
+// timers of a key to the bundle that received it, since they are accessed
+// from the bundle of the stealing instance; the node must not be used for
+// them otherwise. Without a KeyCoder, any element may be stolen on its own.
+//
+// The elements buffered by a bundle that fails are dropped, but those already
+// stolen from it are still processed, so the stage must tolerate an element
+// being processed more than once when bundles are retried. GBK and CoGBK
+// results aren't supported, since their values may be bound to the data
+// channel of a bundle.
+type WorkStealingNode struct {
+	Node
+	Pool     *WorkStealingPool
+	KeyCoder *coder.Coder
+	Buffer   int
+
+	enc ElementEncoder
+	buf bytes.Buffer
+
+	// queue and bundle are guarded by the mutex of the pool.
+	queue  []stealEntry
+	bundle *stealBundle
+}
+
+// stealEntry is a buffered element, with its encoded key if keyed.
+type stealEntry struct {
+	key string
+	elm *FullValue
+}
+
+// stealBundle tracks the elements stolen from a bundle of an instance.
+type stealBundle struct {
+	outstanding int
+	err         error
+}
+
+// NewWorkStealingNode returns a node that passes elements to out, sharing
+// them with the other instances of the stage in the given pool. If keyCoder
+// is not nil, the elements of a key are kept together.
+func NewWorkStealingNode(out Node, pool *WorkStealingPool, keyCoder *coder.Coder) *WorkStealingNode {
+	return &WorkStealingNode{Node: out, Pool: pool, KeyCoder: keyCoder, Buffer: defaultStealBuffer}
+}
+
+// Up validates the node and brings up the wrapped node.
+func (n *WorkStealingNode) Up(ctx context.Context) error {
+	if n.Pool == nil {
+		return errors.Errorf("missing pool for work stealing node %v", n.ID())
+	}
+	if n.Buffer < 1 {
+		return errors.Errorf("invalid buffer size for work stealing node %v: %v", n.ID(), n.Buffer)
+	}
+	if n.KeyCoder != nil {
+		n.enc = MakeElementEncoder(n.KeyCoder)
+	}
+	return n.Node.Up(ctx)
+}
+
+// StartBundle adds the instance to the pool, and starts the wrapped node.
+func (n *WorkStealingNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	b := n.Pool.join(n)
+	// Bundles failing before FinishBundle leave the pool once they end. The
+	// node may also be run outside of a plan, which then leaves it on failure.
+	_ = RegisterBundleCleanup(ctx, func() error {
+		n.Pool.leave(n, b)
+		return nil
+	})
+	return n.Node.StartBundle(ctx, id, data)
+}
+
+// ProcessElement buffers the element, and processes buffered elements of the
+// instance beyond the buffer size.
+func (n *WorkStealingNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	if len(values) > 0 {
+		return errors.Errorf("work stealing node %v does not support GBK/CoGBK results", n.ID())
+	}
+	e := stealEntry{elm: CloneFullValue(elm)}
+	if n.KeyCoder != nil {
+		if elm.Elm2 == nil {
+			return errors.Errorf("invalid element %v at work stealing node %v: want KV", elm, n.ID())
+		}
+		n.buf.Reset()
+		if err := n.enc.Encode(&FullValue{Elm: elm.Elm}, &n.buf); err != nil {
+			return errors.WithContextf(err, "encoding key %v at work stealing node %v", elm.Elm, n.ID())
+		}
+		e.key = n.buf.String()
+	}
+	p := n.Pool
+	p.mu.Lock()
+	n.queue = append(n.queue, e)
+	p.mu.Unlock()
+
+	for {
+		p.mu.Lock()
+		over := len(n.queue) > n.Buffer
+		p.mu.Unlock()
+		if !over {
+			return nil
+		}
+		if _, err := n.processOwn(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// FinishBundle processes the buffered elements of the instance, steals from
+// other instances until they have no elements left to steal, and waits for
+// the elements stolen from it before finishing the wrapped node.
+func (n *WorkStealingNode) FinishBundle(ctx context.Context) error {
+	for {
+		ok, err := n.processOwn(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+	}
+	for {
+		ok, err := n.steal(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	p := n.Pool
+	p.mu.Lock()
+	b := n.bundle
+	for b.outstanding > 0 {
+		p.cond.Wait()
+	}
+	p.mu.Unlock()
+	p.leave(n, b)
+	if b.err != nil {
+		return errors.Wrapf(b.err, "element stolen from work stealing node %v failed", n.ID())
+	}
+	return n.Node.FinishBundle(ctx)
+}
+
+// processOwn processes the oldest buffered element of the instance whose key
+// isn't held by another instance, waiting for one if need be. It returns false
+// if no elements are buffered.
+func (n *WorkStealingNode) processOwn(ctx context.Context) (bool, error) {
+	p := n.Pool
+	p.mu.Lock()
+	var e stealEntry
+	for {
+		if len(n.queue) == 0 {
+			p.mu.Unlock()
+			return false, nil
+		}
+		i := n.next()
+		if i >= 0 {
+			e = n.queue[i]
+			n.queue = append(n.queue[:i], n.queue[i+1:]...)
+			break
+		}
+		p.cond.Wait()
+	}
+	if n.KeyCoder != nil {
+		p.inFlight[e.key] = n
+	}
+	p.mu.Unlock()
+
+	err := n.Node.ProcessElement(ctx, e.elm)
+
+	if n.KeyCoder != nil {
+		p.mu.Lock()
+		delete(p.inFlight, e.key)
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+	return true, err
+}
+
+// next returns the index of the oldest buffered element whose key isn't held
+// by another instance, or -1 if there is none. The pool must be locked.
+func (n *WorkStealingNode) next() int {
+	if n.KeyCoder == nil {
+		return 0
+	}
+	for i, e := range n.queue {
+		if holder, ok := n.Pool.inFlight[e.key]; !ok || holder == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// steal takes buffered elements from the instance with the most of them, and
+// processes them. It takes the newest element, along with the other elements
+// of its key if keyed, skipping keys held by an instance. It returns false if
+// no elements could be stolen.
+func (n *WorkStealingNode) steal(ctx context.Context) (bool, error) {
+	p := n.Pool
+	p.mu.Lock()
+	victim, stolen := p.stealFrom(n)
+	if victim == nil {
+		p.mu.Unlock()
+		return false, nil
+	}
+	b, keyed := victim.bundle, victim.KeyCoder != nil
+	b.outstanding += len(stolen)
+	if keyed {
+		p.inFlight[stolen[0].key] = n
+	}
+	p.mu.Unlock()
+
+	workStolen.Inc(ctx, int64(len(stolen)))
+	var err error
+	for _, e := range stolen {
+		if err = n.Node.ProcessElement(ctx, e.elm); err != nil {
+			break
+		}
+	}
+
+	p.mu.Lock()
+	if keyed {
+		delete(p.inFlight, stolen[0].key)
+	}
+	b.outstanding -= len(stolen)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	return true, err
+}
+
+// stealFrom removes elements to steal for the given instance from the other
+// instance with the most buffered elements that has any to steal, and returns
+// them with their instance. The pool must be locked.
+func (p *WorkStealingPool) stealFrom(thief *WorkStealingNode) (*WorkStealingNode, []stealEntry) {
+	var victim *WorkStealingNode
+	at := -1
+	for _, v := range p.active {
+		if v == thief || (victim != nil && len(v.queue) <= len(victim.queue)) {
+			continue
+		}
+		if i := p.stealable(v); i >= 0 {
+			victim, at = v, i
+		}
+	}
+	if victim == nil {
+		return nil, nil
+	}
+	if victim.KeyCoder == nil {
+		e := victim.queue[at]
+		victim.queue = append(victim.queue[:at], victim.queue[at+1:]...)
+		return victim, []stealEntry{e}
+	}
+	key := victim.queue[at].key
+	var stolen []stealEntry
+	rest := victim.queue[:0]
+	for _, e := range victim.queue {
+		if e.key == key {
+			stolen = append(stolen, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	victim.queue = rest
+	return victim, stolen
+}
+
+// stealable returns the index of the newest buffered element of the instance
+// whose key isn't held by any instance, or -1 if there is none. The pool must
+// be locked.
+func (p *WorkStealingPool) stealable(v *WorkStealingNode) int {
+	for i := len(v.queue) - 1; i >= 0; i-- {
+		if v.KeyCoder == nil {
+			return i
+		}
+		if _, held := p.inFlight[v.queue[i].key]; !held {
+			return i
+		}
+	}
+	return -1
+}
+
+// join adds the instance to the pool for a new bundle.
+func (p *WorkStealingPool) join(n *WorkStealingNode) *stealBundle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remove(n)
+	n.queue = nil
+	n.bundle = &stealBundle{}
+	p.active = append(p.active, n)
+	return n.bundle
+}
+
+// leave removes the instance from the pool, if it is still in the given
+// bundle, and drops its buffered elements.
+func (p *WorkStealingPool) leave(n *WorkStealingNode, b *stealBundle) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n.bundle != b {
+		return
+	}
+	p.remove(n)
+	n.queue = nil
+	p.cond.Broadcast()
+}
+
+func (p *WorkStealingPool) remove(n *WorkStealingNode) {
+	for i, v := range p.active {
+		if v == n {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			return
+		}
+	}
+}
+
+func (n *WorkStealingNode) String() string {
+	return fmt.Sprintf("WorkStealingNode[%v]. Node:%v", n.Buffer, n.Node)
+}
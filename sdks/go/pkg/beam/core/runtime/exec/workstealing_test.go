@@ -0,0 +1,239 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// startStealing brings up the given work stealing nodes and starts a bundle
+// on each.
+func startStealing(t *testing.T, ctx context.Context, ns ...*WorkStealingNode) {
+	t.Helper()
+	for _, n := range ns {
+		if err := n.Up(ctx); err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		if err := n.StartBundle(ctx, "1", DataContext{}); err != nil {
+			t.Fatalf("StartBundle failed: %v", err)
+		}
+	}
+}
+
+// TestWorkStealingNode verifies that an idle instance steals the buffered
+// elements of a busy one, keeping the elements of a key together.
+func TestWorkStealingNode(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		keyCoder *coder.Coder
+		in       []FullValue
+		want     []interface{}
+	}{
+		{
+			name: "unkeyed",
+			in:   makeValues(1, 2, 3),
+			want: []interface{}{3, 2, 1},
+		},
+		{
+			name:     "keyed",
+			keyCoder: coder.NewString(),
+			in:       append(append(makeKV("a", 1), makeKV("b", 2)...), makeKV("a", 3)...),
+			want:     []interface{}{1, 3, 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := NewWorkStealingPool()
+			busyOut, idleOut := &CaptureNode{UID: 1}, &CaptureNode{UID: 2}
+			busy := NewWorkStealingNode(busyOut, pool, test.keyCoder)
+			idle := NewWorkStealingNode(idleOut, pool, test.keyCoder)
+			startStealing(t, ctx, busy, idle)
+
+			for i := range test.in {
+				if err := busy.ProcessElement(ctx, &test.in[i]); err != nil {
+					t.Fatalf("ProcessElement failed: %v", err)
+				}
+			}
+			if len(busyOut.Elements) != 0 {
+				t.Fatalf("busy instance processed %v elements before finishing, want them buffered", len(busyOut.Elements))
+			}
+			if err := idle.FinishBundle(ctx); err != nil {
+				t.Fatalf("idle FinishBundle failed: %v", err)
+			}
+			if err := busy.FinishBundle(ctx); err != nil {
+				t.Fatalf("busy FinishBundle failed: %v", err)
+			}
+
+			if len(busyOut.Elements) != 0 {
+				t.Errorf("busy instance processed %v, want all elements stolen", busyOut.Elements)
+			}
+			var got []interface{}
+			for _, v := range idleOut.Elements {
+				if v.Elm2 != nil {
+					got = append(got, v.Elm2)
+				} else {
+					got = append(got, v.Elm)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("stolen = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestWorkStealingNode_buffer verifies that elements beyond the buffer size
+// are processed by their own instance.
+func TestWorkStealingNode_buffer(t *testing.T) {
+	ctx := context.Background()
+	out := &CaptureNode{UID: 1}
+	n := NewWorkStealingNode(out, NewWorkStealingPool(), nil)
+	n.Buffer = 2
+	startStealing(t, ctx, n)
+
+	for _, v := range makeValues(1, 2, 3, 4) {
+		v := v
+		if err := n.ProcessElement(ctx, &v); err != nil {
+			t.Fatalf("ProcessElement failed: %v", err)
+		}
+	}
+	if got, want := extractValues(out.Elements...), []interface{}{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("processed before finishing = %v, want %v", got, want)
+	}
+	if err := n.FinishBundle(ctx); err != nil {
+		t.Fatalf("FinishBundle failed: %v", err)
+	}
+	if got, want := extractValues(out.Elements...), []interface{}{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("processed = %v, want %v", got, want)
+	}
+}
+
+// TestWorkStealingNode_stolenError verifies that an element failing in the
+// stealing instance fails the bundle of the instance it was stolen from.
+func TestWorkStealingNode_stolenError(t *testing.T) {
+	ctx := context.Background()
+	pool := NewWorkStealingPool()
+	busy := NewWorkStealingNode(&CaptureNode{UID: 1}, pool, nil)
+	idle := NewWorkStealingNode(&ErrorNode{UID: 2, Err: errors.New("boom")}, pool, nil)
+	startStealing(t, ctx, busy, idle)
+
+	if err := busy.ProcessElement(ctx, &FullValue{Elm: 1}); err != nil {
+		t.Fatalf("ProcessElement failed: %v", err)
+	}
+	if err := idle.FinishBundle(ctx); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("idle FinishBundle = %v, want boom", err)
+	}
+	if err := busy.FinishBundle(ctx); err == nil || !strings.Contains(err.Error(), "element stolen from work stealing node 1 failed") {
+		t.Errorf("busy FinishBundle = %v, want stolen element error", err)
+	}
+}
+
+// recordingNode is a terminal node that records the elements passed to it by
+// any number of plans, in order.
+type recordingNode struct {
+	UID UnitID
+
+	mu       *sync.Mutex
+	elements *[]FullValue
+}
+
+func (n *recordingNode) ID() UnitID {
+	return n.UID
+}
+
+func (n *recordingNode) Up(ctx context.Context) error {
+	return nil
+}
+
+func (n *recordingNode) StartBundle(ctx context.Context, id string, data DataContext) error {
+	return nil
+}
+
+func (n *recordingNode) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	*n.elements = append(*n.elements, *elm)
+	return nil
+}
+
+func (n *recordingNode) FinishBundle(ctx context.Context) error {
+	return nil
+}
+
+func (n *recordingNode) Down(ctx context.Context) error {
+	return nil
+}
+
+// TestWorkStealingNode_concurrent verifies that concurrent instances process
+// every element exactly once, and the elements of each key in order.
+func TestWorkStealingNode_concurrent(t *testing.T) {
+	var mu sync.Mutex
+	var got []FullValue
+	pool := NewWorkStealingPool()
+
+	const keys, perKey = 5, 200
+	inputs := [][]MainInput{nil, nil}
+	for i := 0; i < perKey; i++ {
+		for k := 0; k < keys; k++ {
+			inputs[0] = append(inputs[0], MainInput{Key: FullValue{Elm: fmt.Sprint(k), Elm2: i}})
+		}
+	}
+	inputs[1] = kvInput("x", 0)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(inputs))
+	for i, in := range inputs {
+		out := &recordingNode{UID: 1, mu: &mu, elements: &got}
+		n := NewWorkStealingNode(out, pool, coder.NewString())
+		n.Buffer = 10
+		root := &FixedRoot{UID: 2, Elements: in, Out: n}
+		p, err := NewPlan("a", []Unit{root, n, out})
+		if err != nil {
+			t.Fatalf("failed to construct plan: %v", err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = p.Execute(context.Background(), "1", DataContext{})
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+	}
+
+	if want := keys*perKey + 1; len(got) != want {
+		t.Fatalf("processed %v elements, want %v", len(got), want)
+	}
+	last := make(map[interface{}]int)
+	for _, v := range got {
+		if prev, ok := last[v.Elm]; ok && v.Elm2.(int) <= prev {
+			t.Fatalf("element %v of key %v processed after %v", v.Elm2, v.Elm, prev)
+		}
+		last[v.Elm] = v.Elm2.(int)
+	}
+}
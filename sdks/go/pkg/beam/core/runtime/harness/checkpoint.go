@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// CheckpointSaver saves a checkpoint of the given transform, taken while
+// processing the given instruction.
+type CheckpointSaver func(instID, transformID string, checkpoint []byte) error
+
+var (
+	checkpointMu       sync.Mutex
+	checkpointInterval time.Duration
+	checkpointSave     CheckpointSaver
+)
+
+// EnableCheckpointing makes the harness checkpoint the splittable DoFns of
+// each bundle at the given interval while it is processed, and pass the
+// checkpoints to save. A non-positive interval disables checkpointing.
+func EnableCheckpointing(interval time.Duration, save CheckpointSaver) {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	checkpointInterval, checkpointSave = interval, save
+}
+
+// startCheckpointing periodically checkpoints the given units, if enabled,
+// until the returned function is called.
+func startCheckpointing(ctx context.Context, instID instructionID, cps map[string]exec.Checkpointer) func() {
+	checkpointMu.Lock()
+	interval, save := checkpointInterval, checkpointSave
+	checkpointMu.Unlock()
+	if interval <= 0 || save == nil || len(cps) == 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for id, c := range cps {
+		id, c := id, c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := exec.PeriodicCheckpoint(ctx, c, interval, func(b []byte) error {
+				return save(string(instID), id, b)
+			})
+			if err != nil {
+				log.Errorf(ctx, "checkpointing %v stopped: %v", id, err)
+			}
+		}()
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
@@ -0,0 +1,58 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
+)
+
+type fixedCheckpointer []byte
+
+func (c fixedCheckpointer) Checkpoint() ([]byte, error) {
+	return c, nil
+}
+
+func (c fixedCheckpointer) Restore(checkpoint []byte) error {
+	return nil
+}
+
+func TestStartCheckpointing(t *testing.T) {
+	cps := map[string]exec.Checkpointer{"sdf": fixedCheckpointer("cp")}
+
+	// Disabled by default.
+	startCheckpointing(context.Background(), "inst1", cps)()
+
+	saved := make(chan string, 1)
+	EnableCheckpointing(time.Millisecond, func(instID, transformID string, checkpoint []byte) error {
+		select {
+		case saved <- instID + "/" + transformID + "/" + string(checkpoint):
+		default:
+		}
+		return nil
+	})
+	defer EnableCheckpointing(0, nil)
+
+	stop := startCheckpointing(context.Background(), "inst2", cps)
+	got := <-saved
+	stop()
+	if want := "inst2/sdf/cp"; got != want {
+		t.Errorf("saved checkpoint %v, want %v", got, want)
+	}
+}
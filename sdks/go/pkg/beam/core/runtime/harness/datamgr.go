@@ -344,7 +344,7 @@ func (c *DataChannel) makeReader(ctx context.Context, id clientID) *dataReader {
 		return r
 	}
 
-	r := &dataReader{id: id, buf: make(chan []byte, bufElements), done: make(chan bool, 1), channel: c}
+	r := &dataReader{id: id, buf: make(chan []byte, bufElements), done: make(chan bool, 1), closed: make(chan struct{}), channel: c}
 
 	// Just in case initial data for an instruction arrives *after* an instructon has ended.
 	// eg. it was blocked by another reader being slow, or the other instruction failed.
@@ -433,17 +433,38 @@ type dataReader struct {
 	channel   *DataChannel
 	completed bool
 	err       error
+
+	// closed is closed by Close, to end a Read blocked waiting for data.
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
+// Close closes the reader. A Read blocked waiting for data returns io.EOF, as
+// reads after the stream ends do. It is safe to call more than once, and
+// concurrently with Read.
 func (r *dataReader) Close() error {
-	r.done <- true
-	r.channel.removeReader(r.id)
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		r.done <- true
+		r.channel.removeReader(r.id)
+	})
 	return nil
 }
 
 func (r *dataReader) Read(buf []byte) (int, error) {
 	if r.cur == nil {
-		b, ok := <-r.buf
+		var b []byte
+		var ok bool
+		select {
+		case b, ok = <-r.buf:
+		case <-r.closed:
+			// Prefer data or the end of the stream, if already there.
+			select {
+			case b, ok = <-r.buf:
+			default:
+				return 0, io.EOF
+			}
+		}
 		if !ok {
 			if r.err == nil {
 				return 0, io.EOF
@@ -0,0 +1,168 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/log"
+)
+
+// ErrorSampling configures how repeated bundle failures are logged, such as
+// when every bundle fails on its first element. Failures are identical if
+// the messages of their errors are.
+type ErrorSampling struct {
+	// First is the number of occurrences of a failure that are logged
+	// individually. Values less than 1 suppress all of them.
+	First int
+	// Every is the interval at which a summary of the suppressed occurrences
+	// of a failure is logged, with their count and the error. If not
+	// positive, the summary is only logged when the harness exits.
+	Every time.Duration
+}
+
+// defaultErrorSampling is used unless set with WithErrorSampling.
+var defaultErrorSampling = ErrorSampling{First: 10, Every: time.Minute}
+
+// maxSampledErrors bounds the number of distinct errors tracked. Further
+// distinct errors are sampled together.
+const maxSampledErrors = 100
+
+const errorSamplingKey contextKey = "beam:errorsampling"
+
+// WithErrorSampling returns a context in which Main samples the logs of
+// repeated bundle failures as configured by s. By default, the first 10
+// occurrences of a failure are logged, followed by a summary of the
+// suppressed ones every minute. Sampling only affects logging: the failures
+// are reported to the runner as usual.
+func WithErrorSampling(ctx context.Context, s ErrorSampling) context.Context {
+	return context.WithValue(ctx, errorSamplingKey, s)
+}
+
+func getErrorSampling(ctx context.Context) ErrorSampling {
+	if s, ok := ctx.Value(errorSamplingKey).(ErrorSampling); ok {
+		return s
+	}
+	return defaultErrorSampling
+}
+
+// errorSampler logs errors as configured by an ErrorSampling. It is safe for
+// concurrent use. A nil errorSampler logs all errors.
+type errorSampler struct {
+	cfg  ErrorSampling
+	what string // Describes the errors, such as "process bundle failed".
+	now  func() time.Time
+	logf func(ctx context.Context, format string, v ...interface{})
+
+	mu      sync.Mutex
+	samples map[string]*errorSample
+	order   []*errorSample
+	other   *errorSample // Distinct errors beyond maxSampledErrors.
+}
+
+// errorSample tracks the occurrences of an error.
+type errorSample struct {
+	err         error // The first occurrence, representing all of them.
+	seen        int
+	suppressed  int
+	lastSummary time.Time
+}
+
+func newErrorSampler(ctx context.Context, what string) *errorSampler {
+	return &errorSampler{
+		cfg:     getErrorSampling(ctx),
+		what:    what,
+		now:     time.Now,
+		logf:    log.Errorf,
+		samples: make(map[string]*errorSample),
+	}
+}
+
+// log logs msg, describing an occurrence of err, unless the occurrences of err
+// are suppressed, in which case a summary is logged if one is due.
+func (s *errorSampler) log(ctx context.Context, err error, msg string) {
+	if s == nil {
+		log.Error(ctx, msg)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sample := s.sample(err)
+	sample.seen++
+	if sample.seen <= s.cfg.First {
+		s.logf(ctx, "%v", msg)
+		return
+	}
+	sample.suppressed++
+	if s.cfg.Every <= 0 {
+		return
+	}
+	if sample.lastSummary.IsZero() {
+		// The first suppressed occurrence starts the interval.
+		sample.lastSummary = s.now()
+		return
+	}
+	if now := s.now(); now.Sub(sample.lastSummary) >= s.cfg.Every {
+		s.summarize(ctx, sample)
+		sample.lastSummary = now
+	}
+}
+
+func (s *errorSampler) sample(err error) *errorSample {
+	key := err.Error()
+	if sample, ok := s.samples[key]; ok {
+		return sample
+	}
+	if len(s.samples) >= maxSampledErrors {
+		if s.other == nil {
+			s.other = &errorSample{err: err}
+		}
+		return s.other
+	}
+	sample := &errorSample{err: err}
+	s.samples[key] = sample
+	s.order = append(s.order, sample)
+	return sample
+}
+
+// flush logs a summary of the errors suppressed since their last summary.
+func (s *errorSampler) flush(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sample := range s.order {
+		s.summarize(ctx, sample)
+	}
+	if s.other != nil {
+		s.summarize(ctx, s.other)
+	}
+}
+
+func (s *errorSampler) summarize(ctx context.Context, sample *errorSample) {
+	if sample.suppressed == 0 {
+		return
+	}
+	if sample == s.other {
+		s.logf(ctx, "%v: suppressed %v more occurrence(s) of other errors, such as: %v", s.what, sample.suppressed, sample.err)
+	} else {
+		s.logf(ctx, "%v: suppressed %v more occurrence(s) of: %v", s.what, sample.suppressed, sample.err)
+	}
+	sample.suppressed = 0
+}
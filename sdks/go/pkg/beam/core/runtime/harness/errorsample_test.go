@@ -0,0 +1,117 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
+)
+
+// logErr logs an occurrence of err with a message naming it.
+func logErr(ctx context.Context, s *errorSampler, err error) {
+	s.log(ctx, err, "failed: "+err.Error())
+}
+
+// recordingSampler returns an error sampler with the given config that
+// records its logs, and a clock advanced by the caller.
+func recordingSampler(ctx context.Context, cfg ErrorSampling) (*errorSampler, *[]string, *time.Time) {
+	var logs []string
+	now := time.Unix(0, 0)
+	s := newErrorSampler(WithErrorSampling(ctx, cfg), "failed")
+	s.now = func() time.Time { return now }
+	s.logf = func(ctx context.Context, format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+	return s, &logs, &now
+}
+
+// TestErrorSampler verifies that only the first occurrences of an error are
+// logged, followed by periodic and final summaries of the suppressed ones.
+func TestErrorSampler(t *testing.T) {
+	ctx := context.Background()
+	boom, bad := errors.New("boom"), errors.New("bad")
+
+	t.Run("periodic", func(t *testing.T) {
+		s, logs, now := recordingSampler(ctx, ErrorSampling{First: 2, Every: time.Minute})
+		for i := 0; i < 5; i++ {
+			logErr(ctx, s, boom)
+		}
+		logErr(ctx, s, bad)
+		*now = now.Add(time.Minute)
+		logErr(ctx, s, boom)
+		logErr(ctx, s, boom)
+		s.flush(ctx)
+
+		want := []string{
+			"failed: boom",
+			"failed: boom",
+			"failed: bad",
+			"failed: suppressed 4 more occurrence(s) of: boom",
+			"failed: suppressed 1 more occurrence(s) of: boom",
+		}
+		if !reflect.DeepEqual(*logs, want) {
+			t.Errorf("logs = %q, want %q", *logs, want)
+		}
+	})
+	t.Run("final", func(t *testing.T) {
+		s, logs, now := recordingSampler(ctx, ErrorSampling{First: 1})
+		for i := 0; i < 3; i++ {
+			logErr(ctx, s, boom)
+			*now = now.Add(time.Hour)
+		}
+		s.flush(ctx)
+		s.flush(ctx)
+
+		want := []string{"failed: boom", "failed: suppressed 2 more occurrence(s) of: boom"}
+		if !reflect.DeepEqual(*logs, want) {
+			t.Errorf("logs = %q, want %q", *logs, want)
+		}
+	})
+	t.Run("bundles", func(t *testing.T) {
+		// Failures of different bundles are logged with different messages,
+		// but sampled by their error.
+		s, logs, _ := recordingSampler(ctx, ErrorSampling{First: 1})
+		for i := 0; i < 3; i++ {
+			s.log(ctx, boom, fmt.Sprintf("bundle %v failed: %v", i, boom))
+		}
+		s.flush(ctx)
+
+		want := []string{"bundle 0 failed: boom", "failed: suppressed 2 more occurrence(s) of: boom"}
+		if !reflect.DeepEqual(*logs, want) {
+			t.Errorf("logs = %q, want %q", *logs, want)
+		}
+	})
+	t.Run("distinct", func(t *testing.T) {
+		s, logs, _ := recordingSampler(ctx, ErrorSampling{})
+		for i := 0; i < maxSampledErrors+2; i++ {
+			logErr(ctx, s, errors.Errorf("error %v", i))
+		}
+		s.flush(ctx)
+
+		if len(*logs) != maxSampledErrors+1 {
+			t.Fatalf("got %v logs, want one per tracked error and one for the others", len(*logs))
+		}
+		want := fmt.Sprintf("failed: suppressed 2 more occurrence(s) of other errors, such as: error %v", maxSampledErrors)
+		if got := (*logs)[maxSampledErrors]; got != want {
+			t.Errorf("last log = %q, want %q", got, want)
+		}
+	})
+}
@@ -98,6 +98,8 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 		active:      make(map[instructionID]*exec.Plan),
 		inactive:    newCircleBuffer(),
 		failed:      make(map[instructionID]error),
+		finalizing:  make(map[instructionID]finalizingPlan),
+		failures:    newErrorSampler(ctx, "process bundle failed"),
 		data:        &DataChannelManager{},
 		state:       &StateChannelManager{},
 	}
@@ -114,6 +116,7 @@ func Main(ctx context.Context, loggingEndpoint, controlEndpoint string) error {
 			atomic.AddInt32(&shutdown, 1)
 			close(respc)
 			wg.Wait()
+			ctrl.failures.flush(ctx)
 
 			if err == io.EOF {
 				recordFooter()
@@ -223,12 +226,38 @@ type control struct {
 	inactive circleBuffer // protected by mu
 	// plans that have failed during execution
 	failed map[instructionID]error // protected by mu
-	mu     sync.Mutex
+	// plans of successful bundles awaiting finalization by the runner. They
+	// are reused once finalized, or once their callbacks have expired.
+	finalizing map[instructionID]finalizingPlan // protected by mu
+	mu         sync.Mutex
+
+	// failures samples the logs of failed bundles, which may all fail alike.
+	failures *errorSampler
 
 	data  *DataChannelManager
 	state *StateChannelManager
 }
 
+// finalizingPlan is the plan of a bundle awaiting finalization.
+type finalizingPlan struct {
+	bdID   bundleDescriptorID
+	plan   *exec.Plan
+	expiry time.Time
+}
+
+// releaseExpired makes the plans whose finalization callbacks have all expired
+// candidates for execution again. The runner won't finalize such bundles
+// anymore, so their callbacks are never invoked. It must be called with mu
+// held.
+func (c *control) releaseExpired(now time.Time) {
+	for instID, fp := range c.finalizing {
+		if now.After(fp.expiry) {
+			delete(c.finalizing, instID)
+			c.plans[fp.bdID] = append(c.plans[fp.bdID], fp.plan)
+		}
+	}
+}
+
 func (c *control) getOrCreatePlan(bdID bundleDescriptorID) (*exec.Plan, error) {
 	c.mu.Lock()
 	plans, ok := c.plans[bdID]
@@ -301,16 +330,26 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 
 		data := NewScopedDataManager(c.data, instID)
 		state := NewScopedStateReader(c.state, instID)
-		err = plan.Execute(ctx, string(instID), exec.DataContext{Data: data, State: state})
+		stopCheckpointing := startCheckpointing(ctx, instID, plan.Checkpointers())
+		counters := &exec.StreamCounters{}
+		err = plan.Execute(ctx, string(instID), exec.DataContext{Data: data, State: state, Counters: counters, CacheTokens: cacheTokens(msg.GetCacheTokens())})
+		stopCheckpointing()
 		data.Close()
 		state.Close()
 
 		mons, pylds := monitoring(plan)
+		mons, pylds = streamMonitoring(counters, mons, pylds)
+		expiry := plan.FinalizationExpiry()
 		// Move the plan back to the candidate state
 		c.mu.Lock()
+		c.releaseExpired(time.Now())
 		// Mark the instruction as failed.
 		if err != nil {
 			c.failed[instID] = err
+		} else if !expiry.IsZero() {
+			// The plan holds the finalization callbacks of the bundle until the
+			// runner finalizes it.
+			c.finalizing[instID] = finalizingPlan{bdID: bdID, plan: plan, expiry: expiry}
 		} else {
 			// Non failure plans can be re-used.
 			c.plans[bdID] = append(c.plans[bdID], plan)
@@ -323,19 +362,49 @@ func (c *control) handleInstruction(ctx context.Context, req *fnpb.InstructionRe
 		c.mu.Unlock()
 
 		if err != nil {
-			return fail(ctx, instID, "process bundle failed for instruction %v using plan %v : %v", instID, bdID, err)
+			resp := failure(instID, "process bundle failed for instruction %v using plan %v : %v", instID, bdID, err)
+			c.failures.log(ctx, err, resp.GetError())
+			return resp
 		}
 
 		return &fnpb.InstructionResponse{
 			InstructionId: string(instID),
 			Response: &fnpb.InstructionResponse_ProcessBundle{
 				ProcessBundle: &fnpb.ProcessBundleResponse{
-					MonitoringData:  pylds,
-					MonitoringInfos: mons,
+					MonitoringData:       pylds,
+					MonitoringInfos:      mons,
+					RequiresFinalization: !expiry.IsZero(),
 				},
 			},
 		}
 
+	case req.GetFinalizeBundle() != nil:
+		msg := req.GetFinalizeBundle()
+
+		ref := instructionID(msg.GetInstructionId())
+		c.mu.Lock()
+		fp, ok := c.finalizing[ref]
+		delete(c.finalizing, ref)
+		c.mu.Unlock()
+		if !ok {
+			return fail(ctx, instID, "failed to finalize: no bundle awaiting finalization for instruction %v", ref)
+		}
+
+		err := fp.plan.Finalize()
+		c.mu.Lock()
+		c.plans[fp.bdID] = append(c.plans[fp.bdID], fp.plan)
+		c.mu.Unlock()
+		if err != nil {
+			return fail(ctx, instID, "failed to finalize bundle %v: %v", ref, err)
+		}
+
+		return &fnpb.InstructionResponse{
+			InstructionId: string(instID),
+			Response: &fnpb.InstructionResponse_FinalizeBundle{
+				FinalizeBundle: &fnpb.FinalizeBundleResponse{},
+			},
+		}
+
 	case req.GetProcessBundleProgress() != nil:
 		msg := req.GetProcessBundleProgress()
 
@@ -491,6 +560,11 @@ func (c *control) getPlanOrResponse(ctx context.Context, kind string, instID, re
 
 func fail(ctx context.Context, id instructionID, format string, args ...interface{}) *fnpb.InstructionResponse {
 	log.Output(ctx, log.SevError, 1, fmt.Sprintf(format, args...))
+	return failure(id, format, args...)
+}
+
+// failure returns the response of a failed instruction, without logging it.
+func failure(id instructionID, format string, args ...interface{}) *fnpb.InstructionResponse {
 	dummy := &fnpb.InstructionResponse_Register{Register: &fnpb.RegisterResponse{}}
 
 	return &fnpb.InstructionResponse{
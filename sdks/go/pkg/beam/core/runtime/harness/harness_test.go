@@ -16,9 +16,11 @@
 package harness
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
@@ -229,3 +231,62 @@ func TestCircleBuffer(t *testing.T) {
 		}
 	})
 }
+
+// TestControl_finalizeBundle verifies that finalizing a bundle makes its plan
+// a candidate for execution again, and that unknown bundles fail.
+func TestControl_finalizeBundle(t *testing.T) {
+	testBDID := bundleDescriptorID("test")
+	testPlan, err := exec.UnmarshalPlan(validDescriptor(t))
+	if err != nil {
+		t.Fatal("bad testPlan")
+	}
+	ctrl := &control{
+		plans: make(map[bundleDescriptorID][]*exec.Plan),
+		finalizing: map[instructionID]finalizingPlan{
+			"bundle": {bdID: testBDID, plan: testPlan, expiry: time.Now().Add(time.Hour)},
+		},
+	}
+	finalize := func(ref string) *fnpb.InstructionResponse {
+		return ctrl.handleInstruction(context.Background(), &fnpb.InstructionRequest{
+			InstructionId: "finalize",
+			Request: &fnpb.InstructionRequest_FinalizeBundle{
+				FinalizeBundle: &fnpb.FinalizeBundleRequest{InstructionId: ref},
+			},
+		})
+	}
+
+	if resp := finalize("bundle"); resp.GetError() != "" || resp.GetFinalizeBundle() == nil {
+		t.Errorf("finalize(bundle) = %v, want FinalizeBundle response", resp)
+	}
+	if got := ctrl.plans[testBDID]; len(got) != 1 || got[0] != testPlan {
+		t.Errorf("plans after finalization = %v, want the finalized plan", got)
+	}
+	if resp := finalize("bundle"); !strings.Contains(resp.GetError(), "no bundle awaiting finalization") {
+		t.Errorf("finalize(bundle) again = %v, want error", resp)
+	}
+}
+
+// TestControl_releaseExpired verifies that plans of bundles whose finalization
+// has expired are reused.
+func TestControl_releaseExpired(t *testing.T) {
+	testBDID := bundleDescriptorID("test")
+	testPlan, err := exec.UnmarshalPlan(validDescriptor(t))
+	if err != nil {
+		t.Fatal("bad testPlan")
+	}
+	now := time.Now()
+	ctrl := &control{
+		plans: make(map[bundleDescriptorID][]*exec.Plan),
+		finalizing: map[instructionID]finalizingPlan{
+			"expired": {bdID: testBDID, plan: testPlan, expiry: now.Add(-time.Second)},
+			"pending": {bdID: testBDID, plan: testPlan, expiry: now.Add(time.Hour)},
+		},
+	}
+	ctrl.releaseExpired(now)
+	if _, ok := ctrl.finalizing["pending"]; !ok || len(ctrl.finalizing) != 1 {
+		t.Errorf("finalizing = %v, want only the pending bundle", ctrl.finalizing)
+	}
+	if got := len(ctrl.plans[testBDID]); got != 1 {
+		t.Errorf("got %v reusable plans, want 1", got)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
@@ -67,6 +68,11 @@ func (l *logger) Log(ctx context.Context, sev log.Severity, calldepth int, msg s
 	}
 	if id, ok := tryGetInstID(ctx); ok {
 		entry.InstructionId = id
+	} else if id, ok := log.FieldValue(ctx, exec.LogBundleIDField); ok {
+		entry.InstructionId = id
+	}
+	if id, ok := log.FieldValue(ctx, exec.LogTransformIDField); ok {
+		entry.TransformId = id
 	}
 
 	select {
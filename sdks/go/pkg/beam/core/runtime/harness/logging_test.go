@@ -20,6 +20,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/go/pkg/beam/log"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
 )
@@ -42,10 +43,34 @@ func TestLogger(t *testing.T) {
 		t.Errorf("incorrect Message: got %v, want %v", got, want)
 	}
 	// This check will fail if the imports change.
-	if got, want := e.GetLogLocation(), "logging_test.go:34"; !strings.HasSuffix(got, want) {
+	if got, want := e.GetLogLocation(), "logging_test.go:35"; !strings.HasSuffix(got, want) {
 		t.Errorf("incorrect LogLocation: got %v, want suffix %v", got, want)
 	}
 	if got, want := e.GetSeverity(), fnpb.LogEntry_Severity_INFO; got != want {
 		t.Errorf("incorrect Severity: got %v, want %v", got, want)
 	}
 }
+
+// TestLogger_fields verifies that the log fields of the context set the
+// instruction and transform of log entries, and are kept out of the message.
+func TestLogger_fields(t *testing.T) {
+	ch := make(chan *fnpb.LogEntry, 1)
+	l := logger{out: ch}
+
+	ctx := log.WithFields(context.Background(),
+		log.Field{Key: exec.LogBundleIDField, Value: "inst"},
+		log.Field{Key: exec.LogTransformIDField, Value: "pardo"},
+		log.Field{Key: "k", Value: "v"})
+	l.Log(ctx, log.SevInfo, 0, "msg")
+
+	e := <-ch
+	if got, want := e.GetTransformId(), "pardo"; got != want {
+		t.Errorf("incorrect TransformID: got %v, want %v", got, want)
+	}
+	if got, want := e.GetInstructionId(), "inst"; got != want {
+		t.Errorf("incorrect InstructionID: got %v, want %v", got, want)
+	}
+	if got, want := e.GetMessage(), "msg"; got != want {
+		t.Errorf("incorrect Message: got %v, want %v", got, want)
+	}
+}
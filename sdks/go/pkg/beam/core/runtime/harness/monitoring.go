@@ -193,10 +193,72 @@ func monitoring(p *exec.Plan) ([]*pipepb.MonitoringInfo, map[string][]byte) {
 			})
 	}
 
+	// Report the output watermark hold of the source, so the runner can close
+	// windows while no elements arrive.
+	if wm, ok := p.Watermark(); ok {
+		payload, err := metricsx.Int64Latest(time.Now(), int64(wm))
+		if err != nil {
+			panic(err)
+		}
+		id := p.SourcePTransformID()
+		payloads[getShortID(metrics.PTransformLabels(id), metricsx.UrnDataChannelOutputWatermark)] = payload
+		monitoringInfo = append(monitoringInfo,
+			&pipepb.MonitoringInfo{
+				Urn:  metricsx.UrnToString(metricsx.UrnDataChannelOutputWatermark),
+				Type: metricsx.UrnToType(metricsx.UrnDataChannelOutputWatermark),
+				Labels: map[string]string{
+					"PTRANSFORM": id,
+				},
+				Payload: payload,
+			})
+	}
+
 	return monitoringInfo,
 		payloads
 }
 
+// streamMonitoring adds the element and byte counts of the data streams of a
+// bundle to its monitoring information, per transform of the stream.
+func streamMonitoring(c *exec.StreamCounters, monitoringInfo []*pipepb.MonitoringInfo, payloads map[string][]byte) ([]*pipepb.MonitoringInfo, map[string][]byte) {
+	streams := c.Streams()
+	if len(streams) == 0 {
+		return monitoringInfo, payloads
+	}
+	if payloads == nil {
+		payloads = make(map[string][]byte)
+	}
+
+	defaultShortIDCache.mu.Lock()
+	defer defaultShortIDCache.mu.Unlock()
+
+	for _, id := range streams {
+		count := c.For(id)
+		for _, m := range []struct {
+			urn metricsx.Urn
+			v   int64
+		}{
+			{metricsx.UrnDataChannelElementCount, count.Elements()},
+			{metricsx.UrnDataChannelByteCount, count.Bytes()},
+		} {
+			payload, err := metricsx.Int64Counter(m.v)
+			if err != nil {
+				panic(err)
+			}
+			payloads[getShortID(metrics.PTransformLabels(id.PtransformID), m.urn)] = payload
+			monitoringInfo = append(monitoringInfo,
+				&pipepb.MonitoringInfo{
+					Urn:  metricsx.UrnToString(m.urn),
+					Type: metricsx.UrnToType(m.urn),
+					Labels: map[string]string{
+						"PTRANSFORM": id.PtransformID,
+					},
+					Payload: payload,
+				})
+		}
+	}
+	return monitoringInfo, payloads
+}
+
 func userLabels(l metrics.Labels) map[string]string {
 	return map[string]string{
 		"PTRANSFORM": l.Transform(),
@@ -16,11 +16,22 @@
 package harness
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/window"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/metricsx"
+	"github.com/apache/beam/sdks/go/pkg/beam/core/typex"
 )
 
 func TestGetShortID(t *testing.T) {
@@ -172,3 +183,111 @@ func BenchmarkGetShortID(b *testing.B) {
 		}
 	})
 }
+
+// watermarkReporter is a test Node that reports a watermark to its source for
+// each element.
+type watermarkReporter struct {
+	exec.Discard
+	source *exec.DataSource
+	wm     time.Time
+}
+
+func (n *watermarkReporter) ProcessElement(ctx context.Context, elm *exec.FullValue, values ...exec.ReStream) error {
+	return n.source.ReportWatermark(n.wm)
+}
+
+// bytesDataManager is a test DataManager whose streams read the given bytes.
+type bytesDataManager []byte
+
+func (m bytesDataManager) OpenRead(ctx context.Context, id exec.StreamID) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(m)), nil
+}
+
+func (m bytesDataManager) OpenWrite(ctx context.Context, id exec.StreamID) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("unexpected write of %v", id)
+}
+
+// TestMonitoring_watermark validates that the watermark reported by the
+// source of a plan is reported as a monitoring info.
+func TestMonitoring_watermark(t *testing.T) {
+	c := coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())
+	out := &watermarkReporter{Discard: exec.Discard{UID: 1}, wm: time.Unix(10, 0)}
+	source := &exec.DataSource{UID: 2, SID: exec.StreamID{PtransformID: "mySource"}, Coder: c, Out: out}
+	out.source = source
+	p, err := exec.NewPlan("a", []exec.Unit{out, source})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	var in bytes.Buffer
+	if err := exec.EncodeWindowedValueHeader(exec.MakeWindowEncoder(c.Window), window.SingleGlobalWindow, mtime.ZeroTimestamp, typex.NoFiringPane(), &in); err != nil {
+		t.Fatalf("encoding header failed: %v", err)
+	}
+	if err := exec.MakeElementEncoder(coder.SkipW(c)).Encode(&exec.FullValue{Elm: int64(1)}, &in); err != nil {
+		t.Fatalf("encoding element failed: %v", err)
+	}
+	if err := p.Execute(context.Background(), "1", exec.DataContext{Data: bytesDataManager(in.Bytes())}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	mons, pylds := monitoring(p)
+	urn := metricsx.UrnToString(metricsx.UrnDataChannelOutputWatermark)
+	for _, mon := range mons {
+		if mon.GetUrn() != urn {
+			continue
+		}
+		if got, want := mon.GetLabels()["PTRANSFORM"], "mySource"; got != want {
+			t.Errorf("watermark PTRANSFORM label = %v, want %v", got, want)
+		}
+		// The payload is the report time followed by the watermark.
+		r := bytes.NewReader(mon.GetPayload())
+		if _, err := coder.DecodeVarInt(r); err != nil {
+			t.Fatalf("decoding report time failed: %v", err)
+		}
+		wm, err := coder.DecodeVarInt(r)
+		if err != nil {
+			t.Fatalf("decoding watermark failed: %v", err)
+		}
+		if got, want := mtime.Time(wm), mtime.FromTime(out.wm); got != want {
+			t.Errorf("watermark = %v, want %v", got, want)
+		}
+		if len(pylds) != len(mons) {
+			t.Errorf("got %v payloads for %v monitoring infos", len(pylds), len(mons))
+		}
+		return
+	}
+	t.Errorf("monitoring infos = %v, want %v", mons, urn)
+}
+
+// TestStreamMonitoring verifies that the counts of the data streams of a
+// bundle are reported per transform, and that no counters report nothing.
+func TestStreamMonitoring(t *testing.T) {
+	if mons, pylds := streamMonitoring(nil, nil, nil); mons != nil || pylds != nil {
+		t.Errorf("streamMonitoring(nil) = %v, %v, want nil", mons, pylds)
+	}
+
+	c := &exec.StreamCounters{}
+	sc := c.For(exec.StreamID{PtransformID: "mySource"})
+	sc.AddElements(3)
+	sc.AddBytes(10)
+
+	mons, pylds := streamMonitoring(c, nil, nil)
+	counts := map[string]int64{
+		metricsx.UrnToString(metricsx.UrnDataChannelElementCount): 3,
+		metricsx.UrnToString(metricsx.UrnDataChannelByteCount):    10,
+	}
+	if len(mons) != len(counts) || len(pylds) != len(counts) {
+		t.Fatalf("streamMonitoring = %v infos and %v payloads, want %v", len(mons), len(pylds), len(counts))
+	}
+	for _, mon := range mons {
+		if got, want := mon.GetLabels()["PTRANSFORM"], "mySource"; got != want {
+			t.Errorf("%v PTRANSFORM label = %v, want %v", mon.GetUrn(), got, want)
+		}
+		v, err := coder.DecodeVarInt(bytes.NewReader(mon.GetPayload()))
+		if err != nil {
+			t.Fatalf("decoding %v failed: %v", mon.GetUrn(), err)
+		}
+		if got, want := v, counts[mon.GetUrn()]; got != want {
+			t.Errorf("%v = %v, want %v", mon.GetUrn(), got, want)
+		}
+	}
+}
@@ -102,6 +102,19 @@ func (s *ScopedStateReader) Close() error {
 	return nil
 }
 
+// cacheTokens converts the cache tokens of a process bundle request.
+func cacheTokens(tokens []*fnpb.ProcessBundleRequest_CacheToken) []exec.CacheToken {
+	var ret []exec.CacheToken
+	for _, t := range tokens {
+		ct := exec.CacheToken{Token: t.GetToken()}
+		if si := t.GetSideInput(); si != nil {
+			ct.TransformID, ct.SideInputID = si.GetTransformId(), si.GetSideInputId()
+		}
+		ret = append(ret, ct)
+	}
+	return ret
+}
+
 type stateKeyReader struct {
 	instID instructionID
 	key    *fnpb.StateKey
@@ -22,11 +22,13 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/apache/beam/sdks/go/pkg/beam/core/runtime/exec"
 	"github.com/apache/beam/sdks/go/pkg/beam/internal/errors"
 	fnpb "github.com/apache/beam/sdks/go/pkg/beam/model/fnexecution_v1"
 )
@@ -428,3 +430,20 @@ func contains(got, want error) bool {
 	}
 	return strings.Contains(got.Error(), want.Error())
 }
+
+func TestCacheTokens(t *testing.T) {
+	got := cacheTokens([]*fnpb.ProcessBundleRequest_CacheToken{
+		{
+			Type:  &fnpb.ProcessBundleRequest_CacheToken_UserState_{UserState: &fnpb.ProcessBundleRequest_CacheToken_UserState{}},
+			Token: []byte("u"),
+		},
+		{
+			Type:  &fnpb.ProcessBundleRequest_CacheToken_SideInput_{SideInput: &fnpb.ProcessBundleRequest_CacheToken_SideInput{TransformId: "p", SideInputId: "s"}},
+			Token: []byte("s"),
+		},
+	})
+	want := []exec.CacheToken{{Token: []byte("u")}, {TransformID: "p", SideInputID: "s", Token: []byte("s")}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cacheTokens() = %v, want %v", got, want)
+	}
+}
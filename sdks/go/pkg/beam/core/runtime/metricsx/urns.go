@@ -50,6 +50,9 @@ var sUrns = [...]string{
 	"beam:metric:ptransform_progress:remaining:v1",
 	"beam:metric:ptransform_progress:completed:v1",
 	"beam:metric:data_channel:read_index:v1",
+	"beam:metric:data_channel:output_watermark:v1",
+	"beam:metric:data_channel:element_count:v1",
+	"beam:metric:data_channel:byte_count:v1",
 
 	"TestingSentinelUrn", // Must remain last.
 }
@@ -78,6 +81,9 @@ const (
 	UrnProgressRemaining
 	UrnProgressCompleted
 	UrnDataChannelReadIndex
+	UrnDataChannelOutputWatermark
+	UrnDataChannelElementCount
+	UrnDataChannelByteCount
 
 	UrnTestSentinel // Must remain last.
 )
@@ -99,7 +105,7 @@ func UrnToType(u Urn) string {
 		return "beam:metrics:distribution_int64:v1"
 	case UrnUserDistFloat64:
 		return "beam:metrics:distribution_double:v1"
-	case UrnUserLatestMsInt64:
+	case UrnUserLatestMsInt64, UrnDataChannelOutputWatermark:
 		return "beam:metrics:latest_int64:v1"
 	case UrnUserLatestMsFloat64:
 		return "beam:metrics:latest_double:v1"
@@ -114,7 +120,7 @@ func UrnToType(u Urn) string {
 
 	case UrnProgressRemaining, UrnProgressCompleted:
 		return "beam:metrics:progress:v1"
-	case UrnDataChannelReadIndex:
+	case UrnDataChannelReadIndex, UrnDataChannelElementCount, UrnDataChannelByteCount:
 		return "beam:metrics:sum_int64:v1"
 
 	// Monitoring Table isn't currently in the protos.
@@ -17,6 +17,7 @@ package typex
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/apache/beam/sdks/go/pkg/beam/core/graph/mtime"
 )
@@ -33,8 +34,9 @@ var (
 	YType = reflect.TypeOf((*Y)(nil)).Elem()
 	ZType = reflect.TypeOf((*Z)(nil)).Elem()
 
-	EventTimeType = reflect.TypeOf((*EventTime)(nil)).Elem()
-	WindowType    = reflect.TypeOf((*Window)(nil)).Elem()
+	EventTimeType       = reflect.TypeOf((*EventTime)(nil)).Elem()
+	WindowType          = reflect.TypeOf((*Window)(nil)).Elem()
+	BundleFinalizerType = reflect.TypeOf((*BundleFinalizer)(nil)).Elem()
 
 	KVType            = reflect.TypeOf((*KV)(nil)).Elem()
 	CoGBKType         = reflect.TypeOf((*CoGBK)(nil)).Elem()
@@ -64,6 +66,52 @@ type Window interface {
 	Equals(o Window) bool
 }
 
+// BundleFinalizer registers callbacks to be invoked once the runner has
+// durably committed the output of the current bundle, such as acknowledging
+// messages to an external system.
+type BundleFinalizer interface {
+	// RegisterCallback registers a callback that is invoked after the bundle
+	// is committed, unless the given duration has elapsed by then. A failing
+	// callback may be retried.
+	RegisterCallback(validFor time.Duration, callback func() error)
+}
+
+// PaneTiming indicates when a pane was produced, relative to the watermark
+// passing the end of its window.
+type PaneTiming byte
+
+const (
+	// PaneEarly panes were produced before the watermark passed the end of
+	// the window.
+	PaneEarly PaneTiming = 0
+	// PaneOnTime panes were produced when the watermark passed the end of the
+	// window.
+	PaneOnTime PaneTiming = 1
+	// PaneLate panes were produced after the watermark passed the end of the
+	// window.
+	PaneLate PaneTiming = 2
+	// PaneUnknown panes were produced without regard to the watermark.
+	PaneUnknown PaneTiming = 3
+)
+
+// PaneInfo describes the trigger firing that produced an element in a window.
+// The zero value is not a valid pane, since the first pane of a window has
+// IsFirst set, so it denotes a missing pane. Elements that were not produced
+// by a trigger firing are in the NoFiringPane.
+type PaneInfo struct {
+	Timing          PaneTiming
+	IsFirst, IsLast bool
+	// Index is the index of the pane among the panes of the window, and
+	// NonSpeculativeIndex among its non-early panes, or -1 if early.
+	Index, NonSpeculativeIndex int64
+}
+
+// NoFiringPane returns the pane of elements that were not produced by a
+// trigger firing.
+func NoFiringPane() PaneInfo {
+	return PaneInfo{Timing: PaneUnknown, IsFirst: true, IsLast: true}
+}
+
 // KV, CoGBK, WindowedValue represent composite generic types. They are not used
 // directly in user code signatures, but only in FullTypes.
 
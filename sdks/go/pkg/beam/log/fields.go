@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+)
+
+// Field is a structured key-value pair attached to the log messages of a
+// context, such as the bundle being processed. Loggers may record the fields
+// of the context alongside the message, such as the harness logger, which
+// sets the instruction and transform of its log entries from them. They are
+// not part of the message.
+type Field struct {
+	Key, Value string
+}
+
+type fieldsKey struct{}
+
+// WithFields returns a context in which log messages carry the given fields
+// in addition to those of ctx. A field replaces any field of ctx with the
+// same key.
+func WithFields(ctx context.Context, fields ...Field) context.Context {
+	prev := Fields(ctx)
+	ret := make([]Field, 0, len(prev)+len(fields))
+	for _, f := range prev {
+		if !hasField(fields, f.Key) {
+			ret = append(ret, f)
+		}
+	}
+	ret = append(ret, fields...)
+	return context.WithValue(ctx, fieldsKey{}, ret)
+}
+
+// Fields returns the log fields of the context, in the order they were
+// added.
+func Fields(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsKey{}).([]Field)
+	return fields
+}
+
+// FieldValue returns the value of the log field of the context with the given
+// key, if present.
+func FieldValue(ctx context.Context, key string) (string, bool) {
+	for _, f := range Fields(ctx) {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+func hasField(fields []Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -185,7 +185,7 @@ func ExampleEqualsList_mismatch() {
 	fmt.Println(err)
 
 	// Output:
-	// DoFn[UID:1, PID:passert.failIfBadEntries, Name: github.com/apache/beam/sdks/go/pkg/beam/testing/passert.failIfBadEntries] failed:
+	// DoFn[UID:1, PID:passert.failIfBadEntries, Name: github.com/apache/beam/sdks/go/pkg/beam/testing/passert.failIfBadEntries, Stage: plan] failed:
 	// actual PCollection does not match expected values
 	// =========
 	// 2 correct entries (present in both)